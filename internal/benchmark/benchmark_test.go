@@ -3,11 +3,16 @@ package benchmark
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
+	"nextcloud-perf/internal/fakecloud"
 	"nextcloud-perf/internal/webdav"
 )
 
@@ -52,7 +57,7 @@ func TestRunSmallFiles(t *testing.T) {
 	}
 
 	// 2 files, 1KB each, 1 parallel
-	res, err := RunSmallFiles(context.Background(), client, "/test", "file_", 2, 1024, 1)
+	res, err := RunSmallFiles(context.Background(), client, "/test", "file_", 2, 1024, 1, "")
 	if err != nil {
 		t.Fatalf("RunSmallFiles failed: %v", err)
 	}
@@ -66,6 +71,12 @@ func TestRunSmallFiles(t *testing.T) {
 	if len(res.Errors) > 0 {
 		t.Errorf("Expected no errors, got %v", res.Errors)
 	}
+	if res.Latency == nil || res.Latency.Count != 2 {
+		t.Fatalf("Expected latency stats for 2 requests, got %+v", res.Latency)
+	}
+	if res.Latency.P50 < 10*time.Millisecond {
+		t.Errorf("Expected p50 >= 10ms, got %v", res.Latency.P50)
+	}
 }
 
 func TestRunDownloadSmallFiles(t *testing.T) {
@@ -83,7 +94,7 @@ func TestRunDownloadSmallFiles(t *testing.T) {
 		},
 	}
 
-	res, err := RunDownloadSmallFiles(context.Background(), client, "/test", "file_", 2, 1)
+	res, err := RunDownloadSmallFiles(context.Background(), client, "/test", "file_", 2, 1024, 1, "")
 	if err != nil {
 		t.Fatalf("RunDownloadSmallFiles failed: %v", err)
 	}
@@ -94,4 +105,464 @@ func TestRunDownloadSmallFiles(t *testing.T) {
 	if res.TotalSize != 2048 {
 		t.Errorf("Expected 2048 bytes download, got %d", res.TotalSize)
 	}
+	if len(res.Timings) != 2 || res.Latency == nil || res.Latency.Count != 2 {
+		t.Errorf("Expected 2 GET timings, got %d timings and %+v", len(res.Timings), res.Latency)
+	}
+}
+
+func TestComputeLatencyStats(t *testing.T) {
+	var timings []webdav.RequestTiming
+	for i := 1; i <= 100; i++ {
+		timings = append(timings, webdav.RequestTiming{Method: "PUT", Total: time.Duration(i) * time.Millisecond})
+	}
+	timings = append(timings, webdav.RequestTiming{Method: "MOVE", Total: time.Minute})
+
+	stats := ComputeLatencyStats(timings, "PUT")
+	if stats.Count != 100 {
+		t.Fatalf("Expected 100 samples, got %d", stats.Count)
+	}
+	if stats.P50 != 50*time.Millisecond || stats.P90 != 90*time.Millisecond || stats.P99 != 99*time.Millisecond {
+		t.Errorf("Unexpected percentiles: p50=%v p90=%v p99=%v", stats.P50, stats.P90, stats.P99)
+	}
+	if stats.Max != 100*time.Millisecond {
+		t.Errorf("Expected max 100ms, got %v", stats.Max)
+	}
+
+	total := 0
+	for _, b := range stats.Histogram {
+		total += b.Count
+	}
+	if total != 100 || stats.Histogram[0].Count != 10 {
+		t.Errorf("Unexpected histogram: %+v", stats.Histogram)
+	}
+
+	if ComputeLatencyStats(timings, "GET") != nil {
+		t.Error("Expected nil stats without matching samples")
+	}
+}
+
+func TestRunMetadata(t *testing.T) {
+	client := newMockClient()
+	var mu sync.Mutex
+	methods := map[string]int{}
+	client.Client.Transport = &MockTransport{
+		RoundTripFunc: func(req *http.Request) *http.Response {
+			mu.Lock()
+			methods[req.Method]++
+			mu.Unlock()
+			if req.Method != "PROPFIND" {
+				return &http.Response{StatusCode: 201, Body: io.NopCloser(bytes.NewBufferString(""))}
+			}
+			if req.Header.Get("Depth") == "infinity" {
+				return &http.Response{StatusCode: 403, Body: io.NopCloser(bytes.NewBufferString(""))}
+			}
+			return &http.Response{
+				StatusCode: 207,
+				Body: io.NopCloser(bytes.NewBufferString(`<d:multistatus xmlns:d="DAV:">` +
+					`<d:response><d:href>/tree/</d:href></d:response>` +
+					`<d:response><d:href>/tree/dir0000/</d:href></d:response></d:multistatus>`)),
+			}
+		},
+	}
+
+	res, err := RunMetadata(context.Background(), client, "/test", 2, 3, 16, 4, 2)
+	if err != nil {
+		t.Fatalf("RunMetadata failed: %v", err)
+	}
+	if methods["MKCOL"] != 3 || methods["PUT"] != 6 {
+		t.Errorf("Expected 3 MKCOL and 6 PUT, got %v", methods)
+	}
+	if len(res.Listings) != 3 {
+		t.Fatalf("Expected 3 listings, got %d", len(res.Listings))
+	}
+	one := res.Listings[1]
+	if one.Depth != "1" || one.Entries != 2 || one.Latency == nil || one.Latency.Count != 4 {
+		t.Errorf("Unexpected depth 1 listing: %+v", one)
+	}
+	if inf := res.Listings[2]; len(inf.Errors) != 1 {
+		t.Errorf("Expected depth infinity to fail once, got %v", inf.Errors)
+	}
+}
+
+func TestZeroReaderSeek(t *testing.T) {
+	first := make([]byte, 4096)
+	z := &ZeroReader{Limit: 1 << 20}
+	io.ReadFull(z, make([]byte, 1000))
+	io.ReadFull(z, first)
+
+	if _, err := z.Seek(1000, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	again := make([]byte, 4096)
+	io.ReadFull(z, again)
+	if !bytes.Equal(first, again) {
+		t.Error("Expected the same data after seeking back")
+	}
+}
+
+func TestZeroReaderSeed(t *testing.T) {
+	read := func(seed uint64) []byte {
+		b, _ := io.ReadAll(&ZeroReader{Limit: 64 * 1024, Seed: seed})
+		return b
+	}
+	if !bytes.Equal(read(1), read(1)) {
+		t.Error("Expected the same data for the same seed")
+	}
+	if bytes.Equal(read(1), read(2)) {
+		t.Error("Expected different data for different seeds")
+	}
+	if FileSeed("/test/a.bin") != FileSeed("test/a.bin") {
+		t.Error("Expected the seed to ignore a leading slash")
+	}
+}
+
+func TestUploadRetryReplaysBody(t *testing.T) {
+	fake := fakecloud.New(fakecloud.Options{})
+	fake.AddFault(fakecloud.Fault{Method: "PUT", Status: http.StatusServiceUnavailable, Times: 2})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	client := webdav.NewClient(ts.URL, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+	client.Retry = webdav.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	const size = 64 * 1024
+	if _, err := client.UploadSimple(context.Background(), "retry.bin", &ZeroReader{Limit: size, Seed: 7}, size); err != nil {
+		t.Fatalf("Expected the upload to succeed after retries, got %v", err)
+	}
+	want, _ := io.ReadAll(&ZeroReader{Limit: size, Seed: 7})
+	if got, ok := fake.File(fakecloud.DefaultUser, "retry.bin"); !ok || !bytes.Equal(got, want) {
+		t.Errorf("Expected the full body after retries, got %d bytes", len(got))
+	}
+}
+
+func TestDownloadIntegrityAgainstFake(t *testing.T) {
+	fake := fakecloud.New(fakecloud.Options{})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	client := webdav.NewClient(ts.URL, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+	ctx := context.Background()
+
+	if err := client.CreateDirectory(ctx, "/test"); err != nil {
+		t.Fatal(err)
+	}
+	up, err := RunSmallFiles(ctx, client, "/test", "file_", 4, 8*1024, 2, ChecksumMD5)
+	if err != nil || len(up.Errors) > 0 {
+		t.Fatalf("RunSmallFiles failed: %v %v", err, up.Errors)
+	}
+
+	fake.AddFault(fakecloud.Fault{Method: "GET", Path: "/remote.php/dav/files/*/test/file_1.bin", Truncate: true})
+	fake.AddFault(fakecloud.Fault{Method: "GET", Path: "/remote.php/dav/files/*/test/file_2.bin", Corrupt: true})
+	down, err := RunDownloadSmallFiles(ctx, client, "/test", "file_", 4, 8*1024, 2, ChecksumMD5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if down.Corrupted != 2 || len(down.Errors) != 2 {
+		t.Fatalf("Expected 2 corrupted downloads, got %d (%v)", down.Corrupted, down.Errors)
+	}
+	var size, sum int
+	for _, err := range down.Errors {
+		switch {
+		case errors.Is(err, ErrSizeMismatch):
+			size++
+		case errors.Is(err, ErrChecksumMismatch):
+			sum++
+		}
+	}
+	if size != 1 || sum != 1 {
+		t.Errorf("Expected one size and one checksum mismatch, got %v", down.Errors)
+	}
+
+	// Without a checksum only the truncation is noticed
+	down, _ = RunDownloadSmallFiles(ctx, client, "/test", "file_", 4, 8*1024, 2, "")
+	if down.Corrupted != 1 {
+		t.Errorf("Expected 1 corrupted download without checksum, got %d (%v)", down.Corrupted, down.Errors)
+	}
+}
+
+func TestChunkedRoundTripAgainstFake(t *testing.T) {
+	fake := fakecloud.New(fakecloud.Options{})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	client := webdav.NewClient(ts.URL, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+	ctx := context.Background()
+
+	if err := client.CreateDirectory(ctx, "/test"); err != nil {
+		t.Fatal(err)
+	}
+	up, err := RunChunkedFile(ctx, client, "/test", "large.bin", 300*1024, webdav.ChunkOptions{ChunkSize: 64 * 1024, Parallel: 3}, ChecksumSHA256)
+	if err != nil || len(up.Errors) > 0 {
+		t.Fatalf("RunChunkedFile failed: %v %v", err, up.Errors)
+	}
+	if up.Latency == nil || up.Latency.Count != 5 {
+		t.Errorf("Expected 5 chunk PUTs, got %+v", up.Latency)
+	}
+	content, ok := fake.File(fakecloud.DefaultUser, "test/large.bin")
+	want := make([]byte, 300*1024)
+	io.ReadFull(&ZeroReader{Limit: int64(len(want)), Seed: FileSeed("/test/large.bin")}, want)
+	if !ok || !bytes.Equal(content, want) {
+		t.Errorf("Expected the assembled file to match the uploaded data")
+	}
+
+	down, err := RunDownloadLargeFile(ctx, client, "/test", "large.bin", 300*1024, ChecksumSHA256)
+	if err != nil || len(down.Errors) > 0 {
+		t.Fatalf("RunDownloadLargeFile failed: %v %v", err, down.Errors)
+	}
+	if down.TotalSize != 300*1024 {
+		t.Errorf("Expected %d bytes downloaded, got %d", 300*1024, down.TotalSize)
+	}
+}
+
+func TestRunSoakAgainstFake(t *testing.T) {
+	fake := fakecloud.New(fakecloud.Options{})
+	fake.AddFault(fakecloud.Fault{Method: "PROPFIND", Status: 403, Times: 1})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	client := webdav.NewClient(ts.URL, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+	ctx := context.Background()
+
+	if err := client.CreateDirectory(ctx, "/test"); err != nil {
+		t.Fatal(err)
+	}
+	var streamed int
+	opts := SoakOptions{Duration: 600 * time.Millisecond, Interval: 200 * time.Millisecond, FileSize: 16 * 1024, Files: 3, Parallel: 2, Upload: 1, Download: 1, List: 1}
+	res, err := RunSoak(ctx, client, "/test", opts, func(IntervalSample) { streamed++ })
+	if err != nil {
+		t.Fatalf("RunSoak failed: %v", err)
+	}
+	if len(res.Samples) < 2 || len(res.Samples) > 4 || streamed != len(res.Samples) {
+		t.Fatalf("Expected about 3 samples passed to onSample, got %d (%d streamed)", len(res.Samples), streamed)
+	}
+	var ops, errs int
+	for _, s := range res.Samples {
+		ops += s.Ops
+		errs += s.Errors
+		if s.Ops > 0 && s.P95 == 0 {
+			t.Errorf("Expected latency in sample %+v", s)
+		}
+	}
+	first := res.Samples[0]
+	if ops == 0 || first.UploadMBps <= 0 || first.DownloadMBps <= 0 {
+		t.Errorf("Expected uploads and downloads in the first sample, got %+v", first)
+	}
+	if errs != 1 || len(res.Errors) != 1 {
+		t.Errorf("Expected the injected PROPFIND fault as the only error, got %d (%v)", errs, res.Errors)
+	}
+	if !fake.Exists(fakecloud.DefaultUser, "test/soak/read002.bin") || !fake.Exists(fakecloud.DefaultUser, "test/soak/write000.bin") {
+		t.Error("Expected seeded and uploaded soak files")
+	}
+}
+
+func TestRunLoadAgainstFake(t *testing.T) {
+	fake := fakecloud.New(fakecloud.Options{Users: map[string]string{"u1": "p1", "u2": "p2"}})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	// Three virtual users share two accounts, each with its own folder
+	var users []VirtualUser
+	for i, acc := range []string{"u1", "u2", "u1"} {
+		users = append(users, VirtualUser{
+			Name:   fmt.Sprintf("vu%d", i+1),
+			Client: webdav.NewClient(ts.URL, acc, "p"+acc[1:], nil),
+			Folder: fmt.Sprintf("load-%d", i+1),
+		})
+	}
+	opts := LoadOptions{RampUp: 300 * time.Millisecond, Duration: 800 * time.Millisecond, Interval: 200 * time.Millisecond, FileSize: 8 * 1024, Files: 2, ThinkTime: 10 * time.Millisecond}
+	res, err := RunLoad(context.Background(), users, opts, nil)
+	if err != nil {
+		t.Fatalf("RunLoad failed: %v", err)
+	}
+	if len(res.Samples) < 3 || len(res.Errors) > 0 {
+		t.Fatalf("Expected about 4 samples without errors, got %d (%v)", len(res.Samples), res.Errors)
+	}
+	if first, last := res.Samples[0], res.Samples[len(res.Samples)-1]; first.Users >= 3 || last.Users != 3 {
+		t.Errorf("Expected the users to ramp up to 3, got %d then %d", first.Users, last.Users)
+	}
+	if len(res.Users) != 3 {
+		t.Fatalf("Expected 3 per-user results, got %d", len(res.Users))
+	}
+	for _, u := range res.Users {
+		// A cycle is a poll, an upload, a listing and a download
+		if u.Cycles == 0 || u.Ops < 4*u.Cycles || u.Errors != 0 || u.Latency == nil || u.Latency.Count < u.Ops {
+			t.Errorf("Unexpected result for %s: %+v", u.Name, u)
+		}
+	}
+	if !fake.Exists("u1", "load-3/file000.bin") || !fake.Exists("u2", "load-2/file000.bin") {
+		t.Error("Expected the uploads in the folders of the users")
+	}
+
+	if _, err := RunLoad(context.Background(), users, LoadOptions{Duration: time.Second, Interval: time.Second, RampUp: 2 * time.Second, Files: 1}, nil); err == nil {
+		t.Error("Expected a ramp-up longer than the run to be rejected")
+	}
+}
+
+func TestRunSyncAgainstFake(t *testing.T) {
+	ts := httptest.NewServer(fakecloud.New(fakecloud.Options{}))
+	defer ts.Close()
+	client := webdav.NewClient(ts.URL, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+	ctx := context.Background()
+
+	if err := client.CreateDirectory(ctx, "/test"); err != nil {
+		t.Fatal(err)
+	}
+	opts := SyncOptions{Dirs: 3, FilesPerDir: 5, FileSize: 1024, Changes: 2, Renames: 1, Deletes: 1, Cycles: 2, Parallel: 3}
+	res, err := RunSync(ctx, client, "/test", opts)
+	if err != nil {
+		t.Fatalf("RunSync failed: %v", err)
+	}
+	if len(res.Errors) > 0 {
+		t.Fatalf("Unexpected errors: %v", res.Errors)
+	}
+	// Root and 3 folders listed, 15 files downloaded, plus the depth 0 check
+	if p := res.Initial.Phases; len(p) != 2 || p[0].Items != 4 || p[1].Items != 15 || res.Initial.Requests != 20 {
+		t.Errorf("Unexpected initial sync: %+v", res.Initial)
+	}
+	if res.Idle <= 0 {
+		t.Error("Expected an idle check time")
+	}
+	if len(res.Cycles) != 2 {
+		t.Fatalf("Expected 2 cycles, got %d", len(res.Cycles))
+	}
+	for i, c := range res.Cycles {
+		phases := map[string]SyncPhase{}
+		for _, p := range c.Phases {
+			phases[p.Name] = p
+		}
+		if c.Conflicts != 1 || len(c.Phases) != 6 || c.Duration <= 0 {
+			t.Errorf("Cycle %d: unexpected result %+v", i+1, c)
+		}
+		// Only the files changed by the other client are downloaded
+		if d := phases[PhaseDownload]; d.Items != 2 || d.Requests != 2 {
+			t.Errorf("Cycle %d: unexpected download phase %+v", i+1, d)
+		}
+		// Rejected upload, download of the server version, conflict copy
+		if c := phases[PhaseConflict]; c.Requests != 3 || c.Errors != 0 {
+			t.Errorf("Cycle %d: unexpected conflict phase %+v", i+1, c)
+		}
+	}
+	if res.Latency == nil || res.Latency.Count != res.Cycles[0].Requests+res.Cycles[1].Requests {
+		t.Errorf("Expected the latency of all cycle requests, got %+v", res.Latency)
+	}
+
+	// Two conflict copies were added and two files deleted
+	entries, err := client.Propfind(ctx, "/test/sync", webdav.DepthInfinity, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := 0
+	for _, e := range entries {
+		if !e.IsCollection {
+			files++
+		}
+	}
+	if files != 15 {
+		t.Errorf("Expected 15 files after the cycles, got %d", files)
+	}
+
+	if _, err := RunSync(ctx, client, "/test", SyncOptions{Dirs: 1, FilesPerDir: 5, Changes: 2, Renames: 1, Deletes: 1, Cycles: 1, Parallel: 1}); err == nil {
+		t.Error("Expected a tree too small for a cycle to be rejected")
+	}
+}
+
+func TestRunProtocolAgainstFake(t *testing.T) {
+	fake := fakecloud.New(fakecloud.Options{})
+	ts := httptest.NewUnstartedServer(fake)
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetHTTP1(true)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	defer ts.Close()
+	client := webdav.NewClient(ts.URL, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+	ctx := context.Background()
+	if err := client.CreateDirectory(ctx, "/test"); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := ProtocolOptions{Files: 4, FileSize: 16 * 1024, Parallel: 4, LargeSize: 256 * 1024, Checksum: ChecksumSHA256}
+	for protocol, want := range map[string]string{webdav.ProtocolHTTP1: "HTTP/1.1", webdav.ProtocolHTTP2: "HTTP/2.0"} {
+		run := RunProtocol(ctx, client, "/test", protocol, opts)
+		if run.Err != nil || run.Negotiated != want {
+			t.Fatalf("%s: expected %s, got %q (%v)", protocol, want, run.Negotiated, run.Err)
+		}
+		for _, res := range []*Result{run.Upload, run.Download, run.LargeUpload, run.LargeDownload} {
+			if res == nil || len(res.Errors) > 0 || res.SpeedMBps <= 0 {
+				t.Errorf("%s: unexpected result %+v", protocol, res)
+			}
+		}
+	}
+	if !fake.Exists(fakecloud.DefaultUser, "test/proto-h2/large.bin") {
+		t.Error("Expected each protocol to use its own folder")
+	}
+
+	// HTTP/3 needs TLS and is reported as not available
+	if run := RunProtocol(ctx, client, "/test", webdav.ProtocolHTTP3, opts); run.Err == nil || run.Upload != nil {
+		t.Errorf("Expected HTTP/3 to be unavailable over http, got %+v", run)
+	}
+}
+
+func TestRunEndpointsAgainstFake(t *testing.T) {
+	fake := fakecloud.New(fakecloud.Options{Latency: 20 * time.Millisecond})
+	fake.AddFault(fakecloud.Fault{Method: "PROPFIND", Status: http.StatusForbidden})
+	var mu sync.Mutex
+	authenticated := 0 // Requests other than status.php
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status.php" {
+			mu.Lock()
+			authenticated++
+			mu.Unlock()
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	client := webdav.NewClient(ts.URL, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+	client.Client.Transport = ts.Client().Transport
+
+	res, err := RunEndpoints(context.Background(), client, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 || res[0].Name != EndpointStatus || res[1].Name != EndpointOCS || res[2].Name != EndpointPropfind {
+		t.Fatalf("Unexpected endpoints: %+v", res)
+	}
+	for _, ep := range res[:2] {
+		if ep.Samples != 3 || ep.Failed != 0 || len(ep.Phases) != len(RequestPhases) {
+			t.Fatalf("%s: unexpected result %+v", ep.Name, ep)
+		}
+		// Every request has to connect and handshake again
+		if ep.Phase(RequestConnect).Min <= 0 || ep.Phase(RequestTLS).Min <= 0 {
+			t.Errorf("%s: expected a new connection per request, got %+v", ep.Name, ep.Phases)
+		}
+		if ttfb := ep.Phase(RequestTTFB); ttfb.Min < 20*time.Millisecond || ttfb.Median < ttfb.Min || ttfb.Max < ttfb.Median {
+			t.Errorf("%s: expected the server latency in the TTFB, got %+v", ep.Name, ttfb)
+		}
+		if ep.Total.Mean < ep.Phase(RequestTTFB).Mean || !ep.ServerBound() {
+			t.Errorf("%s: expected a server-bound request, got total %+v", ep.Name, ep.Total)
+		}
+	}
+	if ep := res[2]; ep.Failed != 3 || ep.Error == "" || ep.Samples != 0 || ep.ServerBound() {
+		t.Errorf("Expected the refused PROPFIND to fail, got %+v", ep)
+	}
+
+	// status.php needs no login, so its handshake is measured before the
+	// login is checked, without failed logins that trip brute-force protection
+	bad := webdav.NewClient(ts.URL, fakecloud.DefaultUser, "wrong", nil)
+	bad.Client.Transport = ts.Client().Transport
+	mu.Lock()
+	authenticated = 0
+	mu.Unlock()
+	res, err = RunEndpoints(context.Background(), bad, 2, EndpointStatus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Samples != 2 || res[0].Phase(RequestTLS).Median <= 0 {
+		t.Errorf("Expected only status.php to be measured without login, got %+v", res)
+	}
+	mu.Lock()
+	logins := authenticated
+	mu.Unlock()
+	if logins > 1 {
+		t.Errorf("Expected at most one authenticated request with a wrong password, got %d", logins)
+	}
+
+	if _, err := RunEndpoints(context.Background(), client, 0); err == nil {
+		t.Error("Expected zero samples to be rejected")
+	}
 }
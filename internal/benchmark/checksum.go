@@ -0,0 +1,119 @@
+package benchmark
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Checksum types sent as OC-Checksum with uploads and verified after downloads.
+const (
+	ChecksumSHA256 = "SHA256"
+	ChecksumMD5    = "MD5"
+)
+
+// Integrity errors of downloads. Unlike transfer errors the request
+// succeeded, but the received data differs from the uploaded file.
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrSizeMismatch     = errors.New("size mismatch")
+)
+
+// checksumCache holds computed checksums by checksumKey, so that large files
+// are hashed once for the upload header and the download verification.
+var checksumCache sync.Map
+
+type checksumKey struct {
+	typ  string
+	seed uint64
+	size int64
+}
+
+// FileSeed returns the ZeroReader seed of a test file. It is derived from
+// the remote path, so the expected content is known again at download time.
+func FileSeed(remotePath string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(strings.TrimPrefix(remotePath, "/")))
+	return h.Sum64()
+}
+
+// ContentChecksum returns the checksum of the data of a ZeroReader with the
+// given seed and size as "<TYPE>:<hex>", the OC-Checksum format. Returns ""
+// for an empty or unknown type.
+func ContentChecksum(typ string, seed uint64, size int64) string {
+	key := checksumKey{typ, seed, size}
+	if sum, ok := checksumCache.Load(key); ok {
+		return sum.(string)
+	}
+	h := newHash(typ)
+	if h == nil {
+		return ""
+	}
+	io.Copy(h, &ZeroReader{Limit: size, Seed: seed})
+	sum := typ + ":" + hex.EncodeToString(h.Sum(nil))
+	checksumCache.Store(key, sum)
+	return sum
+}
+
+func newHash(typ string) hash.Hash {
+	switch typ {
+	case ChecksumSHA256:
+		return sha256.New()
+	case ChecksumMD5:
+		return md5.New()
+	}
+	return nil
+}
+
+// verifyDownload reads the body of the downloaded test file name and checks
+// its size and, with a checksum type, its content against the uploaded data.
+// Without a known size nothing is verified. Returns the bytes read and when
+// the body was fully received: the checksum is not part of the transfer, it
+// is computed in a goroutine fed through a pipe and compared afterwards.
+func verifyDownload(body io.Reader, name string, size int64, typ string) (int64, time.Time, error) {
+	h := newHash(typ)
+	var w io.Writer = io.Discard
+	var pw *io.PipeWriter
+	hashed := make(chan struct{})
+	if h != nil && size > 0 {
+		var pr *io.PipeReader
+		pr, pw = io.Pipe()
+		w = pw
+		go func() {
+			defer close(hashed)
+			io.Copy(h, pr)
+		}()
+	}
+	n, err := io.Copy(w, body)
+	received := time.Now()
+	if pw != nil {
+		pw.Close()
+		<-hashed
+	}
+	if err != nil || size <= 0 {
+		return n, received, err
+	}
+	if n != size {
+		return n, received, fmt.Errorf("%w: %s: received %d of %d bytes", ErrSizeMismatch, name, n, size)
+	}
+	if pw != nil {
+		got := typ + ":" + hex.EncodeToString(h.Sum(nil))
+		if want := ContentChecksum(typ, FileSeed(name), size); got != want {
+			return n, received, fmt.Errorf("%w: %s: received %s, uploaded %s", ErrChecksumMismatch, name, got, want)
+		}
+	}
+	return n, received, nil
+}
+
+// isIntegrityError reports whether err is a size or checksum mismatch.
+func isIntegrityError(err error) bool {
+	return errors.Is(err, ErrChecksumMismatch) || errors.Is(err, ErrSizeMismatch)
+}
@@ -0,0 +1,211 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"nextcloud-perf/internal/webdav"
+)
+
+// Endpoints of RunEndpoints, from least to most server work: status.php
+// barely starts PHP, the OCS call also authenticates the user and loads the
+// apps, the PROPFIND goes through the WebDAV stack and the file cache on top.
+const (
+	EndpointStatus   = "status"
+	EndpointOCS      = "ocs"
+	EndpointPropfind = "propfind"
+)
+
+// Phases of an HTTP request in the order they happen.
+const (
+	RequestDNS      = "dns"
+	RequestConnect  = "connect"
+	RequestTLS      = "tls"
+	RequestSend     = "send"
+	RequestTTFB     = "ttfb"
+	RequestTransfer = "transfer"
+)
+
+// RequestPhases lists the request phases in the order they happen.
+var RequestPhases = []string{RequestDNS, RequestConnect, RequestTLS, RequestSend, RequestTTFB, RequestTransfer}
+
+// RequestPhaseLabels are the display names of the request phases.
+var RequestPhaseLabels = map[string]string{
+	RequestDNS:      "DNS",
+	RequestConnect:  "TCP",
+	RequestTLS:      "TLS",
+	RequestSend:     "Send",
+	RequestTTFB:     "TTFB",
+	RequestTransfer: "Transfer",
+}
+
+// DurationStats summarizes a set of duration samples.
+type DurationStats struct {
+	Min    time.Duration `json:"min"`
+	Median time.Duration `json:"median"`
+	Mean   time.Duration `json:"mean"`
+	Max    time.Duration `json:"max"`
+}
+
+// PhaseStats is one request phase over all samples of an endpoint.
+type PhaseStats struct {
+	Name string `json:"name"`
+	DurationStats
+}
+
+// EndpointTiming breaks the requests to one endpoint down into their phases.
+// Every request runs on a new connection, so DNS, TCP connect and TLS are
+// part of each sample.
+type EndpointTiming struct {
+	Name    string        `json:"name"`
+	Method  string        `json:"method"`
+	Path    string        `json:"path"`
+	Samples int           `json:"samples"` // Requests that got a response
+	Failed  int           `json:"failed,omitempty"`
+	Phases  []PhaseStats  `json:"phases,omitempty"` // In the order of RequestPhases
+	Total   DurationStats `json:"total"`
+	Error   string        `json:"error,omitempty"` // First error
+}
+
+// Phase returns the statistics of the named phase, or zero if it was not measured.
+func (e EndpointTiming) Phase(name string) DurationStats {
+	for _, p := range e.Phases {
+		if p.Name == name {
+			return p.DurationStats
+		}
+	}
+	return DurationStats{}
+}
+
+// ServerTime estimates the mean server processing time: the time to first
+// byte minus one round trip, for which the TCP connect time stands in.
+func (e EndpointTiming) ServerTime() time.Duration {
+	return max(e.Phase(RequestTTFB).Mean-e.Phase(RequestConnect).Mean, 0)
+}
+
+// ServerBound reports whether the server processing takes longer than
+// everything the network adds to a request on a new connection.
+func (e EndpointTiming) ServerBound() bool {
+	server := e.ServerTime()
+	return e.Samples > 0 && server > e.Total.Mean-server
+}
+
+// RunEndpoints requests status.php, the OCS capabilities and a PROPFIND of
+// the user's root folder 'samples' times each, every time on a new
+// connection, and breaks the request time down into DNS, TCP connect, TLS,
+// sending, time to first byte (server processing) and transfer. names
+// selects endpoints by name, e.g. only EndpointStatus before the login has
+// been checked; all endpoints are requested if it is empty.
+func RunEndpoints(ctx context.Context, client *webdav.Client, samples int, names ...string) ([]EndpointTiming, error) {
+	if samples <= 0 {
+		return nil, fmt.Errorf("invalid parameters: samples=%d", samples)
+	}
+
+	endpoints := []struct {
+		name, method, path string
+		request            func(ctx context.Context) error
+	}{
+		{EndpointStatus, "GET", "/status.php", func(ctx context.Context) error {
+			_, err := client.GetStatus(ctx)
+			return err
+		}},
+		{EndpointOCS, "GET", "/ocs/v1.php/cloud/capabilities", func(ctx context.Context) error {
+			_, err := client.GetCapabilities(ctx)
+			return err
+		}},
+		{EndpointPropfind, "PROPFIND", fmt.Sprintf("/remote.php/dav/files/%s/", client.Username), func(ctx context.Context) error {
+			_, err := client.Propfind(ctx, "", webdav.DepthZero, nil)
+			return err
+		}},
+	}
+
+	var out []EndpointTiming
+	for _, ep := range endpoints {
+		if ctx.Err() != nil {
+			break
+		}
+		if len(names) > 0 && !slices.Contains(names, ep.name) {
+			continue
+		}
+		res := EndpointTiming{Name: ep.name, Method: ep.method, Path: ep.path}
+		tctx, rec := withTimings(ctx)
+		for i := 0; i < samples && ctx.Err() == nil; i++ {
+			client.CloseIdleConnections()
+			if err := ep.request(tctx); err != nil {
+				res.Failed++
+				if res.Error == "" {
+					res.Error = err.Error()
+				}
+			}
+		}
+		res.summarize(rec.Samples())
+		out = append(out, res)
+	}
+	return out, ctx.Err()
+}
+
+// summarize computes the phase statistics of the requests that got a
+// response. Failed attempts that were retried are left out.
+func (e *EndpointTiming) summarize(timings []webdav.RequestTiming) {
+	var ok []webdav.RequestTiming
+	for _, t := range timings {
+		if !t.Failed {
+			ok = append(ok, t)
+		}
+	}
+	e.Samples = len(ok)
+	if len(ok) == 0 {
+		return
+	}
+
+	pick := func(f func(t webdav.RequestTiming) time.Duration) DurationStats {
+		d := make([]time.Duration, len(ok))
+		for i, t := range ok {
+			d[i] = f(t)
+		}
+		return durationStats(d)
+	}
+	for _, name := range RequestPhases {
+		e.Phases = append(e.Phases, PhaseStats{Name: name, DurationStats: pick(func(t webdav.RequestTiming) time.Duration {
+			return phaseDuration(t, name)
+		})})
+	}
+	e.Total = pick(func(t webdav.RequestTiming) time.Duration { return t.Total })
+}
+
+// phaseDuration returns the named phase of a request timing.
+func phaseDuration(t webdav.RequestTiming, phase string) time.Duration {
+	switch phase {
+	case RequestDNS:
+		return t.DNS
+	case RequestConnect:
+		return t.Connect
+	case RequestTLS:
+		return t.TLS
+	case RequestSend:
+		return t.Send
+	case RequestTTFB:
+		return t.TTFB
+	case RequestTransfer:
+		return t.Transfer
+	}
+	return 0
+}
+
+// durationStats summarizes d, which it sorts. d must not be empty.
+func durationStats(d []time.Duration) DurationStats {
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	var sum time.Duration
+	for _, v := range d {
+		sum += v
+	}
+	return DurationStats{
+		Min:    d[0],
+		Median: percentile(d, 50),
+		Mean:   sum / time.Duration(len(d)),
+		Max:    d[len(d)-1],
+	}
+}
@@ -0,0 +1,130 @@
+package benchmark
+
+import (
+	"sort"
+	"time"
+
+	"nextcloud-perf/internal/webdav"
+)
+
+// HistogramBuckets are the upper bounds of the request latency histogram.
+// Requests slower than the last bound are counted in an overflow bucket.
+var HistogramBuckets = []time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// HistogramBucket counts the requests with a total time up to UpperBound.
+// The overflow bucket has UpperBound 0.
+type HistogramBucket struct {
+	UpperBound time.Duration `json:"upper_bound"`
+	Count      int           `json:"count"`
+}
+
+// PhaseAverages holds the mean duration of each request phase.
+type PhaseAverages struct {
+	DNS      time.Duration `json:"dns"`
+	Connect  time.Duration `json:"connect"`
+	TLS      time.Duration `json:"tls"`
+	Send     time.Duration `json:"send"`
+	TTFB     time.Duration `json:"ttfb"`
+	Transfer time.Duration `json:"transfer"`
+}
+
+// LatencyStats summarizes the per-request timings of a benchmark run.
+type LatencyStats struct {
+	Count     int               `json:"count"`
+	Failed    int               `json:"failed"`
+	Min       time.Duration     `json:"min"`
+	Mean      time.Duration     `json:"mean"`
+	P50       time.Duration     `json:"p50"`
+	P90       time.Duration     `json:"p90"`
+	P95       time.Duration     `json:"p95"`
+	P99       time.Duration     `json:"p99"`
+	Max       time.Duration     `json:"max"`
+	Phases    PhaseAverages     `json:"phases"`
+	Histogram []HistogramBucket `json:"histogram"`
+}
+
+// ComputeLatencyStats aggregates the timings of all requests with the given
+// HTTP method (all requests if method is empty). Returns nil without samples.
+func ComputeLatencyStats(timings []webdav.RequestTiming, method string) *LatencyStats {
+	var totals []time.Duration
+	var phases PhaseAverages
+	stats := &LatencyStats{}
+
+	for _, t := range timings {
+		if method != "" && t.Method != method {
+			continue
+		}
+		if t.Failed {
+			stats.Failed++
+		}
+		totals = append(totals, t.Total)
+		phases.DNS += t.DNS
+		phases.Connect += t.Connect
+		phases.TLS += t.TLS
+		phases.Send += t.Send
+		phases.TTFB += t.TTFB
+		phases.Transfer += t.Transfer
+	}
+	if len(totals) == 0 {
+		return nil
+	}
+
+	sort.Slice(totals, func(i, j int) bool { return totals[i] < totals[j] })
+	n := time.Duration(len(totals))
+
+	var sum time.Duration
+	for _, d := range totals {
+		sum += d
+	}
+
+	stats.Count = len(totals)
+	stats.Min = totals[0]
+	stats.Max = totals[len(totals)-1]
+	stats.Mean = sum / n
+	stats.P50 = percentile(totals, 50)
+	stats.P90 = percentile(totals, 90)
+	stats.P95 = percentile(totals, 95)
+	stats.P99 = percentile(totals, 99)
+	stats.Phases = PhaseAverages{
+		DNS:      phases.DNS / n,
+		Connect:  phases.Connect / n,
+		TLS:      phases.TLS / n,
+		Send:     phases.Send / n,
+		TTFB:     phases.TTFB / n,
+		Transfer: phases.Transfer / n,
+	}
+	stats.Histogram = histogram(totals)
+	return stats
+}
+
+// percentile returns the nearest-rank percentile p (0-100) of sorted values.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func histogram(values []time.Duration) []HistogramBucket {
+	buckets := make([]HistogramBucket, len(HistogramBuckets)+1)
+	for i, b := range HistogramBuckets {
+		buckets[i].UpperBound = b
+	}
+	for _, v := range values {
+		idx := sort.Search(len(HistogramBuckets), func(i int) bool { return v <= HistogramBuckets[i] })
+		buckets[idx].Count++
+	}
+	return buckets
+}
@@ -0,0 +1,190 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nextcloud-perf/internal/webdav"
+)
+
+// VirtualUser is one simulated sync client of a load test.
+type VirtualUser struct {
+	Name   string         // Shown in the per-user results
+	Client *webdav.Client // Authenticated as the account of the user
+	Folder string         // Remote working folder, created by RunLoad
+}
+
+// LoadOptions configures RunLoad.
+type LoadOptions struct {
+	RampUp    time.Duration // Users start evenly spread over this time
+	Duration  time.Duration // Total run time including the ramp-up
+	Interval  time.Duration // Sampling interval
+	FileSize  int64         // Size of the files uploaded and downloaded per cycle
+	Files     int           // Files per user cycled by the uploads
+	ThinkTime time.Duration // Pause between two sync cycles of a user
+}
+
+// UserResult contains the operations and request latencies of one virtual user.
+type UserResult struct {
+	Name    string        `json:"name"`
+	Cycles  int           `json:"cycles"` // Completed sync cycles
+	Ops     int           `json:"ops"`
+	Errors  int           `json:"errors"`
+	Latency *LatencyStats `json:"latency,omitempty"`
+}
+
+// LoadResult contains the aggregate time series and per-user results of a load run.
+type LoadResult struct {
+	Duration time.Duration // Time covered by Samples
+	Samples  []IntervalSample
+	Users    []UserResult
+	Errors   []error // The first errors of the run
+}
+
+// loadUser tracks one virtual user while the run is going.
+type loadUser struct {
+	VirtualUser
+	rec     *webdav.TimingRecorder
+	timings []webdav.RequestTiming // Drained by the sampler only
+	cycles  atomic.Int64
+	ops     atomic.Int64
+	errors  atomic.Int64
+}
+
+// RunLoad simulates len(users) desktop sync clients for opts.Duration. The
+// users start one after another, evenly spread over opts.RampUp, and then loop
+// a sync cycle until the end of the run:
+//
+//   - PROPFIND depth 0 on the working folder (the change poll of the client)
+//   - PUT of one of opts.Files files
+//   - PROPFIND depth 1 on the working folder
+//   - GET of the file uploaded in the previous cycle
+//
+// followed by opts.ThinkTime. Aggregate throughput, latency and errors are
+// sampled every opts.Interval together with the number of active users, so
+// that the samples show where throughput stops growing with the users.
+// onSample, if set, is called with each sample as soon as its interval ends.
+func RunLoad(ctx context.Context, users []VirtualUser, opts LoadOptions, onSample func(IntervalSample)) (*LoadResult, error) {
+	if len(users) == 0 || opts.Duration <= 0 || opts.Interval <= 0 || opts.RampUp < 0 || opts.RampUp > opts.Duration ||
+		opts.FileSize < 0 || opts.Files <= 0 || opts.ThinkTime < 0 {
+		return nil, fmt.Errorf("invalid parameters: %d users, %+v", len(users), opts)
+	}
+
+	lus := make([]*loadUser, len(users))
+	for i, u := range users {
+		lus[i] = &loadUser{VirtualUser: u, rec: &webdav.TimingRecorder{}}
+	}
+
+	win := &opWindow{}
+	smp := newSampler(win, func() []webdav.RequestTiming {
+		var all []webdav.RequestTiming
+		for _, u := range lus {
+			t := u.rec.Drain()
+			u.timings = append(u.timings, t...)
+			all = append(all, t...)
+		}
+		return all
+	}, onSample)
+	smp.users = &activeUsers{}
+	deadline := smp.start.Add(opts.Duration)
+
+	var wg sync.WaitGroup
+	for i, u := range lus {
+		wg.Add(1)
+		go func(i int, u *loadUser) {
+			defer wg.Done()
+			delay := opts.RampUp * time.Duration(i) / time.Duration(len(lus))
+			select {
+			case <-time.After(time.Until(smp.start.Add(delay))):
+			case <-ctx.Done():
+				return
+			}
+			// The working folder is set up outside the measured requests
+			if err := u.Client.CreateDirectory(ctx, u.Folder); err != nil {
+				u.ops.Add(1)
+				u.errors.Add(1)
+				win.add(opList, 0, fmt.Errorf("%s: %w", u.Name, err))
+				return
+			}
+			smp.users.add(1)
+			defer smp.users.add(-1)
+			u.loop(webdav.WithTimingRecorder(ctx, u.rec), deadline, opts, win)
+		}(i, u)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	smp.run(opts.Interval, done)
+
+	res := &LoadResult{Samples: smp.samples, Duration: smp.elapsed(), Errors: win.firstErrors()}
+	for _, u := range lus {
+		res.Users = append(res.Users, UserResult{
+			Name:   u.Name,
+			Cycles: int(u.cycles.Load()),
+			Ops:    int(u.ops.Load()),
+			Errors: int(u.errors.Load()),
+			// Includes the requests finished after the last sample
+			Latency: ComputeLatencyStats(append(u.timings, u.rec.Drain()...), ""),
+		})
+	}
+	return res, nil
+}
+
+// loop runs sync cycles until the deadline.
+func (u *loadUser) loop(ctx context.Context, deadline time.Time, opts LoadOptions, win *opWindow) {
+	name := func(i int) string { return fmt.Sprintf("%s/file%03d.bin", u.Folder, i%opts.Files) }
+	op := func(kind int, f func() (int64, error)) bool {
+		n, err := f()
+		u.ops.Add(1)
+		if err != nil {
+			u.errors.Add(1)
+			err = fmt.Errorf("%s: %w", u.Name, err)
+		}
+		win.add(kind, n, err)
+		return err == nil
+	}
+
+	last := -1 // Cycle of the last successful upload
+	for cycle := 0; time.Now().Before(deadline) && ctx.Err() == nil; cycle++ {
+		op(opList, func() (int64, error) {
+			_, err := u.Client.Propfind(ctx, u.Folder, webdav.DepthZero, nil)
+			return 0, err
+		})
+		uploaded := op(opUpload, func() (int64, error) {
+			_, err := u.Client.UploadSimple(ctx, name(cycle), &ZeroReader{Limit: opts.FileSize}, opts.FileSize)
+			return opts.FileSize, err
+		})
+		op(opList, func() (int64, error) {
+			_, err := u.Client.Propfind(ctx, u.Folder, webdav.DepthOne, nil)
+			return 0, err
+		})
+		// Read the previous upload, in the first cycle the one just written
+		read := last
+		if uploaded {
+			if read < 0 {
+				read = cycle
+			}
+			last = cycle
+		}
+		if read >= 0 {
+			op(opDownload, func() (int64, error) {
+				return soakDownloadFile(ctx, u.Client, name(read))
+			})
+		}
+		u.cycles.Add(1)
+
+		if opts.ThinkTime > 0 {
+			wait := min(opts.ThinkTime, time.Until(deadline))
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"nextcloud-perf/internal/webdav"
+)
+
+// ListingResult contains the PROPFIND latency for one depth.
+type ListingResult struct {
+	Depth   string        // "0", "1" or "infinity"
+	Entries int           // Entries returned by the last successful listing
+	Latency *LatencyStats // Latency distribution over all iterations
+	Errors  []error
+}
+
+// MetadataResult contains the metrics of a folder tree listing benchmark.
+type MetadataResult struct {
+	Dirs        int           // Number of directories in the tree
+	FilesPerDir int           // Number of files per directory
+	Setup       time.Duration // Time to create the tree
+	Listings    []ListingResult
+	Errors      []error // Errors while creating the tree
+}
+
+// RunMetadata creates a folder tree of 'dirs' directories with 'files' files
+// each below basePath/tree and measures PROPFIND listing latency of the tree
+// root at depth 0, 1 and infinity, repeating each listing 'iterations' times.
+//
+// Parameters:
+//   - ctx: Context for cancellation
+//   - client: WebDAV client configured for target server
+//   - basePath: Remote directory path for the tree
+//   - dirs: Number of directories to create
+//   - files: Number of files per directory
+//   - fileSize: Size of each file in bytes
+//   - iterations: Number of PROPFIND requests per depth
+//   - parallel: Maximum number of concurrent requests while creating the tree
+//
+// Many servers disable depth infinity; that listing then reports an error
+// without failing the other depths.
+func RunMetadata(ctx context.Context, client *webdav.Client, basePath string, dirs, files int, fileSize int64, iterations, parallel int) (*MetadataResult, error) {
+	if dirs <= 0 || files < 0 || fileSize < 0 || iterations <= 0 || parallel <= 0 {
+		return nil, fmt.Errorf("invalid parameters: dirs=%d, files=%d, size=%d, iterations=%d, parallel=%d", dirs, files, fileSize, iterations, parallel)
+	}
+
+	root := fmt.Sprintf("%s/tree", basePath)
+	res := &MetadataResult{Dirs: dirs, FilesPerDir: files}
+
+	start := time.Now()
+	if err := client.CreateDirectory(ctx, root); err != nil {
+		return nil, err
+	}
+	res.Errors = buildTree(ctx, client, root, dirs, files, fileSize, parallel)
+	res.Setup = time.Since(start)
+
+	for _, depth := range []string{webdav.DepthZero, webdav.DepthOne, webdav.DepthInfinity} {
+		res.Listings = append(res.Listings, measureListing(ctx, client, root, depth, iterations))
+	}
+	return res, nil
+}
+
+// buildTree creates the directories and uploads their files with bounded concurrency.
+func buildTree(ctx context.Context, client *webdav.Client, root string, dirs, files int, fileSize int64, parallel int) []error {
+	dirName := func(d int) string { return fmt.Sprintf("%s/dir%04d", root, d) }
+
+	errs := forEach(dirs, parallel, func(d int) error {
+		return client.CreateDirectory(ctx, dirName(d))
+	})
+	return append(errs, forEach(dirs*files, parallel, func(i int) error {
+		name := fmt.Sprintf("%s/file%04d.bin", dirName(i/files), i%files)
+		_, err := client.UploadSimple(ctx, name, &ZeroReader{Limit: fileSize}, fileSize)
+		return err
+	})...)
+}
+
+// measureListing runs 'iterations' PROPFIND requests on root with the given depth.
+func measureListing(ctx context.Context, client *webdav.Client, root, depth string, iterations int) ListingResult {
+	res := ListingResult{Depth: depth}
+	tctx, rec := withTimings(ctx)
+
+	for i := 0; i < iterations; i++ {
+		entries, err := client.Propfind(tctx, root, depth, nil)
+		if err != nil {
+			res.Errors = append(res.Errors, err)
+			// A refused depth will not succeed on retry
+			break
+		}
+		res.Entries = len(entries)
+	}
+	res.Latency = ComputeLatencyStats(rec.Samples(), "PROPFIND")
+	return res
+}
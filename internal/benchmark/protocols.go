@@ -0,0 +1,66 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"nextcloud-perf/internal/webdav"
+)
+
+// ProtocolOptions configures the workload of RunProtocol.
+type ProtocolOptions struct {
+	Files     int // Small files, transferred in parallel
+	FileSize  int64
+	Parallel  int
+	LargeSize int64  // Single file transferred in one request
+	Checksum  string // Checksum type to verify downloads with, "" for size only
+}
+
+// ProtocolRun holds the comparison workload over one protocol. Err is set
+// if the protocol was not available and the workload was skipped.
+type ProtocolRun struct {
+	Protocol   string
+	Negotiated string // Version the server answered with, e.g. "HTTP/2.0"
+	Err        error
+
+	Upload        *Result // Small files, in parallel
+	Download      *Result
+	LargeUpload   *Result
+	LargeDownload *Result
+}
+
+// RunProtocol runs the protocol comparison workload over one protocol
+// (webdav.ProtocolHTTP1, ProtocolHTTP2 or ProtocolHTTP3): parallel uploads
+// and downloads of small files, which HTTP/2 and HTTP/3 multiplex over one
+// connection, then the transfer of one large file, which shows the effect
+// of flow control and congestion handling. The run starts with a new
+// connection pool and uses its own folder below basePath.
+func RunProtocol(ctx context.Context, client *webdav.Client, basePath, protocol string, opts ProtocolOptions) ProtocolRun {
+	run := ProtocolRun{Protocol: protocol}
+	pc, err := client.WithProtocol(protocol)
+	if err == nil {
+		run.Negotiated, err = pc.NegotiatedProtocol(ctx)
+	}
+	if err != nil {
+		run.Err = fmt.Errorf("%s not available: %w", protocol, err)
+		return run
+	}
+	defer pc.CloseIdleConnections()
+
+	dir := fmt.Sprintf("%s/proto-%s", basePath, strings.ReplaceAll(protocol, "/", ""))
+	if err := pc.CreateDirectory(ctx, dir); err != nil {
+		run.Err = err
+		return run
+	}
+	if run.Upload, err = RunSmallFiles(ctx, pc, dir, "small_", opts.Files, opts.FileSize, opts.Parallel, opts.Checksum); err == nil {
+		run.Download, err = RunDownloadSmallFiles(ctx, pc, dir, "small_", opts.Files, opts.FileSize, opts.Parallel, opts.Checksum)
+	}
+	if err == nil && opts.LargeSize > 0 {
+		if run.LargeUpload, err = runLargeFile(ctx, pc, dir, "large.bin", opts.LargeSize, false, webdav.ChunkOptions{}, opts.Checksum); err == nil {
+			run.LargeDownload, err = RunDownloadLargeFile(ctx, pc, dir, "large.bin", opts.LargeSize, opts.Checksum)
+		}
+	}
+	run.Err = err
+	return run
+}
@@ -2,9 +2,9 @@ package benchmark
 
 import (
 	"context"
-	"crypto/rand"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"sync"
 	"time"
 
@@ -14,10 +14,12 @@ import (
 // ZeroReader generates random data for upload benchmarks.
 // It uses a pre-generated random buffer with offset-based reading
 // to avoid crypto overhead while preventing transparent compression detection.
+// The data is reproducible: readers with the same Seed return the same bytes,
+// so downloads can be verified against a checksum (see FileSeed).
 type ZeroReader struct {
-	Limit        int64 // Maximum bytes to read
-	BytesRead    int64 // Total bytes read so far
-	bufferOffset int   // Current offset in GlobalRandomBuffer
+	Limit     int64  // Maximum bytes to read
+	BytesRead int64  // Total bytes read so far
+	Seed      uint64 // Selects the start offset in GlobalRandomBuffer
 }
 
 func (z *ZeroReader) Read(p []byte) (n int, err error) {
@@ -31,36 +33,62 @@ func (z *ZeroReader) Read(p []byte) (n int, err error) {
 		n = len(p)
 	}
 
-	// Copy with variable offset to prevent pattern recognition
-	// This makes each ZeroReader instance produce unique data
+	// Copy with a per-seed offset so that files with different seeds differ
 	copied := 0
 	for copied < n {
-		available := len(GlobalRandomBuffer) - z.bufferOffset
+		offset := int((z.Seed + uint64(z.BytesRead) + uint64(copied)) % uint64(len(GlobalRandomBuffer)))
 		toCopy := n - copied
-		if toCopy > available {
+		if available := len(GlobalRandomBuffer) - offset; toCopy > available {
 			toCopy = available
 		}
-		copy(p[copied:], GlobalRandomBuffer[z.bufferOffset:z.bufferOffset+toCopy])
+		copy(p[copied:], GlobalRandomBuffer[offset:offset+toCopy])
 		copied += toCopy
-		// Wrap around to beginning of buffer
-		z.bufferOffset = (z.bufferOffset + toCopy) % len(GlobalRandomBuffer)
 	}
 
 	z.BytesRead += int64(n)
 	return n, nil
 }
 
+// Seek implements io.Seeker so interrupted chunked uploads can be resumed.
+// The data at a given offset only depends on the Seed.
+func (z *ZeroReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += z.BytesRead
+	case io.SeekEnd:
+		offset += z.Limit
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	z.BytesRead = offset
+	return offset, nil
+}
+
+// ReadAt implements io.ReaderAt, so a retried upload can send the data again
+// on a reader of its own while the failed attempt may still hold this one.
+func (z *ZeroReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	r := ZeroReader{Limit: z.Limit, BytesRead: off, Seed: z.Seed}
+	n, err := r.Read(p)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
 // GlobalRandomBuffer is a 10MB buffer of random data used by ZeroReader
 // to generate non-compressible test data efficiently.
 // Larger buffer size reduces pattern repetition in large uploads.
+// It is generated from a fixed seed, so test files are identical across runs.
 var GlobalRandomBuffer []byte
 
 func init() {
 	// Use 10MB buffer instead of 1MB for better randomness in large files
 	GlobalRandomBuffer = make([]byte, 10*1024*1024)
-	if _, err := rand.Read(GlobalRandomBuffer); err != nil {
-		panic(fmt.Sprintf("failed to initialize random buffer: %v", err))
-	}
+	rand.NewChaCha8([32]byte{'n', 'e', 'x', 't', 'c', 'l', 'o', 'u', 'd', '-', 'p', 'e', 'r', 'f'}).Read(GlobalRandomBuffer)
 }
 
 // Result contains the performance metrics from a benchmark run.
@@ -71,6 +99,40 @@ type Result struct {
 	Duration  time.Duration // Time taken for the operation
 	SpeedMBps float64       // Transfer speed in MB/s
 	Errors    []error       // Collection of errors encountered
+
+	Timings []webdav.RequestTiming // Per-request timings of all HTTP requests
+	Latency *LatencyStats          // Latency distribution of the transfer requests (PUT or GET)
+	Retries int                    // Requests repeated after a transient failure
+	Resumes int                    // Chunked uploads resumed after a failure
+
+	Corrupted int // Downloads whose size or checksum differs from the upload
+}
+
+// withTimings starts recording request timings on ctx.
+func withTimings(ctx context.Context) (context.Context, *webdav.TimingRecorder) {
+	rec := &webdav.TimingRecorder{}
+	return webdav.WithTimingRecorder(ctx, rec), rec
+}
+
+// countCorrupted counts the integrity errors among r.Errors.
+func (r *Result) countCorrupted() {
+	for _, err := range r.Errors {
+		if isIntegrityError(err) {
+			r.Corrupted++
+		}
+	}
+}
+
+// setTimings stores the recorded timings and their latency stats for method in r.
+func (r *Result) setTimings(rec *webdav.TimingRecorder, method string) *Result {
+	r.Timings = rec.Samples()
+	r.Latency = ComputeLatencyStats(r.Timings, method)
+	for _, t := range r.Timings {
+		if t.Attempt > 1 {
+			r.Retries++
+		}
+	}
+	return r
 }
 
 // RunSmallFiles performs a parallel upload benchmark with multiple small files.
@@ -84,6 +146,7 @@ type Result struct {
 //   - count: Number of files to upload
 //   - size: Size of each file in bytes
 //   - parallel: Maximum number of concurrent uploads
+//   - checksum: Checksum type sent as OC-Checksum header ("" = none)
 //
 // Returns:
 //   - *Result: Aggregated performance metrics including speed and duration
@@ -91,7 +154,7 @@ type Result struct {
 //
 // Individual file upload errors are collected in Result.Errors but do not
 // prevent the benchmark from completing.
-func RunSmallFiles(ctx context.Context, client *webdav.Client, basePath string, filePrefix string, count int, size int64, parallel int) (*Result, error) {
+func RunSmallFiles(ctx context.Context, client *webdav.Client, basePath string, filePrefix string, count int, size int64, parallel int, checksum string) (*Result, error) {
 	// Parameter validation
 	if count <= 0 || size <= 0 || parallel <= 0 {
 		return &Result{
@@ -107,6 +170,13 @@ func RunSmallFiles(ctx context.Context, client *webdav.Client, basePath string,
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, parallel) // Semaphore for concurrency control
 	errChan := make(chan error, count)   // Buffered channel for errors
+	ctx, rec := withTimings(ctx)
+
+	// Hash before the clock starts, the checksum is not part of the transfer
+	sums := make([]string, count)
+	for i := range sums {
+		sums[i] = ContentChecksum(checksum, FileSeed(fmt.Sprintf("%s/%s%d.bin", basePath, filePrefix, i)), size)
+	}
 
 	start := time.Now()
 
@@ -118,9 +188,9 @@ func RunSmallFiles(ctx context.Context, client *webdav.Client, basePath string,
 			defer func() { <-sem }()
 
 			filename := fmt.Sprintf("%s/%s%d.bin", basePath, filePrefix, idx)
-			reader := &ZeroReader{Limit: size}
+			reader := &ZeroReader{Limit: size, Seed: FileSeed(filename)}
 
-			_, err := client.UploadSimple(ctx, filename, reader, size)
+			_, err := client.UploadWithChecksum(ctx, filename, reader, size, sums[idx])
 			if err == nil {
 				client.LogFunc(fmt.Sprintf("DEBUG: Uploaded %d bytes to %s", size, filename))
 			}
@@ -151,14 +221,15 @@ func RunSmallFiles(ctx context.Context, client *webdav.Client, basePath string,
 		mbps = float64(totalSize) / 1024 / 1024 / duration.Seconds()
 	}
 
-	return &Result{
+	res := &Result{
 		Scenario:  "Small Files (Parallel)",
 		Files:     count,
 		TotalSize: totalSize,
 		Duration:  duration,
 		SpeedMBps: mbps,
 		Errors:    errs,
-	}, nil
+	}
+	return res.setTimings(rec, "PUT"), nil
 }
 
 // RunLargeFile performs a single large file upload benchmark with optional chunking.
@@ -167,6 +238,7 @@ func RunSmallFiles(ctx context.Context, client *webdav.Client, basePath string,
 //   - ctx: Context for cancellation
 //   - client: WebDAV client configured for target server
 //   - basePath: Remote directory path for test file
+//   - fileName: Name of the test file inside basePath
 //   - size: Size of the file in bytes
 //   - useChunking: If true, uses chunked upload protocol (recommended for files > 50MB)
 //
@@ -176,17 +248,35 @@ func RunSmallFiles(ctx context.Context, client *webdav.Client, basePath string,
 //
 // This function is optimized for large files and uses streaming to avoid
 // loading the entire file into memory.
-func RunLargeFile(ctx context.Context, client *webdav.Client, basePath string, size int64, useChunking bool) (*Result, error) {
-	filename := fmt.Sprintf("%s/test_large.bin", basePath)
-	reader := &ZeroReader{Limit: size}
+func RunLargeFile(ctx context.Context, client *webdav.Client, basePath string, fileName string, size int64, useChunking bool) (*Result, error) {
+	return runLargeFile(ctx, client, basePath, fileName, size, useChunking, webdav.ChunkOptions{}, "")
+}
+
+// RunChunkedFile performs a single Chunking V2 upload benchmark with the given
+// chunk size and number of parallel chunk uploads (see webdav.ChunkOptions).
+// Unless checksum is empty, the checksum of the file is sent with the final MOVE.
+func RunChunkedFile(ctx context.Context, client *webdav.Client, basePath string, fileName string, size int64, opts webdav.ChunkOptions, checksum string) (*Result, error) {
+	return runLargeFile(ctx, client, basePath, fileName, size, true, opts, checksum)
+}
+
+func runLargeFile(ctx context.Context, client *webdav.Client, basePath string, fileName string, size int64, useChunking bool, opts webdav.ChunkOptions, checksum string) (*Result, error) {
+	filename := fmt.Sprintf("%s/%s", basePath, fileName)
+	reader := &ZeroReader{Limit: size, Seed: FileSeed(filename)}
+	// Hash before the clock starts, the checksum is not part of the transfer
+	sum := ContentChecksum(checksum, reader.Seed, size)
+	opts.Checksum = sum
+	ctx, rec := withTimings(ctx)
 
 	start := time.Now()
 	var err error
+	resumes := 0
 
 	if useChunking {
-		_, err = client.UploadChunked(ctx, filename, reader, size)
+		var up *webdav.ChunkedUpload
+		up, err = client.UploadChunkedWithOptions(ctx, filename, reader, size, opts)
+		resumes = up.Resumes
 	} else {
-		_, err = client.UploadSimple(ctx, filename, reader, size)
+		_, err = client.UploadWithChecksum(ctx, filename, reader, size, sum)
 	}
 
 	duration := time.Since(start)
@@ -202,14 +292,17 @@ func RunLargeFile(ctx context.Context, client *webdav.Client, basePath string, s
 		mbps = float64(size) / 1024 / 1024 / duration.Seconds()
 	}
 
-	return &Result{
+	// For chunked uploads the PUT samples are the individual chunks
+	res := &Result{
 		Scenario:  "Large File",
 		Files:     1,
 		TotalSize: size,
 		Duration:  duration,
 		SpeedMBps: mbps,
 		Errors:    errs,
-	}, nil
+		Resumes:   resumes,
+	}
+	return res.setTimings(rec, "PUT"), nil
 }
 
 // DiscardReader reads from r and discards everything (like /dev/null), counting bytes
@@ -237,19 +330,30 @@ func (z *ZeroReader) ReadFrom(r io.Reader) (n int64, err error) {
 //   - basePath: Remote directory path containing test files
 //   - filePrefix: Prefix of test filenames to download
 //   - count: Number of files to download
+//   - size: Uploaded size of each file, verified after download (0 = not verified)
 //   - parallel: Maximum number of concurrent downloads
+//   - checksum: Checksum type verified against the uploaded content ("" = size only)
 //
 // Returns:
 //   - *Result: Performance metrics including download speed and duration
 //   - error: Error if benchmark cannot be initialized (nil on success)
 //
 // Files must exist on the server (typically created by RunSmallFiles).
-// Individual file download errors are collected in Result.Errors.
-func RunDownloadSmallFiles(ctx context.Context, client *webdav.Client, basePath string, filePrefix string, count int, parallel int) (*Result, error) {
+// Individual file download errors are collected in Result.Errors; truncated
+// or corrupted downloads yield ErrSizeMismatch or ErrChecksumMismatch and are
+// counted in Result.Corrupted.
+func RunDownloadSmallFiles(ctx context.Context, client *webdav.Client, basePath string, filePrefix string, count int, size int64, parallel int, checksum string) (*Result, error) {
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, parallel)
 	errChan := make(chan error, count)
 	bytesChan := make(chan int64, count)
+	ctx, rec := withTimings(ctx)
+	for i := 0; i < count; i++ {
+		ContentChecksum(checksum, FileSeed(fmt.Sprintf("%s/%s%d.bin", basePath, filePrefix, i)), size) // Usually cached from the upload
+	}
+
+	var endMu sync.Mutex
+	var end time.Time // Last body fully received
 
 	start := time.Now()
 
@@ -271,10 +375,16 @@ func RunDownloadSmallFiles(ctx context.Context, client *webdav.Client, basePath
 			}
 			defer rc.Close()
 
-			// Read and discard
-			written, errCopy := io.Copy(io.Discard, rc)
+			// Read, verify and discard
+			written, received, errCopy := verifyDownload(rc, filename, size, checksum)
+			endMu.Lock()
+			if received.After(end) {
+				end = received
+			}
+			endMu.Unlock()
 			if errCopy != nil {
 				client.LogFunc(fmt.Sprintf("Download stream error: %v", errCopy))
+				errChan <- errCopy
 			}
 			client.LogFunc(fmt.Sprintf("DEBUG: Downloaded %d bytes from %s", written, filename))
 			
@@ -297,21 +407,27 @@ func RunDownloadSmallFiles(ctx context.Context, client *webdav.Client, basePath
 		totalBytes += b
 	}
 
+	// Until the last body was received, verifying it is not part of the transfer
 	duration := time.Since(start)
+	if !end.IsZero() {
+		duration = end.Sub(start)
+	}
 
 	var mbps float64
 	if duration.Seconds() > 0 {
 		mbps = float64(totalBytes) / 1024 / 1024 / duration.Seconds()
 	}
 
-	return &Result{
+	res := &Result{
 		Scenario:  "Small Files Download",
 		Files:     count,
 		TotalSize: totalBytes,
 		Duration:  duration,
 		SpeedMBps: mbps,
 		Errors:    errs,
-	}, nil
+	}
+	res.countCorrupted()
+	return res.setTimings(rec, "GET"), nil
 }
 
 // RunDownloadLargeFile performs a single large file download benchmark.
@@ -320,6 +436,9 @@ func RunDownloadSmallFiles(ctx context.Context, client *webdav.Client, basePath
 //   - ctx: Context for cancellation
 //   - client: WebDAV client configured for target server
 //   - basePath: Remote directory path containing the test file
+//   - fileName: Name of the test file inside basePath
+//   - size: Uploaded size of the file, verified after download (0 = not verified)
+//   - checksum: Checksum type verified against the uploaded content ("" = size only)
 //
 // Returns:
 //   - *Result: Performance metrics including download speed and duration
@@ -327,38 +446,47 @@ func RunDownloadSmallFiles(ctx context.Context, client *webdav.Client, basePath
 //
 // The file must exist on the server (typically created by RunLargeFile).
 // This function uses streaming to avoid loading the entire file into memory.
-func RunDownloadLargeFile(ctx context.Context, client *webdav.Client, basePath string) (*Result, error) {
-	filename := fmt.Sprintf("%s/test_large.bin", basePath)
+func RunDownloadLargeFile(ctx context.Context, client *webdav.Client, basePath string, fileName string, size int64, checksum string) (*Result, error) {
+	filename := fmt.Sprintf("%s/%s", basePath, fileName)
+	ctx, rec := withTimings(ctx)
+	ContentChecksum(checksum, FileSeed(filename), size) // Usually cached from the upload
 
 	start := time.Now()
 	rc, err := client.Download(ctx, filename)
 
 	var totalBytes int64
 	var errs []error
+	var received time.Time
 
 	if err != nil {
 		errs = append(errs, err)
 	} else {
-		defer rc.Close()
-		totalBytes, err = io.Copy(io.Discard, rc)
+		totalBytes, received, err = verifyDownload(rc, filename, size, checksum)
 		if err != nil {
 			errs = append(errs, err)
 		}
+		rc.Close() // Close before collecting timings so an aborted stream is recorded too
 	}
 
+	// Until the body was received, verifying it is not part of the transfer
 	duration := time.Since(start)
+	if !received.IsZero() {
+		duration = received.Sub(start)
+	}
 
 	var mbps float64
 	if duration.Seconds() > 0 {
 		mbps = float64(totalBytes) / 1024 / 1024 / duration.Seconds()
 	}
 
-	return &Result{
+	res := &Result{
 		Scenario:  "Large File Download",
 		Files:     1,
 		TotalSize: totalBytes,
 		Duration:  duration,
 		SpeedMBps: mbps,
 		Errors:    errs,
-	}, nil
+	}
+	res.countCorrupted()
+	return res.setTimings(rec, "GET"), nil
 }
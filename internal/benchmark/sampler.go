@@ -0,0 +1,178 @@
+package benchmark
+
+import (
+	"sync"
+	"time"
+
+	"nextcloud-perf/internal/webdav"
+)
+
+// Operations of the sustained workloads (soak and load tests).
+const (
+	opUpload = iota
+	opDownload
+	opList
+)
+
+// maxRunErrors limits the errors kept in the results of sustained workloads.
+const maxRunErrors = 10
+
+// IntervalSample aggregates the operations finished in one sampling interval
+// of a soak or load test.
+type IntervalSample struct {
+	Offset       time.Duration `json:"offset"` // Start of the interval relative to the start of the run
+	Length       time.Duration `json:"length"`
+	Users        int           `json:"users,omitempty"` // Peak of the active virtual users in the interval (load tests)
+	Ops          int           `json:"ops"`
+	Errors       int           `json:"errors"`
+	UploadMBps   float64       `json:"upload_mbps"`
+	DownloadMBps float64       `json:"download_mbps"`
+	P50          time.Duration `json:"p50"` // Latency of all HTTP requests in the interval
+	P95          time.Duration `json:"p95"`
+}
+
+// ThroughputMBps returns the combined upload and download throughput.
+func (s IntervalSample) ThroughputMBps() float64 {
+	return s.UploadMBps + s.DownloadMBps
+}
+
+// ErrorRate returns the failed operations in percent.
+func (s IntervalSample) ErrorRate() float64 {
+	if s.Ops == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Ops) * 100
+}
+
+// opWindow counts the operations of the current interval.
+type opWindow struct {
+	mu        sync.Mutex
+	ops       int
+	errors    int
+	upBytes   int64
+	downBytes int64
+	errs      []error // The first errors of the whole run
+}
+
+func (w *opWindow) add(op int, n int64, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ops++
+	if err != nil {
+		w.errors++
+		if len(w.errs) < maxRunErrors {
+			w.errs = append(w.errs, err)
+		}
+		return
+	}
+	if op == opUpload {
+		w.upBytes += n
+	} else {
+		w.downBytes += n
+	}
+}
+
+// take returns and resets the counters of the interval.
+func (w *opWindow) take() (ops, errors int, upBytes, downBytes int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ops, errors, upBytes, downBytes = w.ops, w.errors, w.upBytes, w.downBytes
+	w.ops, w.errors, w.upBytes, w.downBytes = 0, 0, 0, 0
+	return
+}
+
+// firstErrors returns the first errors of the run.
+func (w *opWindow) firstErrors() []error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.errs
+}
+
+// activeUsers tracks the number of running virtual users and its peak per interval.
+type activeUsers struct {
+	mu     sync.Mutex
+	active int
+	peak   int
+}
+
+// add changes the active users by delta.
+func (a *activeUsers) add(delta int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.active += delta
+	a.peak = max(a.peak, a.active)
+}
+
+// take returns the peak of the interval and starts the next.
+func (a *activeUsers) take() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	peak := a.peak
+	a.peak = a.active
+	return peak
+}
+
+// sampler turns the operations counted in a window and the request timings
+// drained from the recorders into one IntervalSample per interval.
+type sampler struct {
+	start    time.Time
+	last     time.Time
+	win      *opWindow
+	drain    func() []webdav.RequestTiming
+	users    *activeUsers // Nil for a fixed worker pool
+	onSample func(IntervalSample)
+	samples  []IntervalSample
+}
+
+func newSampler(win *opWindow, drain func() []webdav.RequestTiming, onSample func(IntervalSample)) *sampler {
+	now := time.Now()
+	return &sampler{start: now, last: now, win: win, drain: drain, onSample: onSample}
+}
+
+func (s *sampler) flush(now time.Time) {
+	ops, errs, up, down := s.win.take()
+	timings := s.drain()
+	length := now.Sub(s.last)
+	sample := IntervalSample{
+		Offset:       s.last.Sub(s.start),
+		Length:       length,
+		Ops:          ops,
+		Errors:       errs,
+		UploadMBps:   float64(up) / 1024 / 1024 / length.Seconds(),
+		DownloadMBps: float64(down) / 1024 / 1024 / length.Seconds(),
+	}
+	if s.users != nil {
+		sample.Users = s.users.take()
+	}
+	if l := ComputeLatencyStats(timings, ""); l != nil {
+		sample.P50, sample.P95 = l.P50, l.P95
+	}
+	s.last = now
+	s.samples = append(s.samples, sample)
+	if s.onSample != nil {
+		s.onSample(sample)
+	}
+}
+
+// run takes a sample every interval until done is closed. Operations
+// finishing in a trailing remainder shorter than half an interval are dropped.
+func (s *sampler) run(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.flush(now)
+		case <-done:
+			if now := time.Now(); now.Sub(s.last) >= interval/2 {
+				s.flush(now)
+			}
+			return
+		}
+	}
+}
+
+// elapsed returns the time covered by the samples.
+func (s *sampler) elapsed() time.Duration {
+	return s.last.Sub(s.start)
+}
@@ -0,0 +1,144 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nextcloud-perf/internal/webdav"
+)
+
+// SoakOptions configures RunSoak.
+type SoakOptions struct {
+	Duration time.Duration // Total run time
+	Interval time.Duration // Sampling interval
+	FileSize int64         // Size of uploaded and downloaded files
+	Files    int           // Files cycled by uploads and downloads
+	Parallel int           // Concurrent workers
+
+	// Relative weights of the operations
+	Upload   int
+	Download int
+	List     int
+}
+
+// SoakResult contains the time series of a soak run.
+type SoakResult struct {
+	Setup    time.Duration // Time to upload the files read by downloads
+	Duration time.Duration // Time covered by Samples
+	Samples  []IntervalSample
+	Errors   []error // The first errors of the run
+}
+
+// RunSoak loops a mixed workload of uploads, downloads and PROPFIND listings
+// below basePath/soak for opts.Duration and samples throughput, latency and
+// errors every opts.Interval. onSample, if set, is called with each sample as
+// soon as its interval ends.
+//
+// Downloads read files uploaded once before the run, uploads overwrite a
+// separate set of files, so that reads never wait for a file lock. Operations
+// finishing in a trailing remainder shorter than half an interval are not sampled.
+func RunSoak(ctx context.Context, client *webdav.Client, basePath string, opts SoakOptions, onSample func(IntervalSample)) (*SoakResult, error) {
+	if opts.Duration <= 0 || opts.Interval <= 0 || opts.FileSize < 0 || opts.Files <= 0 || opts.Parallel <= 0 ||
+		opts.Upload < 0 || opts.Download < 0 || opts.List < 0 || opts.Upload+opts.Download+opts.List == 0 {
+		return nil, fmt.Errorf("invalid parameters: %+v", opts)
+	}
+
+	dir := basePath + "/soak"
+	if err := client.CreateDirectory(ctx, dir); err != nil {
+		return nil, err
+	}
+	readName := func(i int) string { return fmt.Sprintf("%s/read%03d.bin", dir, i) }
+	writeName := func(i int) string { return fmt.Sprintf("%s/write%03d.bin", dir, i) }
+
+	res := &SoakResult{}
+	setupStart := time.Now()
+	if opts.Download > 0 {
+		if err := soakSeed(ctx, client, readName, opts); err != nil {
+			return nil, fmt.Errorf("failed to upload soak files: %w", err)
+		}
+	}
+	res.Setup = time.Since(setupStart)
+
+	var mix []int
+	for op, weight := range []int{opts.Upload, opts.Download, opts.List} {
+		for i := 0; i < weight; i++ {
+			mix = append(mix, op)
+		}
+	}
+
+	win := &opWindow{}
+	tctx, rec := withTimings(ctx)
+	smp := newSampler(win, rec.Drain, onSample)
+	deadline := smp.start.Add(opts.Duration)
+	var next atomic.Int64
+
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Parallel; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// Each worker starts at a different position of the mix
+			for i := w; time.Now().Before(deadline) && ctx.Err() == nil; i++ {
+				op := mix[i%len(mix)]
+				file := int(next.Add(1)) % opts.Files
+				var n int64
+				var err error
+				switch op {
+				case opUpload:
+					_, err = client.UploadSimple(tctx, writeName(file), &ZeroReader{Limit: opts.FileSize}, opts.FileSize)
+					n = opts.FileSize
+				case opDownload:
+					n, err = soakDownloadFile(tctx, client, readName(file))
+				default:
+					_, err = client.Propfind(tctx, dir, webdav.DepthOne, nil)
+				}
+				win.add(op, n, err)
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	smp.run(opts.Interval, done)
+	res.Samples = smp.samples
+	res.Duration = smp.elapsed()
+	res.Errors = win.firstErrors()
+	return res, nil
+}
+
+// soakSeed uploads the files read by the download operations.
+func soakSeed(ctx context.Context, client *webdav.Client, name func(int) string, opts SoakOptions) error {
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	sem := make(chan struct{}, opts.Parallel)
+	for i := 0; i < opts.Files; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if _, err := client.UploadSimple(ctx, name(idx), &ZeroReader{Limit: opts.FileSize}, opts.FileSize); err != nil {
+				once.Do(func() { firstErr = err })
+			}
+		}(i)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func soakDownloadFile(ctx context.Context, client *webdav.Client, name string) (int64, error) {
+	rc, err := client.Download(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	return io.Copy(io.Discard, rc)
+}
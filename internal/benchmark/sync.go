@@ -0,0 +1,446 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nextcloud-perf/internal/webdav"
+)
+
+// Phases of a sync cycle in the order the desktop client runs them.
+const (
+	PhaseDiscovery = "discovery"
+	PhaseDownload  = "download"
+	PhaseUpload    = "upload"
+	PhaseConflict  = "conflict"
+	PhaseRename    = "rename"
+	PhaseDelete    = "delete"
+)
+
+// syncIdlePolls is the number of change checks averaged for SyncResult.Idle.
+const syncIdlePolls = 3
+
+// SyncOptions configures RunSync.
+type SyncOptions struct {
+	Dirs        int // Folders of the synced tree
+	FilesPerDir int
+	FileSize    int64
+	Changes     int // Files changed per cycle, on the server and locally each
+	Renames     int // Files renamed per cycle
+	Deletes     int // Files deleted per cycle
+	Cycles      int // Incremental sync cycles
+	Parallel    int // Concurrent requests, like the client's propagation jobs
+}
+
+// SyncPhase is one step of a sync cycle.
+type SyncPhase struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Requests int           `json:"requests"`
+	Items    int           `json:"items"` // Folders listed or files propagated
+	Errors   int           `json:"errors,omitempty"`
+}
+
+// SyncCycle is one run of the sync client, from the change check until all
+// changes are propagated.
+type SyncCycle struct {
+	Duration  time.Duration `json:"duration"` // Sum of the phases
+	Requests  int           `json:"requests"`
+	Conflicts int           `json:"conflicts,omitempty"` // Uploads rejected because of a concurrent server change
+	Phases    []SyncPhase   `json:"phases"`
+}
+
+// SyncResult contains the metrics of a sync cycle replay.
+type SyncResult struct {
+	Setup   time.Duration // Time to create the tree
+	Initial SyncCycle     // First sync of a new client: full discovery and download
+	Idle    time.Duration // Mean change check without changes
+	Cycles  []SyncCycle   // Incremental cycles
+	Latency *LatencyStats // All requests of the incremental cycles
+	Errors  []error       // First errors of setup and cycles
+}
+
+// syncClient is the local state of the simulated desktop client: the ETags
+// of all files and folders as of the last sync, like its sync journal.
+type syncClient struct {
+	client   *webdav.Client
+	root     string
+	parallel int
+	entries  map[string]syncEntry // By path relative to the user's files
+}
+
+type syncEntry struct {
+	etag string
+	dir  bool
+}
+
+// syncFile is a file found changed by discovery.
+type syncFile struct {
+	path, etag string
+}
+
+// syncPlan lists the files a cycle changes. Remote changes are made before
+// the cycle by another client and only show up in discovery.
+type syncPlan struct {
+	cycle    int
+	local    []string // Changed locally and uploaded
+	renames  []string
+	deletes  []string
+	conflict string // Changed on the server while the client uploads its own change
+	size     int64
+}
+
+// RunSync replays the requests of the desktop sync client on a folder tree
+// of opts.Dirs folders with opts.FilesPerDir files each below basePath/sync.
+//
+// A new client first syncs the whole tree: a PROPFIND walk of all folders and
+// a download of all files. Each following cycle is what the client does
+// after another client changed files and the user changed files locally:
+//
+//   - discovery: a depth 0 PROPFIND of the root; if its ETag changed, depth 1
+//     listings of all folders whose ETag changed, down to the changed files
+//   - download: GET of the files changed on the server
+//   - upload: PUT of the local changes with If-Match on the known ETag
+//   - conflict: a PUT rejected with 412 because the file changed on the
+//     server meanwhile, followed by a GET of the server version and the
+//     upload of the local version as conflict copy
+//   - rename and delete: MOVE and DELETE of local renames and deletions
+//
+// The sync cycle time is the sum of the phases; the remote changes between
+// the cycles are not timed.
+func RunSync(ctx context.Context, client *webdav.Client, basePath string, opts SyncOptions) (*SyncResult, error) {
+	files := opts.Dirs * opts.FilesPerDir
+	perCycle := 2*opts.Changes + opts.Renames + opts.Deletes + 1
+	if opts.Dirs <= 0 || opts.FilesPerDir <= 0 || opts.FileSize < 0 || opts.Parallel <= 0 || opts.Cycles < 0 ||
+		opts.Changes < 0 || opts.Renames < 0 || opts.Deletes < 0 || files-opts.Cycles*opts.Deletes < perCycle {
+		return nil, fmt.Errorf("invalid parameters: dirs=%d, files=%d, size=%d, changes=%d, renames=%d, deletes=%d, cycles=%d, parallel=%d",
+			opts.Dirs, opts.FilesPerDir, opts.FileSize, opts.Changes, opts.Renames, opts.Deletes, opts.Cycles, opts.Parallel)
+	}
+
+	root := strings.Trim(basePath, "/") + "/sync"
+	res := &SyncResult{}
+	start := time.Now()
+	if err := client.CreateDirectory(ctx, root); err != nil {
+		return nil, err
+	}
+	res.Errors = buildTree(ctx, client, root, opts.Dirs, opts.FilesPerDir, opts.FileSize, opts.Parallel)
+	res.Setup = time.Since(start)
+	if len(res.Errors) > maxRunErrors {
+		res.Errors = res.Errors[:maxRunErrors]
+	}
+
+	s := &syncClient{client: client, root: root, parallel: opts.Parallel, entries: map[string]syncEntry{}}
+	tctx, rec := withTimings(ctx)
+	var errs []error
+	res.Initial, _, errs = s.cycle(ctx, tctx, rec, nil)
+	res.addErrors(errs)
+	if len(s.files()) < perCycle {
+		return res, fmt.Errorf("initial sync found %d of %d files", len(s.files()), files)
+	}
+
+	for i := 0; i < syncIdlePolls; i++ {
+		t := time.Now()
+		if _, _, err := s.discover(tctx); err != nil {
+			res.addErrors([]error{err})
+		}
+		res.Idle += time.Since(t)
+	}
+	res.Idle /= syncIdlePolls
+	rec.Drain()
+
+	rng := rand.New(rand.NewPCG(uint64(files), uint64(opts.Cycles)))
+	var timings []webdav.RequestTiming
+	for c := 1; c <= opts.Cycles && ctx.Err() == nil; c++ {
+		candidates := s.files()
+		pick := rng.Perm(len(candidates))[:perCycle]
+		take := func(n int) []string {
+			var out []string
+			for _, i := range pick[:n] {
+				out = append(out, candidates[i])
+			}
+			pick = pick[n:]
+			return out
+		}
+		res.addErrors(s.changeRemote(ctx, take(opts.Changes), opts.FileSize))
+		plan := &syncPlan{cycle: c, local: take(opts.Changes), renames: take(opts.Renames), deletes: take(opts.Deletes), conflict: take(1)[0], size: opts.FileSize}
+
+		cycle, t, errs := s.cycle(ctx, tctx, rec, plan)
+		res.Cycles = append(res.Cycles, cycle)
+		res.addErrors(errs)
+		timings = append(timings, t...)
+	}
+	res.Latency = ComputeLatencyStats(timings, "")
+	return res, nil
+}
+
+func (r *SyncResult) addErrors(errs []error) {
+	for _, err := range errs {
+		if len(r.Errors) < maxRunErrors {
+			r.Errors = append(r.Errors, err)
+		}
+	}
+}
+
+// cycle runs one sync with the recording context tctx: discovery and download
+// of the server changes and, with a plan, the propagation of local changes.
+// Untimed actions of the other client use ctx.
+func (s *syncClient) cycle(ctx, tctx context.Context, rec *webdav.TimingRecorder, plan *syncPlan) (SyncCycle, []webdav.RequestTiming, []error) {
+	var out SyncCycle
+	var timings, all []webdav.RequestTiming
+	var errs []error
+	phase := func(name string, items int, run func() []error) {
+		start := time.Now()
+		phaseErrs := run()
+		p := SyncPhase{Name: name, Duration: time.Since(start), Items: items, Errors: len(phaseErrs)}
+		timings = rec.Drain()
+		p.Requests = len(timings)
+		all = append(all, timings...)
+		out.Phases = append(out.Phases, p)
+		out.Duration += p.Duration
+		out.Requests += p.Requests
+		errs = append(errs, phaseErrs...)
+	}
+
+	var changed []syncFile
+	var folders int
+	phase(PhaseDiscovery, 0, func() []error {
+		var err error
+		changed, folders, err = s.discover(tctx)
+		if err != nil {
+			return []error{err}
+		}
+		return nil
+	})
+	out.Phases[0].Items = folders
+	phase(PhaseDownload, len(changed), func() []error { return s.download(tctx, changed) })
+	if plan == nil {
+		return out, all, errs
+	}
+
+	phase(PhaseUpload, len(plan.local), func() []error { return s.upload(tctx, plan.local, plan.size) })
+	// The other client changes the file after discovery, so the local
+	// client only learns about it from the rejected upload
+	serverETag, err := s.client.UploadIfMatch(ctx, plan.conflict, &ZeroReader{Limit: plan.size}, plan.size, s.entries[plan.conflict].etag)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	phase(PhaseConflict, 1, func() []error {
+		conflict, err := s.resolveConflict(tctx, plan, serverETag)
+		if conflict {
+			out.Conflicts++
+		}
+		if err != nil {
+			return []error{err}
+		}
+		return nil
+	})
+	phase(PhaseRename, len(plan.renames), func() []error { return s.rename(tctx, plan.renames, plan.cycle) })
+	phase(PhaseDelete, len(plan.deletes), func() []error { return s.remove(tctx, plan.deletes) })
+	return out, all, errs
+}
+
+// discover checks the root for changes and walks down all folders whose ETag
+// changed. Returns the changed files and the number of folders listed.
+func (s *syncClient) discover(ctx context.Context) ([]syncFile, int, error) {
+	top, err := s.client.Propfind(ctx, s.root, webdav.DepthZero, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(top) > 0 && top[0].ETag == s.entries[s.root].etag {
+		return nil, 0, nil
+	}
+
+	var changed []syncFile
+	folders := 0
+	queue := []string{s.root}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		list, err := s.client.Propfind(ctx, dir, webdav.DepthOne, nil)
+		if err != nil {
+			return changed, folders, err
+		}
+		folders++
+		seen := map[string]bool{}
+		for _, r := range list {
+			p := s.relPath(r.Href)
+			if p == dir {
+				s.entries[dir] = syncEntry{etag: r.ETag, dir: true}
+				continue
+			}
+			seen[p] = true
+			known, ok := s.entries[p]
+			switch {
+			case ok && known.etag == r.ETag:
+			case r.IsCollection:
+				queue = append(queue, p)
+			default:
+				// Stored once downloaded
+				changed = append(changed, syncFile{path: p, etag: r.ETag})
+			}
+		}
+		// Removed on the server
+		for p := range s.entries {
+			if path.Dir(p) == dir && !seen[p] {
+				delete(s.entries, p)
+			}
+		}
+	}
+	return changed, folders, nil
+}
+
+// relPath turns a PROPFIND href into a path relative to the user's files.
+func (s *syncClient) relPath(href string) string {
+	prefix := "/remote.php/dav/files/" + s.client.Username + "/"
+	if i := strings.Index(href, prefix); i >= 0 {
+		href = href[i+len(prefix):]
+	}
+	return strings.Trim(href, "/")
+}
+
+// files returns the known files in a stable order.
+func (s *syncClient) files() []string {
+	var files []string
+	for p, e := range s.entries {
+		if !e.dir {
+			files = append(files, p)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (s *syncClient) download(ctx context.Context, files []syncFile) []error {
+	ok := make([]bool, len(files))
+	errs := forEach(len(files), s.parallel, func(i int) error {
+		_, err := soakDownloadFile(ctx, s.client, files[i].path)
+		ok[i] = err == nil
+		return err
+	})
+	for i, f := range files {
+		if ok[i] {
+			s.entries[f.path] = syncEntry{etag: f.etag}
+		}
+	}
+	return errs
+}
+
+func (s *syncClient) upload(ctx context.Context, files []string, size int64) []error {
+	etags := make([]string, len(files))
+	errs := forEach(len(files), s.parallel, func(i int) error {
+		var err error
+		etags[i], err = s.client.UploadIfMatch(ctx, files[i], &ZeroReader{Limit: size}, size, s.entries[files[i]].etag)
+		return err
+	})
+	for i, p := range files {
+		if etags[i] != "" {
+			s.entries[p] = syncEntry{etag: etags[i]}
+		}
+	}
+	return errs
+}
+
+// changeRemote changes files like another client of the same user would.
+func (s *syncClient) changeRemote(ctx context.Context, files []string, size int64) []error {
+	return forEach(len(files), s.parallel, func(i int) error {
+		_, err := s.client.UploadIfMatch(ctx, files[i], &ZeroReader{Limit: size}, size, s.entries[files[i]].etag)
+		return err
+	})
+}
+
+// resolveConflict uploads the local change of plan.conflict with the stale
+// ETag. Once the server rejects it, the client downloads the server version
+// and uploads its own as conflict copy. Reports whether the conflict was
+// detected.
+func (s *syncClient) resolveConflict(ctx context.Context, plan *syncPlan, serverETag string) (bool, error) {
+	p := plan.conflict
+	etag, err := s.client.UploadIfMatch(ctx, p, &ZeroReader{Limit: plan.size}, plan.size, s.entries[p].etag)
+	if err == nil {
+		s.entries[p] = syncEntry{etag: etag}
+		return false, fmt.Errorf("upload of %s with a stale ETag was accepted, the server ignores If-Match", p)
+	}
+	if !errors.Is(err, webdav.ErrPreconditionFailed) {
+		return false, err
+	}
+	if _, err := soakDownloadFile(ctx, s.client, p); err != nil {
+		return true, err
+	}
+	// The GET response carries the ETag of the server version
+	s.entries[p] = syncEntry{etag: serverETag}
+
+	ext := path.Ext(p)
+	copyPath := fmt.Sprintf("%s_conflict-%d%s", strings.TrimSuffix(p, ext), plan.cycle, ext)
+	etag, err = s.client.UploadIfMatch(ctx, copyPath, &ZeroReader{Limit: plan.size}, plan.size, "")
+	if err != nil {
+		return true, err
+	}
+	s.entries[copyPath] = syncEntry{etag: etag}
+	return true, nil
+}
+
+func (s *syncClient) rename(ctx context.Context, files []string, cycle int) []error {
+	targets := make([]string, len(files))
+	ok := make([]bool, len(files))
+	for i, p := range files {
+		ext := path.Ext(p)
+		targets[i] = fmt.Sprintf("%s_renamed-%d%s", strings.TrimSuffix(p, ext), cycle, ext)
+	}
+	errs := forEach(len(files), s.parallel, func(i int) error {
+		err := s.client.Move(ctx, files[i], targets[i], false)
+		ok[i] = err == nil
+		return err
+	})
+	for i, p := range files {
+		if ok[i] {
+			s.entries[targets[i]] = s.entries[p]
+			delete(s.entries, p)
+		}
+	}
+	return errs
+}
+
+func (s *syncClient) remove(ctx context.Context, files []string) []error {
+	ok := make([]bool, len(files))
+	errs := forEach(len(files), s.parallel, func(i int) error {
+		err := s.client.Delete(ctx, files[i])
+		ok[i] = err == nil
+		return err
+	})
+	for i, p := range files {
+		if ok[i] {
+			delete(s.entries, p)
+		}
+	}
+	return errs
+}
+
+// forEach runs task for 0..n-1 with at most parallel tasks at once and
+// returns their errors.
+func forEach(n, parallel int, task func(i int) error) []error {
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := task(idx); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
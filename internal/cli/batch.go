@@ -0,0 +1,284 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"nextcloud-perf/internal/config"
+	"nextcloud-perf/internal/report"
+	"nextcloud-perf/internal/targets"
+	"nextcloud-perf/internal/ui"
+	"nextcloud-perf/internal/workflow"
+)
+
+func batchCommand(args []string) int {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	outDir := fs.String("out-dir", "batch-"+time.Now().Format("20060102-150405"), "Directory for the summary and the per-target reports")
+	concurrency := fs.Int("concurrency", 0, "Targets benchmarked at once (default: inventory setting or 1)")
+	rankBy := fs.String("rank-by", report.RankByScore, "Rank by the transfer score or a metric key, e.g. network.ping_avg_ms")
+	thresholdsFile := fs.String("thresholds", "", "YAML/JSON thresholds file applied to every target; violations exit with code 4")
+	timeout := fs.Duration("timeout", 0, "Abort each target after this duration (0 = no limit)")
+	keepHistory := fs.Bool("history", true, "Store completed runs in the local history shown by the web UI")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nextcloud-perf batch [flags] <inventory.yaml>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return ExitUsage
+	}
+	if *rankBy != report.RankByScore && !report.IsMetricKey(*rankBy) {
+		fmt.Fprintf(os.Stderr, "Error: unknown --rank-by %q, use %q or a metric key (* is a scenario, family, endpoint or protocol name):\n  %s\n",
+			*rankBy, report.RankByScore, strings.Join(report.MetricKeys, "\n  "))
+		return ExitUsage
+	}
+
+	inv, err := config.LoadInventory(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+	if *concurrency > 0 {
+		inv.Concurrency = *concurrency
+	}
+	runs, err := batchOptions(inv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+	if *thresholdsFile != "" {
+		thresholds, err := config.LoadThresholds(*thresholdsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitUsage
+		}
+		for i := range runs {
+			runs[i].Thresholds = thresholds
+		}
+	}
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitRuntime
+	}
+
+	ctx, cancel := signalContext(0)
+	defer cancel()
+
+	// Bounded concurrency: at most inv.Concurrency targets run at once
+	reporters := make([]*TerminalReporter, len(runs))
+	sem := make(chan struct{}, inv.Concurrency)
+	var wg sync.WaitGroup
+	for i := range runs {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		reporters[i] = NewTerminalReporter(os.Stderr)
+		reporters[i].Prefix = fmt.Sprintf("[%s] ", inv.Targets[i].Name)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			runCtx, cancelRun := ctx, context.CancelFunc(func() {})
+			if *timeout > 0 {
+				runCtx, cancelRun = context.WithTimeout(ctx, *timeout)
+			}
+			defer cancelRun()
+			workflow.Run(runCtx, runs[i], reporters[i])
+		}(i)
+	}
+	wg.Wait()
+
+	entries := make([]report.BatchEntry, 0, len(runs))
+	reports := make([]report.ReportData, 0, len(runs))
+	code := ExitOK
+	for i, t := range inv.Targets {
+		rpt := report.ReportData{TargetURL: t.URL, Profile: t.Profile, Error: "not run"}
+		if reporters[i] != nil {
+			rpt = reporters[i].Result()
+		}
+		if !rpt.Completed && rpt.Error == "" {
+			rpt.Error = "aborted"
+		}
+		e := report.NewBatchEntry(t.Name, rpt)
+		if reporters[i] != nil {
+			if err := writeTargetReports(*outDir, &e, reporters[i]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return ExitRuntime
+			}
+			if *keepHistory && rpt.Completed {
+				saveHistory(reporters[i])
+			}
+		}
+		switch {
+		case rpt.Error != "":
+			code = ExitFailure
+		case e.Passed != nil && !*e.Passed && code == ExitOK:
+			code = ExitVerdict
+		}
+		entries = append(entries, e)
+		reports = append(reports, rpt)
+	}
+
+	summary := report.RankBatch(entries, reports, *rankBy)
+	if err := writeBatchSummary(*outDir, summary); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitRuntime
+	}
+	printBatchSummary(os.Stdout, summary)
+	fmt.Fprintf(os.Stderr, "Batch summary written to %s\n", filepath.Join(*outDir, "index.html"))
+	return code
+}
+
+// batchOptions resolves credentials and profiles of all inventory targets
+// up front, so that a typo fails the batch before anything is run.
+func batchOptions(inv *config.Inventory) ([]workflow.BenchmarkOptions, error) {
+	runs := make([]workflow.BenchmarkOptions, len(inv.Targets))
+	passphrase := cliPassphrase()
+	for i := range inv.Targets {
+		t := &inv.Targets[i]
+		pass := t.Pass
+		if t.Saved != "" {
+			if err := applySavedTarget(t, passphrase); err != nil {
+				return nil, fmt.Errorf("target %q: %w", t.Name, err)
+			}
+			if t.Profile == "" {
+				t.Profile = inv.Profile
+			}
+			pass = t.Pass
+		}
+		switch {
+		case t.PassFile != "":
+			p, err := readSecret(t.PassFile)
+			if err != nil {
+				return nil, fmt.Errorf("target %q: failed to read password file: %w", t.Name, err)
+			}
+			pass = p
+		case t.PassEnv != "":
+			pass = os.Getenv(t.PassEnv)
+			if pass == "" {
+				return nil, fmt.Errorf("target %q: environment variable %s is empty", t.Name, t.PassEnv)
+			}
+		}
+
+		req := ui.RunRequest{URL: t.URL, User: t.User, Pass: pass}
+		if err := req.Validate(); err != nil {
+			return nil, fmt.Errorf("target %q: %w", t.Name, err)
+		}
+		profile, err := config.ResolveProfile(t.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("target %q: %w", t.Name, err)
+		}
+		runs[i] = workflow.BenchmarkOptions{URL: t.URL, User: t.User, Pass: pass, Profile: profile}
+	}
+	return runs, nil
+}
+
+// applySavedTarget fills URL, user, profile and the password of an inventory
+// target from its saved target, unless the inventory sets them. The stored
+// password is refused if the inventory sets a different URL.
+func applySavedTarget(t *config.InventoryTarget, passphrase targets.PassphraseFunc) error {
+	store, err := targets.Open(targets.DefaultDir())
+	if err != nil {
+		return err
+	}
+	saved, err := store.Get(t.Saved)
+	if err != nil {
+		return err
+	}
+	if t.URL == "" {
+		t.URL = saved.URL
+	}
+	if t.User == "" {
+		t.User = saved.User
+	}
+	if t.Profile == "" {
+		t.Profile = saved.Profile
+	}
+	if t.Pass == "" && t.PassFile == "" && t.PassEnv == "" {
+		if err := saved.CheckURL(t.URL); err != nil {
+			return err
+		}
+		if t.Pass, err = store.Password(t.Saved, passphrase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTargetReports writes the HTML and JSON report of one target and
+// records their file names in e.
+func writeTargetReports(dir string, e *report.BatchEntry, reporter *TerminalReporter) error {
+	if html := reporter.HTML(); html != nil {
+		e.ReportHTML = e.Name + ".html"
+		if err := os.WriteFile(filepath.Join(dir, e.ReportHTML), html, 0o644); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	b, err := report.GenerateJSON(reporter.Result())
+	if err != nil {
+		return err
+	}
+	e.ReportJSON = e.Name + ".json"
+	if err := os.WriteFile(filepath.Join(dir, e.ReportJSON), b, 0o644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+func writeBatchSummary(dir string, s *report.BatchSummary) error {
+	html, err := report.GenerateBatchHTML(s)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), html, 0o644); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "summary.json"), b, 0o644); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
+
+// printBatchSummary writes the ranking as an aligned table.
+func printBatchSummary(w io.Writer, s *report.BatchSummary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "RANK\tTARGET\tSERVER\tPROFILE\t%s\tRESULT\t\n", s.RankLabel)
+	for _, e := range s.Entries {
+		rank, score, result := "-", "--", "ok"
+		if e.Rank > 0 {
+			rank = fmt.Sprint(e.Rank)
+		}
+		if e.Score != nil {
+			score = fmt.Sprintf("%.2f %s", *e.Score, s.RankUnit)
+		}
+		switch {
+		case e.Error != "":
+			result = "error: " + e.Error
+		case e.Passed != nil && !*e.Passed:
+			result = "thresholds violated"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t\n", rank, e.Name, e.ServerVer, e.Profile, score, result)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"nextcloud-perf/internal/config"
+	"nextcloud-perf/internal/report"
+)
+
+func checkCommand(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	thresholdsFile := fs.String("thresholds", "", "YAML/JSON thresholds file (required)")
+	jsonOut := fs.Bool("json", false, "Print the verdict as JSON instead of a table")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nextcloud-perf check --thresholds <file> [flags] <report.json>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if fs.NArg() != 1 || *thresholdsFile == "" {
+		fs.Usage()
+		return ExitUsage
+	}
+
+	thresholds, err := config.LoadThresholds(*thresholdsFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+	rpt, err := loadReport(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitRuntime
+	}
+	verdict := report.Evaluate(thresholds, rpt)
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(verdict); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitRuntime
+		}
+	} else {
+		printVerdict(os.Stdout, verdict)
+	}
+	if !verdict.Passed {
+		return ExitVerdict
+	}
+	return ExitOK
+}
+
+// printVerdict writes one line per rule result and a summary line.
+func printVerdict(w io.Writer, v *report.Verdict) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESULT\tRULE\tMETRIC\tDETAILS\t")
+	for _, r := range v.Results {
+		result := "PASS"
+		if !r.Passed {
+			result = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", result, r.Rule, r.Label, r.Message)
+	}
+	tw.Flush()
+
+	if v.Passed {
+		fmt.Fprintf(w, "Thresholds %q passed\n", v.Thresholds)
+	} else {
+		fmt.Fprintf(w, "Thresholds %q violated: %d of %d rules failed\n", v.Thresholds, len(v.Failed()), len(v.Results))
+	}
+}
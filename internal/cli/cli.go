@@ -0,0 +1,102 @@
+// Package cli implements the headless command-line interface of nextcloud-perf.
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Exit codes returned by Main.
+const (
+	ExitOK      = 0
+	ExitFailure = 1 // Benchmark ran but reported an error
+	ExitUsage   = 2 // Invalid command line
+	ExitRuntime = 3 // Local problem (files, permissions, ...)
+	ExitVerdict = 4 // Benchmark ran but violated the thresholds
+)
+
+// command is a single CLI sub-command.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+func commands() []command {
+	return []command{
+		{name: "run", summary: "Run the benchmark without the web UI", run: runCommand},
+		{name: "profiles", summary: "List available benchmark profiles", run: profilesCommand},
+		{name: "targets", summary: "Manage saved targets with credentials in the OS keyring", run: targetsCommand},
+		{name: "compare", summary: "Compare two JSON reports and show regressions", run: compareCommand},
+		{name: "check", summary: "Check a JSON report against a thresholds file", run: checkCommand},
+		{name: "load", summary: "Simulate many sync clients to find where the instance saturates", run: loadCommand},
+		{name: "batch", summary: "Benchmark all instances of an inventory file and rank them", run: batchCommand},
+		{name: "exporter", summary: "Re-run a profile periodically and serve Prometheus metrics", run: exporterCommand},
+		{name: "serve-fake", summary: "Serve a fake Nextcloud for demos and offline development", run: serveFakeCommand},
+	}
+}
+
+// IsCommand reports whether name is a known sub-command.
+func IsCommand(name string) bool {
+	if name == "help" || name == "-h" || name == "--help" {
+		return true
+	}
+	for _, c := range commands() {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Main dispatches args (without the program name) to the matching sub-command
+// and returns the process exit code.
+func Main(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return ExitUsage
+	}
+	for _, c := range commands() {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(os.Stdout)
+		return ExitOK
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	usage(os.Stderr)
+	return ExitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: nextcloud-perf [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a command the web UI is started on http://localhost:3000.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'nextcloud-perf <command> -h' for command flags.")
+}
+
+// readSecret reads a password from path ("-" means stdin).
+// A single trailing newline is stripped so that files created with echo work.
+func readSecret(path string) (string, error) {
+	var b []byte
+	var err error
+	if path == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nextcloud-perf/internal/report"
+	"nextcloud-perf/internal/targets"
+)
+
+func TestTargetOptionsPassFile(t *testing.T) {
+	passFile := filepath.Join(t.TempDir(), "pass.txt")
+	if err := os.WriteFile(passFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("failed to write pass file: %v", err)
+	}
+
+	target := targetFlags{url: "https://cloud.example.com", user: "admin", passFile: passFile}
+	opts, err := target.options()
+	if err != nil {
+		t.Fatalf("options failed: %v", err)
+	}
+	if opts.Pass != "s3cret" {
+		t.Errorf("Expected trimmed password, got %q", opts.Pass)
+	}
+
+	// Missing password must be rejected
+	t.Setenv(passwordEnv, "")
+	target = targetFlags{url: "https://cloud.example.com", user: "admin"}
+	if _, err := target.options(); err == nil {
+		t.Error("Expected error for missing password")
+	}
+}
+
+func TestTerminalReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewTerminalReporter(&buf)
+
+	r.Broadcast("hello")
+	r.SendResult(report.ReportData{TargetURL: "https://cloud.example.com"})
+	r.SaveReport([]byte("<html></html>"))
+
+	if !strings.Contains(buf.String(), "hello") {
+		t.Errorf("Expected progress output, got %q", buf.String())
+	}
+	if r.Result().TargetURL != "https://cloud.example.com" {
+		t.Errorf("Unexpected result: %+v", r.Result())
+	}
+	if string(r.HTML()) != "<html></html>" {
+		t.Errorf("Unexpected HTML: %s", r.HTML())
+	}
+}
+
+func TestPrintComparison(t *testing.T) {
+	base := report.ReportData{Scenarios: []report.ScenarioResult{{Name: "small", Label: "Small Files", Upload: &report.SpeedResult{SpeedMBps: 10}}}}
+	current := report.ReportData{Scenarios: []report.ScenarioResult{{Name: "small", Label: "Small Files", Upload: &report.SpeedResult{SpeedMBps: 7}}}}
+
+	var buf bytes.Buffer
+	printComparison(&buf, report.Compare(base, current), false)
+	out := buf.String()
+	for _, want := range []string{"Small Files upload", "10.00 MB/s", "7.00 MB/s", "-3.00", "-30.0%", "1 regression(s)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestParseFault(t *testing.T) {
+	f, err := parseFault("put:/remote.php/dav/uploads/*:503:2")
+	if err != nil {
+		t.Fatalf("parseFault failed: %v", err)
+	}
+	if f.Method != "PUT" || f.Path != "/remote.php/dav/uploads/*" || f.Status != 503 || f.Times != 2 {
+		t.Errorf("Unexpected fault: %+v", f)
+	}
+	if f, err = parseFault("*::reset"); err != nil || !f.Reset || f.Method != "" {
+		t.Errorf("Expected a reset fault for all requests, got %+v (%v)", f, err)
+	}
+	if f, err = parseFault("GET:*:truncate:1"); err != nil || !f.Truncate || f.Times != 1 {
+		t.Errorf("Expected a truncate fault, got %+v (%v)", f, err)
+	}
+	for _, bad := range []string{"PUT", "PUT:/x:teapot", "PUT:/x:999", "PUT:/x:503:-1"} {
+		if _, err := parseFault(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestTargetOptionsSaved(t *testing.T) {
+	t.Setenv("NEXTCLOUD_PERF_DATA_DIR", t.TempDir())
+	t.Setenv(targets.PassphraseEnv, "passphrase")
+	store, err := targets.Open(targets.DefaultDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := targets.Target{Name: "prod", URL: "https://cloud.example.com", User: "admin", Profile: "quick"}
+	if _, err := store.Save(saved, "s3cret", targets.StorageFile, targets.EnvPassphrase); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	var target targetFlags
+	target.register(fs)
+	profile := fs.String("profile", "standard", "")
+	if err := fs.Parse([]string{"--target", "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := target.options()
+	if err != nil {
+		t.Fatalf("options failed: %v", err)
+	}
+	if opts.URL != saved.URL || opts.User != "admin" || opts.Pass != "s3cret" {
+		t.Errorf("Expected the saved target, got %+v", opts)
+	}
+	if got := target.profile(fs, *profile); got != "quick" {
+		t.Errorf("Expected the saved profile, got %q", got)
+	}
+
+	// Explicit flags win over the saved target
+	fs.Parse([]string{"--profile", "thorough", "--user", "other"})
+	if opts, err = target.options(); err != nil || opts.User != "other" {
+		t.Errorf("Expected --user to override the saved user, got %+v (%v)", opts, err)
+	}
+	if got := target.profile(fs, *profile); got != "thorough" {
+		t.Errorf("Expected --profile to win, got %q", got)
+	}
+
+	// The stored password only goes to the saved URL
+	fs.Parse([]string{"--url", "https://cloud.example.com/"})
+	if opts, err = target.options(); err != nil || opts.Pass != "s3cret" {
+		t.Errorf("Expected the saved URL to be accepted, got %+v (%v)", opts, err)
+	}
+	fs.Parse([]string{"--url", "https://attacker.example.com"})
+	if _, err := target.options(); !errors.Is(err, targets.ErrURLMismatch) {
+		t.Errorf("Expected the stored password to be refused for another URL, got %v", err)
+	}
+	fs.Parse([]string{"--pass", "other"})
+	if opts, err = target.options(); err != nil || opts.URL != "https://attacker.example.com" || opts.Pass != "other" {
+		t.Errorf("Expected an explicit password to allow another URL, got %+v (%v)", opts, err)
+	}
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"nextcloud-perf/internal/report"
+)
+
+func compareCommand(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	htmlOut := fs.String("html", "", "Also write the comparison as HTML to this file")
+	jsonOut := fs.Bool("json", false, "Print the comparison as JSON instead of a table")
+	noColor := fs.Bool("no-color", false, "Disable colored output")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nextcloud-perf compare [flags] <base.json> <current.json>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return ExitUsage
+	}
+
+	base, err := loadReport(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitRuntime
+	}
+	current, err := loadReport(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitRuntime
+	}
+	cmp := report.Compare(base, current)
+
+	if *htmlOut != "" {
+		html, err := report.GenerateComparisonHTML(cmp)
+		if err == nil {
+			err = os.WriteFile(*htmlOut, html, 0o644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write %s: %v\n", *htmlOut, err)
+			return ExitRuntime
+		}
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(cmp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitRuntime
+		}
+		return ExitOK
+	}
+
+	printComparison(os.Stdout, cmp, !*noColor && useColor(os.Stdout))
+	return ExitOK
+}
+
+// loadReport reads a JSON report written by "run --json" or the UI download.
+func loadReport(path string) (report.ReportData, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return report.ReportData{}, err
+	}
+	rpt, err := report.ParseJSON(b)
+	if err != nil {
+		return report.ReportData{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return rpt, nil
+}
+
+// ANSI colors matching the report quality colors
+var ansiColors = map[string]string{
+	report.ColorGood: "\033[32m",
+	report.ColorWarn: "\033[33m",
+	report.ColorBad:  "\033[31m",
+	report.ColorNone: "\033[90m",
+}
+
+const ansiReset = "\033[0m"
+
+// useColor reports whether f is a terminal and NO_COLOR is not set.
+func useColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// printComparison writes the comparison as an aligned table.
+func printComparison(w io.Writer, cmp *report.Comparison, color bool) {
+	fmt.Fprintf(w, "Base:    %s  %s %s\n", cmp.Base.GeneratedAt.Format("2006-01-02 15:04:05"), cmp.Base.TargetURL, cmp.Base.ServerVer)
+	fmt.Fprintf(w, "Current: %s  %s %s\n\n", cmp.Current.GeneratedAt.Format("2006-01-02 15:04:05"), cmp.Current.TargetURL, cmp.Current.ServerVer)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METRIC\tBASE\tCURRENT\tDELTA\tCHANGE\t")
+	for _, d := range cmp.Deltas {
+		change := d.Status
+		if d.Base != nil && d.Current != nil && *d.Base != 0 {
+			change = fmt.Sprintf("%+.1f%%", d.Percent)
+		}
+		delta := ""
+		if d.Base != nil && d.Current != nil {
+			delta = fmt.Sprintf("%+.2f", d.Delta)
+		}
+		if color {
+			// All color codes have the same length, so the columns stay aligned
+			change = ansiColors[d.Color] + change + ansiReset
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", d.Label, formatValue(d.Base, d.Unit), formatValue(d.Current, d.Unit), delta, change)
+	}
+	tw.Flush()
+
+	if n := len(cmp.Regressions()); n > 0 {
+		fmt.Fprintf(w, "\n%d regression(s) beyond %.0f%% noise threshold\n", n, report.NoiseThreshold*100)
+	}
+}
+
+func formatValue(v *float64, unit string) string {
+	if v == nil {
+		return "--"
+	}
+	return fmt.Sprintf("%.2f %s", *v, unit)
+}
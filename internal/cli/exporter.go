@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"nextcloud-perf/internal/config"
+	"nextcloud-perf/internal/metrics"
+	"nextcloud-perf/internal/report"
+	"nextcloud-perf/internal/workflow"
+)
+
+// metricsReporter forwards every result to a metrics collector.
+type metricsReporter struct {
+	*TerminalReporter
+	metrics *metrics.Collector
+}
+
+func (r *metricsReporter) SendResult(data report.ReportData) {
+	r.TerminalReporter.SendResult(data)
+	r.metrics.Observe(data)
+}
+
+func exporterCommand(args []string) int {
+	fs := flag.NewFlagSet("exporter", flag.ContinueOnError)
+	var target targetFlags
+	target.register(fs)
+	profileName := fs.String("profile", "quick", "Benchmark profile name or path to a YAML/JSON profile file")
+	interval := fs.Duration("interval", 15*time.Minute, "Time between the starts of two runs")
+	listen := fs.String("listen", ":9310", "Address to serve Prometheus metrics on (/metrics)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	opts, err := target.options()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+	if opts.Profile, err = config.ResolveProfile(target.profile(fs, *profileName)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+	if *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --interval must be positive")
+		return ExitUsage
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to listen on %s: %v\n", *listen, err)
+		return ExitRuntime
+	}
+	collector := metrics.NewCollector()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", collector)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "Error: metrics server stopped: %v\n", err)
+		}
+	}()
+	defer srv.Shutdown(context.Background())
+
+	ctx, cancel := signalContext(0)
+	defer cancel()
+
+	fmt.Fprintf(os.Stderr, "Serving metrics on http://%s/metrics, running profile %q every %v\n", ln.Addr(), opts.Profile.Name, *interval)
+	for {
+		start := time.Now()
+		workflow.Run(ctx, opts, &metricsReporter{TerminalReporter: NewTerminalReporter(os.Stderr), metrics: collector})
+		if ctx.Err() != nil {
+			return ExitOK
+		}
+
+		wait := time.Until(start.Add(*interval))
+		fmt.Fprintf(os.Stderr, "Next run in %v\n", wait.Round(time.Second))
+		select {
+		case <-ctx.Done():
+			return ExitOK
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"nextcloud-perf/internal/config"
+	"nextcloud-perf/internal/workflow"
+)
+
+func loadCommand(args []string) int {
+	fs := flag.NewFlagSet("load", flag.ContinueOnError)
+	var target targetFlags
+	target.register(fs)
+	sc := config.LoadScenario{FileSize: config.LoadFileSize}
+	fs.IntVar(&sc.Users, "users", config.LoadUsers, "Number of virtual users (simulated sync clients)")
+	rampUp := fs.Duration("ramp-up", config.LoadRampUp, "Start the users evenly spread over this time (0 = all at once)")
+	duration := fs.Duration("duration", config.LoadDuration, "Total run time including the ramp-up")
+	interval := fs.Duration("interval", 0, "Sampling interval (default 10s, at most a tenth of the duration)")
+	thinkTime := fs.Duration("think-time", config.LoadThinkTime, "Pause of each user between two sync cycles")
+	fs.Func("file-size", "Size of the file uploaded and downloaded per sync cycle (default 256KB)", func(s string) error {
+		b, err := config.ParseByteSize(s)
+		sc.FileSize = b
+		return err
+	})
+	accountsFile := fs.String("accounts", "", "YAML/JSON file with existing test accounts; without it --user must be an admin and accounts are created and deleted via the provisioning API")
+	out := fs.String("out", "Nextcloud_Load_Report.html", "Write the HTML report to this file ('' to skip)")
+	jsonOut := fs.String("json", "", "Write the JSON report to this file ('-' for stdout)")
+	timeout := fs.Duration("timeout", 0, "Abort the load test after this duration (0 = no limit)")
+	thresholdsFile := fs.String("thresholds", "", "YAML/JSON thresholds file; violations exit with code 4")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	sc.RampUp = config.Duration(*rampUp)
+	explicit := false
+	fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "ramp-up" })
+	if !explicit && *rampUp > *duration/2 {
+		// Keep the default ramp-up within short runs
+		sc.RampUp = config.Duration(*duration / 2)
+	}
+	sc.Duration = config.Duration(*duration)
+	sc.Interval = config.Duration(*interval)
+	sc.ThinkTime = config.Duration(*thinkTime)
+	if sc.Users <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --users must be positive")
+		return ExitUsage
+	}
+	if err := sc.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+
+	var accounts []config.Account
+	if *accountsFile != "" {
+		var err error
+		if accounts, err = config.LoadAccounts(*accountsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitUsage
+		}
+		// No admin needed: the pre-flight check runs as the first test account
+		if target.user == "" && target.saved == "" {
+			target.user, target.pass = accounts[0].User, accounts[0].Pass
+		}
+	}
+	bopts, err := target.options()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+	opts := workflow.LoadOptions{URL: bopts.URL, User: bopts.User, Pass: bopts.Pass, Scenario: sc, Accounts: accounts}
+	if *thresholdsFile != "" {
+		if opts.Thresholds, err = config.LoadThresholds(*thresholdsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitUsage
+		}
+	}
+
+	ctx, cancel := signalContext(*timeout)
+	defer cancel()
+
+	reporter := NewTerminalReporter(os.Stderr)
+	workflow.RunLoad(ctx, opts, reporter)
+
+	rpt := reporter.Result()
+	if code := writeOutputs(reporter, *out, *jsonOut); code != ExitOK {
+		return code
+	}
+	if rpt.Error != "" {
+		fmt.Fprintf(os.Stderr, "Load test failed: %s\n", rpt.Error)
+		return ExitFailure
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Load test aborted: %v\n", ctx.Err())
+		return ExitFailure
+	}
+	if rpt.Verdict != nil {
+		printVerdict(os.Stderr, rpt.Verdict)
+		if !rpt.Verdict.Passed {
+			return ExitVerdict
+		}
+	}
+	return ExitOK
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"nextcloud-perf/internal/config"
+)
+
+func profilesCommand(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: nextcloud-perf profiles")
+		return ExitUsage
+	}
+	for _, p := range config.AvailableProfiles() {
+		fmt.Printf("%-12s %s\n", p.Name, p.Description)
+		for _, sc := range p.Scenarios {
+			fmt.Printf("  - %s [%s]\n", sc.DisplayLabel(), sc.Direction)
+		}
+		if p.Sync != nil {
+			sync := *p.Sync
+			_ = sync.Validate() // Fill defaults for display
+			fmt.Printf("  - Sync cycles (%d x %d files, %d cycles)\n", sync.Dirs, sync.Files, sync.Cycles)
+		}
+		if p.Protocols != nil {
+			protocols := *p.Protocols
+			_ = protocols.Validate() // Fill defaults for display
+			fmt.Printf("  - Protocol comparison (%s: %d x %s, %s)\n", strings.Join(protocols.Versions, ", "), protocols.Files, protocols.FileSize, protocols.LargeSize)
+		}
+		if p.Soak != nil {
+			soak := *p.Soak
+			_ = soak.Validate() // Fill defaults for display
+			fmt.Printf("  - Soak test (%s, %d workers, %s files)\n", soak.Duration, soak.Parallel, soak.FileSize)
+		}
+	}
+	fmt.Printf("\nCustom profiles (YAML/JSON) are loaded from %s\n", config.ProfilesDir())
+	return ExitOK
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"nextcloud-perf/internal/report"
+)
+
+// TerminalReporter implements workflow.Reporter for headless runs.
+// Progress messages are written line by line to Out (usually stderr),
+// the latest ReportData and the rendered HTML are kept for the caller.
+// If Events is set, every step is additionally recorded as NDJSON.
+type TerminalReporter struct {
+	Out    io.Writer
+	Events *report.EventWriter
+	Prefix string // Prepended to every progress line, e.g. the target name in batch runs
+	start  time.Time
+
+	mu     sync.Mutex
+	latest report.ReportData
+	html   []byte
+}
+
+// NewTerminalReporter creates a reporter writing progress to out.
+func NewTerminalReporter(out io.Writer) *TerminalReporter {
+	return &TerminalReporter{
+		Out:   out,
+		start: time.Now(),
+	}
+}
+
+func (r *TerminalReporter) Broadcast(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.Out, "[%7.1fs] %s%s\n", time.Since(r.start).Seconds(), r.Prefix, msg)
+	if r.Events != nil {
+		if err := r.Events.WriteLog(msg); err != nil {
+			fmt.Fprintf(r.Out, "Warning: failed to write event: %v\n", err)
+		}
+	}
+}
+
+func (r *TerminalReporter) SendResult(data report.ReportData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latest = data
+	if r.Events != nil {
+		if err := r.Events.WriteResult(data); err != nil {
+			fmt.Fprintf(r.Out, "Warning: failed to write event: %v\n", err)
+		}
+	}
+}
+
+func (r *TerminalReporter) SaveReport(html []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.html = html
+}
+
+// Result returns the most recent ReportData sent by the workflow.
+func (r *TerminalReporter) Result() report.ReportData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.latest
+}
+
+// HTML returns the rendered report, or nil if none was generated.
+func (r *TerminalReporter) HTML() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.html
+}
@@ -0,0 +1,296 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"nextcloud-perf/internal/config"
+	"nextcloud-perf/internal/history"
+	"nextcloud-perf/internal/network"
+	"nextcloud-perf/internal/report"
+	"nextcloud-perf/internal/ui"
+	"nextcloud-perf/internal/workflow"
+)
+
+// passwordEnv is read when neither --pass-file nor --pass is given.
+const passwordEnv = "NEXTCLOUD_PASS"
+
+// targetFlags holds the connection flags shared by all benchmark commands.
+type targetFlags struct {
+	url      string
+	user     string
+	pass     string
+	passFile string
+	saved    string // Name of a saved target
+
+	savedProfile string // Profile of the saved target, set by options
+}
+
+func (t *targetFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&t.url, "url", "", "Nextcloud base URL, e.g. https://cloud.example.com")
+	fs.StringVar(&t.user, "user", "", "Nextcloud username")
+	fs.StringVar(&t.pass, "pass", "", "Password or app token (prefer --pass-file or $"+passwordEnv+")")
+	fs.StringVar(&t.passFile, "pass-file", "", "Read password from file ('-' for stdin)")
+	fs.StringVar(&t.saved, "target", "", "Saved target name (see 'targets'); other flags override its settings")
+}
+
+// options resolves the password and validates the target like the web UI does.
+func (t *targetFlags) options() (workflow.BenchmarkOptions, error) {
+	url, user, pass := t.url, t.user, t.pass
+	if t.saved != "" {
+		// The stored password is only read if no other password was given,
+		// and only sent to the saved URL
+		saved, savedPass, err := loadSavedTarget(t.saved, url, pass == "" && t.passFile == "")
+		if err != nil {
+			return workflow.BenchmarkOptions{}, err
+		}
+		if url == "" {
+			url = saved.URL
+		}
+		if user == "" {
+			user = saved.User
+		}
+		if savedPass != "" {
+			pass = savedPass
+		}
+		t.savedProfile = saved.Profile
+	}
+	if t.passFile != "" {
+		p, err := readSecret(t.passFile)
+		if err != nil {
+			return workflow.BenchmarkOptions{}, fmt.Errorf("failed to read password file: %w", err)
+		}
+		pass = p
+	}
+	if pass == "" {
+		pass = os.Getenv(passwordEnv)
+	}
+
+	req := ui.RunRequest{URL: url, User: user, Pass: pass}
+	if err := req.Validate(); err != nil {
+		return workflow.BenchmarkOptions{}, err
+	}
+	return workflow.BenchmarkOptions{URL: url, User: user, Pass: pass}, nil
+}
+
+// profile returns name, the value of --profile, or the saved target's
+// profile if the flag was not given.
+func (t *targetFlags) profile(fs *flag.FlagSet, name string) string {
+	explicit := false
+	fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "profile" })
+	if !explicit && t.savedProfile != "" {
+		return t.savedProfile
+	}
+	return name
+}
+
+// setSoakDuration adds a soak test with the given duration to p or changes
+// the duration of its soak test. The sampling interval is derived anew.
+func setSoakDuration(p *config.BenchmarkProfile, d time.Duration) error {
+	soak := config.SoakScenario{}
+	if p.Soak != nil {
+		soak = *p.Soak
+	}
+	soak.Duration = config.Duration(d)
+	soak.Interval = 0
+	if err := soak.Validate(); err != nil {
+		return err
+	}
+	p.Soak = &soak
+	return nil
+}
+
+// signalContext returns a context that is cancelled on SIGINT/SIGTERM and
+// optionally after timeout.
+func signalContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	var target targetFlags
+	target.register(fs)
+	out := fs.String("out", "Nextcloud_Perf_Report.html", "Write the HTML report to this file ('' to skip)")
+	jsonOut := fs.String("json", "", "Write the JSON report to this file ('-' for stdout)")
+	eventsOut := fs.String("events", "", "Stream NDJSON progress events to this file ('-' for stdout)")
+	timeout := fs.Duration("timeout", 0, "Abort the benchmark after this duration (0 = no limit)")
+	profileName := fs.String("profile", config.DefaultProfileName, "Benchmark profile name or path to a YAML/JSON profile file")
+	keepHistory := fs.Bool("history", true, "Store the completed run in the local history shown by the web UI")
+	thresholdsFile := fs.String("thresholds", "", "YAML/JSON thresholds file; violations exit with code 4")
+	soak := fs.Duration("soak", 0, "Run a soak test of this duration (e.g. 30m) after the profile, replacing the profile's own soak duration")
+	protocol := fs.String("protocol", config.ProtocolHTTP1, "HTTP protocol of all requests: http/1.1, h2 or h3 (QUIC, https only)")
+	checksum := fs.String("checksum", "", "Verify downloads with sha256, md5 or none (size only), overriding the profile")
+	mtr := fs.Int("mtr", 1, "Probe every traceroute hop this many times, one cycle per second, for loss and latency per hop like mtr")
+	asnDB := fs.String("asn-db", "", "iptoasn.com ip2asn TSV file (optionally .gz) to look up the autonomous systems of the traceroute hops")
+	endpointSamples := fs.Int("endpoint-samples", config.DefaultEndpointSamples, "Requests to status.php, the OCS API and WebDAV each for the request phase breakdown, every one on a new connection")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	opts, err := target.options()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+	if opts.Profile, err = config.ResolveProfile(target.profile(fs, *profileName)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+	if opts.Protocol, err = config.ParseProtocol(*protocol); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+	if *checksum != "" {
+		opts.Profile.Checksum = *checksum
+		if err := opts.Profile.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitUsage
+		}
+	}
+	if *mtr < 1 {
+		fmt.Fprintln(os.Stderr, "Error: --mtr must be at least 1")
+		return ExitUsage
+	}
+	opts.MTRCycles = *mtr
+	if *endpointSamples < 1 {
+		fmt.Fprintln(os.Stderr, "Error: --endpoint-samples must be at least 1")
+		return ExitUsage
+	}
+	opts.EndpointSamples = *endpointSamples
+	if *asnDB != "" {
+		if opts.ASN, err = network.LoadASNDatabase(*asnDB); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitUsage
+		}
+	}
+	if *soak > 0 {
+		if err := setSoakDuration(opts.Profile, *soak); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitUsage
+		}
+	}
+
+	if *thresholdsFile != "" {
+		if opts.Thresholds, err = config.LoadThresholds(*thresholdsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitUsage
+		}
+	}
+
+	ctx, cancel := signalContext(*timeout)
+	defer cancel()
+
+	reporter := NewTerminalReporter(os.Stderr)
+	if *eventsOut != "" {
+		w, closeFn, err := createOutput(*eventsOut)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitRuntime
+		}
+		defer closeFn()
+		reporter.Events = report.NewEventWriter(w)
+	}
+	workflow.Run(ctx, opts, reporter)
+
+	rpt := reporter.Result()
+	if code := writeOutputs(reporter, *out, *jsonOut); code != ExitOK {
+		return code
+	}
+	if *keepHistory && rpt.Completed {
+		saveHistory(reporter)
+	}
+
+	if rpt.Error != "" {
+		fmt.Fprintf(os.Stderr, "Benchmark failed: %s\n", rpt.Error)
+		return ExitFailure
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Benchmark aborted: %v\n", ctx.Err())
+		return ExitFailure
+	}
+	if rpt.Verdict != nil {
+		printVerdict(os.Stderr, rpt.Verdict)
+		if !rpt.Verdict.Passed {
+			return ExitVerdict
+		}
+	}
+	return ExitOK
+}
+
+// writeOutputs writes the HTML and JSON artifacts of a finished run.
+func writeOutputs(reporter *TerminalReporter, htmlPath, jsonPath string) int {
+	if htmlPath != "" {
+		html := reporter.HTML()
+		if html == nil {
+			fmt.Fprintln(os.Stderr, "No HTML report was generated.")
+		} else if err := os.WriteFile(htmlPath, html, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write report: %v\n", err)
+			return ExitRuntime
+		} else {
+			fmt.Fprintf(os.Stderr, "HTML report written to %s\n", htmlPath)
+		}
+	}
+
+	if jsonPath != "" {
+		b, err := report.GenerateJSON(reporter.Result())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to encode JSON report: %v\n", err)
+			return ExitRuntime
+		}
+		if jsonPath == "-" {
+			_, err = os.Stdout.Write(append(b, '\n'))
+		} else {
+			err = os.WriteFile(jsonPath, b, 0o644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write JSON report: %v\n", err)
+			return ExitRuntime
+		}
+		if jsonPath != "-" {
+			fmt.Fprintf(os.Stderr, "JSON report written to %s\n", jsonPath)
+		}
+	}
+	return ExitOK
+}
+
+// saveHistory stores the run in the default history directory. Failures are
+// only reported, the run itself succeeded.
+func saveHistory(reporter *TerminalReporter) {
+	store, err := history.Open(history.DefaultDir())
+	if err == nil {
+		_, err = store.Save(reporter.Result(), reporter.HTML())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save run history: %v\n", err)
+	}
+}
+
+// createOutput opens path for writing; "-" selects stdout.
+func createOutput(path string) (io.Writer, func(), error) {
+	if path == "-" {
+		return os.Stdout, func() {}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	return f, func() { f.Close() }, nil
+}
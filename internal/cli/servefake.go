@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"nextcloud-perf/internal/config"
+	"nextcloud-perf/internal/fakecloud"
+)
+
+func serveFakeCommand(args []string) int {
+	fs := flag.NewFlagSet("serve-fake", flag.ContinueOnError)
+	listen := fs.String("listen", net.JoinHostPort(config.DefaultServerHost, "8080"), "Address to serve the fake Nextcloud on, e.g. :8080 for all interfaces")
+	user := fs.String("user", fakecloud.DefaultUser, "Username accepted by the fake server")
+	pass := fs.String("pass", fakecloud.DefaultPassword, "Password accepted by the fake server")
+	version := fs.String("version", fakecloud.DefaultVersion, "Nextcloud version reported by status.php")
+	latency := fs.Duration("latency", 0, "Delay added to every request, e.g. 40ms")
+	bandwidth := fs.String("bandwidth", "", "Bandwidth per request body and direction, e.g. 10MB (per second)")
+	maintenance := fs.Bool("maintenance", false, "Report maintenance mode and answer all other requests with 503")
+	discard := fs.Bool("discard-data", false, "Keep only file sizes instead of contents (for large uploads)")
+	quiet := fs.Bool("quiet", false, "Do not log requests")
+	var faults []fakecloud.Fault
+	fs.Func("fault", "Inject a fault as METHOD:PATH-PATTERN:STATUS[:TIMES], STATUS may be \"reset\", \"truncate\" or \"corrupt\" (repeatable)", func(s string) error {
+		f, err := parseFault(s)
+		if err == nil {
+			faults = append(faults, f)
+		}
+		return err
+	})
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	opts := fakecloud.Options{
+		Users:       map[string]string{*user: *pass},
+		Version:     *version,
+		Latency:     *latency,
+		Maintenance: *maintenance,
+		DiscardData: *discard,
+		Faults:      faults,
+	}
+	if *bandwidth != "" {
+		b, err := config.ParseByteSize(*bandwidth)
+		if err != nil || b <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid --bandwidth %q\n", *bandwidth)
+			return ExitUsage
+		}
+		opts.Bandwidth = int64(b)
+	}
+	if !*quiet {
+		opts.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to listen on %s: %v\n", *listen, err)
+		return ExitRuntime
+	}
+	srv := &http.Server{Handler: fakecloud.New(opts), ReadHeaderTimeout: 10 * time.Second}
+	// Also accept HTTP/2 without TLS (h2c), as sent by run --protocol h2 for http:// URLs
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	go srv.Serve(ln)
+
+	ctx, cancel := signalContext(0)
+	defer cancel()
+	fmt.Fprintf(os.Stderr, "Fake Nextcloud %s listening on http://%s (user %q)\n", *version, ln.Addr(), *user)
+	<-ctx.Done()
+	srv.Shutdown(context.Background())
+	return ExitOK
+}
+
+// parseFault parses METHOD:PATH-PATTERN:STATUS[:TIMES]. An empty method or
+// pattern, or "*" as method, matches everything.
+func parseFault(s string) (fakecloud.Fault, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return fakecloud.Fault{}, fmt.Errorf("expected METHOD:PATH-PATTERN:STATUS[:TIMES], got %q", s)
+	}
+	f := fakecloud.Fault{Method: strings.ToUpper(parts[0]), Path: parts[1]}
+	if f.Method == "*" {
+		f.Method = ""
+	}
+	switch strings.ToLower(parts[2]) {
+	case "reset":
+		f.Reset = true
+	case "truncate":
+		f.Truncate = true
+	case "corrupt":
+		f.Corrupt = true
+	default:
+		status, err := strconv.Atoi(parts[2])
+		if err != nil || status < 100 || status > 599 {
+			return fakecloud.Fault{}, fmt.Errorf("invalid status %q", parts[2])
+		}
+		f.Status = status
+	}
+	if len(parts) == 4 {
+		times, err := strconv.Atoi(parts[3])
+		if err != nil || times < 0 {
+			return fakecloud.Fault{}, fmt.Errorf("invalid count %q", parts[3])
+		}
+		f.Times = times
+	}
+	return f, nil
+}
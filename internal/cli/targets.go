@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"golang.org/x/term"
+
+	"nextcloud-perf/internal/targets"
+	"nextcloud-perf/internal/ui"
+)
+
+func targetsCommand(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: nextcloud-perf targets list")
+		fmt.Fprintln(os.Stderr, "       nextcloud-perf targets add [flags] <name>")
+		fmt.Fprintln(os.Stderr, "       nextcloud-perf targets remove <name>")
+	}
+	if len(args) == 0 {
+		usage()
+		return ExitUsage
+	}
+	switch args[0] {
+	case "list":
+		return targetsList(args[1:])
+	case "add":
+		return targetsAdd(args[1:])
+	case "remove":
+		return targetsRemove(args[1:])
+	case "-h", "--help", "help":
+		usage()
+		return ExitOK
+	}
+	usage()
+	return ExitUsage
+}
+
+func targetsList(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: nextcloud-perf targets list")
+		return ExitUsage
+	}
+	store, err := targets.Open(targets.DefaultDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitRuntime
+	}
+	list, err := store.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitRuntime
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(os.Stderr, "No saved targets. Add one with 'nextcloud-perf targets add'.")
+		return ExitOK
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tURL\tUSER\tPROFILE\tSTORAGE\t")
+	for _, t := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", t.Name, t.URL, t.User, t.Profile, t.Storage)
+	}
+	tw.Flush()
+	return ExitOK
+}
+
+func targetsAdd(args []string) int {
+	fs := flag.NewFlagSet("targets add", flag.ContinueOnError)
+	url := fs.String("url", "", "Nextcloud base URL, e.g. https://cloud.example.com")
+	user := fs.String("user", "", "Nextcloud username")
+	passFile := fs.String("pass-file", "", "Read password from file ('-' for stdin); default $"+passwordEnv+" or a prompt")
+	profile := fs.String("profile", "", "Default benchmark profile for this target")
+	storage := fs.String("storage", "", "Where to keep the password: keyring or file (default: keyring, file if unavailable)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: nextcloud-perf targets add [flags] <name>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return ExitUsage
+	}
+
+	var pass string
+	var err error
+	switch {
+	case *passFile != "":
+		if pass, err = readSecret(*passFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to read password file: %v\n", err)
+			return ExitUsage
+		}
+	case os.Getenv(passwordEnv) != "":
+		pass = os.Getenv(passwordEnv)
+	default:
+		if pass, err = promptSecret("Password for " + *user + ": "); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitUsage
+		}
+	}
+	req := ui.RunRequest{URL: *url, User: *user, Pass: pass}
+	if err := req.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitUsage
+	}
+
+	store, err := targets.Open(targets.DefaultDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitRuntime
+	}
+	t := targets.Target{Name: fs.Arg(0), URL: *url, User: *user, Profile: *profile}
+	saved, err := store.Save(t, pass, *storage, cliPassphrase())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitRuntime
+	}
+	fmt.Fprintf(os.Stderr, "Saved target %q (password in %s)\n", saved.Name, saved.Storage)
+	return ExitOK
+}
+
+func targetsRemove(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: nextcloud-perf targets remove <name>")
+		return ExitUsage
+	}
+	store, err := targets.Open(targets.DefaultDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitRuntime
+	}
+	if err := store.Delete(args[0], cliPassphrase()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, targets.ErrNotFound) {
+			return ExitUsage
+		}
+		return ExitRuntime
+	}
+	fmt.Fprintf(os.Stderr, "Removed target %q\n", args[0])
+	return ExitOK
+}
+
+// loadSavedTarget returns a saved target and, if withPassword is set, its
+// password. The password is refused if url overrides the saved URL.
+func loadSavedTarget(name, url string, withPassword bool) (targets.Target, string, error) {
+	store, err := targets.Open(targets.DefaultDir())
+	if err != nil {
+		return targets.Target{}, "", err
+	}
+	t, err := store.Get(name)
+	if err != nil || !withPassword {
+		return t, "", err
+	}
+	if err := t.CheckURL(url); err != nil {
+		return t, "", fmt.Errorf("target %q: %w (give the password with --pass-file or --pass)", name, err)
+	}
+	pass, err := store.Password(name, cliPassphrase())
+	return t, pass, err
+}
+
+// cliPassphrase reads the passphrase of the encrypted secrets file from
+// $NEXTCLOUD_PERF_PASSPHRASE or, on a terminal, prompts once for it.
+func cliPassphrase() targets.PassphraseFunc {
+	var cached string
+	return func() (string, error) {
+		if cached != "" {
+			return cached, nil
+		}
+		p, err := targets.EnvPassphrase()
+		if err != nil {
+			if p, err = promptSecret("Passphrase for the encrypted secrets file: "); err != nil {
+				return "", targets.ErrNoPassphrase
+			}
+		}
+		cached = p
+		return p, nil
+	}
+}
+
+// promptSecret reads a line from the terminal without echoing it.
+func promptSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("no terminal to prompt for a secret")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
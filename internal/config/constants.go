@@ -7,6 +7,7 @@ const (
 	LogChannelBufferSize    = 100
 	ResultChannelBufferSize = 1
 	DefaultServerPort       = 3000
+	DefaultServerHost       = "127.0.0.1" // Loopback only, set NEXTCLOUD_PERF_UI_HOST to expose the UI
 	SSEHeartbeatInterval    = 30 * time.Second
 	ClientChannelBufferSize = 10
 )
@@ -17,13 +18,23 @@ const (
 	DefaultPingTimeout       = 2 * time.Second
 	PingDelayBetweenTests    = 200 * time.Millisecond
 	DefaultTracerouteMaxHops = 15
+	DefaultMTRInterval       = 1 * time.Second // Between the probe cycles of --mtr
+	DefaultEndpointSamples   = 10              // Requests per endpoint for the request phase breakdown
 )
 
 // WebDAV Configuration
 const (
 	DefaultChunkSize     = 25 * 1024 * 1024 // 25MB
+	MaxChunkCount        = 10000            // Chunking V2 limit per upload
+	MaxChunkMemory       = 1 << 30          // 1GB, chunk_size × chunk_parallel held in memory per upload
 	DefaultHTTPTimeout   = 5 * time.Minute
 	MOVEOperationTimeout = 10 * time.Minute
+
+	// Retries of idempotent requests and resumes of chunked uploads
+	DefaultRetryAttempts  = 3
+	RetryBaseDelay        = 500 * time.Millisecond
+	RetryMaxDelay         = 10 * time.Second
+	DefaultResumeAttempts = 2
 )
 
 // Benchmark Configuration
@@ -40,6 +51,43 @@ const (
 
 	// Large File Test
 	LargeFileSize = 256 * 1024 * 1024 // 256MB
+
+	// Metadata (PROPFIND) Test
+	MetadataDirs        = 10
+	MetadataFilesPerDir = 10
+	MetadataFileSize    = 1024 // 1KB
+	MetadataIterations  = 5
+
+	// Sync Cycle Test (desktop client replay)
+	SyncDirs        = 10
+	SyncFilesPerDir = 20
+	SyncFileSize    = 16 * 1024 // 16KB
+	SyncChanges     = 10
+	SyncRenames     = 5
+	SyncDeletes     = 5
+	SyncCycles      = 3
+	SyncParallel    = 6 // Parallel propagation jobs of the desktop client
+
+	// Protocol Comparison (same workload over each HTTP version)
+	ProtocolFiles     = 20
+	ProtocolFileSize  = 256 * 1024 // 256KB
+	ProtocolParallel  = 8
+	ProtocolLargeSize = 64 * 1024 * 1024 // 64MB
+
+	// Soak Test
+	SoakInterval = time.Minute
+	SoakFileSize = 1024 * 1024 // 1MB
+	SoakFiles    = 20
+	SoakParallel = 4
+
+	// Load Test (virtual sync clients)
+	LoadUsers     = 10
+	LoadRampUp    = time.Minute
+	LoadDuration  = 5 * time.Minute
+	LoadInterval  = 10 * time.Second
+	LoadFileSize  = 256 * 1024 // 256KB
+	LoadFiles     = 5
+	LoadThinkTime = 5 * time.Second
 )
 
 // System Monitoring
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InventoryTarget is one Nextcloud instance of an inventory. The password is
+// taken from PassFile, PassEnv or Pass, in that order, or from the saved
+// target named by Saved, which also supplies URL, user and profile unless
+// they are set here.
+type InventoryTarget struct {
+	Name     string `json:"name" yaml:"name"`
+	Saved    string `json:"saved,omitempty" yaml:"saved,omitempty"` // Name of a saved target (see "targets" command)
+	URL      string `json:"url" yaml:"url"`
+	User     string `json:"user" yaml:"user"`
+	Pass     string `json:"pass,omitempty" yaml:"pass,omitempty"` // Discouraged, prefer pass_file or pass_env
+	PassFile string `json:"pass_file,omitempty" yaml:"pass_file,omitempty"`
+	PassEnv  string `json:"pass_env,omitempty" yaml:"pass_env,omitempty"`
+	Profile  string `json:"profile,omitempty" yaml:"profile,omitempty"` // Overrides Inventory.Profile
+}
+
+// Inventory lists the instances benchmarked by a batch run.
+type Inventory struct {
+	Profile     string            `json:"profile,omitempty" yaml:"profile,omitempty"`         // Default profile, DefaultProfileName if empty
+	Concurrency int               `json:"concurrency,omitempty" yaml:"concurrency,omitempty"` // Targets benchmarked at once, default 1
+	Targets     []InventoryTarget `json:"targets" yaml:"targets"`
+}
+
+// Validate fills defaults and checks that every target is usable.
+func (inv *Inventory) Validate() error {
+	if len(inv.Targets) == 0 {
+		return fmt.Errorf("inventory: no targets defined")
+	}
+	if inv.Profile == "" {
+		inv.Profile = DefaultProfileName
+	}
+	if inv.Concurrency == 0 {
+		inv.Concurrency = 1
+	}
+	if inv.Concurrency < 0 {
+		return fmt.Errorf("inventory: concurrency must be positive")
+	}
+
+	seen := map[string]bool{}
+	for i := range inv.Targets {
+		t := &inv.Targets[i]
+		if t.Name == "" && t.Saved != "" {
+			t.Name = t.Saved
+		}
+		if t.URL == "" && t.Saved == "" {
+			return fmt.Errorf("target %d: url is required", i+1)
+		}
+		if t.Name == "" {
+			u, err := url.Parse(t.URL)
+			if err != nil || u.Host == "" {
+				return fmt.Errorf("target %d: invalid url %q", i+1, t.URL)
+			}
+			t.Name = u.Host
+		}
+		if strings.ContainsAny(t.Name, "/\\") {
+			return fmt.Errorf("target %q: name must not contain slashes", t.Name)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("target %q: duplicate name", t.Name)
+		}
+		seen[t.Name] = true
+		if t.User == "" && t.Saved == "" {
+			return fmt.Errorf("target %q: user is required", t.Name)
+		}
+		if t.Pass == "" && t.PassFile == "" && t.PassEnv == "" && t.Saved == "" {
+			return fmt.Errorf("target %q: one of pass_file, pass_env, pass or saved is required", t.Name)
+		}
+		if t.PassFile == "-" {
+			return fmt.Errorf("target %q: pass_file cannot be stdin in an inventory", t.Name)
+		}
+		if t.Profile == "" && t.Saved == "" {
+			t.Profile = inv.Profile // Saved targets fall back to their own profile first
+		}
+	}
+	return nil
+}
+
+// LoadInventory reads an inventory from a YAML (.yaml/.yml) or JSON file.
+// Relative pass_file paths are resolved against the inventory's directory.
+func LoadInventory(file string) (*Inventory, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var inv Inventory
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &inv)
+	default:
+		err = json.Unmarshal(b, &inv)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse inventory %s: %w", file, err)
+	}
+	if err := inv.Validate(); err != nil {
+		return nil, err
+	}
+	for i := range inv.Targets {
+		if p := inv.Targets[i].PassFile; p != "" && !filepath.IsAbs(p) {
+			inv.Targets[i].PassFile = filepath.Join(filepath.Dir(file), p)
+		}
+	}
+	return &inv, nil
+}
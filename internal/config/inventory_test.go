@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadInventory(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "customers.yaml")
+	content := `
+profile: quick
+concurrency: 4
+targets:
+  - url: https://a.example.com
+    user: perf
+    pass_file: secrets/a.txt
+  - name: customer-b
+    url: https://b.example.com
+    user: perf
+    pass_env: CUSTOMER_B_PASS
+    profile: standard
+  - saved: prod
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := LoadInventory(path)
+	if err != nil {
+		t.Fatalf("LoadInventory failed: %v", err)
+	}
+	if inv.Concurrency != 4 || len(inv.Targets) != 3 {
+		t.Fatalf("Unexpected inventory: %+v", inv)
+	}
+	a, b := inv.Targets[0], inv.Targets[1]
+	if a.Name != "a.example.com" || a.Profile != "quick" || a.PassFile != filepath.Join(dir, "secrets/a.txt") {
+		t.Errorf("Defaults not applied: %+v", a)
+	}
+	if b.Name != "customer-b" || b.Profile != "standard" {
+		t.Errorf("Unexpected target: %+v", b)
+	}
+	// Saved targets keep an empty profile so that their own profile applies
+	if c := inv.Targets[2]; c.Name != "prod" || c.Profile != "" {
+		t.Errorf("Unexpected saved target: %+v", c)
+	}
+}
+
+func TestInventoryValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		inv  Inventory
+	}{
+		{"no targets", Inventory{}},
+		{"no url", Inventory{Targets: []InventoryTarget{{User: "u", Pass: "p"}}}},
+		{"no password", Inventory{Targets: []InventoryTarget{{URL: "https://a", User: "u"}}}},
+		{"stdin password", Inventory{Targets: []InventoryTarget{{URL: "https://a", User: "u", PassFile: "-"}}}},
+		{"duplicate", Inventory{Targets: []InventoryTarget{
+			{URL: "https://a", User: "u", Pass: "p"},
+			{URL: "https://a", User: "v", Pass: "p"},
+		}}},
+	}
+	for _, tt := range tests {
+		if err := tt.inv.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", tt.name)
+		}
+	}
+}
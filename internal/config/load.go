@@ -0,0 +1,112 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadScenario simulates many desktop sync clients at once to find the
+// number of users at which an instance saturates. RampUp and ThinkTime are
+// used as given, zero starts all users at once or loops without pause.
+type LoadScenario struct {
+	Users     int      `json:"users" yaml:"users"`
+	RampUp    Duration `json:"ramp_up" yaml:"ramp_up"`
+	Duration  Duration `json:"duration" yaml:"duration"` // Including the ramp-up
+	Interval  Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	FileSize  ByteSize `json:"file_size,omitempty" yaml:"file_size,omitempty"`
+	Files     int      `json:"files,omitempty" yaml:"files,omitempty"` // Files per user cycled by the uploads
+	ThinkTime Duration `json:"think_time" yaml:"think_time"`
+}
+
+// Validate fills defaults and checks users, durations and sizes.
+func (l *LoadScenario) Validate() error {
+	if l.Users == 0 {
+		l.Users = LoadUsers
+	}
+	if l.Duration == 0 {
+		l.Duration = Duration(LoadDuration)
+	}
+	if l.Interval == 0 {
+		l.Interval = Duration(LoadInterval)
+		if l.Interval > l.Duration/10 {
+			l.Interval = l.Duration / 10
+		}
+	}
+	if l.FileSize == 0 {
+		l.FileSize = LoadFileSize
+	}
+	if l.Files == 0 {
+		l.Files = LoadFiles
+	}
+	switch {
+	case l.Users < 0:
+		return fmt.Errorf("load: users must be positive")
+	case l.Duration <= 0:
+		return fmt.Errorf("load: duration must be positive")
+	case l.RampUp < 0 || l.RampUp > l.Duration:
+		return fmt.Errorf("load: ramp-up must be between 0 and the duration (%s)", l.Duration)
+	case l.Interval <= 0 || l.Interval > l.Duration:
+		return fmt.Errorf("load: interval must be positive and at most the duration (%s)", l.Duration)
+	case l.FileSize < 0 || l.Files < 0 || l.ThinkTime < 0:
+		return fmt.Errorf("load: file_size, files and think_time must not be negative")
+	}
+	return nil
+}
+
+// Account is an existing test account used by a load test.
+type Account struct {
+	User    string `json:"user" yaml:"user"`
+	Pass    string `json:"pass,omitempty" yaml:"pass,omitempty"`
+	PassEnv string `json:"pass_env,omitempty" yaml:"pass_env,omitempty"` // Takes precedence over Pass
+}
+
+// LoadAccounts reads test accounts from a YAML (.yaml/.yml) or JSON file with
+// an "accounts" list and resolves pass_env references.
+func LoadAccounts(file string) ([]Account, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Accounts []Account `json:"accounts" yaml:"accounts"`
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &list)
+	default:
+		err = json.Unmarshal(b, &list)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse accounts %s: %w", file, err)
+	}
+	if len(list.Accounts) == 0 {
+		return nil, fmt.Errorf("accounts %s: no accounts defined", file)
+	}
+
+	seen := map[string]bool{}
+	for i := range list.Accounts {
+		a := &list.Accounts[i]
+		if a.User == "" {
+			return nil, fmt.Errorf("account %d: user is required", i+1)
+		}
+		if seen[a.User] {
+			return nil, fmt.Errorf("account %q: duplicate user", a.User)
+		}
+		seen[a.User] = true
+		if a.PassEnv != "" {
+			a.Pass = os.Getenv(a.PassEnv)
+			if a.Pass == "" {
+				return nil, fmt.Errorf("account %q: environment variable %s is empty", a.User, a.PassEnv)
+			}
+		}
+		if a.Pass == "" {
+			return nil, fmt.Errorf("account %q: one of pass or pass_env is required", a.User)
+		}
+	}
+	return list.Accounts, nil
+}
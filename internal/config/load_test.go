@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadScenario(t *testing.T) {
+	l := LoadScenario{Duration: Duration(30 * time.Second)}
+	if err := l.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if l.Users != LoadUsers || l.Interval != Duration(3*time.Second) || l.FileSize != LoadFileSize || l.Files != LoadFiles {
+		t.Errorf("Defaults not applied: %+v", l)
+	}
+	if l.RampUp != 0 || l.ThinkTime != 0 {
+		t.Errorf("Expected ramp-up and think time to stay zero: %+v", l)
+	}
+
+	for _, bad := range []LoadScenario{
+		{Users: -1},
+		{Duration: Duration(-time.Second)},
+		{Duration: Duration(time.Minute), RampUp: Duration(2 * time.Minute)},
+		{Duration: Duration(time.Minute), ThinkTime: Duration(-time.Second)},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestLoadAccounts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "accounts.yaml")
+	content := `
+accounts:
+  - user: load-01
+    pass: secret
+  - user: load-02
+    pass_env: LOAD_02_PASS
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LOAD_02_PASS", "from-env")
+
+	accounts, err := LoadAccounts(path)
+	if err != nil {
+		t.Fatalf("LoadAccounts failed: %v", err)
+	}
+	if len(accounts) != 2 || accounts[0].Pass != "secret" || accounts[1].Pass != "from-env" {
+		t.Errorf("Unexpected accounts: %+v", accounts)
+	}
+
+	t.Setenv("LOAD_02_PASS", "")
+	if _, err := LoadAccounts(path); err == nil {
+		t.Error("Expected an empty pass_env variable to be rejected")
+	}
+	dup := filepath.Join(dir, "dup.json")
+	os.WriteFile(dup, []byte(`{"accounts": [{"user": "a", "pass": "x"}, {"user": "a", "pass": "y"}]}`), 0o600)
+	if _, err := LoadAccounts(dup); err == nil {
+		t.Error("Expected duplicate users to be rejected")
+	}
+}
@@ -0,0 +1,658 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Direction selects which transfer directions a scenario measures.
+type Direction string
+
+const (
+	DirectionUpload   Direction = "upload"
+	DirectionDownload Direction = "download"
+	DirectionBoth     Direction = "both"
+)
+
+// ByteSize is a size in bytes that can be written as a plain number
+// or with a unit suffix ("512KB", "5MB", "2GB") in profile files.
+type ByteSize int64
+
+// ParseByteSize parses "512KB", "5 MB", "2GiB" or "1048576". Units are binary (1KB = 1024 B).
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+		{"B", 1},
+	}
+	factor := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			factor = u.factor
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return ByteSize(v * float64(factor)), nil
+}
+
+// String formats the size with the largest unit that divides it evenly.
+func (b ByteSize) String() string {
+	switch {
+	case b >= 1<<30 && b%(1<<30) == 0:
+		return fmt.Sprintf("%dGB", b>>30)
+	case b >= 1<<20 && b%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", b>>20)
+	case b >= 1<<10 && b%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", b>>10)
+	}
+	return fmt.Sprintf("%dB", int64(b))
+}
+
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*b = ByteSize(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("size must be a number or a string like \"5MB\"")
+	}
+	v, err := ParseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+func (b ByteSize) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+func (b *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	v, err := ParseByteSize(node.Value)
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+// Duration is a time span written as a Go duration string ("30m", "1h30m")
+// or as a number of seconds in profile files.
+type Duration time.Duration
+
+// ParseDuration parses "30m", "90s" or a plain number of seconds.
+func ParseDuration(s string) (Duration, error) {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseFloat(s, 64); err == nil && v >= 0 {
+		return Duration(v * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return Duration(d), nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var secs float64
+	if err := json.Unmarshal(data, &secs); err == nil {
+		*d = Duration(secs * float64(time.Second))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a number of seconds or a string like \"30m\"")
+	}
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	v, err := ParseDuration(node.Value)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Scenario describes one transfer test of a benchmark profile.
+type Scenario struct {
+	Name      string    `json:"name" yaml:"name"`
+	Label     string    `json:"label,omitempty" yaml:"label,omitempty"`
+	Count     int       `json:"count" yaml:"count"`
+	Size      ByteSize  `json:"size" yaml:"size"`
+	Parallel  int       `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+	Direction Direction `json:"direction,omitempty" yaml:"direction,omitempty"`
+	Chunked   bool      `json:"chunked,omitempty" yaml:"chunked,omitempty"`
+
+	// Chunked uploads only
+	ChunkSize         ByteSize `json:"chunk_size,omitempty" yaml:"chunk_size,omitempty"`
+	ChunkParallel     int      `json:"chunk_parallel,omitempty" yaml:"chunk_parallel,omitempty"`         // Chunks in flight at once
+	CompareSequential bool     `json:"compare_sequential,omitempty" yaml:"compare_sequential,omitempty"` // Also measure a sequential upload
+}
+
+// DisplayLabel returns the label shown in the UI and report, e.g. "Small Files (5 x 512KB)".
+func (s Scenario) DisplayLabel() string {
+	label := s.Label
+	if label == "" {
+		label = s.Name
+	}
+	if s.Chunked && s.ChunkParallel > 1 {
+		return fmt.Sprintf("%s (%s Chunked, %d parallel)", label, s.Size, s.ChunkParallel)
+	}
+	if s.Chunked {
+		return fmt.Sprintf("%s (%s Chunked)", label, s.Size)
+	}
+	return fmt.Sprintf("%s (%d x %s)", label, s.Count, s.Size)
+}
+
+// Uploads reports whether the upload speed of the scenario is measured.
+func (s Scenario) Uploads() bool {
+	return s.Direction == DirectionUpload || s.Direction == DirectionBoth
+}
+
+// Downloads reports whether the download speed of the scenario is measured.
+func (s Scenario) Downloads() bool {
+	return s.Direction == DirectionDownload || s.Direction == DirectionBoth
+}
+
+// MetadataScenario describes the folder tree used to benchmark PROPFIND listings.
+type MetadataScenario struct {
+	Dirs       int      `json:"dirs" yaml:"dirs"`
+	Files      int      `json:"files" yaml:"files"` // Files per directory
+	FileSize   ByteSize `json:"file_size,omitempty" yaml:"file_size,omitempty"`
+	Iterations int      `json:"iterations,omitempty" yaml:"iterations,omitempty"` // Listings per depth
+	Parallel   int      `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+}
+
+// Validate fills defaults and checks the tree dimensions.
+func (m *MetadataScenario) Validate() error {
+	if m.FileSize == 0 {
+		m.FileSize = MetadataFileSize
+	}
+	if m.Iterations == 0 {
+		m.Iterations = MetadataIterations
+	}
+	if m.Parallel == 0 {
+		m.Parallel = SmallFileParallel
+	}
+	if m.Dirs <= 0 || m.Files < 0 || m.FileSize < 0 || m.Iterations < 0 || m.Parallel < 0 {
+		return fmt.Errorf("metadata: dirs must be positive, files, file_size, iterations and parallel must not be negative")
+	}
+	return nil
+}
+
+// SyncScenario replays the requests of a desktop sync client on a folder
+// tree: initial discovery, ETag based change detection and the propagation
+// of changes, renames, deletes and a conflict.
+type SyncScenario struct {
+	Dirs     int      `json:"dirs,omitempty" yaml:"dirs,omitempty"`
+	Files    int      `json:"files,omitempty" yaml:"files,omitempty"` // Files per directory
+	FileSize ByteSize `json:"file_size,omitempty" yaml:"file_size,omitempty"`
+	Changes  int      `json:"changes,omitempty" yaml:"changes,omitempty"` // Files changed per cycle, on each side
+	Renames  int      `json:"renames,omitempty" yaml:"renames,omitempty"` // Files renamed per cycle
+	Deletes  int      `json:"deletes,omitempty" yaml:"deletes,omitempty"` // Files deleted per cycle
+	Cycles   int      `json:"cycles,omitempty" yaml:"cycles,omitempty"`   // Incremental sync cycles
+	Parallel int      `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+}
+
+// Validate fills defaults and checks that the tree holds enough files for
+// all cycles.
+func (s *SyncScenario) Validate() error {
+	if s.Dirs == 0 {
+		s.Dirs = SyncDirs
+	}
+	if s.Files == 0 {
+		s.Files = SyncFilesPerDir
+	}
+	if s.FileSize == 0 {
+		s.FileSize = SyncFileSize
+	}
+	if s.Changes == 0 {
+		s.Changes = SyncChanges
+	}
+	if s.Renames == 0 {
+		s.Renames = SyncRenames
+	}
+	if s.Deletes == 0 {
+		s.Deletes = SyncDeletes
+	}
+	if s.Cycles == 0 {
+		s.Cycles = SyncCycles
+	}
+	if s.Parallel == 0 {
+		s.Parallel = SyncParallel
+	}
+	switch {
+	case s.Dirs < 0 || s.Files < 0 || s.FileSize < 0 || s.Changes < 0 || s.Renames < 0 || s.Deletes < 0 || s.Cycles < 0 || s.Parallel < 0:
+		return fmt.Errorf("sync: dirs, files, file_size, changes, renames, deletes, cycles and parallel must not be negative")
+	case s.Dirs*s.Files-s.Cycles*s.Deletes < s.FilesPerCycle():
+		// Deleted files are gone for the following cycles
+		return fmt.Errorf("sync: %d x %d files are too few for %d cycles of %d changes, %d renames and %d deletes",
+			s.Dirs, s.Files, s.Cycles, s.Changes, s.Renames, s.Deletes)
+	}
+	return nil
+}
+
+// FilesPerCycle returns the number of distinct files a cycle touches: the
+// changes on both sides, the renames, the deletes and the conflicting file.
+func (s SyncScenario) FilesPerCycle() int {
+	return 2*s.Changes + s.Renames + s.Deletes + 1
+}
+
+// HTTP protocol versions of ProtocolComparison.Versions, named by their
+// ALPN identifiers.
+const (
+	ProtocolHTTP1 = "http/1.1"
+	ProtocolHTTP2 = "h2"
+	ProtocolHTTP3 = "h3"
+)
+
+// ParseProtocol returns the protocol version v in canonical (lower) case.
+func ParseProtocol(v string) (string, error) {
+	switch v = strings.ToLower(v); v {
+	case ProtocolHTTP1, ProtocolHTTP2, ProtocolHTTP3:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown protocol %q, use %s, %s or %s", v, ProtocolHTTP1, ProtocolHTTP2, ProtocolHTTP3)
+}
+
+// ProtocolComparison runs the same transfer workload over several HTTP
+// protocol versions, each on new connections, to compare them against one
+// server. Versions the server does not offer are reported as unavailable.
+type ProtocolComparison struct {
+	Versions  []string `json:"versions,omitempty" yaml:"versions,omitempty"` // Default: http/1.1, h2, h3
+	Files     int      `json:"files,omitempty" yaml:"files,omitempty"`       // Small files per run, transferred in parallel
+	FileSize  ByteSize `json:"file_size,omitempty" yaml:"file_size,omitempty"`
+	Parallel  int      `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+	LargeSize ByteSize `json:"large_size,omitempty" yaml:"large_size,omitempty"` // Single large file, transferred in one request
+}
+
+// Validate fills defaults and checks the protocol versions.
+func (c *ProtocolComparison) Validate() error {
+	if len(c.Versions) == 0 {
+		c.Versions = []string{ProtocolHTTP1, ProtocolHTTP2, ProtocolHTTP3}
+	}
+	if c.Files == 0 {
+		c.Files = ProtocolFiles
+	}
+	if c.FileSize == 0 {
+		c.FileSize = ProtocolFileSize
+	}
+	if c.Parallel == 0 {
+		c.Parallel = ProtocolParallel
+	}
+	if c.LargeSize == 0 {
+		c.LargeSize = ProtocolLargeSize
+	}
+	seen := map[string]bool{}
+	for i, v := range c.Versions {
+		v, err := ParseProtocol(v)
+		if err != nil {
+			return fmt.Errorf("protocols: %w", err)
+		}
+		if seen[v] {
+			return fmt.Errorf("protocols: version %s listed twice", v)
+		}
+		seen[v] = true
+		c.Versions[i] = v
+	}
+	if c.Files < 0 || c.FileSize < 0 || c.Parallel < 0 || c.LargeSize < 0 {
+		return fmt.Errorf("protocols: files, file_size, parallel and large_size must not be negative")
+	}
+	return nil
+}
+
+// SoakScenario loops a mixed upload/download/listing workload for a fixed
+// duration and samples it per interval, to reveal degradation that only
+// shows under sustained load (full PHP-FPM pools, Redis eviction, ...).
+type SoakScenario struct {
+	Duration Duration `json:"duration" yaml:"duration"`
+	Interval Duration `json:"interval,omitempty" yaml:"interval,omitempty"` // Sampling interval
+	FileSize ByteSize `json:"file_size,omitempty" yaml:"file_size,omitempty"`
+	Files    int      `json:"files,omitempty" yaml:"files,omitempty"` // Files cycled by the workload
+	Parallel int      `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+	Mix      SoakMix  `json:"mix,omitempty" yaml:"mix,omitempty"`
+}
+
+// SoakMix weights the operations of the soak workload, e.g. 1:2:1 runs
+// twice as many downloads as uploads or listings.
+type SoakMix struct {
+	Upload   int `json:"upload" yaml:"upload"`
+	Download int `json:"download" yaml:"download"`
+	List     int `json:"list" yaml:"list"`
+}
+
+// Validate fills defaults and checks duration, interval and mix.
+func (s *SoakScenario) Validate() error {
+	if s.Interval == 0 {
+		s.Interval = Duration(SoakInterval)
+		if s.Interval > s.Duration/10 {
+			s.Interval = s.Duration / 10
+		}
+	}
+	if s.FileSize == 0 {
+		s.FileSize = SoakFileSize
+	}
+	if s.Files == 0 {
+		s.Files = SoakFiles
+	}
+	if s.Parallel == 0 {
+		s.Parallel = SoakParallel
+	}
+	if s.Mix == (SoakMix{}) {
+		s.Mix = SoakMix{Upload: 1, Download: 2, List: 1}
+	}
+	switch {
+	case s.Duration <= 0:
+		return fmt.Errorf("soak: duration must be positive")
+	case s.Interval <= 0 || s.Interval > s.Duration:
+		return fmt.Errorf("soak: interval must be positive and at most the duration (%s)", s.Duration)
+	case s.FileSize < 0 || s.Files < 0 || s.Parallel < 0:
+		return fmt.Errorf("soak: file_size, files and parallel must not be negative")
+	case s.Mix.Upload < 0 || s.Mix.Download < 0 || s.Mix.List < 0:
+		return fmt.Errorf("soak: mix weights must not be negative")
+	}
+	return nil
+}
+
+// Checksum types of BenchmarkProfile.Checksum.
+const (
+	ChecksumSHA256 = "sha256"
+	ChecksumMD5    = "md5"
+	ChecksumNone   = "none" // Only the size of downloads is verified
+)
+
+// BenchmarkProfile is a named set of scenarios executed by workflow.Run.
+type BenchmarkProfile struct {
+	Name          string              `json:"name" yaml:"name"`
+	Description   string              `json:"description,omitempty" yaml:"description,omitempty"`
+	SkipSpeedtest bool                `json:"skip_speedtest,omitempty" yaml:"skip_speedtest,omitempty"`
+	Checksum      string              `json:"checksum,omitempty" yaml:"checksum,omitempty"` // Verification of the transfer scenarios: sha256 (default), md5 or none (raw throughput)
+	Scenarios     []Scenario          `json:"scenarios" yaml:"scenarios"`
+	Metadata      *MetadataScenario   `json:"metadata,omitempty" yaml:"metadata,omitempty"`   // Optional PROPFIND listing benchmark
+	Sync          *SyncScenario       `json:"sync,omitempty" yaml:"sync,omitempty"`           // Optional desktop sync cycle replay
+	Protocols     *ProtocolComparison `json:"protocols,omitempty" yaml:"protocols,omitempty"` // Optional HTTP/1.1 vs. HTTP/2 vs. HTTP/3 comparison
+	Soak          *SoakScenario       `json:"soak,omitempty" yaml:"soak,omitempty"`           // Optional sustained load test, run last
+}
+
+// Validate fills defaults and checks that the profile can be executed.
+func (p *BenchmarkProfile) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if len(p.Scenarios) == 0 && p.Metadata == nil && p.Sync == nil && p.Protocols == nil && p.Soak == nil {
+		return fmt.Errorf("profile %q has no scenarios", p.Name)
+	}
+	switch p.Checksum = strings.ToLower(p.Checksum); p.Checksum {
+	case "":
+		p.Checksum = ChecksumSHA256
+	case ChecksumSHA256, ChecksumMD5, ChecksumNone:
+	default:
+		return fmt.Errorf("profile %q: checksum must be %s, %s or %s", p.Name, ChecksumSHA256, ChecksumMD5, ChecksumNone)
+	}
+	if p.Metadata != nil {
+		if err := p.Metadata.Validate(); err != nil {
+			return fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+	}
+	if p.Sync != nil {
+		if err := p.Sync.Validate(); err != nil {
+			return fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+	}
+	if p.Protocols != nil {
+		if err := p.Protocols.Validate(); err != nil {
+			return fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+	}
+	if p.Soak != nil {
+		if err := p.Soak.Validate(); err != nil {
+			return fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+	}
+	seen := map[string]bool{}
+	for i := range p.Scenarios {
+		s := &p.Scenarios[i]
+		if s.Name == "" {
+			return fmt.Errorf("scenario %d: name is required", i+1)
+		}
+		if strings.ContainsAny(s.Name, "/\\ ") {
+			return fmt.Errorf("scenario %q: name must not contain spaces or slashes", s.Name)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("scenario %q: duplicate name", s.Name)
+		}
+		seen[s.Name] = true
+		if s.Count == 0 {
+			s.Count = 1
+		}
+		if s.Parallel == 0 {
+			s.Parallel = 1
+		}
+		if s.Direction == "" {
+			s.Direction = DirectionBoth
+		}
+		if s.Chunked && s.ChunkSize == 0 {
+			s.ChunkSize = DefaultChunkSize
+		}
+		if s.Chunked && s.ChunkParallel == 0 {
+			s.ChunkParallel = 1
+		}
+		switch {
+		case s.Count < 0 || s.Parallel < 0:
+			return fmt.Errorf("scenario %q: count and parallel must be positive", s.Name)
+		case s.Size <= 0:
+			return fmt.Errorf("scenario %q: size must be positive", s.Name)
+		case s.Direction != DirectionUpload && s.Direction != DirectionDownload && s.Direction != DirectionBoth:
+			return fmt.Errorf("scenario %q: invalid direction %q", s.Name, s.Direction)
+		case s.Chunked && s.Count != 1:
+			return fmt.Errorf("scenario %q: chunked scenarios upload exactly one file", s.Name)
+		case !s.Chunked && (s.ChunkSize != 0 || s.ChunkParallel != 0 || s.CompareSequential):
+			return fmt.Errorf("scenario %q: chunk_size, chunk_parallel and compare_sequential require chunked: true", s.Name)
+		case s.ChunkSize < 0 || s.ChunkParallel < 0:
+			return fmt.Errorf("scenario %q: chunk_size and chunk_parallel must be positive", s.Name)
+		case s.Chunked && int64(s.Size)/int64(s.ChunkSize) >= MaxChunkCount:
+			return fmt.Errorf("scenario %q: chunk_size %s yields more than %d chunks", s.Name, s.ChunkSize, MaxChunkCount)
+		case s.Chunked && int64(s.ChunkSize)*int64(s.ChunkParallel) > MaxChunkMemory:
+			return fmt.Errorf("scenario %q: chunk_size %s × chunk_parallel %d exceeds %s of chunk buffers", s.Name, s.ChunkSize, s.ChunkParallel, ByteSize(MaxChunkMemory))
+		}
+	}
+	return nil
+}
+
+// BuiltinProfiles returns the profiles shipped with the tool.
+// "standard" reproduces the original fixed transfer suite.
+func BuiltinProfiles() []BenchmarkProfile {
+	return []BenchmarkProfile{
+		{
+			Name:          "quick",
+			Description:   "Smoke test (~30s) for slow or metered WAN links",
+			SkipSpeedtest: true,
+			Scenarios: []Scenario{
+				{Name: "small", Label: "Small Files", Count: 3, Size: 256 * 1024, Parallel: 3, Direction: DirectionBoth},
+				{Name: "medium", Label: "Medium Files", Count: 1, Size: 5 * 1024 * 1024, Parallel: 1, Direction: DirectionBoth},
+			},
+		},
+		{
+			Name:        DefaultProfileName,
+			Description: "Balanced default suite",
+			Scenarios: []Scenario{
+				{Name: "small", Label: "Small Files", Count: SmallFileCount, Size: SmallFileSize, Parallel: SmallFileParallel, Direction: DirectionBoth},
+				{Name: "medium", Label: "Medium Files", Count: MediumFileCount, Size: MediumFileSize, Parallel: MediumFileParallel, Direction: DirectionBoth},
+				{Name: "large", Label: "Large File", Count: 1, Size: LargeFileSize, Parallel: 1, Direction: DirectionBoth, Chunked: true},
+			},
+		},
+		{
+			Name:        "thorough",
+			Description: "Multi-GB transfers for datacenter links",
+			Scenarios: []Scenario{
+				{Name: "small", Label: "Small Files", Count: 50, Size: SmallFileSize, Parallel: 10, Direction: DirectionBoth},
+				{Name: "medium", Label: "Medium Files", Count: 10, Size: 20 * 1024 * 1024, Parallel: 2, Direction: DirectionBoth},
+				{Name: "large", Label: "Large File", Count: 1, Size: 1024 * 1024 * 1024, Parallel: 1, Direction: DirectionBoth, Chunked: true},
+				{Name: "huge", Label: "Huge File", Count: 1, Size: 4 * 1024 * 1024 * 1024, Parallel: 1, Direction: DirectionBoth, Chunked: true},
+			},
+		},
+		{
+			Name:          "metadata",
+			Description:   "PROPFIND listings of a 1000-file folder tree at depth 0, 1 and infinity",
+			SkipSpeedtest: true,
+			Metadata:      &MetadataScenario{Dirs: 50, Files: 20},
+		},
+		{
+			Name:          "sync",
+			Description:   "Desktop client sync cycles: discovery, changes, renames, deletes, conflict",
+			SkipSpeedtest: true,
+			Sync:          &SyncScenario{},
+		},
+		{
+			Name:          "protocols",
+			Description:   "Same transfers over HTTP/1.1, HTTP/2 and HTTP/3 (QUIC) for comparison",
+			SkipSpeedtest: true,
+			Protocols:     &ProtocolComparison{},
+		},
+		{
+			Name:          "soak",
+			Description:   "30 min sustained mixed load to detect degradation over time",
+			SkipSpeedtest: true,
+			Soak:          &SoakScenario{Duration: Duration(30 * time.Minute)},
+		},
+	}
+}
+
+// DefaultProfileName is used when no profile is selected.
+const DefaultProfileName = "standard"
+
+// DefaultProfile returns the built-in default profile.
+func DefaultProfile() *BenchmarkProfile {
+	for _, p := range BuiltinProfiles() {
+		if p.Name == DefaultProfileName {
+			p := p
+			_ = p.Validate()
+			return &p
+		}
+	}
+	return nil
+}
+
+// LoadProfile reads a profile from a YAML (.yaml/.yml) or JSON file.
+func LoadProfile(path string) (*BenchmarkProfile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p BenchmarkProfile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &p)
+	default:
+		err = json.Unmarshal(b, &p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profile %s: %w", path, err)
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ProfilesDir is the directory scanned for user-defined profiles.
+func ProfilesDir() string {
+	return filepath.Join(DataDir(), "profiles")
+}
+
+// DataDir is the per-user directory for persistent tool data.
+func DataDir() string {
+	if dir := os.Getenv("NEXTCLOUD_PERF_DATA_DIR"); dir != "" {
+		return dir
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "nextcloud-perf")
+}
+
+// AvailableProfiles returns the built-in profiles followed by all valid
+// profiles found in ProfilesDir, sorted by name. User profiles cannot
+// shadow built-in names.
+func AvailableProfiles() []BenchmarkProfile {
+	profiles := BuiltinProfiles()
+	builtin := map[string]bool{}
+	for _, p := range profiles {
+		builtin[p.Name] = true
+	}
+
+	var user []BenchmarkProfile
+	for _, pattern := range []string{"*.yaml", "*.yml", "*.json"} {
+		matches, _ := filepath.Glob(filepath.Join(ProfilesDir(), pattern))
+		for _, m := range matches {
+			p, err := LoadProfile(m)
+			if err != nil || builtin[p.Name] {
+				continue
+			}
+			user = append(user, *p)
+		}
+	}
+	sort.Slice(user, func(i, j int) bool { return user[i].Name < user[j].Name })
+	return append(profiles, user...)
+}
+
+// ResolveProfile returns the profile with the given name, or loads it from
+// nameOrPath if it is a file. An empty string selects the default profile.
+func ResolveProfile(nameOrPath string) (*BenchmarkProfile, error) {
+	if nameOrPath == "" {
+		nameOrPath = DefaultProfileName
+	}
+	for _, p := range AvailableProfiles() {
+		if p.Name == nameOrPath {
+			p := p
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			return &p, nil
+		}
+	}
+	if _, err := os.Stat(nameOrPath); err == nil {
+		return LoadProfile(nameOrPath)
+	}
+	return nil, fmt.Errorf("unknown profile %q", nameOrPath)
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]ByteSize{
+		"512KB":   512 * 1024,
+		"5 MB":    5 * 1024 * 1024,
+		"2GiB":    2 * 1024 * 1024 * 1024,
+		"1048576": 1024 * 1024,
+		"1.5M":    1536 * 1024,
+	}
+	for in, want := range cases {
+		got, err := ParseByteSize(in)
+		if err != nil {
+			t.Errorf("ParseByteSize(%q) failed: %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseByteSize(%q) = %d, want %d", in, got, want)
+		}
+	}
+	if _, err := ParseByteSize("lots"); err == nil {
+		t.Error("Expected error for invalid size")
+	}
+}
+
+func TestLoadProfileYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wan.yaml")
+	content := `
+name: wan
+description: WAN smoke test
+scenarios:
+  - name: tiny
+    count: 10
+    size: 64KB
+    parallel: 4
+    direction: upload
+  - name: big
+    size: 1GB
+    chunked: true
+metadata:
+  dirs: 20
+  files: 50
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	if len(p.Scenarios) != 2 {
+		t.Fatalf("Expected 2 scenarios, got %d", len(p.Scenarios))
+	}
+	tiny := p.Scenarios[0]
+	if tiny.Size != 64*1024 || tiny.Parallel != 4 || !tiny.Uploads() || tiny.Downloads() {
+		t.Errorf("Unexpected scenario: %+v", tiny)
+	}
+	big := p.Scenarios[1]
+	if big.Count != 1 || big.Direction != DirectionBoth || big.DisplayLabel() != "big (1GB Chunked)" {
+		t.Errorf("Defaults not applied: %+v", big)
+	}
+	if p.Metadata == nil || p.Metadata.Dirs != 20 || p.Metadata.Files != 50 || p.Metadata.Iterations != MetadataIterations {
+		t.Errorf("Unexpected metadata scenario: %+v", p.Metadata)
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	p := BenchmarkProfile{Name: "bad", Scenarios: []Scenario{{Name: "x", Count: 2, Size: 1024, Chunked: true}}}
+	if err := p.Validate(); err == nil {
+		t.Error("Expected error for chunked scenario with count > 1")
+	}
+
+	p = BenchmarkProfile{Name: "bad", Scenarios: []Scenario{{Name: "x", Size: 1024, ChunkParallel: 4}}}
+	if err := p.Validate(); err == nil {
+		t.Error("Expected error for chunk_parallel on a non-chunked scenario")
+	}
+
+	p = BenchmarkProfile{Name: "bad", Scenarios: []Scenario{{Name: "x", Size: 1 << 30, Chunked: true, ChunkSize: 100 << 20, ChunkParallel: 64}}}
+	if err := p.Validate(); err == nil {
+		t.Error("Expected error for chunk buffers above MaxChunkMemory")
+	}
+
+	p = BenchmarkProfile{Name: "ok", Scenarios: []Scenario{{Name: "x", Size: 1 << 30, Chunked: true, ChunkParallel: 4}}}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if p.Scenarios[0].ChunkSize != DefaultChunkSize || p.Scenarios[0].DisplayLabel() != "x (1GB Chunked, 4 parallel)" {
+		t.Errorf("Unexpected chunk defaults: %+v", p.Scenarios[0])
+	}
+
+	if p.Checksum != ChecksumSHA256 {
+		t.Errorf("Expected checksum to default to %s, got %q", ChecksumSHA256, p.Checksum)
+	}
+	p.Checksum = "MD5"
+	if err := p.Validate(); err != nil || p.Checksum != ChecksumMD5 {
+		t.Errorf("Expected checksum md5, got %q (%v)", p.Checksum, err)
+	}
+	p.Checksum = "crc32"
+	if err := p.Validate(); err == nil {
+		t.Error("Expected error for an unknown checksum type")
+	}
+
+	for _, builtin := range BuiltinProfiles() {
+		builtin := builtin
+		if err := builtin.Validate(); err != nil {
+			t.Errorf("Built-in profile %s invalid: %v", builtin.Name, err)
+		}
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	t.Setenv("NEXTCLOUD_PERF_DATA_DIR", t.TempDir())
+	p, err := ResolveProfile("")
+	if err != nil || p.Name != DefaultProfileName {
+		t.Fatalf("Expected default profile, got %v, %v", p, err)
+	}
+	if p, err := ResolveProfile("metadata"); err != nil || p.Metadata == nil || p.Metadata.Dirs*p.Metadata.Files != 1000 || p.Metadata.Iterations != MetadataIterations {
+		t.Errorf("Expected the metadata profile with defaults applied, got %+v (%v)", p, err)
+	}
+	if _, err := ResolveProfile("does-not-exist"); err == nil {
+		t.Error("Expected error for unknown profile")
+	}
+}
+
+func TestSoakScenario(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "soak.yaml")
+	content := `
+name: soak
+soak:
+  duration: 30m
+  file_size: 2MB
+  mix: {upload: 1, download: 3, list: 0}
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	s := p.Soak
+	if s == nil || s.Duration != Duration(30*time.Minute) || s.Interval != Duration(SoakInterval) || s.FileSize != 2*1024*1024 {
+		t.Fatalf("Unexpected soak scenario: %+v", s)
+	}
+	if s.Parallel != SoakParallel || s.Files != SoakFiles || s.Mix != (SoakMix{Upload: 1, Download: 3}) {
+		t.Errorf("Defaults not applied: %+v", s)
+	}
+
+	short := SoakScenario{Duration: Duration(2 * time.Minute)}
+	if err := short.Validate(); err != nil || short.Interval != Duration(12*time.Second) {
+		t.Errorf("Expected 12s interval for a 2m soak, got %s (%v)", short.Interval, err)
+	}
+	bad := SoakScenario{Duration: Duration(time.Minute), Interval: Duration(time.Hour)}
+	if err := bad.Validate(); err == nil {
+		t.Error("Expected error for an interval longer than the duration")
+	}
+	if _, err := ParseDuration("soon"); err == nil {
+		t.Error("Expected error for invalid duration")
+	}
+	if d, err := ParseDuration("90"); err != nil || d != Duration(90*time.Second) {
+		t.Errorf("Expected plain numbers as seconds, got %s (%v)", d, err)
+	}
+}
+
+func TestSyncScenario(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.yaml")
+	content := `
+name: sync
+sync:
+  dirs: 4
+  files: 25
+  renames: 2
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	s := p.Sync
+	if s == nil || s.Dirs != 4 || s.Files != 25 || s.Renames != 2 {
+		t.Fatalf("Unexpected sync scenario: %+v", s)
+	}
+	if s.FileSize != SyncFileSize || s.Changes != SyncChanges || s.Deletes != SyncDeletes || s.Cycles != SyncCycles || s.Parallel != SyncParallel {
+		t.Errorf("Defaults not applied: %+v", s)
+	}
+
+	// 3 cycles delete 15 of 40 files, leaving fewer than the 31 a cycle touches
+	small := SyncScenario{Dirs: 4, Files: 10}
+	if err := small.Validate(); err == nil {
+		t.Error("Expected error for a tree too small for the cycles")
+	}
+}
+
+func TestProtocolComparison(t *testing.T) {
+	c := ProtocolComparison{Versions: []string{"H2", "http/1.1"}}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if c.Versions[0] != ProtocolHTTP2 || c.Files != ProtocolFiles || c.FileSize != ProtocolFileSize || c.LargeSize != ProtocolLargeSize {
+		t.Errorf("Defaults not applied: %+v", c)
+	}
+	all := ProtocolComparison{}
+	if err := all.Validate(); err != nil || len(all.Versions) != 3 {
+		t.Errorf("Expected all protocols by default, got %v (%v)", all.Versions, err)
+	}
+	for _, bad := range [][]string{{"spdy"}, {"h2", "h2"}} {
+		c := ProtocolComparison{Versions: bad}
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected error for versions %v", bad)
+		}
+	}
+}
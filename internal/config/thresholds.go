@@ -0,0 +1,84 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ThresholdRule bounds one report metric (see report.Metrics for the keys).
+// Metric may contain '*' wildcards, e.g. "scenario.*.upload.speed_mbps";
+// the rule then applies to every matching metric.
+type ThresholdRule struct {
+	Name     string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Metric   string   `json:"metric" yaml:"metric"`
+	Min      *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+	Optional bool     `json:"optional,omitempty" yaml:"optional,omitempty"` // Pass if the metric was not measured
+}
+
+// DisplayName returns Name or, if empty, the metric key.
+func (r ThresholdRule) DisplayName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Metric
+}
+
+// Thresholds is a set of rules a report must satisfy, e.g. to gate a deployment.
+type Thresholds struct {
+	Name  string          `json:"name,omitempty" yaml:"name,omitempty"`
+	Rules []ThresholdRule `json:"rules" yaml:"rules"`
+}
+
+// Validate checks that every rule has a valid metric pattern and at least one bound.
+func (t *Thresholds) Validate() error {
+	if len(t.Rules) == 0 {
+		return fmt.Errorf("thresholds: no rules defined")
+	}
+	for i, r := range t.Rules {
+		if r.Metric == "" {
+			return fmt.Errorf("rule %d: metric is required", i+1)
+		}
+		if _, err := path.Match(r.Metric, ""); err != nil {
+			return fmt.Errorf("rule %q: invalid metric pattern: %w", r.DisplayName(), err)
+		}
+		if r.Min == nil && r.Max == nil {
+			return fmt.Errorf("rule %q: min or max is required", r.DisplayName())
+		}
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			return fmt.Errorf("rule %q: min is greater than max", r.DisplayName())
+		}
+	}
+	return nil
+}
+
+// LoadThresholds reads thresholds from a YAML (.yaml/.yml) or JSON file.
+func LoadThresholds(file string) (*Thresholds, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var t Thresholds
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &t)
+	default:
+		err = json.Unmarshal(b, &t)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse thresholds %s: %w", file, err)
+	}
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
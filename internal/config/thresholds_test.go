@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadThresholds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.yaml")
+	content := `
+rules:
+  - name: Large upload
+    metric: scenario.large.upload.speed_mbps
+    min: 50
+  - metric: metadata.depth_1.p95_ms
+    max: 500
+    optional: true
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	th, err := LoadThresholds(path)
+	if err != nil {
+		t.Fatalf("LoadThresholds failed: %v", err)
+	}
+	if th.Name != "ci" {
+		t.Errorf("Expected name from file name, got %q", th.Name)
+	}
+	if len(th.Rules) != 2 || *th.Rules[0].Min != 50 || th.Rules[0].Max != nil || !th.Rules[1].Optional {
+		t.Errorf("Unexpected rules: %+v", th.Rules)
+	}
+	if th.Rules[1].DisplayName() != "metadata.depth_1.p95_ms" {
+		t.Errorf("Expected metric as display name, got %q", th.Rules[1].DisplayName())
+	}
+}
+
+func TestThresholdsValidate(t *testing.T) {
+	one, two := 1.0, 2.0
+	tests := []struct {
+		name string
+		th   Thresholds
+	}{
+		{"no rules", Thresholds{}},
+		{"no metric", Thresholds{Rules: []ThresholdRule{{Min: &one}}}},
+		{"no bound", Thresholds{Rules: []ThresholdRule{{Metric: "network.dns_ms"}}}},
+		{"min above max", Thresholds{Rules: []ThresholdRule{{Metric: "network.dns_ms", Min: &two, Max: &one}}}},
+		{"bad pattern", Thresholds{Rules: []ThresholdRule{{Metric: "scenario.[", Max: &one}}}},
+	}
+	for _, tt := range tests {
+		if err := tt.th.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", tt.name)
+		}
+	}
+}
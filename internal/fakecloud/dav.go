@@ -0,0 +1,491 @@
+package fakecloud
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	davRoot     = "/remote.php/dav/"
+	filesRoot   = davRoot + "files/"
+	uploadsRoot = davRoot + "uploads/"
+)
+
+// node is a file or folder of the in-memory tree, keyed by its cleaned URL path.
+type node struct {
+	dir      bool
+	data     []byte
+	size     int64 // Used instead of data with DiscardData
+	id       int
+	etag     string
+	modTime  time.Time
+	checksum string // OC-Checksum of the upload, "<TYPE>:<hex>"
+}
+
+// body returns a reader of the file content: the stored data or, with
+// DiscardData, zeros of the stored size, which are never held in memory.
+func (n *node) body() io.Reader {
+	if n.data != nil {
+		return bytes.NewReader(n.data)
+	}
+	return io.LimitReader(zeros{}, n.size)
+}
+
+// zeros reads an endless stream of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func (n *node) length() int64 {
+	if n.data != nil {
+		return int64(len(n.data))
+	}
+	return n.size
+}
+
+// davPath joins a root and a relative name into a cleaned tree key.
+func davPath(root, name string) string {
+	return path.Clean(root + "/" + name)
+}
+
+// newNode creates a node with a fresh file ID and ETag. Callers must hold mu.
+func (s *Server) newNode(dir bool) *node {
+	s.nextID++
+	return &node{dir: dir, id: s.nextID, etag: fmt.Sprintf("%x", time.Now().UnixNano()+int64(s.nextID)), modTime: time.Now()}
+}
+
+// mkdirAll creates p and its parents. Callers must hold mu or own s exclusively.
+func (s *Server) mkdirAll(p string) {
+	p = path.Clean(p)
+	for dir := p; dir != "/" && dir != "."; dir = path.Dir(dir) {
+		if s.nodes[dir] == nil {
+			s.nodes[dir] = s.newNode(true)
+		}
+	}
+}
+
+// children returns the keys directly below dir, sorted. Callers must hold mu.
+func (s *Server) children(dir string, recursive bool) []string {
+	var out []string
+	prefix := dir + "/"
+	for k := range s.nodes {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if recursive || !strings.Contains(k[len(prefix):], "/") {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// removeAll deletes p and everything below it. Callers must hold mu.
+func (s *Server) removeAll(p string) {
+	for _, k := range s.children(p, true) {
+		delete(s.nodes, k)
+	}
+	delete(s.nodes, p)
+}
+
+// touchParents updates the ETags of all folders above p, like Nextcloud's
+// ETag propagation. Callers must hold mu.
+func (s *Server) touchParents(p string) {
+	for dir := path.Dir(p); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		if n := s.nodes[dir]; n != nil {
+			n.etag = fmt.Sprintf("%x", time.Now().UnixNano())
+			n.modTime = time.Now()
+		}
+	}
+}
+
+func (s *Server) handleDAV(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+	// Users may only access their own files and uploads
+	p := path.Clean(r.URL.Path)
+	if !within(p, filesRoot+user) && !within(p, uploadsRoot+user) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case "PROPFIND":
+		s.handlePropfind(w, r, p)
+	case "MKCOL":
+		s.handleMkcol(w, p)
+	case http.MethodPut:
+		s.handlePut(w, r, p)
+	case http.MethodGet, http.MethodHead:
+		s.handleGet(w, r, p)
+	case http.MethodDelete:
+		s.handleDelete(w, p)
+	case "MOVE":
+		s.handleMove(w, r, p, user)
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1, 3")
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, MKCOL, MOVE")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func within(p, root string) bool {
+	return p == root || strings.HasPrefix(p, root+"/")
+}
+
+func (s *Server) handleMkcol(w http.ResponseWriter, p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nodes[p] != nil {
+		http.Error(w, "The resource you tried to create already exists", http.StatusMethodNotAllowed)
+		return
+	}
+	if parent := s.nodes[path.Dir(p)]; parent == nil || !parent.dir {
+		http.Error(w, "Parent node does not exist", http.StatusConflict)
+		return
+	}
+	s.nodes[p] = s.newNode(true)
+	s.touchParents(p)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handlePut(w http.ResponseWriter, r *http.Request, p string) {
+	s.mu.Lock()
+	parent := s.nodes[path.Dir(p)]
+	existing := s.nodes[p]
+	discard := s.opts.DiscardData
+	s.mu.Unlock()
+	if parent == nil || !parent.dir {
+		http.Error(w, "Parent node does not exist", http.StatusConflict)
+		return
+	}
+	if existing != nil && existing.dir {
+		http.Error(w, "A folder exists at this path", http.StatusConflict)
+		return
+	}
+
+	// Read the body without holding the lock, uploads may be throttled
+	var data []byte
+	var size int64
+	var err error
+	if discard {
+		size, err = io.Copy(io.Discard, r.Body)
+	} else {
+		data, err = io.ReadAll(r.Body)
+		size = int64(len(data))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if data == nil && !discard {
+		data = []byte{}
+	}
+	checksum := r.Header.Get(checksumHeader)
+	if err := verifyChecksum(checksum, data, discard); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Checked after the body was read, another upload may have finished meanwhile
+	existing = s.nodes[p]
+	if !preconditionMet(r, existing) {
+		http.Error(w, "An If-Match or If-None-Match condition failed", http.StatusPreconditionFailed)
+		return
+	}
+	n := s.newNode(false)
+	if existing != nil {
+		n.id = existing.id
+	}
+	n.data, n.size, n.checksum = data, size, checksum
+	s.nodes[p] = n
+	s.touchParents(p)
+	w.Header().Set("ETag", `"`+n.etag+`"`)
+	w.Header().Set("OC-ETag", `"`+n.etag+`"`)
+	w.Header().Set("OC-FileId", fmt.Sprintf("%08d", n.id))
+	if existing != nil {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// preconditionMet evaluates the If-Match and If-None-Match headers the sync
+// client sends with uploads against the current node, nil if none exists.
+func preconditionMet(r *http.Request, n *node) bool {
+	if m := r.Header.Get("If-Match"); m != "" {
+		return n != nil && (m == "*" || strings.Trim(m, `"`) == n.etag)
+	}
+	if m := r.Header.Get("If-None-Match"); m != "" {
+		return n == nil || (m != "*" && strings.Trim(m, `"`) != n.etag)
+	}
+	return true
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, p string) {
+	s.mu.Lock()
+	n := s.nodes[p]
+	var body io.Reader
+	var size int64
+	if n != nil && !n.dir {
+		body, size = n.body(), n.length()
+	}
+	s.mu.Unlock()
+	if n == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if n.dir {
+		http.Error(w, "Cannot download a folder", http.StatusNotImplemented)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("ETag", `"`+n.etag+`"`)
+	w.Header().Set("Last-Modified", n.modTime.UTC().Format(http.TimeFormat))
+	if n.checksum != "" {
+		w.Header().Set(checksumHeader, n.checksum)
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, body)
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nodes[p] == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if strings.Count(p, "/") <= strings.Count(filesRoot, "/") {
+		http.Error(w, "Cannot delete the home folder", http.StatusForbidden)
+		return
+	}
+	s.removeAll(p)
+	s.touchParents(p)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMove renames a node. Moving "<upload folder>/.file" assembles the
+// chunks of a Chunking V2 upload in numeric order into the destination.
+func (s *Server) handleMove(w http.ResponseWriter, r *http.Request, p, user string) {
+	dest, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil || dest.Path == "" {
+		http.Error(w, "Missing or invalid Destination header", http.StatusBadRequest)
+		return
+	}
+	destPath := path.Clean(dest.Path)
+	if !within(destPath, filesRoot+user) {
+		http.Error(w, "Destination outside of the user's files", http.StatusForbidden)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if parent := s.nodes[path.Dir(destPath)]; parent == nil || !parent.dir {
+		http.Error(w, "Destination parent does not exist", http.StatusConflict)
+		return
+	}
+	existing := s.nodes[destPath]
+	if existing != nil && r.Header.Get("Overwrite") == "F" {
+		http.Error(w, "Destination exists", http.StatusPreconditionFailed)
+		return
+	}
+
+	var n *node
+	moved := map[string]*node{} // Children of a moved folder by their new path
+	if path.Base(p) == ".file" && within(p, uploadsRoot+user) {
+		folder := path.Dir(p)
+		if s.nodes[folder] == nil {
+			http.Error(w, "Upload folder not found", http.StatusNotFound)
+			return
+		}
+		if n, err = s.assemble(folder, r.Header.Get("OC-Total-Length")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n.checksum = r.Header.Get(checksumHeader)
+		if err := verifyChecksum(n.checksum, n.data, s.opts.DiscardData); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.removeAll(folder)
+	} else {
+		if n = s.nodes[p]; n == nil {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		for _, k := range s.children(p, true) {
+			moved[destPath+strings.TrimPrefix(k, p)] = s.nodes[k]
+		}
+		s.removeAll(p)
+		s.touchParents(p)
+	}
+
+	s.removeAll(destPath)
+	s.nodes[destPath] = n
+	for k, c := range moved {
+		s.nodes[k] = c
+	}
+	s.touchParents(destPath)
+	w.Header().Set("ETag", `"`+n.etag+`"`)
+	if existing != nil {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// assemble concatenates the chunks of an upload folder. Callers must hold mu.
+func (s *Server) assemble(folder, totalLength string) (*node, error) {
+	type chunk struct {
+		idx int
+		n   *node
+	}
+	var chunks []chunk
+	for _, k := range s.children(folder, false) {
+		idx, err := strconv.Atoi(path.Base(k))
+		if err != nil {
+			continue
+		}
+		chunks = append(chunks, chunk{idx, s.nodes[k]})
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].idx < chunks[j].idx })
+
+	n := s.newNode(false)
+	var buf bytes.Buffer
+	for _, c := range chunks {
+		if s.opts.DiscardData {
+			n.size += c.n.length()
+		} else {
+			buf.Write(c.n.data)
+		}
+	}
+	if !s.opts.DiscardData {
+		n.data = buf.Bytes()
+	}
+	if totalLength != "" {
+		want, err := strconv.ParseInt(totalLength, 10, 64)
+		if err != nil || want != n.length() {
+			return nil, fmt.Errorf("expected filesize of %s bytes but read %d bytes from the chunks", totalLength, n.length())
+		}
+	}
+	return n, nil
+}
+
+func (s *Server) handlePropfind(w http.ResponseWriter, r *http.Request, p string) {
+	io.Copy(io.Discard, r.Body) // All known properties are returned regardless of the request
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.nodes[p]
+	if n == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	keys := []string{p}
+	if n.dir {
+		switch r.Header.Get("Depth") {
+		case "0":
+		case "infinity":
+			keys = append(keys, s.children(p, true)...)
+		default:
+			keys = append(keys, s.children(p, false)...)
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0"?>` + "\n")
+	b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns" xmlns:nc="http://nextcloud.org/ns">`)
+	for _, k := range keys {
+		s.writeResponse(&b, k, s.nodes[k])
+	}
+	b.WriteString(`</d:multistatus>`)
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write(b.Bytes())
+}
+
+// writeResponse writes the multistatus entry of one node. Callers must hold mu.
+func (s *Server) writeResponse(b *bytes.Buffer, key string, n *node) {
+	href := (&url.URL{Path: key}).EscapedPath()
+	if n.dir {
+		href += "/"
+	}
+	b.WriteString(`<d:response><d:href>`)
+	xml.EscapeText(b, []byte(href))
+	b.WriteString(`</d:href><d:propstat><d:prop>`)
+	if n.dir {
+		var size int64
+		for _, k := range s.children(key, true) {
+			size += s.nodes[k].length()
+		}
+		fmt.Fprintf(b, `<d:resourcetype><d:collection/></d:resourcetype><oc:size>%d</oc:size><oc:permissions>RGDNVCK</oc:permissions>`, size)
+	} else {
+		fmt.Fprintf(b, `<d:resourcetype/><d:getcontentlength>%d</d:getcontentlength><d:getcontenttype>application/octet-stream</d:getcontenttype><oc:permissions>RGDNVW</oc:permissions>`, n.length())
+		if n.checksum != "" {
+			b.WriteString(`<oc:checksums><oc:checksum>`)
+			xml.EscapeText(b, []byte(n.checksum))
+			b.WriteString(`</oc:checksum></oc:checksums>`)
+		}
+	}
+	fmt.Fprintf(b, `<d:getetag>&quot;%s&quot;</d:getetag><oc:fileid>%d</oc:fileid><d:getlastmodified>%s</d:getlastmodified>`,
+		n.etag, n.id, n.modTime.UTC().Format(http.TimeFormat))
+	b.WriteString(`</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`)
+}
+
+// checksumHeader carries the checksum of an upload, see webdav.ChecksumHeader.
+const checksumHeader = "OC-Checksum"
+
+// verifyChecksum checks the OC-Checksum header of an upload against the
+// received data for the types Nextcloud computes. Other types, and all types
+// with DiscardData, are stored without verification.
+func verifyChecksum(header string, data []byte, discard bool) error {
+	typ, want, ok := strings.Cut(header, ":")
+	if !ok || discard {
+		return nil
+	}
+	var h hash.Hash
+	switch strings.ToUpper(typ) {
+	case "MD5":
+		h = md5.New()
+	case "SHA1":
+		h = sha1.New()
+	case "SHA256":
+		h = sha256.New()
+	default:
+		return nil
+	}
+	h.Write(data)
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return errors.New("The computed checksum does not match the one received from the client.")
+	}
+	return nil
+}
@@ -0,0 +1,464 @@
+// Package fakecloud implements an in-process fake Nextcloud server for tests
+// and demos. It serves status.php, the OCS capabilities and user provisioning
+// API and an in-memory WebDAV tree including Chunking V2 uploads and Login
+// Flow v2, and can simulate latency, limited bandwidth, failing requests and
+// maintenance mode.
+package fakecloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults used when Options leave them empty.
+const (
+	DefaultUser     = "admin"
+	DefaultPassword = "admin"
+	DefaultVersion  = "30.0.4"
+)
+
+// Options configures a fake server. They can be changed later via the setters.
+type Options struct {
+	Users       map[string]string // Username -> password; default DefaultUser/DefaultPassword
+	Version     string            // Reported server version, default DefaultVersion
+	Latency     time.Duration     // Added to every request before it is handled
+	Bandwidth   int64             // Bytes per second for each request and response body; 0 = unlimited
+	Maintenance bool              // status.php reports maintenance, all other endpoints return 503
+	DiscardData bool              // Keep only file sizes (downloads return zeros), for multi-GB demos
+	Faults      []Fault
+	Logger      *log.Logger // Logs every request if set
+}
+
+// Fault makes matching requests fail, either with an HTTP status, by
+// closing the connection or by damaging the response body like a broken
+// proxy. Matching requests are counted per fault: the first Skip matches
+// pass, the next Times matches fail (0 = all of them).
+type Fault struct {
+	Method     string // Empty matches all methods
+	Path       string // path.Match pattern for the URL path, empty matches all
+	Status     int    // Response status, e.g. 503; ignored if Reset is set
+	RetryAfter int    // Retry-After header in seconds, 0 = none
+	Reset      bool   // Close the connection without a response
+	Truncate   bool   // Send only half of the body, without Content-Length
+	Corrupt    bool   // Flip the first byte of the body
+	Skip       int
+	Times      int
+}
+
+type faultState struct {
+	Fault
+	matched int
+}
+
+// match reports whether the fault applies to this request and counts it.
+func (f *faultState) match(r *http.Request) bool {
+	if f.Method != "" && !strings.EqualFold(f.Method, r.Method) {
+		return false
+	}
+	if f.Path != "" {
+		if ok, _ := path.Match(f.Path, r.URL.Path); !ok {
+			return false
+		}
+	}
+	f.matched++
+	if f.matched <= f.Skip {
+		return false
+	}
+	return f.Times == 0 || f.matched <= f.Skip+f.Times
+}
+
+// Request is one entry of the request log.
+type Request struct {
+	Method string
+	Path   string
+	Status int
+}
+
+// Server is a fake Nextcloud. It implements http.Handler, e.g. for httptest.NewServer.
+type Server struct {
+	mu       sync.Mutex
+	opts     Options
+	faults   []*faultState
+	nodes    map[string]*node
+	nextID   int
+	requests []Request
+
+	logins       map[string]*loginFlow // Poll token -> pending Login Flow v2
+	appPasswords map[string]string     // App password -> user
+}
+
+// New creates a fake server with an empty home folder for every user.
+func New(opts Options) *Server {
+	if len(opts.Users) == 0 {
+		opts.Users = map[string]string{DefaultUser: DefaultPassword}
+	} else {
+		opts.Users = maps.Clone(opts.Users) // Changed by the provisioning API
+	}
+	if opts.Version == "" {
+		opts.Version = DefaultVersion
+	}
+	s := &Server{opts: opts, nodes: map[string]*node{}, logins: map[string]*loginFlow{}, appPasswords: map[string]string{}}
+	for _, f := range opts.Faults {
+		s.faults = append(s.faults, &faultState{Fault: f})
+	}
+	for user := range opts.Users {
+		s.mkdirAll(filesRoot + user)
+		s.mkdirAll(uploadsRoot + user)
+	}
+	return s
+}
+
+// SetLatency changes the delay added to every request.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.Latency = d
+}
+
+// SetBandwidth changes the per-request body bandwidth in bytes per second.
+func (s *Server) SetBandwidth(bytesPerSec int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.Bandwidth = bytesPerSec
+}
+
+// SetMaintenance switches maintenance mode on or off.
+func (s *Server) SetMaintenance(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts.Maintenance = on
+}
+
+// AddFault injects a fault for subsequent requests.
+func (s *Server) AddFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &faultState{Fault: f})
+}
+
+// ClearFaults removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests returns the log of all handled requests.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// File returns the content of a file in a user's home folder.
+// With DiscardData only the size is kept and the content is nil, see Size.
+func (s *Server) File(user, name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.nodes[davPath(filesRoot+user, name)]
+	if n == nil || n.dir {
+		return nil, false
+	}
+	return n.data, true
+}
+
+// Size returns the size of a file in a user's home folder.
+func (s *Server) Size(user, name string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.nodes[davPath(filesRoot+user, name)]
+	if n == nil || n.dir {
+		return 0, false
+	}
+	return n.length(), true
+}
+
+// Exists reports whether a file or folder exists in a user's home folder.
+func (s *Server) Exists(user, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nodes[davPath(filesRoot+user, name)] != nil
+}
+
+// ServeHTTP applies latency and faults and dispatches to the endpoints.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	start := time.Now()
+	defer func() {
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Status: rec.status})
+		s.mu.Unlock()
+		if s.opts.Logger != nil {
+			s.opts.Logger.Printf("%s %s %d %v", r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Millisecond))
+		}
+	}()
+
+	s.mu.Lock()
+	opts := s.opts
+	var fault *Fault
+	for _, f := range s.faults {
+		if f.match(r) {
+			fault = &f.Fault
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if opts.Latency > 0 {
+		select {
+		case <-time.After(opts.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fault != nil && (fault.Truncate || fault.Corrupt) {
+		rec.ResponseWriter = &damagedWriter{ResponseWriter: w, fault: *fault}
+	} else if fault != nil {
+		s.inject(rec, *fault)
+		return
+	}
+	if opts.Bandwidth > 0 {
+		r.Body = &throttledReader{r: r.Body, rate: opts.Bandwidth, start: time.Now()}
+		rec.throttle = &throttledWriter{w: rec.ResponseWriter, rate: opts.Bandwidth, start: time.Now()}
+	}
+
+	switch {
+	case r.URL.Path == "/status.php":
+		s.handleStatus(rec, opts)
+	case opts.Maintenance:
+		http.Error(rec, "Service Unavailable: maintenance mode", http.StatusServiceUnavailable)
+	case r.URL.Path == "/ocs/v1.php/cloud/capabilities" || r.URL.Path == "/ocs/v2.php/cloud/capabilities":
+		if _, ok := s.authenticate(rec, r); ok {
+			s.handleCapabilities(rec, opts)
+		}
+	case strings.HasPrefix(r.URL.Path, loginFlowPath):
+		s.handleLogin(rec, r)
+	case r.URL.Path == appPasswordPath:
+		s.handleRevoke(rec, r)
+	case r.URL.Path == usersPath || strings.HasPrefix(r.URL.Path, usersPath+"/"):
+		s.handleUsers(rec, r)
+	case strings.HasPrefix(r.URL.Path, davRoot):
+		s.handleDAV(rec, r)
+	default:
+		http.NotFound(rec, r)
+	}
+}
+
+func (s *Server) inject(w *statusRecorder, f Fault) {
+	if f.Reset {
+		if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				w.status = 0
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler) // Aborts the response for HTTP/2 and non-hijackable writers
+	}
+	status := f.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	if f.RetryAfter > 0 {
+		w.Header().Set("Retry-After", fmt.Sprint(f.RetryAfter))
+	}
+	http.Error(w, "Injected fault", status)
+}
+
+// authenticate checks basic auth and returns the user name.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user, pass, ok := r.BasicAuth()
+	if ok {
+		s.mu.Lock()
+		want, known := s.opts.Users[user]
+		appUser, isApp := s.appPasswords[pass]
+		s.mu.Unlock()
+		if (known && want == pass) || (isApp && appUser == user) {
+			return user, true
+		}
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="Nextcloud"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	return "", false
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, opts Options) {
+	writeJSON(w, map[string]any{
+		"installed":       true,
+		"maintenance":     opts.Maintenance,
+		"needsDbUpgrade":  false,
+		"version":         opts.Version + ".1",
+		"versionstring":   opts.Version,
+		"edition":         "",
+		"productname":     "Nextcloud",
+		"extendedSupport": false,
+	})
+}
+
+func (s *Server) handleCapabilities(w http.ResponseWriter, opts Options) {
+	var major, minor, micro int
+	fmt.Sscanf(opts.Version, "%d.%d.%d", &major, &minor, &micro)
+	writeJSON(w, map[string]any{
+		"ocs": map[string]any{
+			"meta": map[string]any{"status": "ok", "statuscode": 100, "message": "OK"},
+			"data": map[string]any{
+				"version": map[string]any{
+					"major": major, "minor": minor, "micro": micro,
+					"string": opts.Version, "edition": "",
+				},
+				"capabilities": map[string]any{
+					"core":  map[string]any{"pollinterval": 60},
+					"files": map[string]any{"bigfilechunking": true},
+					"dav":   map[string]any{"chunking": "1.0"},
+				},
+			},
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+// statusRecorder remembers the response status for the request log and
+// optionally throttles the response body.
+type statusRecorder struct {
+	http.ResponseWriter
+	status   int
+	throttle io.Writer
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(p []byte) (int, error) {
+	if w.throttle != nil {
+		return w.throttle.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// damagedWriter passes a successful response through, but truncates its
+// body to half of the Content-Length or flips its first byte.
+type damagedWriter struct {
+	http.ResponseWriter
+	fault     Fault
+	started   bool
+	damage    bool
+	remaining int64 // Body bytes still passed with Truncate
+}
+
+func (w *damagedWriter) WriteHeader(status int) {
+	if w.started {
+		return
+	}
+	w.started = true
+	w.damage = status >= 200 && status < 300
+	w.remaining = -1
+	if w.damage && w.fault.Truncate {
+		if n, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil {
+			// Without Content-Length the short body ends cleanly, as if complete
+			w.Header().Del("Content-Length")
+			w.remaining = n / 2
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *damagedWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.WriteHeader(http.StatusOK)
+	}
+	n := len(p)
+	if !w.damage {
+		return w.ResponseWriter.Write(p)
+	}
+	if w.remaining >= 0 {
+		p = p[:min(int64(len(p)), w.remaining)]
+		w.remaining -= int64(len(p))
+	}
+	if w.fault.Corrupt && len(p) > 0 {
+		w.fault.Corrupt = false
+		if _, err := w.ResponseWriter.Write([]byte{p[0] ^ 0xff}); err != nil {
+			return 0, err
+		}
+		p = p[1:]
+	}
+	if _, err := w.ResponseWriter.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// throttledReader limits reads to rate bytes per second on average.
+type throttledReader struct {
+	r     io.ReadCloser
+	rate  int64
+	start time.Time
+	n     int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if max := t.rate / 10; max > 0 && int64(len(p)) > max {
+		p = p[:max] // Keep bursts to about 100ms worth of data
+	}
+	n, err := t.r.Read(p)
+	t.n += int64(n)
+	pace(t.start, t.n, t.rate)
+	return n, err
+}
+
+func (t *throttledReader) Close() error {
+	return t.r.Close()
+}
+
+// throttledWriter limits writes to rate bytes per second on average.
+type throttledWriter struct {
+	w     io.Writer
+	rate  int64
+	start time.Time
+	n     int64
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	step := int(t.rate / 10)
+	if step <= 0 {
+		step = 1
+	}
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > step {
+			chunk = chunk[:step]
+		}
+		n, err := t.w.Write(chunk)
+		written += n
+		t.n += int64(n)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+		pace(t.start, t.n, t.rate)
+	}
+	return written, nil
+}
+
+// pace sleeps until n bytes are due at rate bytes per second since start.
+func pace(start time.Time, n, rate int64) {
+	due := start.Add(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+	if d := time.Until(due); d > 0 {
+		time.Sleep(d)
+	}
+}
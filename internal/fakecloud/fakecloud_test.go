@@ -0,0 +1,272 @@
+package fakecloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nextcloud-perf/internal/webdav"
+)
+
+func newClient(t *testing.T, opts Options) (*Server, *webdav.Client) {
+	t.Helper()
+	fake := New(opts)
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	client := webdav.NewClient(ts.URL, DefaultUser, DefaultPassword, nil)
+	client.Retry = webdav.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	return fake, client
+}
+
+func TestStatusAndCapabilities(t *testing.T) {
+	fake, client := newClient(t, Options{Version: "29.0.1"})
+	ctx := context.Background()
+
+	status, err := client.GetStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.VersionString != "29.0.1" || status.Maintenance {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	caps, err := client.GetCapabilities(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.Ocs.Data.Version.Major != 29 || !caps.Ocs.Data.Capabilities.Files.BigFileChunking {
+		t.Errorf("Unexpected capabilities: %+v", caps.Ocs.Data)
+	}
+
+	fake.SetMaintenance(true)
+	if status, err = client.GetStatus(ctx); err != nil || !status.Maintenance {
+		t.Errorf("Expected maintenance in status.php, got %+v (%v)", status, err)
+	}
+	if err := client.CreateDirectory(ctx, "x"); err == nil {
+		t.Error("Expected WebDAV to fail in maintenance mode")
+	}
+
+	bad := webdav.NewClient(client.BaseURL, DefaultUser, "wrong", nil)
+	if _, err := bad.GetCapabilities(ctx); err == nil {
+		t.Error("Expected wrong password to be rejected")
+	}
+}
+
+func TestFilesAndPropfind(t *testing.T) {
+	fake, client := newClient(t, Options{})
+	ctx := context.Background()
+
+	if err := client.CreateDirectory(ctx, "tree"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDirectory(ctx, "tree/sub"); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("hello nextcloud")
+	if _, err := client.UploadSimple(ctx, "tree/sub/a.txt", bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.UploadSimple(ctx, "missing/a.txt", bytes.NewReader(content), int64(len(content))); err == nil {
+		t.Error("Expected upload into a missing folder to fail")
+	}
+
+	body, err := client.Download(ctx, "tree/sub/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if !bytes.Equal(got, content) {
+		t.Errorf("Expected %q, got %q", content, got)
+	}
+
+	for depth, want := range map[string]int{webdav.DepthZero: 1, webdav.DepthOne: 2, webdav.DepthInfinity: 3} {
+		res, err := client.Propfind(ctx, "tree", depth, webdav.DefaultProps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != want {
+			t.Errorf("Depth %s: expected %d entries, got %d", depth, want, len(res))
+		}
+		if !res[0].IsCollection || res[0].Size != int64(len(content)) || res[0].FileID == "" {
+			t.Errorf("Depth %s: unexpected root entry %+v", depth, res[0])
+		}
+	}
+
+	if err := client.Delete(ctx, "tree"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.Exists(DefaultUser, "tree/sub/a.txt") {
+		t.Error("Expected DELETE to remove the folder recursively")
+	}
+}
+
+func TestChunkedUpload(t *testing.T) {
+	fake, client := newClient(t, Options{})
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	_, err := client.UploadChunkedWithOptions(context.Background(), "big.bin", bytes.NewReader(data), int64(len(data)),
+		webdav.ChunkOptions{ChunkSize: 1024, Parallel: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := fake.File(DefaultUser, "big.bin")
+	if !ok || !bytes.Equal(got, data) {
+		t.Errorf("Expected chunks to be assembled in order (%d bytes), got %d bytes", len(data), len(got))
+	}
+	for _, r := range fake.Requests() {
+		if r.Method == "MOVE" && r.Status != http.StatusCreated {
+			t.Errorf("Expected MOVE to return 201, got %d", r.Status)
+		}
+	}
+}
+
+func TestChunkedUploadMKCOLReset(t *testing.T) {
+	fake, client := newClient(t, Options{})
+	fake.AddFault(Fault{Method: "MKCOL", Reset: true, Times: 1})
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	_, err := client.UploadChunkedWithOptions(context.Background(), "reset.bin", bytes.NewReader(data), int64(len(data)),
+		webdav.ChunkOptions{ChunkSize: 1024, Parallel: 2})
+	if err != nil {
+		t.Fatalf("Expected the upload to survive a reset MKCOL, got %v", err)
+	}
+	if got, ok := fake.File(DefaultUser, "reset.bin"); !ok || !bytes.Equal(got, data) {
+		t.Errorf("Expected %d bytes, got %d bytes", len(data), len(got))
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	_, client := newClient(t, Options{})
+	ctx := context.Background()
+	data := []byte("hello nextcloud")
+	const sum = "MD5:e7c4923523e3d351c558721d2cff6a23"
+
+	if _, err := client.UploadWithChecksum(ctx, "bad.txt", bytes.NewReader(data), int64(len(data)), "MD5:00000000000000000000000000000000"); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Expected a wrong checksum to be rejected, got %v", err)
+	}
+	if _, err := client.UploadWithChecksum(ctx, "a.txt", bytes.NewReader(data), int64(len(data)), sum); err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Propfind(ctx, "a.txt", webdav.DepthZero, []xml.Name{webdav.PropChecksums})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res[0].Props[webdav.PropChecksums]; !strings.Contains(got, sum) {
+		t.Errorf("Expected oc:checksums to contain %s, got %q", sum, got)
+	}
+}
+
+func TestDamagedResponses(t *testing.T) {
+	fake, client := newClient(t, Options{})
+	ctx := context.Background()
+	data := bytes.Repeat([]byte{1}, 1000)
+	if _, err := client.UploadSimple(ctx, "a.bin", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.AddFault(Fault{Method: "GET", Truncate: true, Times: 1})
+	fake.AddFault(Fault{Method: "GET", Corrupt: true, Times: 1})
+	for _, want := range []struct {
+		size  int
+		first byte
+	}{{500, 1}, {1000, 0xfe}} {
+		body, err := client.Download(ctx, "a.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(body)
+		body.Close()
+		if err != nil || len(got) != want.size || got[0] != want.first {
+			t.Errorf("Expected %d bytes starting with %#x, got %d bytes (%v)", want.size, want.first, len(got), err)
+		}
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	fake, client := newClient(t, Options{})
+	ctx := context.Background()
+	data := make([]byte, 100)
+
+	// Two transient failures are retried by the client
+	fake.AddFault(Fault{Method: "PUT", Path: "*/flaky.bin", Status: http.StatusServiceUnavailable, Times: 2})
+	if _, err := client.UploadSimple(ctx, "flaky.bin", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("Expected retries to succeed, got %v", err)
+	}
+
+	// The second chunk fails permanently and the upload folder is removed
+	fake.ClearFaults()
+	fake.AddFault(Fault{Method: "PUT", Path: "/remote.php/dav/uploads/*/*/00002", Status: http.StatusInsufficientStorage})
+	_, err := client.UploadChunkedWithOptions(ctx, "chunked.bin", bytes.NewReader(make([]byte, 3000)), 3000, webdav.ChunkOptions{ChunkSize: 1024})
+	var chunkErr *webdav.ChunkedUploadError
+	if !errors.As(err, &chunkErr) {
+		t.Fatalf("Expected ChunkedUploadError, got %v", err)
+	}
+	deleted := false
+	for _, r := range fake.Requests() {
+		if r.Method == "DELETE" && strings.HasSuffix(r.Path, chunkErr.TransferID) {
+			deleted = true
+		}
+	}
+	if !deleted {
+		t.Error("Expected the upload folder to be deleted")
+	}
+
+	// Connection resets surface as network errors
+	fake.ClearFaults()
+	fake.AddFault(Fault{Method: "GET", Reset: true})
+	if _, err := client.Download(ctx, "flaky.bin"); err == nil {
+		t.Error("Expected a reset connection to fail the download")
+	}
+}
+
+func TestLatencyAndBandwidth(t *testing.T) {
+	_, client := newClient(t, Options{Latency: 50 * time.Millisecond, Bandwidth: 100 * 1024})
+	ctx := context.Background()
+
+	start := time.Now()
+	if _, err := client.GetStatus(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("Expected latency of at least 50ms, got %v", d)
+	}
+
+	data := make([]byte, 30*1024)
+	start = time.Now()
+	if _, err := client.UploadSimple(ctx, "slow.bin", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	// 30KB at 100KB/s takes about 300ms plus latency
+	if d := time.Since(start); d < 250*time.Millisecond {
+		t.Errorf("Expected bandwidth limit to slow down the upload, took %v", d)
+	}
+}
+
+func TestDiscardData(t *testing.T) {
+	fake, client := newClient(t, Options{DiscardData: true})
+	data := bytes.Repeat([]byte{1}, 5000)
+	if _, err := client.UploadChunkedWithOptions(context.Background(), "big.bin", bytes.NewReader(data), 5000, webdav.ChunkOptions{ChunkSize: 1024}); err != nil {
+		t.Fatal(err)
+	}
+	if size, ok := fake.Size(DefaultUser, "big.bin"); !ok || size != 5000 {
+		t.Errorf("Expected a size of 5000 bytes, got %d", size)
+	}
+
+	// Downloads stream zeros of the stored size
+	body, err := client.Download(context.Background(), "big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil || len(got) != 5000 || got[0] != 0 {
+		t.Errorf("Expected 5000 zero bytes, got %d bytes (%v)", len(got), err)
+	}
+}
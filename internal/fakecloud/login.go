@@ -0,0 +1,127 @@
+package fakecloud
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	loginFlowPath   = "/index.php/login/v2"
+	loginPagePath   = loginFlowPath + "/flow/"
+	loginPollPath   = loginFlowPath + "/poll"
+	appPasswordPath = "/ocs/v2.php/core/apppassword"
+)
+
+// loginFlow is a pending Login Flow v2. Opening the login page grants access
+// immediately, standing in for the user logging in through the browser.
+type loginFlow struct {
+	user string // Set once access was granted
+}
+
+func randomToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// baseURL reconstructs the server URL the client used.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// AppPasswords returns the number of app passwords that were issued and not revoked yet.
+func (s *Server) AppPasswords() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.appPasswords)
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == loginFlowPath && r.Method == "POST":
+		token := randomToken()
+		s.mu.Lock()
+		s.logins[token] = &loginFlow{}
+		s.mu.Unlock()
+		writeJSON(w, map[string]any{
+			"poll":  map[string]string{"token": token, "endpoint": baseURL(r) + loginPollPath},
+			"login": baseURL(r) + loginPagePath + token,
+		})
+
+	case strings.HasPrefix(r.URL.Path, loginPagePath) && r.Method == "GET":
+		s.mu.Lock()
+		flow := s.logins[strings.TrimPrefix(r.URL.Path, loginPagePath)]
+		if flow != nil {
+			flow.user = s.firstUser()
+		}
+		s.mu.Unlock()
+		if flow == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<!DOCTYPE html><title>Account connected</title><p>Account connected. You can close this window.</p>"))
+
+	case r.URL.Path == loginPollPath && r.Method == "POST":
+		token := r.FormValue("token")
+		s.mu.Lock()
+		flow := s.logins[token]
+		if flow == nil || flow.user == "" {
+			s.mu.Unlock()
+			http.NotFound(w, r)
+			return
+		}
+		delete(s.logins, token) // Credentials are handed out only once
+		pass := randomToken()
+		s.appPasswords[pass] = flow.user
+		s.mu.Unlock()
+		writeJSON(w, map[string]string{"server": baseURL(r), "loginName": flow.user, "appPassword": pass})
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRevoke deletes the app password used to authenticate the request.
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := s.authenticate(w, r); !ok {
+		return
+	}
+	_, pass, _ := r.BasicAuth()
+	s.mu.Lock()
+	_, isApp := s.appPasswords[pass]
+	delete(s.appPasswords, pass)
+	s.mu.Unlock()
+	if !isApp {
+		http.Error(w, "Cannot revoke a regular password", http.StatusForbidden)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"ocs": map[string]any{"meta": map[string]any{"status": "ok", "statuscode": 200, "message": "OK"}, "data": []any{}},
+	})
+}
+
+// firstUser is the account that grants access in a login flow: DefaultUser
+// if it exists, otherwise the alphabetically first user. s.mu must be held.
+func (s *Server) firstUser() string {
+	if _, ok := s.opts.Users[DefaultUser]; ok {
+		return DefaultUser
+	}
+	users := make([]string, 0, len(s.opts.Users))
+	for u := range s.opts.Users {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+	return users[0]
+}
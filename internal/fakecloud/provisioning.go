@@ -0,0 +1,84 @@
+package fakecloud
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// usersPath is the user endpoint of the OCS provisioning API.
+const usersPath = "/ocs/v2.php/cloud/users"
+
+// HasUser reports whether an account exists.
+func (s *Server) HasUser(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.opts.Users[name]
+	return ok
+}
+
+// ocsResponse writes an OCS v2 envelope with the given HTTP status.
+func ocsResponse(w http.ResponseWriter, status int, message string) {
+	state := "ok"
+	if status != http.StatusOK {
+		state = "failure"
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"ocs": map[string]any{"meta": map[string]any{"status": state, "statuscode": status, "message": message}, "data": []any{}},
+	})
+}
+
+// handleUsers serves account creation and deletion of the provisioning API.
+// Only the account that also approves login flows (see firstUser) is an administrator.
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user != s.firstUser() {
+		ocsResponse(w, http.StatusForbidden, "Logged in account must be an admin")
+		return
+	}
+
+	switch {
+	case r.Method == "POST" && r.URL.Path == usersPath:
+		name, pass := r.FormValue("userid"), r.FormValue("password")
+		_, exists := s.opts.Users[name]
+		switch {
+		case name == "" || strings.ContainsAny(name, "/\\"):
+			ocsResponse(w, http.StatusBadRequest, "Invalid user name")
+		case pass == "":
+			ocsResponse(w, http.StatusBadRequest, "A password is required")
+		case exists:
+			ocsResponse(w, http.StatusBadRequest, "User already exists")
+		default:
+			s.opts.Users[name] = pass
+			s.mkdirAll(filesRoot + name)
+			s.mkdirAll(uploadsRoot + name)
+			ocsResponse(w, http.StatusOK, "OK")
+		}
+
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, usersPath+"/"):
+		name := strings.TrimPrefix(r.URL.Path, usersPath+"/")
+		if _, ok := s.opts.Users[name]; !ok {
+			ocsResponse(w, http.StatusNotFound, "User does not exist")
+			return
+		}
+		delete(s.opts.Users, name)
+		for _, root := range []string{filesRoot + name, uploadsRoot + name} {
+			for p := range s.nodes {
+				if p == root || strings.HasPrefix(p, root+"/") {
+					delete(s.nodes, p)
+				}
+			}
+		}
+		ocsResponse(w, http.StatusOK, "OK")
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
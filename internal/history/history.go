@@ -0,0 +1,328 @@
+// Package history persists completed benchmark reports on disk so that runs
+// against the same instance can be browsed and compared over time.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nextcloud-perf/internal/config"
+	"nextcloud-perf/internal/report"
+)
+
+// ErrNotFound is returned for unknown run IDs.
+var ErrNotFound = errors.New("run not found")
+
+const indexFile = "index.json"
+
+// validID guards file names built from IDs that come from HTTP requests.
+var validID = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}-[0-9]{3}$`)
+
+// Entry describes one stored run in the index.
+type Entry struct {
+	ID          string    `json:"id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	TargetURL   string    `json:"target_url"`
+	ServerVer   string    `json:"server_ver"`
+	Profile     string    `json:"profile"`
+	Error       string    `json:"error,omitempty"`
+
+	// Headline metrics for trend lists, keyed like report.Metric.Key
+	Metrics map[string]float64 `json:"metrics,omitempty"`
+}
+
+// Store keeps one JSON and one HTML file per run plus an index in a directory.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// DefaultDir is the history directory below config.DataDir.
+func DefaultDir() string {
+	return filepath.Join(config.DataDir(), "history")
+}
+
+// Open creates dir if needed and returns a store backed by it.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns the directory of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save stores a completed report and its rendered HTML. If html is empty it
+// is rendered from data.
+func (s *Store) Save(data report.ReportData, html []byte) (Entry, error) {
+	if len(html) == 0 {
+		var err error
+		if html, err = report.GenerateHTML(data); err != nil {
+			return Entry{}, err
+		}
+	}
+	b, err := report.GenerateJSON(data)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return Entry{}, err
+	}
+	e := newEntry(data, entries)
+
+	if err := writeFile(s.path(e.ID, ".json"), b); err != nil {
+		return Entry{}, err
+	}
+	if err := writeFile(s.path(e.ID, ".html"), html); err != nil {
+		return Entry{}, err
+	}
+	if err := s.store(append(entries, e)); err != nil {
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+// List returns the stored runs, newest first. A non-empty target limits the
+// result to runs against that URL (ignoring case and trailing slashes).
+func (s *Store) List(target string) ([]Entry, error) {
+	s.mu.Lock()
+	entries, err := s.load()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if target == "" || normalizeTarget(e.TargetURL) == normalizeTarget(target) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out, nil
+}
+
+// Targets returns the distinct target URLs of all stored runs, sorted.
+func (s *Store) Targets() ([]string, error) {
+	entries, err := s.List("")
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, e := range entries {
+		if key := normalizeTarget(e.TargetURL); !seen[key] {
+			seen[key] = true
+			out = append(out, e.TargetURL)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// Get returns the index entry of a run.
+func (s *Store) Get(id string) (Entry, error) {
+	if !validID.MatchString(id) {
+		return Entry{}, ErrNotFound
+	}
+	s.mu.Lock()
+	entries, err := s.load()
+	s.mu.Unlock()
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return Entry{}, ErrNotFound
+}
+
+// Report reads the stored report of a run.
+func (s *Store) Report(id string) (report.ReportData, error) {
+	b, err := s.JSON(id)
+	if err != nil {
+		return report.ReportData{}, err
+	}
+	return report.ParseJSON(b)
+}
+
+// JSON returns the stored JSON export of a run.
+func (s *Store) JSON(id string) ([]byte, error) {
+	return s.read(id, ".json")
+}
+
+// HTML returns the stored HTML report of a run.
+func (s *Store) HTML(id string) ([]byte, error) {
+	return s.read(id, ".html")
+}
+
+// Delete removes a run and its files.
+func (s *Store) Delete(id string) error {
+	if !validID.MatchString(id) {
+		return ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.ID != id {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(entries) {
+		return ErrNotFound
+	}
+	if err := s.store(kept); err != nil {
+		return err
+	}
+	for _, ext := range []string{".json", ".html"} {
+		if err := os.Remove(s.path(id, ext)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) read(id, ext string) ([]byte, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	b, err := os.ReadFile(s.path(id, ext))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+func (s *Store) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+// load reads the index. A missing index is rebuilt from the stored JSON
+// files so that copying run files into the directory is enough to import them.
+// Callers must hold mu.
+func (s *Store) load() ([]Entry, error) {
+	b, err := os.ReadFile(filepath.Join(s.dir, indexFile))
+	if os.IsNotExist(err) {
+		return s.rebuild()
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse history index: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *Store) rebuild() ([]Entry, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, f := range files {
+		id := strings.TrimSuffix(filepath.Base(f), ".json")
+		if !validID.MatchString(id) {
+			continue
+		}
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		data, err := report.ParseJSON(b)
+		if err != nil {
+			continue // Not a report, leave it alone
+		}
+		e := entryFor(data)
+		e.ID = id
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// store writes the index atomically. Callers must hold mu.
+func (s *Store) store(entries []Entry) error {
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(s.dir, indexFile), b)
+}
+
+// writeFile replaces path via a temporary file so readers never see partial content.
+func writeFile(path string, b []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func entryFor(data report.ReportData) Entry {
+	e := Entry{
+		GeneratedAt: data.GeneratedAt,
+		TargetURL:   data.TargetURL,
+		ServerVer:   data.CloudCheck.Version,
+		Profile:     data.Profile,
+		Error:       data.Error,
+		Metrics:     map[string]float64{},
+	}
+	if e.ServerVer == "" {
+		e.ServerVer = data.ServerVer
+	}
+	for _, m := range report.Metrics(data) {
+		if strings.HasSuffix(m.Key, ".speed_mbps") || strings.HasPrefix(m.Key, "network.ping_avg") {
+			e.Metrics[m.Key] = m.Value
+		}
+	}
+	return e
+}
+
+// newEntry assigns an ID derived from the report time, unique among entries.
+func newEntry(data report.ReportData, entries []Entry) Entry {
+	e := entryFor(data)
+	at := data.GeneratedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	taken := map[string]bool{}
+	for _, x := range entries {
+		taken[x.ID] = true
+	}
+	for i := 0; ; i++ {
+		id := fmt.Sprintf("%s-%03d", at.UTC().Format("20060102-150405"), i)
+		if !taken[id] {
+			e.ID = id
+			return e
+		}
+	}
+}
+
+func normalizeTarget(u string) string {
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(u), "/"))
+}
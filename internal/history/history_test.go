@@ -0,0 +1,134 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nextcloud-perf/internal/report"
+)
+
+func testReport(target string, at time.Time) report.ReportData {
+	return report.ReportData{
+		GeneratedAt: at,
+		TargetURL:   target,
+		Profile:     "quick",
+		Completed:   true,
+		Scenarios: []report.ScenarioResult{
+			{Name: "small", Label: "Small", Upload: &report.SpeedResult{SpeedMBps: 12.5}},
+		},
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	first, err := s.Save(testReport("https://a.example.com", at), []byte("<html>a</html>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same second: the ID must still be unique
+	second, err := s.Save(testReport("https://a.example.com/", at), []byte("<html>a2</html>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == second.ID {
+		t.Fatalf("Expected unique IDs, got %s twice", first.ID)
+	}
+	if _, err := s.Save(testReport("https://b.example.com", at.Add(time.Hour)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := s.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(all))
+	}
+	if all[0].TargetURL != "https://b.example.com" {
+		t.Errorf("Expected newest entry first, got %s", all[0].TargetURL)
+	}
+	if all[0].Metrics["scenario.small.upload.speed_mbps"] != 12.5 {
+		t.Errorf("Expected upload metric in index, got %v", all[0].Metrics)
+	}
+
+	filtered, err := s.List("HTTPS://A.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 2 {
+		t.Errorf("Expected 2 entries for target a, got %d", len(filtered))
+	}
+	targets, _ := s.Targets()
+	if len(targets) != 2 {
+		t.Errorf("Expected 2 targets, got %v", targets)
+	}
+
+	html, err := s.HTML(first.ID)
+	if err != nil || string(html) != "<html>a</html>" {
+		t.Errorf("Expected stored HTML, got %q (%v)", html, err)
+	}
+	rpt, err := s.Report(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rpt.TargetURL != "https://a.example.com" || !rpt.GeneratedAt.Equal(at) {
+		t.Errorf("Expected stored report, got %+v", rpt)
+	}
+	if html, err := s.HTML(all[0].ID); err != nil || len(html) == 0 {
+		t.Errorf("Expected HTML to be rendered when none was given, got %v", err)
+	}
+
+	if err := s.Delete(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, first.ID+".json")); !os.IsNotExist(err) {
+		t.Errorf("Expected report file to be removed, got %v", err)
+	}
+	if err := s.Delete(first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for second delete, got %v", err)
+	}
+}
+
+func TestStoreRejectsInvalidIDs(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"", "../index", "index", "20240501-100000-000/../../x"} {
+		if _, err := s.HTML(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for %q, got %v", id, err)
+		}
+	}
+}
+
+func TestStoreRebuildsIndex(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir)
+	e, err := s.Save(testReport("https://a.example.com", time.Now()), []byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, indexFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != e.ID {
+		t.Errorf("Expected index to be rebuilt with %s, got %+v", e.ID, entries)
+	}
+}
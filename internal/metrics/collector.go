@@ -0,0 +1,384 @@
+// Package metrics exposes benchmark results in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nextcloud-perf/internal/benchmark"
+	"nextcloud-perf/internal/report"
+)
+
+// Namespace prefixes all exported metric names.
+const Namespace = "nextcloud_perf"
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// targetState is the state of one benchmarked instance.
+type targetState struct {
+	latest    report.ReportData // Latest (possibly partial) result
+	running   bool
+	finished  time.Time // GeneratedAt of the last counted run
+	duration  time.Duration
+	succeeded bool
+	runs      map[string]float64 // by result label
+	errors    map[[2]string]float64
+}
+
+// Collector keeps the latest result per target and cumulative run and error
+// counters. Feed it every ReportData sent by the workflow via Observe; partial
+// results update the gauges while a run is in progress.
+type Collector struct {
+	mu      sync.Mutex
+	targets map[string]*targetState
+	now     func() time.Time
+}
+
+// NewCollector returns an empty collector.
+func NewCollector() *Collector {
+	return &Collector{targets: map[string]*targetState{}, now: time.Now}
+}
+
+// Observe records a (partial or final) result. A result is final if it is
+// completed or carries an error; each run is counted once even if the final
+// result is sent repeatedly.
+func (c *Collector) Observe(data report.ReportData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := c.targets[data.TargetURL]
+	if t == nil {
+		t = &targetState{runs: map[string]float64{}, errors: map[[2]string]float64{}}
+		c.targets[data.TargetURL] = t
+	}
+	t.latest = data
+
+	if !data.Completed && data.Error == "" {
+		t.running = true
+		return
+	}
+	t.running = false
+	if data.GeneratedAt.Equal(t.finished) {
+		return
+	}
+	t.finished = data.GeneratedAt
+	t.duration = c.now().Sub(data.GeneratedAt)
+	t.succeeded = data.Error == ""
+
+	if t.succeeded {
+		t.runs["success"]++
+	} else {
+		t.runs["failure"]++
+	}
+	for _, sc := range data.Scenarios {
+		for dir, res := range map[string]*report.SpeedResult{"upload": sc.Upload, "download": sc.Download, "sequential": sc.Sequential} {
+			if res != nil {
+				t.errors[[2]string{sc.Name, dir}] += float64(len(res.Errors))
+			}
+		}
+	}
+	if data.Metadata != nil {
+		n := len(data.Metadata.Errors)
+		for _, l := range data.Metadata.Listings {
+			n += len(l.Errors)
+		}
+		t.errors[[2]string{"metadata", "propfind"}] += float64(n)
+	}
+	if data.Sync != nil {
+		t.errors[[2]string{"sync", "mixed"}] += float64(len(data.Sync.Errors))
+	}
+	if data.Soak != nil {
+		t.errors[[2]string{"soak", "mixed"}] += float64(data.Soak.FailedOps())
+	}
+}
+
+// ServeHTTP writes all metrics in the Prometheus text exposition format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	c.WriteTo(w)
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.targets))
+	for name := range c.targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	e := newEncoder()
+	for _, name := range names {
+		c.collect(e, name, c.targets[name])
+	}
+	n, err := io.WriteString(w, e.String())
+	return int64(n), err
+}
+
+func (c *Collector) collect(e *encoder, target string, t *targetState) {
+	r := t.latest
+	base := labels{"target", target}
+
+	e.gauge("running", "Whether a benchmark run is in progress.", base, boolValue(t.running))
+	e.gauge("scenarios_completed", "Scenarios with results in the current or last run.", base, float64(len(r.Scenarios)))
+	for _, result := range []string{"success", "failure"} {
+		e.counter("runs_total", "Finished benchmark runs by result.", base.with("result", result), t.runs[result])
+	}
+	if !t.finished.IsZero() {
+		e.gauge("up", "Whether the last run finished without error.", base, boolValue(t.succeeded))
+		e.gauge("last_run_timestamp_seconds", "Start time of the last finished run.", base, float64(t.finished.Unix()))
+		e.gauge("last_run_duration_seconds", "Duration of the last finished run.", base, t.duration.Seconds())
+	}
+	keys := make([][2]string, 0, len(t.errors))
+	for k := range t.errors {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i][0]+keys[i][1] < keys[j][0]+keys[j][1] })
+	for _, k := range keys {
+		e.counter("errors_total", "Failed requests of finished runs by scenario and direction.",
+			base.with("scenario", k[0]).with("direction", k[1]), t.errors[k])
+	}
+
+	// Network
+	if r.PingStats.Count > 0 {
+		e.gauge("ping_packet_loss_ratio", "TCP connect failures as a ratio of attempts.", base, r.PingStats.PacketLoss/100)
+		e.gauge("ping_sent", "TCP connect attempts.", base, float64(r.PingStats.Count))
+		e.gauge("ping_successful", "Successful TCP connects.", base, float64(r.PingStats.SuccessCount))
+	}
+	if r.PingStats.SuccessCount > 0 {
+		for _, s := range []struct {
+			stat string
+			ms   float64
+		}{{"min", r.PingStats.MinMs}, {"avg", r.PingStats.AvgMs}, {"max", r.PingStats.MaxMs}} {
+			e.gauge("ping_rtt_seconds", "TCP connect time to the target.", base.with("stat", s.stat), s.ms/1000)
+		}
+	}
+	if p := r.ICMPPing; p != nil && p.Count > 0 {
+		e.gauge("icmp_ping_packet_loss_ratio", "Lost ICMP echo requests as a ratio of requests sent.", base, p.PacketLoss/100)
+	}
+	if p := r.ICMPPing; p != nil && p.SuccessCount > 0 {
+		for _, s := range []struct {
+			stat string
+			ms   float64
+		}{{"min", p.MinMs}, {"avg", p.AvgMs}, {"max", p.MaxMs}} {
+			e.gauge("icmp_ping_rtt_seconds", "ICMP echo round-trip time to the target.", base.with("stat", s.stat), s.ms/1000)
+		}
+	}
+	if r.DNS.Host != "" {
+		e.gauge("dns_up", "Whether DNS resolution succeeded.", base, boolValue(r.DNS.Error == ""))
+		if r.DNS.Error == "" {
+			e.gauge("dns_resolution_seconds", "DNS resolution time of the target host.", base, r.DNS.ResolutionTime/1000)
+			e.gauge("dns_resolved_addresses", "Addresses returned for the target host.", base, float64(len(r.DNS.ResolvedIPs)))
+		}
+	}
+	if r.DualStack != nil {
+		for _, f := range r.DualStack.Families {
+			l := base.with("family", strings.ToLower(f.Name()))
+			e.gauge("family_dns_up", "Whether the host has addresses of the family (A or AAAA records).", l, boolValue(f.DNS.Error == ""))
+			if f.Ping != nil && f.Ping.Count > 0 {
+				e.gauge("family_ping_packet_loss_ratio", "TCP connect failures per address family as a ratio of attempts.", l, f.Ping.PacketLoss/100)
+			}
+			if f.Ping != nil && f.Ping.SuccessCount > 0 {
+				e.gauge("family_ping_rtt_seconds", "Average TCP connect time per address family.", l, f.Ping.AvgMs/1000)
+			}
+		}
+		if he := r.DualStack.HappyEyeballs; he != nil && he.Count > len(he.Errors) {
+			e.gauge("dual_stack_connect_seconds", "Average dual-stack (Happy Eyeballs) TCP connect time.", base, he.AvgMs/1000)
+		}
+		if penalty := r.DualStack.FallbackPenaltyMs(); penalty != nil {
+			e.gauge("dual_stack_fallback_delay_seconds", "Dual-stack connect time over a direct connect with the faster address family.", base, *penalty/1000)
+		}
+	}
+	if p := r.PathMTU; p != nil && p.PathMTU > 0 {
+		e.gauge("path_mtu_bytes", "Largest packet that reaches the target without fragmentation.", base, float64(p.PathMTU))
+		e.gauge("mtu_black_hole", "Whether larger packets are dropped without ICMP fragmentation needed.", base, boolValue(p.BlackHole))
+	}
+	if r.AdvancedNet.TLSHandshakeMs > 0 {
+		e.gauge("tls_handshake_seconds", "TLS handshake time.", base, r.AdvancedNet.TLSHandshakeMs/1000)
+	}
+	for _, ep := range r.Endpoints {
+		if ep.Samples == 0 {
+			continue
+		}
+		l := base.with("endpoint", ep.Name)
+		for _, p := range ep.Phases {
+			e.gauge("endpoint_phase_seconds", "Median duration of a request phase on a new connection.", l.with("phase", p.Name), p.Median.Seconds())
+		}
+		e.gauge("endpoint_request_seconds", "Median request time on a new connection.", l, ep.Total.Median.Seconds())
+	}
+	if r.Speedtest != nil {
+		e.gauge("speedtest_bytes_per_second", "Reference internet speed (Speedtest.net).", base.with("direction", "upload"), r.Speedtest.UploadMBps*1e6)
+		e.gauge("speedtest_bytes_per_second", "Reference internet speed (Speedtest.net).", base.with("direction", "download"), r.Speedtest.DownloadMBps*1e6)
+	}
+
+	// WebDAV transfers
+	for _, sc := range r.Scenarios {
+		for _, d := range []struct {
+			dir string
+			res *report.SpeedResult
+		}{{"upload", sc.Upload}, {"download", sc.Download}, {"sequential", sc.Sequential}} {
+			if d.res == nil {
+				continue
+			}
+			l := base.with("scenario", sc.Name).with("direction", d.dir)
+			e.gauge("transfer_bytes_per_second", "WebDAV transfer speed of the last run.", l, d.res.SpeedMBps*1024*1024)
+			e.gauge("transfer_duration_seconds", "WebDAV transfer duration of the last run.", l, d.res.Duration.Seconds())
+			e.gauge("transfer_errors", "Failed requests of the last run.", l, float64(len(d.res.Errors)))
+			e.gauge("transfer_retries", "Requests repeated after transient failures in the last run.", l, float64(d.res.Retries))
+			e.gauge("transfer_resumes", "Chunked uploads resumed in the last run.", l, float64(d.res.Resumes))
+			if d.dir == "download" {
+				e.gauge("transfer_corrupted", "Downloads whose size or checksum differed from the upload in the last run.", l, float64(d.res.Corrupted))
+			}
+			latencyQuantiles(e, "transfer_request_duration_seconds", "WebDAV request latency of the last run.", l, d.res.Latency)
+		}
+	}
+	if r.Metadata != nil {
+		for _, listing := range r.Metadata.Listings {
+			l := base.with("depth", listing.Depth)
+			e.gauge("propfind_entries", "Entries returned by a directory listing.", l, float64(listing.Entries))
+			latencyQuantiles(e, "propfind_duration_seconds", "PROPFIND listing latency of the last run.", l, listing.Latency)
+		}
+	}
+	if r.Sync != nil && len(r.Sync.Cycles) > 0 {
+		help := "Sync cycle time of the last run, from the change check until all changes are propagated."
+		e.gauge("sync_cycle_duration_seconds", help, base.with("cycle", "initial"), r.Sync.Initial.Duration.Seconds())
+		e.gauge("sync_cycle_duration_seconds", help, base.with("cycle", "idle"), r.Sync.Idle.Seconds())
+		e.gauge("sync_cycle_duration_seconds", help, base.with("cycle", "median"), r.Sync.CycleMedian().Seconds())
+		e.gauge("sync_cycle_duration_seconds", help, base.with("cycle", "max"), r.Sync.CycleMax().Seconds())
+	}
+	if r.Protocols != nil {
+		for _, run := range r.Protocols.Runs {
+			l := base.with("protocol", run.Protocol)
+			e.gauge("protocol_up", "Whether the HTTP protocol version was available in the last comparison.", l, boolValue(run.Upload != nil))
+			for _, w := range report.ProtocolWorkloads {
+				if res := run.Speed(w); res != nil {
+					e.gauge("protocol_bytes_per_second", "WebDAV transfer speed per HTTP protocol version in the last comparison.", l.with("workload", w), res.SpeedMBps*1024*1024)
+				}
+			}
+		}
+	}
+	if r.Soak != nil && len(r.Soak.Samples) > 0 {
+		last := r.Soak.Samples[len(r.Soak.Samples)-1]
+		e.gauge("soak_elapsed_seconds", "Time covered by the soak test samples so far.", base, (last.Offset + last.Length).Seconds())
+		e.gauge("soak_bytes_per_second", "WebDAV throughput in the latest soak test interval.", base.with("direction", "upload"), last.UploadMBps*1024*1024)
+		e.gauge("soak_bytes_per_second", "WebDAV throughput in the latest soak test interval.", base.with("direction", "download"), last.DownloadMBps*1024*1024)
+		e.gauge("soak_error_ratio", "Failed operations in the latest soak test interval.", base, last.ErrorRate()/100)
+		for _, q := range []struct {
+			q string
+			d time.Duration
+		}{{"0.5", last.P50}, {"0.95", last.P95}} {
+			e.gauge("soak_request_duration_seconds", "Request latency in the latest soak test interval.", base.with("quantile", q.q), q.d.Seconds())
+		}
+	}
+}
+
+func latencyQuantiles(e *encoder, name, help string, l labels, s *benchmark.LatencyStats) {
+	if s == nil || s.Count == 0 {
+		return
+	}
+	for _, q := range []struct {
+		q string
+		d time.Duration
+	}{{"0.5", s.P50}, {"0.9", s.P90}, {"0.95", s.P95}, {"0.99", s.P99}, {"1", s.Max}} {
+		e.gauge(name, help, l.with("quantile", q.q), q.d.Seconds())
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// labels is a flat list of name/value pairs in output order.
+type labels []string
+
+func (l labels) with(name, value string) labels {
+	return append(append(labels{}, l...), name, value)
+}
+
+func (l labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(l)/2)
+	for i := 0; i+1 < len(l); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, l[i], escapeLabel(l[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value as required by the text format.
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// encoder groups samples by metric family so HELP and TYPE are written once.
+type encoder struct {
+	order    []string
+	families map[string]*family
+}
+
+type family struct {
+	help, kind string
+	samples    []string
+}
+
+func newEncoder() *encoder {
+	return &encoder{families: map[string]*family{}}
+}
+
+func (e *encoder) add(kind, name, help string, l labels, v float64) {
+	name = Namespace + "_" + name
+	f := e.families[name]
+	if f == nil {
+		f = &family{help: help, kind: kind}
+		e.families[name] = f
+		e.order = append(e.order, name)
+	}
+	f.samples = append(f.samples, fmt.Sprintf("%s%s %s", name, l, formatValue(v)))
+}
+
+func (e *encoder) gauge(name, help string, l labels, v float64) {
+	e.add("gauge", name, help, l, v)
+}
+
+func (e *encoder) counter(name, help string, l labels, v float64) {
+	e.add("counter", name, help, l, v)
+}
+
+func (e *encoder) String() string {
+	var b strings.Builder
+	for _, name := range e.order {
+		f := e.families[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.kind)
+		for _, s := range f.samples {
+			b.WriteString(s)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return fmt.Sprintf("%g", v)
+}
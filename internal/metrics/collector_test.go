@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nextcloud-perf/internal/benchmark"
+	"nextcloud-perf/internal/network"
+	"nextcloud-perf/internal/report"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	start := time.Now().Add(-time.Minute)
+	data := report.ReportData{
+		GeneratedAt: start,
+		TargetURL:   `https://cloud.example.com/"x"`,
+		PingStats:   network.DetailedPingStats{Count: 10, SuccessCount: 10, MinMs: 5, AvgMs: 10, MaxMs: 20},
+		DNS:         network.DNSResult{Host: "cloud.example.com", ResolutionTime: 12},
+		ICMPPing:    &network.DetailedPingStats{Method: network.MethodICMPDgram, Count: 10, SuccessCount: 9, AvgMs: 8, PacketLoss: 10},
+		DualStack: &network.DualStackResult{Families: []network.FamilyResult{
+			{Family: network.IPv4, Ping: &network.DetailedPingStats{Count: 5, SuccessCount: 5, AvgMs: 10}},
+			{Family: network.IPv6, DNS: network.DNSResult{Error: "no such host"}},
+		}},
+		PathMTU: &network.PathMTUResult{LocalMTU: 1500, PathMTU: 1420, BlackHole: true},
+		Endpoints: []benchmark.EndpointTiming{{Name: benchmark.EndpointStatus, Samples: 5,
+			Phases: []benchmark.PhaseStats{{Name: benchmark.RequestTTFB, DurationStats: benchmark.DurationStats{Median: 40 * time.Millisecond}}},
+			Total:  benchmark.DurationStats{Median: 90 * time.Millisecond}}},
+	}
+
+	// Partial result while running
+	c.Observe(data)
+	out := scrape(t, c)
+	for _, want := range []string{
+		`nextcloud_perf_running{target="https://cloud.example.com/\"x\""} 1`,
+		`nextcloud_perf_runs_total{target="https://cloud.example.com/\"x\"",result="success"} 0`,
+		`nextcloud_perf_ping_rtt_seconds{target="https://cloud.example.com/\"x\"",stat="avg"} 0.01`,
+		`nextcloud_perf_dns_resolution_seconds{target="https://cloud.example.com/\"x\""} 0.012`,
+		`nextcloud_perf_icmp_ping_rtt_seconds{target="https://cloud.example.com/\"x\"",stat="avg"} 0.008`,
+		`nextcloud_perf_icmp_ping_packet_loss_ratio{target="https://cloud.example.com/\"x\""} 0.1`,
+		`nextcloud_perf_family_ping_rtt_seconds{target="https://cloud.example.com/\"x\"",family="ipv4"} 0.01`,
+		`nextcloud_perf_family_dns_up{target="https://cloud.example.com/\"x\"",family="ipv6"} 0`,
+		`nextcloud_perf_path_mtu_bytes{target="https://cloud.example.com/\"x\""} 1420`,
+		`nextcloud_perf_mtu_black_hole{target="https://cloud.example.com/\"x\""} 1`,
+		`nextcloud_perf_endpoint_phase_seconds{target="https://cloud.example.com/\"x\"",endpoint="status",phase="ttfb"} 0.04`,
+		`nextcloud_perf_endpoint_request_seconds{target="https://cloud.example.com/\"x\"",endpoint="status"} 0.09`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+
+	// Final result, sent twice like the workflow does
+	data.Completed = true
+	data.Scenarios = []report.ScenarioResult{{
+		Name: "small",
+		Upload: &report.SpeedResult{SpeedMBps: 2, Duration: time.Second, Errors: []string{"boom"},
+			Latency: &benchmark.LatencyStats{Count: 5, P50: 100 * time.Millisecond, P99: time.Second}},
+	}}
+	data.Soak = &report.SoakResult{Samples: []benchmark.IntervalSample{
+		{Length: time.Minute, Ops: 50, Errors: 1, UploadMBps: 1, DownloadMBps: 2, P95: 250 * time.Millisecond},
+	}}
+	c.Observe(data)
+	c.Observe(data)
+	out = scrape(t, c)
+	for _, want := range []string{
+		`nextcloud_perf_running{target="https://cloud.example.com/\"x\""} 0`,
+		`nextcloud_perf_up{target="https://cloud.example.com/\"x\""} 1`,
+		`result="success"} 1`,
+		`nextcloud_perf_errors_total{target="https://cloud.example.com/\"x\"",scenario="small",direction="upload"} 1`,
+		`scenario="small",direction="upload"} 2.097152e+06`,
+		`scenario="small",direction="upload",quantile="0.5"} 0.1`,
+		`scenario="soak",direction="mixed"} 1`,
+		`nextcloud_perf_soak_bytes_per_second{target="https://cloud.example.com/\"x\"",direction="download"} 2.097152e+06`,
+		`nextcloud_perf_soak_error_ratio{target="https://cloud.example.com/\"x\""} 0.02`,
+		`nextcloud_perf_soak_request_duration_seconds{target="https://cloud.example.com/\"x\"",quantile="0.95"} 0.25`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "# TYPE nextcloud_perf_transfer_bytes_per_second gauge"); n != 1 {
+		t.Errorf("Expected one TYPE line per family, got %d", n)
+	}
+}
+
+func scrape(t *testing.T, c *Collector) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected text/plain, got %s", ct)
+	}
+	return rec.Body.String()
+}
@@ -0,0 +1,109 @@
+package network
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ASInfo describes the autonomous system an address belongs to.
+type ASInfo struct {
+	Number  int
+	Country string
+	Name    string
+}
+
+// ASNDatabase maps IP ranges to autonomous systems. It is read from the
+// IP-to-ASN files of iptoasn.com (ip2asn-v4.tsv, ip2asn-v6.tsv or
+// ip2asn-combined.tsv, optionally gzipped), so lookups work offline.
+type ASNDatabase struct {
+	ranges []asnRange // Sorted by start
+}
+
+type asnRange struct {
+	start, end netip.Addr
+	as         *ASInfo
+}
+
+// LoadASNDatabase reads an iptoasn.com TSV file. Each line holds range
+// start, range end, AS number, country code and AS description separated
+// by tabs. Ranges of AS 0 are not routed and skipped.
+func LoadASNDatabase(path string) (*ASNDatabase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	db, err := parseASNDatabase(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+func parseASNDatabase(r io.Reader) (*ASNDatabase, error) {
+	db := &ASNDatabase{}
+	systems := map[int]*ASInfo{} // Share the entries of systems with many ranges
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Split(sc.Text(), "\t")
+		if len(fields) < 5 {
+			if strings.TrimSpace(sc.Text()) == "" {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: expected 5 tab-separated fields", line)
+		}
+		start, err1 := netip.ParseAddr(fields[0])
+		end, err2 := netip.ParseAddr(fields[1])
+		number, err3 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("line %d: invalid range or AS number", line)
+		}
+		if number == 0 {
+			continue
+		}
+		as := systems[number]
+		if as == nil {
+			as = &ASInfo{Number: number, Country: fields[3], Name: fields[4]}
+			systems[number] = as
+		}
+		db.ranges = append(db.ranges, asnRange{start: start, end: end, as: as})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(db.ranges, func(i, j int) bool { return db.ranges[i].start.Less(db.ranges[j].start) })
+	return db, nil
+}
+
+// Lookup returns the autonomous system of ip.
+func (db *ASNDatabase) Lookup(ip net.IP) (ASInfo, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok || db == nil {
+		return ASInfo{}, false
+	}
+	addr = addr.Unmap()
+	// Last range starting at or before addr
+	i := sort.Search(len(db.ranges), func(i int) bool { return addr.Less(db.ranges[i].start) }) - 1
+	if i < 0 || db.ranges[i].end.Less(addr) {
+		return ASInfo{}, false
+	}
+	return *db.ranges[i].as, true
+}
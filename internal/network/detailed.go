@@ -1,8 +1,11 @@
 package network
 
 import (
+	"context"
+	"fmt"
 	"net"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -15,6 +18,7 @@ type PingResult struct {
 
 type DetailedPingStats struct {
 	Host         string       `json:"host"`
+	Method       string       `json:"method,omitempty"` // MethodTCP or one of the ICMP methods
 	Count        int          `json:"count"`
 	Results      []PingResult `json:"results"`
 	MinMs        float64      `json:"min_ms"`
@@ -26,17 +30,31 @@ type DetailedPingStats struct {
 
 // MeasureDetailedTCPPing performs 'count' TCP connects and returns detailed stats
 func MeasureDetailedTCPPing(host string, count int, timeout time.Duration) (DetailedPingStats, error) {
+	return tcpPing("tcp", host, count, timeout)
+}
+
+// MeasureFamilyTCPPing is MeasureDetailedTCPPing over one address family,
+// IPv4 or IPv6, instead of the address the resolver returns first.
+func MeasureFamilyTCPPing(family, host string, count int, timeout time.Duration) (DetailedPingStats, error) {
+	network := "tcp4"
+	if family == IPv6 {
+		network = "tcp6"
+	}
+	return tcpPing(network, host, count, timeout)
+}
+
+func tcpPing(network, host string, count int, timeout time.Duration) (DetailedPingStats, error) {
 	stats := DetailedPingStats{
-		Host:  host,
-		Count: count,
+		Host:   host,
+		Method: MethodTCP,
+		Count:  count,
 	}
 
-	var totalTime float64
 	var validTimes []float64
 
 	for i := 1; i <= count; i++ {
 		start := time.Now()
-		conn, err := net.DialTimeout("tcp", host, timeout)
+		conn, err := net.DialTimeout(network, host, timeout)
 		duration := time.Since(start).Seconds() * 1000 // ms
 
 		res := PingResult{
@@ -52,27 +70,89 @@ func MeasureDetailedTCPPing(host string, count int, timeout time.Duration) (Deta
 			res.TimeMs = duration
 			stats.SuccessCount++
 
-			totalTime += duration
 			validTimes = append(validTimes, duration)
 		}
 		stats.Results = append(stats.Results, res)
 		time.Sleep(200 * time.Millisecond) // Small pause between pings
 	}
 
-	// Calculate Stats
-	if stats.SuccessCount > 0 && len(validTimes) > 0 {
-		stats.AvgMs = totalTime / float64(stats.SuccessCount)
+	stats.summarize(validTimes)
+	return stats, nil
+}
 
-		sort.Float64s(validTimes)
-		stats.MinMs = validTimes[0]
-		stats.MaxMs = validTimes[len(validTimes)-1]
+// MeasureICMPPing sends 'count' ICMP echo requests and returns detailed
+// stats. It uses an unprivileged ICMP socket where the system allows it and
+// falls back to a raw socket, which requires root.
+func MeasureICMPPing(host string, count int, timeout time.Duration) (DetailedPingStats, error) {
+	stats := DetailedPingStats{
+		Host:  host,
+		Count: count,
 	}
+	destAddr, err := net.ResolveIPAddr("ip4", host)
+	if err != nil {
+		return stats, fmt.Errorf("resolve failed: %v", err)
+	}
+
+	var p prober
+	var skipped []string
+	for _, method := range []string{MethodICMPDgram, MethodICMPRaw} {
+		if p, err = newProber(method, destAddr.IP, 0); err == nil {
+			stats.Method = method
+			break
+		}
+		skipped = append(skipped, fmt.Sprintf("%s: %v", method, err))
+	}
+	if p == nil {
+		return stats, fmt.Errorf("no ICMP socket available (%s)", strings.Join(skipped, "; "))
+	}
+	defer p.Close()
+
+	var validTimes []float64
+	for i := 1; i <= count; i++ {
+		start := time.Now()
+		addr, _, err := p.probe(64, i, timeout)
+		duration := time.Since(start).Seconds() * 1000 // ms
+		if err == nil && !addr.Equal(destAddr.IP) {
+			err = fmt.Errorf("unreachable (reported by %s)", addr)
+		}
 
-	stats.PacketLoss = (float64(count-stats.SuccessCount) / float64(count)) * 100
+		res := PingResult{
+			Seq: i,
+		}
+		if err != nil {
+			res.ErrorMsg = err.Error()
+		} else {
+			res.Success = true
+			res.TimeMs = duration
+			stats.SuccessCount++
+			validTimes = append(validTimes, duration)
+		}
+		stats.Results = append(stats.Results, res)
+		time.Sleep(200 * time.Millisecond)
+	}
 
+	stats.summarize(validTimes)
 	return stats, nil
 }
 
+// summarize calculates min, max, average and loss from the successful times.
+func (s *DetailedPingStats) summarize(validTimes []float64) {
+	if len(validTimes) > 0 {
+		var totalTime float64
+		for _, t := range validTimes {
+			totalTime += t
+		}
+		s.AvgMs = totalTime / float64(len(validTimes))
+
+		sort.Float64s(validTimes)
+		s.MinMs = validTimes[0]
+		s.MaxMs = validTimes[len(validTimes)-1]
+	}
+	if s.Count > 0 {
+		s.PacketLoss = (float64(s.Count-s.SuccessCount) / float64(s.Count)) * 100
+	}
+}
+
 type DNSResult struct {
 	Host           string   `json:"host"`
 	ResolutionTime float64  `json:"resolution_time"` // ms
@@ -83,7 +163,18 @@ type DNSResult struct {
 func MeasureDNS(host string) DNSResult {
 	start := time.Now()
 	ips, err := net.LookupIP(host)
-	duration := time.Since(start).Seconds() * 1000
+	return dnsResult(host, ips, err, time.Since(start))
+}
+
+// MeasureDNSFamily resolves only the A (IPv4) or AAAA (IPv6) records of host.
+func MeasureDNSFamily(family, host string) DNSResult {
+	start := time.Now()
+	ips, err := net.DefaultResolver.LookupIP(context.Background(), family, host)
+	return dnsResult(host, ips, err, time.Since(start))
+}
+
+func dnsResult(host string, ips []net.IP, err error, elapsed time.Duration) DNSResult {
+	duration := elapsed.Seconds() * 1000
 
 	res := DNSResult{
 		Host:           host,
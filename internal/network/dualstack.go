@@ -0,0 +1,113 @@
+package network
+
+import (
+	"net"
+	"sort"
+	"time"
+)
+
+// HappyEyeballsDelay is how long a dual-stack connect waits for IPv6 before
+// it also tries IPv4, the default of Go and close to the 250ms of RFC 8305.
+const HappyEyeballsDelay = 300 * time.Millisecond
+
+// FamilyResult holds the measurements over one address family.
+type FamilyResult struct {
+	Family          string             `json:"family"` // IPv4 or IPv6
+	DNS             DNSResult          `json:"dns"`    // A or AAAA records only
+	Ping            *DetailedPingStats `json:"ping,omitempty"`
+	Traceroute      *TracerouteResult  `json:"traceroute,omitempty"`
+	TracerouteError string             `json:"traceroute_error,omitempty"`
+}
+
+// Name returns the display name of the family, e.g. "IPv6".
+func (f FamilyResult) Name() string {
+	return FamilyName(f.Family)
+}
+
+// HappyEyeballsResult holds dual-stack connects as browsers and the desktop
+// client make them: IPv6 first, then IPv4 as well if IPv6 has not connected
+// after HappyEyeballsDelay. A broken IPv6 path shows up as connect times of
+// the delay plus the IPv4 connect time.
+type HappyEyeballsResult struct {
+	Host      string   `json:"host"`
+	Count     int      `json:"count"`
+	AvgMs     float64  `json:"avg_ms"`
+	MaxMs     float64  `json:"max_ms"`
+	IPv4Count int      `json:"ipv4_count"` // Connects that ended up on IPv4
+	IPv6Count int      `json:"ipv6_count"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// DualStackResult compares the IPv4 and IPv6 paths to the server.
+type DualStackResult struct {
+	Families      []FamilyResult       `json:"families"`
+	HappyEyeballs *HappyEyeballsResult `json:"happy_eyeballs,omitempty"`
+}
+
+// Family returns the result of an address family, or nil.
+func (d *DualStackResult) Family(family string) *FamilyResult {
+	for i := range d.Families {
+		if d.Families[i].Family == family {
+			return &d.Families[i]
+		}
+	}
+	return nil
+}
+
+// FallbackPenaltyMs returns how much longer the dual-stack connect takes
+// than a direct connect over the faster family, the delay users notice if
+// one path is broken. Returns nil without the measurements to compare.
+func (d *DualStackResult) FallbackPenaltyMs() *float64 {
+	he := d.HappyEyeballs
+	if he == nil || he.Count == len(he.Errors) {
+		return nil
+	}
+	var best float64
+	for _, f := range d.Families {
+		if f.Ping != nil && f.Ping.SuccessCount > 0 && (best == 0 || f.Ping.AvgMs < best) {
+			best = f.Ping.AvgMs
+		}
+	}
+	if best == 0 {
+		return nil
+	}
+	penalty := max(he.AvgMs-best, 0)
+	return &penalty
+}
+
+// MeasureHappyEyeballs performs 'count' dual-stack TCP connects to host
+// (host:port) and records which address family each one ended up on.
+func MeasureHappyEyeballs(host string, count int, timeout time.Duration) HappyEyeballsResult {
+	res := HappyEyeballsResult{Host: host, Count: count}
+	dialer := net.Dialer{Timeout: timeout, FallbackDelay: HappyEyeballsDelay}
+
+	var times []float64
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		conn, err := dialer.Dial("tcp", host)
+		duration := time.Since(start).Seconds() * 1000 // ms
+		if err != nil {
+			res.Errors = append(res.Errors, err.Error())
+			continue
+		}
+		if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && addr.IP.To4() == nil {
+			res.IPv6Count++
+		} else {
+			res.IPv4Count++
+		}
+		conn.Close()
+		times = append(times, duration)
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	if len(times) > 0 {
+		var total float64
+		for _, t := range times {
+			total += t
+		}
+		res.AvgMs = total / float64(len(times))
+		sort.Float64s(times)
+		res.MaxMs = times[len(times)-1]
+	}
+	return res
+}
@@ -1,68 +1,16 @@
 package network
 
 import (
-	"crypto/tls"
 	"net"
-	"net/http"
-	"net/http/httptrace"
 	"os"
 	"strings"
-	"time"
 )
 
 type ExtendedNetworkInfo struct {
-	TLSHandshakeMs float64
-	ProxyDetected  bool
-	VPNDetected    bool
-	VPNType        string
-	MTU            int
-}
-
-func MeasureTLSHandshake(targetURL string) (time.Duration, error) {
-	var start, connect, dnsDone, tlsStart time.Duration
-	var tlsHandshake time.Duration
-
-	trace := &httptrace.ClientTrace{
-		DNSDone: func(dnsInfo httptrace.DNSDoneInfo) {
-			dnsDone = time.Duration(time.Now().UnixNano())
-		},
-		ConnectDone: func(network, addr string, err error) {
-			connect = time.Duration(time.Now().UnixNano())
-		},
-		TLSHandshakeStart: func() {
-			tlsStart = time.Duration(time.Now().UnixNano())
-		},
-		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
-			tlsHandshake = time.Since(time.Unix(0, int64(tlsStart)))
-		},
-	}
-
-	req, err := http.NewRequest("GET", targetURL, nil)
-	if err != nil {
-		return 0, err
-	}
-	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
-
-	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-	}
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   10 * time.Second,
-	}
-
-	start = time.Duration(time.Now().UnixNano()) // dummy just for completeness
-	_ = start
-	_ = dnsDone
-	_ = connect
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	return tlsHandshake, nil
+	ProxyDetected bool
+	VPNDetected   bool
+	VPNType       string
+	MTU           int
 }
 
 func GetExtendedNetworkInfo() ExtendedNetworkInfo {
@@ -10,9 +10,10 @@ import (
 
 type InterfaceInfo struct {
 	Name      string `json:"name"`
-	Type      string `json:"type"` // "Ethernet", "WiFi", "Unknown"
-	IPAddress string `json:"ip_address"`
-	LinkSpeed string `json:"link_speed"` // e.g., "1000 Mbps"
+	Type      string `json:"type"`                   // "Ethernet", "WiFi", "Unknown"
+	IPAddress string `json:"ip_address"`             // IPv4, or IPv6 on IPv6-only interfaces
+	IPv6      string `json:"ipv6_address,omitempty"` // Global IPv6 address
+	LinkSpeed string `json:"link_speed"`             // e.g., "1000 Mbps"
 	IsUp      bool   `json:"is_up"`
 }
 
@@ -44,16 +45,25 @@ func GetLocalNetworkInfo() LocalNetworkInfo {
 			continue
 		}
 
-		// Get first non-loopback IP
-		var ipAddr string
+		// Get first non-loopback IPv4 and global IPv6 address. Link-local
+		// IPv6 addresses exist on every interface and can't reach the server.
+		var ipAddr, ipv6Addr string
 		for _, addr := range addrs {
-			if ipnet, ok := addr.(*net.IPNet); ok {
-				if ipnet.IP.To4() != nil && !ipnet.IP.IsLoopback() {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.IsLoopback() {
+				continue
+			}
+			if ipnet.IP.To4() != nil {
+				if ipAddr == "" {
 					ipAddr = ipnet.IP.String()
-					break
 				}
+			} else if ipnet.IP.IsGlobalUnicast() && ipv6Addr == "" {
+				ipv6Addr = ipnet.IP.String()
 			}
 		}
+		if ipAddr == "" {
+			ipAddr = ipv6Addr
+		}
 		if ipAddr == "" {
 			continue
 		}
@@ -61,6 +71,7 @@ func GetLocalNetworkInfo() LocalNetworkInfo {
 		ifInfo := InterfaceInfo{
 			Name:      iface.Name,
 			IPAddress: ipAddr,
+			IPv6:      ipv6Addr,
 			IsUp:      true,
 			Type:      detectInterfaceType(iface.Name),
 			LinkSpeed: getLinkSpeed(iface.Name),
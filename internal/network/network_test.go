@@ -1,8 +1,13 @@
 package network
 
 import (
+	"errors"
+	"net"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMeasureDNS(t *testing.T) {
@@ -59,3 +64,247 @@ func TestSpeedtestStruct(t *testing.T) {
 		t.Error("Struct assignment failed")
 	}
 }
+
+func TestTracerouteMethodsLocalhost(t *testing.T) {
+	for _, family := range Families {
+		loopback := map[string]string{IPv4: "127.0.0.1", IPv6: "::1"}[family]
+		t.Run(FamilyName(family), func(t *testing.T) {
+			// TCP probes need a listening port
+			ln, err := net.Listen("tcp", net.JoinHostPort(loopback, "0"))
+			if err != nil {
+				t.Skipf("No %s loopback: %v", FamilyName(family), err)
+			}
+			defer ln.Close()
+			port := ln.Addr().(*net.TCPAddr).Port
+			dest := net.ParseIP(loopback)
+
+			for _, method := range TracerouteMethods {
+				t.Run(method, func(t *testing.T) {
+					p, err := newProber(method, dest, port)
+					if err != nil {
+						t.Skipf("%s not available: %v", method, err)
+					}
+					defer p.Close()
+					hops, answered := trace(p, dest, TracerouteOptions{MaxHops: 3, Cycles: 2})
+					if !answered || len(hops) != 1 {
+						t.Fatalf("Expected localhost as the only hop, got %+v", hops)
+					}
+					if h := hops[0]; h.Address != loopback || h.Sent != 2 || h.Received != 2 || h.AvgMs <= 0 {
+						t.Errorf("Unexpected hop %+v", hops[0])
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestMeasureICMPPing(t *testing.T) {
+	stats, err := MeasureICMPPing("127.0.0.1", 2, time.Second)
+	if err != nil {
+		t.Skipf("No ICMP socket available: %v", err)
+	}
+	if stats.Method != MethodICMPDgram && stats.Method != MethodICMPRaw {
+		t.Errorf("Unexpected method %q", stats.Method)
+	}
+	if stats.SuccessCount != 2 || stats.PacketLoss != 0 || stats.AvgMs <= 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestQuotesEcho(t *testing.T) {
+	echo, err := echoRequest(1, 7, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Minimal IPv4 header (version 4, 20 bytes) followed by the quoted request
+	quoted := append([]byte{0x45}, make([]byte, 19)...)
+	quoted = append(quoted, echo[:8]...)
+	if !quotesEcho(quoted, 7, false) {
+		t.Error("Expected the quoted echo request to match sequence 7")
+	}
+	if quotesEcho(quoted, 8, false) || quotesEcho(quoted[:24], 7, false) {
+		t.Error("Expected no match for another sequence or a short quote")
+	}
+
+	// IPv6 header has a fixed length of 40 bytes
+	echo6, err := echoRequest(1, 7, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quoted6 := append(make([]byte, 40), echo6...)
+	if !quotesEcho(quoted6, 7, true) || quotesEcho(quoted6, 7, false) {
+		t.Error("Expected the quoted ICMPv6 echo request to match only as IPv6")
+	}
+}
+
+// scriptedProber answers probes from a fixed path. Hop 2 drops every second
+// probe, hop 3 is the target.
+type scriptedProber struct {
+	path  []net.IP
+	calls map[int]int
+}
+
+func (p *scriptedProber) probe(ttl, seq int, timeout time.Duration) (net.IP, bool, error) {
+	p.calls[ttl]++
+	if ttl == 2 && p.calls[ttl]%2 == 0 {
+		return nil, false, errors.New("timeout")
+	}
+	return p.path[ttl-1], ttl == len(p.path), nil
+}
+
+func (p *scriptedProber) Close() error { return nil }
+
+func TestTraceCycles(t *testing.T) {
+	path := []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), net.ParseIP("192.0.2.1")}
+	p := &scriptedProber{path: path, calls: map[int]int{}}
+	hops, answered := trace(p, path[2], TracerouteOptions{MaxHops: 10, Cycles: 4})
+	if !answered || len(hops) != 3 {
+		t.Fatalf("Expected 3 hops, got %+v", hops)
+	}
+	if p.calls[4] != 0 {
+		t.Error("Expected no probes beyond the target")
+	}
+	if h := hops[1]; h.Sent != 4 || h.Received != 2 || h.PacketLoss != 50 || h.Address != "10.0.0.2" {
+		t.Errorf("Unexpected statistics for hop 2: %+v", h)
+	}
+	if h := hops[2]; h.Sent != 4 || h.Received != 4 || h.MinMs > h.AvgMs || h.AvgMs > h.MaxMs || h.StdDevMs < 0 {
+		t.Errorf("Unexpected statistics for hop 3: %+v", h)
+	}
+}
+
+// pathWithMTU answers probes up to mtu. Larger ones are dropped silently,
+// or with fragmentation needed if report is set.
+type pathWithMTU struct {
+	mtu    int
+	report bool
+}
+
+func (p pathWithMTU) probeSize(size, seq int, timeout time.Duration) (bool, int, error) {
+	switch {
+	case size <= p.mtu:
+		return true, 0, nil
+	case p.report:
+		return false, p.mtu, nil
+	}
+	return false, 0, errors.New("timeout")
+}
+
+func (p pathWithMTU) Close() error { return nil }
+
+func TestSearchMTU(t *testing.T) {
+	// PPPoE with working path MTU discovery: found with the reported MTU
+	res := PathMTUResult{LocalMTU: 1500}
+	if !searchMTU(pathWithMTU{mtu: 1492, report: true}, 576, &res) {
+		t.Fatal("Expected an answer")
+	}
+	if res.PathMTU != 1492 || res.ReportedMTU != 1492 || res.BlackHole || res.Probes != 3 {
+		t.Errorf("Unexpected result %+v", res)
+	}
+
+	// VPN dropping large packets silently
+	res = PathMTUResult{LocalMTU: 1500, TCPMTU: 1500}
+	searchMTU(pathWithMTU{mtu: 1420}, 576, &res)
+	if res.PathMTU != 1420 || !res.BlackHole || !res.TCPAffected() {
+		t.Errorf("Expected a black hole at 1420, got %+v", res)
+	}
+	// MSS clamping on the VPN keeps TCP below it
+	res.TCPMTU = 1400
+	if res.TCPAffected() {
+		t.Error("Expected TCP not to be affected with clamped MSS")
+	}
+
+	res = PathMTUResult{LocalMTU: 1500}
+	if searchMTU(pathWithMTU{mtu: 500}, 576, &res) {
+		t.Error("Expected no answer below the minimum MTU")
+	}
+}
+
+func TestDiscoverPathMTULocalhost(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	res, err := DiscoverPathMTU(IPv4, "127.0.0.1", ln.Addr().(*net.TCPAddr).Port)
+	if err != nil {
+		t.Skipf("Path MTU discovery not available: %v", err)
+	}
+	// Loopback has no MTU limit on the way
+	if res.PathMTU != res.LocalMTU || res.BlackHole || res.TCPMTU == 0 {
+		t.Errorf("Unexpected result %+v", res)
+	}
+}
+
+func TestASNDatabase(t *testing.T) {
+	tsv := "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+		"1.0.1.0\t1.0.3.255\t0\tNone\tNot routed\n" +
+		"2a00:1450::\t2a00:1450:ffff:ffff:ffff:ffff:ffff:ffff\t15169\tUS\tGOOGLE\n" +
+		"\n"
+	db, err := parseASNDatabase(strings.NewReader(tsv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if as, ok := db.Lookup(net.ParseIP("1.0.0.1")); !ok || as.Number != 13335 || as.Name != "CLOUDFLARENET" {
+		t.Errorf("Unexpected AS for 1.0.0.1: %+v", as)
+	}
+	if as, ok := db.Lookup(net.ParseIP("2a00:1450:4001::1")); !ok || as.Number != 15169 {
+		t.Errorf("Unexpected AS for 2a00:1450:4001::1: %+v", as)
+	}
+	for _, ip := range []string{"1.0.2.1", "9.9.9.9", "0.0.0.1"} {
+		if as, ok := db.Lookup(net.ParseIP(ip)); ok {
+			t.Errorf("Expected no AS for %s, got %+v", ip, as)
+		}
+	}
+	var none *ASNDatabase
+	if _, ok := none.Lookup(net.ParseIP("1.0.0.1")); ok {
+		t.Error("Expected no AS without database")
+	}
+	if _, err := parseASNDatabase(strings.NewReader("1.0.0.0\tnot-an-ip\t1\tUS\tX\n")); err == nil {
+		t.Error("Expected an error for an invalid line")
+	}
+}
+
+func TestMeasureDNSFamily(t *testing.T) {
+	if res := MeasureDNSFamily(IPv4, "127.0.0.1"); res.Error != "" || len(res.ResolvedIPs) != 1 {
+		t.Errorf("Expected the IPv4 literal to resolve over IPv4, got %+v", res)
+	}
+	if res := MeasureDNSFamily(IPv6, "127.0.0.1"); res.Error == "" {
+		t.Errorf("Expected no IPv6 address for an IPv4 literal, got %+v", res)
+	}
+}
+
+func TestHappyEyeballs(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	he := MeasureHappyEyeballs(ln.Addr().String(), 2, time.Second)
+	if he.IPv4Count != 2 || he.IPv6Count != 0 || len(he.Errors) != 0 || he.AvgMs <= 0 {
+		t.Errorf("Unexpected result %+v", he)
+	}
+	ping, _ := MeasureFamilyTCPPing(IPv4, ln.Addr().String(), 1, time.Second)
+	if ping.SuccessCount != 1 {
+		t.Errorf("Expected the IPv4 connect to succeed, got %+v", ping)
+	}
+	if ping, _ := MeasureFamilyTCPPing(IPv6, net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), 1, time.Second); ping.SuccessCount != 0 {
+		t.Errorf("Expected no IPv6 connect to an IPv4 address, got %+v", ping)
+	}
+
+	// A 300ms dual-stack connect with a 10ms IPv4 path costs 290ms
+	ds := DualStackResult{
+		Families: []FamilyResult{
+			{Family: IPv4, Ping: &DetailedPingStats{Count: 5, SuccessCount: 5, AvgMs: 10}},
+			{Family: IPv6, Ping: &DetailedPingStats{Count: 5, PacketLoss: 100}},
+		},
+		HappyEyeballs: &HappyEyeballsResult{Count: 5, AvgMs: 300, IPv4Count: 5},
+	}
+	if penalty := ds.FallbackPenaltyMs(); penalty == nil || *penalty != 290 {
+		t.Errorf("Expected a penalty of 290ms, got %v", penalty)
+	}
+	if ds.Family(IPv6).Name() != "IPv6" || ds.Family("ip5") != nil {
+		t.Error("Unexpected family lookup")
+	}
+}
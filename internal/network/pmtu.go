@@ -0,0 +1,220 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	mtuProbeTries = 3     // Probes per size, so that a lost probe is not taken for the limit
+	maxPacketSize = 65535 // Largest IP packet, the loopback MTU is larger
+)
+
+// PMTUMethods lists the probe methods of path MTU discovery in the order
+// DiscoverPathMTU tries them. All of them send packets with fragmentation
+// prohibited (DF bit), which only Linux allows without root.
+var PMTUMethods = []string{MethodICMPDgram, MethodICMPRaw, MethodUDP}
+
+// PathMTUResult holds the largest packet that gets through to the server.
+//
+// Routers that cannot forward a packet with fragmentation prohibited drop it
+// and tell the sender with an ICMP "fragmentation needed" (IPv6: "packet too
+// big"), from which TCP learns to send smaller segments. If that ICMP is
+// filtered, which happens on VPN and PPPoE links, the path is an MTU black
+// hole: small requests work, but connections stall as soon as they send
+// full-sized segments, e.g. on uploads.
+type PathMTUResult struct {
+	Family   string `json:"family"`
+	Method   string `json:"method,omitempty"`
+	LocalMTU int    `json:"local_mtu"` // MTU of the interface towards the server
+	PathMTU  int    `json:"path_mtu"`  // Largest packet that reached the server, 0 if unknown
+
+	// Smallest next-hop MTU reported with ICMP fragmentation needed or packet
+	// too big, or by the local route
+	ReportedMTU int `json:"reported_mtu,omitempty"`
+
+	// MSS of a TCP connection to the service port, limited by the MSS the
+	// server announces and any MSS clamping on the way, and the packet size
+	// it results in
+	TCPMSS int `json:"tcp_mss,omitempty"`
+	TCPMTU int `json:"tcp_mtu,omitempty"`
+
+	// Packets above PathMTU were dropped without ICMP error
+	BlackHole bool `json:"black_hole"`
+
+	Probes  int      `json:"probes"`
+	Skipped []string `json:"skipped,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// TCPAffected reports whether TCP connections run into the black hole,
+// because they send larger packets than get through.
+func (r PathMTUResult) TCPAffected() bool {
+	return r.BlackHole && (r.TCPMTU == 0 || r.TCPMTU > r.PathMTU)
+}
+
+// sizeProber sends a probe of the given IP packet size with fragmentation
+// prohibited. It reports whether the probe reached the target, or the MTU a
+// router or the local route reported as too small. A probe without answer
+// yields an error.
+type sizeProber interface {
+	probeSize(size, seq int, timeout time.Duration) (bool, int, error)
+	Close() error
+}
+
+// DiscoverPathMTU searches the largest packet that reaches host over the
+// address family IPv4 or IPv6, between the minimum MTU of the family and the
+// MTU of the local interface. port is the service port of host, used to read
+// the MSS of a TCP connection.
+func DiscoverPathMTU(family, host string, port int) (PathMTUResult, error) {
+	res := PathMTUResult{Family: family}
+	destAddr, err := net.ResolveIPAddr(family, host)
+	if err != nil {
+		return res, fmt.Errorf("resolve failed: %v", err)
+	}
+	dest := destAddr.IP
+	v6 := dest.To4() == nil
+	res.LocalMTU = min(localMTU(dest), maxPacketSize)
+
+	if port > 0 {
+		if mss, err := tcpMSS(dest, port); err == nil {
+			res.TCPMSS = mss
+			res.TCPMTU = mss + ipHeaderLen(v6) + 20
+		}
+	}
+
+	minMTU := 576
+	if v6 {
+		minMTU = 1280
+	}
+	for _, method := range PMTUMethods {
+		p, err := newSizeProber(method, dest)
+		if err != nil {
+			res.Skipped = append(res.Skipped, fmt.Sprintf("%s: %v", method, err))
+			continue
+		}
+		ok := searchMTU(p, minMTU, &res)
+		p.Close()
+		if !ok {
+			res.Skipped = append(res.Skipped, method+": no replies")
+			continue
+		}
+		res.Method = method
+		return res, nil
+	}
+	return res, fmt.Errorf("no path MTU method available (%s)", strings.Join(res.Skipped, "; "))
+}
+
+// searchMTU finds the largest packet between minMTU and res.LocalMTU that
+// reaches the target, by binary search or directly at the MTU a router
+// reported. It reports false if not even minMTU gets an answer.
+func searchMTU(p sizeProber, minMTU int, res *PathMTUResult) bool {
+	seq := 0
+	send := func(size int) (bool, int, error) {
+		var err error
+		for try := 0; try < mtuProbeTries; try++ {
+			seq++
+			res.Probes++
+			var reached bool
+			var mtu int
+			if reached, mtu, err = p.probeSize(size, seq, probeTimeout); err == nil {
+				return reached, mtu, nil
+			}
+		}
+		return false, 0, err
+	}
+
+	if reached, _, err := send(minMTU); err != nil || !reached {
+		return false
+	}
+	lo, hi := minMTU, res.LocalMTU+1 // lo got through, hi did not
+	silent := 0                      // Smallest size dropped without ICMP error
+	size := res.LocalMTU
+	for hi-lo > 1 {
+		reached, mtu, err := send(size)
+		switch {
+		case reached:
+			lo = size
+			if size == res.ReportedMTU {
+				hi = size + 1 // Larger ones were already refused
+			}
+		case err != nil:
+			hi = size
+			if silent == 0 || size < silent {
+				silent = size
+			}
+		default:
+			hi = size
+			if mtu > 0 && (res.ReportedMTU == 0 || mtu < res.ReportedMTU) {
+				res.ReportedMTU = mtu
+			}
+		}
+		size = (lo + hi) / 2
+		if m := res.ReportedMTU; m > lo && m < hi {
+			size = m // Try the reported MTU first, usually right
+		}
+	}
+	res.PathMTU = lo
+	res.BlackHole = silent > 0 && (res.ReportedMTU == 0 || silent <= res.ReportedMTU)
+	return true
+}
+
+// localMTU returns the MTU of the interface the route to dest goes through,
+// or 1500 if it cannot be determined.
+func localMTU(dest net.IP) int {
+	// Connecting a UDP socket only looks up the route, nothing is sent
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: dest, Port: udpBasePort})
+	if err != nil {
+		return 1500
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return 1500
+	}
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(local) {
+				return iface.MTU
+			}
+		}
+	}
+	return 1500
+}
+
+// ipHeaderLen returns the length of an IP header without options.
+func ipHeaderLen(v6 bool) int {
+	if v6 {
+		return ipv6.HeaderLen
+	}
+	return ipv4.HeaderLen
+}
+
+// sizedEcho returns an ICMP or ICMPv6 echo request of length bytes: the
+// probe sequence followed by padding.
+func sizedEcho(id, seq, length int, v6 bool) ([]byte, error) {
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if v6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+	wm := icmp.Message{
+		Type: typ, Code: 0,
+		Body: &icmp.Echo{
+			ID: id, Seq: seq,
+			Data: make([]byte, max(length-8, 0)), // 8 bytes ICMP header
+		},
+	}
+	return wm.Marshal(nil)
+}
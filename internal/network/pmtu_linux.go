@@ -0,0 +1,162 @@
+//go:build linux
+
+package network
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/sys/unix"
+)
+
+// tcpiOptTimestamps is TCPI_OPT_TIMESTAMPS of struct tcp_info.
+const tcpiOptTimestamps = 1
+
+// mtuProber sends probes of a given size over the sockets of the traceroute
+// methods. IP_PMTUDISC_PROBE sets the DF bit and ignores the path MTU the
+// kernel has cached, so that every size up to the interface MTU is sent.
+// Routers that drop a probe as too big show up in the error queue.
+type mtuProber struct {
+	socketProber
+	id int // Echo ID of raw ICMP probes, datagram sockets set their own
+}
+
+func newSizeProber(method string, dest net.IP) (sizeProber, error) {
+	p := &mtuProber{
+		socketProber: socketProber{method: method, fd: -1, dest: dest, v6: dest.To4() == nil},
+		id:           os.Getpid() & 0xffff,
+	}
+
+	icmpProto := unix.IPPROTO_ICMP
+	level, opt, val := unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE
+	if p.v6 {
+		icmpProto = unix.IPPROTO_ICMPV6
+		level, opt, val = unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE
+	}
+	var err error
+	switch method {
+	case MethodICMPDgram:
+		p.fd, err = p.openSocket(unix.SOCK_DGRAM, icmpProto)
+	case MethodICMPRaw:
+		p.fd, err = p.openSocket(unix.SOCK_RAW, icmpProto)
+	case MethodUDP:
+		p.fd, err = p.openSocket(unix.SOCK_DGRAM, unix.IPPROTO_UDP)
+	default:
+		return nil, errUnsupported
+	}
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err := unix.SetsockoptInt(p.fd, level, opt, val); err != nil {
+		p.Close()
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+	return p, nil
+}
+
+func (p *mtuProber) probeSize(size, seq int, timeout time.Duration) (bool, int, error) {
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, maxPacketSize)
+
+	// Probes are identified like traceroute probes: ICMP by the echo
+	// sequence, UDP by the destination port
+	port := 0
+	var payload []byte
+	if p.method == MethodUDP {
+		port = udpBasePort + seq
+		payload = make([]byte, size-ipHeaderLen(p.v6)-8) // 8 bytes UDP header
+	} else {
+		payload, _ = sizedEcho(p.id, seq, size-ipHeaderLen(p.v6), p.v6)
+	}
+	if err := unix.Sendto(p.fd, payload, 0, p.sockaddr(port)); err != nil {
+		if err != unix.EMSGSIZE {
+			return false, 0, err
+		}
+		// Larger than the MTU of the local route, which is in the error queue
+		e, _, qerr := readErrQueue(p.fd, buf)
+		if qerr != nil {
+			return false, 0, nil
+		}
+		return false, e.mtu, nil
+	}
+
+	for {
+		revents, err := wait(p.fd, unix.POLLIN, deadline)
+		if err != nil {
+			return false, 0, err
+		}
+		if revents&unix.POLLERR != 0 {
+			e, data, err := readErrQueue(p.fd, buf)
+			if err != nil {
+				continue
+			}
+			if p.method == MethodUDP && e.port != port || p.method != MethodUDP && echoSeq(data, p.v6) != seq {
+				continue
+			}
+			if e.mtu > 0 {
+				return false, e.mtu, nil
+			}
+			if p.method == MethodUDP && e.unreachable && e.offender.Equal(p.dest) {
+				return true, 0, nil // Port unreachable from the target
+			}
+			continue
+		}
+		n, from, err := unix.Recvfrom(p.fd, buf, unix.MSG_DONTWAIT)
+		if err != nil {
+			continue
+		}
+		if p.method == MethodUDP {
+			return true, 0, nil // A service answered on the port
+		}
+		b := buf[:n]
+		if p.method == MethodICMPRaw && !p.v6 {
+			// Raw IPv4 sockets return the IP header as well
+			if n < ipv4.HeaderLen || n < int(b[0]&0x0f)*4 {
+				continue
+			}
+			b = b[int(b[0]&0x0f)*4:]
+		}
+		rm, err := parseICMP(b, p.v6)
+		if err != nil {
+			continue
+		}
+		echo, ok := rm.Body.(*icmp.Echo)
+		if ok && isEchoReply(rm) && echo.Seq == seq && (p.method != MethodICMPRaw || echo.ID == p.id) && sockaddrIP(from).Equal(p.dest) {
+			return true, 0, nil
+		}
+	}
+}
+
+// tcpMSS connects to the service port and returns the MSS of the
+// connection, the smaller of the MSS the server announced and the one the
+// local MTU allows, without the space taken by TCP options.
+func tcpMSS(dest net.IP, port int) (int, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(dest.String(), strconv.Itoa(port)), 5*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var info *unix.TCPInfo
+	var infoErr error
+	if err := raw.Control(func(fd uintptr) {
+		info, infoErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil {
+		return 0, err
+	}
+	if infoErr != nil {
+		return 0, os.NewSyscallError("getsockopt", infoErr)
+	}
+	mss := int(info.Snd_mss)
+	if info.Options&tcpiOptTimestamps != 0 {
+		mss += 12 // Timestamp option in every segment
+	}
+	return mss, nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package network
+
+import "net"
+
+// Other systems do not let unprivileged programs set the DF bit, or the
+// standard library does not expose it.
+
+func newSizeProber(method string, dest net.IP) (sizeProber, error) {
+	return nil, errUnsupported
+}
+
+func tcpMSS(dest net.IP, port int) (int, error) {
+	return 0, errUnsupported
+}
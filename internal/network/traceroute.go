@@ -1,114 +1,431 @@
 package network
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
+// Address families, named like the networks of net.ResolveIPAddr.
+const (
+	IPv4 = "ip4"
+	IPv6 = "ip6"
+)
+
+// Families lists the address families measured separately.
+var Families = []string{IPv4, IPv6}
+
+// FamilyName returns the display name of an address family, e.g. "IPv6".
+func FamilyName(family string) string {
+	if family == IPv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+// Traceroute methods, in the order RunTraceroute tries them.
+const (
+	MethodICMPRaw   = "icmp-raw"   // Raw ICMP socket, requires root or CAP_NET_RAW
+	MethodICMPDgram = "icmp-dgram" // Unprivileged ICMP socket (Linux ping_group_range, macOS)
+	MethodUDP       = "udp"        // UDP probes to high ports, like tracepath
+	MethodTCP       = "tcp"        // TCP SYN to the service port, like traceroute -T
+)
+
+// TracerouteMethods lists the methods in the order they are tried.
+var TracerouteMethods = []string{MethodICMPRaw, MethodICMPDgram, MethodUDP, MethodTCP}
+
+// errUnsupported is returned for methods not implemented on this platform.
+var errUnsupported = errors.New("not supported on this platform")
+
+const (
+	probeTimeout  = 1 * time.Second
+	maxSilentHops = 5 // Consecutive unanswered hops after which the trace stops
+	udpBasePort   = 33434
+	maxSeq        = 30000 // Keeps UDP probe ports below 65535
+	lookupTimeout = 2 * time.Second
+)
+
+// Hop holds the answers to the probes of one TTL. With several probes per
+// hop Address is the router that answered most often, Others lists the
+// remaining ones, e.g. behind load balancers.
 type Hop struct {
-	TTL     int           `json:"ttl"`
-	Address string        `json:"address"`
-	RTT     time.Duration `json:"rtt"`
+	TTL        int      `json:"ttl"`
+	Address    string   `json:"address,omitempty"` // Empty if no probe was answered
+	Others     []string `json:"others,omitempty"`
+	Hostname   string   `json:"hostname,omitempty"` // Reverse DNS of Address
+	ASN        int      `json:"asn,omitempty"`
+	ASName     string   `json:"as_name,omitempty"`
+	Sent       int      `json:"sent"`
+	Received   int      `json:"received"`
+	PacketLoss float64  `json:"packet_loss"` // Percent
+	MinMs      float64  `json:"min_ms"`
+	AvgMs      float64  `json:"avg_ms"`
+	MaxMs      float64  `json:"max_ms"`
+	StdDevMs   float64  `json:"stddev_ms"`
 }
 
-// RunTraceroute performs a traceroute using ICMP Echo Requests.
-// Requires Root/Admin privileges.
-func RunTraceroute(target string, maxHops int) ([]Hop, error) {
-	// Resolve Target
-	destAddr, err := net.ResolveIPAddr("ip4", target)
-	if err != nil {
-		return nil, fmt.Errorf("resolve failed: %v", err)
-	}
+// TracerouteOptions configures RunTraceroute.
+type TracerouteOptions struct {
+	Port    int // Service port of the target, used by TCP probes
+	MaxHops int
+
+	// Cycles > 1 probes every hop that many times, Interval apart, for loss
+	// and latency statistics per hop like mtr.
+	Cycles   int
+	Interval time.Duration
+
+	ResolveNames bool         // Look up the hostnames of the hops
+	ASN          *ASNDatabase // Look up the autonomous systems of the hops
+}
+
+// TracerouteResult holds the hops of a traceroute and the method that
+// found them. Skipped lists the methods tried before, with the reason.
+type TracerouteResult struct {
+	Method  string   `json:"method"`
+	Cycles  int      `json:"cycles"` // Probes sent per hop
+	Hops    []Hop    `json:"hops"`
+	Skipped []string `json:"skipped,omitempty"`
+}
 
-	// Use standard net.ListenPacket for raw ICMP (Requires Root)
-	c, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+// prober sends a probe with the given TTL and waits for the answer. It
+// returns the address that answered and whether that ends the trace
+// (target reached or unreachable). A probe without answer yields an error.
+type prober interface {
+	probe(ttl, seq int, timeout time.Duration) (net.IP, bool, error)
+	Close() error
+}
+
+// RunTraceroute traces the path to target over the address family IPv4 or
+// IPv6. It tries raw ICMP first, then the unprivileged methods, so that it
+// also works without root: ICMP datagram sockets, UDP probes and TCP SYNs to
+// the service port of the target, which firewalls usually let through. A
+// method that cannot open its socket or gets no answer at all is skipped.
+func RunTraceroute(family, target string, opts TracerouteOptions) (TracerouteResult, error) {
+	res := TracerouteResult{Cycles: max(opts.Cycles, 1)}
+	destAddr, err := net.ResolveIPAddr(family, target)
 	if err != nil {
-		return nil, fmt.Errorf("listen failed (ROOT REQUIRED): %v", err)
+		return res, fmt.Errorf("resolve failed: %v", err)
 	}
-	defer c.Close()
 
-	// Wrap with ipv4.PacketConn to set TTL
-	p := ipv4.NewPacketConn(c)
-	if p == nil {
-		return nil, fmt.Errorf("failed to create ipv4 packet connection")
+	for _, method := range TracerouteMethods {
+		p, err := newProber(method, destAddr.IP, opts.Port)
+		if err != nil {
+			res.Skipped = append(res.Skipped, fmt.Sprintf("%s: %v", method, err))
+			continue
+		}
+		hops, answered := trace(p, destAddr.IP, opts)
+		p.Close()
+		if !answered {
+			res.Skipped = append(res.Skipped, method+": no replies")
+			continue
+		}
+		annotate(hops, opts)
+		res.Method, res.Hops = method, hops
+		return res, nil
 	}
-	defer p.Close()
+	return res, fmt.Errorf("no traceroute method available (%s)", strings.Join(res.Skipped, "; "))
+}
 
-	var hops []Hop
+// hopSamples collects the answers to the probes of one TTL.
+type hopSamples struct {
+	sent    int
+	rtts    []float64 // ms
+	answers map[string]int
+	order   []string // Answering addresses in order of appearance
+}
 
-	for ttl := 1; ttl <= maxHops; ttl++ {
-		start := time.Now()
+func (s *hopSamples) add(addr net.IP, rtt time.Duration) {
+	if s.answers == nil {
+		s.answers = map[string]int{}
+	}
+	a := addr.String()
+	if s.answers[a] == 0 {
+		s.order = append(s.order, a)
+	}
+	s.answers[a]++
+	s.rtts = append(s.rtts, rtt.Seconds()*1000)
+}
 
-		// 1. Set TTL
-		if err := p.SetTTL(ttl); err != nil {
-			return hops, fmt.Errorf("failed to set TTL: %v", err)
+// hop returns the statistics of the samples.
+func (s *hopSamples) hop(ttl int) Hop {
+	h := Hop{TTL: ttl, Sent: s.sent, Received: len(s.rtts)}
+	if h.Sent > 0 {
+		h.PacketLoss = float64(h.Sent-h.Received) / float64(h.Sent) * 100
+	}
+	for _, a := range s.order {
+		if h.Address == "" || s.answers[a] > s.answers[h.Address] {
+			h.Address = a
 		}
+	}
+	for _, a := range s.order {
+		if a != h.Address {
+			h.Others = append(h.Others, a)
+		}
+	}
+	if len(s.rtts) == 0 {
+		return h
+	}
+	h.MinMs, h.MaxMs = s.rtts[0], s.rtts[0]
+	var sum float64
+	for _, r := range s.rtts {
+		h.MinMs, h.MaxMs = min(h.MinMs, r), max(h.MaxMs, r)
+		sum += r
+	}
+	h.AvgMs = sum / float64(len(s.rtts))
+	var sq float64
+	for _, r := range s.rtts {
+		sq += (r - h.AvgMs) * (r - h.AvgMs)
+	}
+	h.StdDevMs = math.Sqrt(sq / float64(len(s.rtts)))
+	return h
+}
 
-		// 2. Construct ICMP Message
-		wm := icmp.Message{
-			Type: ipv4.ICMPTypeEcho, Code: 0,
-			Body: &icmp.Echo{
-				ID: os.Getpid() & 0xffff, Seq: ttl,
-				Data: []byte("NextcloudPerf"),
-			},
+// trace probes the hops until the target answers, opts.Cycles times. The
+// first cycle finds the path, the following ones probe only its hops. It
+// reports whether any hop answered in the first cycle.
+func trace(p prober, dest net.IP, opts TracerouteOptions) (hops []Hop, answered bool) {
+	cycles := max(opts.Cycles, 1)
+	limit := opts.MaxHops
+	var samples []hopSamples
+	for cycle := 0; cycle < cycles; cycle++ {
+		if cycle > 0 {
+			time.Sleep(opts.Interval)
 		}
-		wb, err := wm.Marshal(nil)
-		if err != nil {
-			continue
+		silent := 0
+		for ttl := 1; ttl <= limit && silent < maxSilentHops; ttl++ {
+			if len(samples) < ttl {
+				samples = append(samples, hopSamples{})
+			}
+			s := &samples[ttl-1]
+			s.sent++
+			// A sequence per probe, so that late answers are not counted twice
+			seq := (cycle*opts.MaxHops + ttl) % maxSeq
+			start := time.Now()
+			addr, final, err := p.probe(ttl, seq, probeTimeout)
+			if err != nil {
+				silent++
+				continue
+			}
+			s.add(addr, time.Since(start))
+			silent = 0
+			answered = true
+			if final || addr.Equal(dest) {
+				limit = ttl
+				break
+			}
+		}
+		if !answered {
+			return nil, false
 		}
+	}
+
+	for i := range samples {
+		hops = append(hops, samples[i].hop(i+1))
+	}
+	return hops, true
+}
 
-		// 3. Send (Use p.WriteTo for raw control)
-		// Note: WriteTo expects CM but we can pass nil if we set TTL on conn
-		if _, err := p.WriteTo(wb, nil, destAddr); err != nil {
+// annotate looks up the hostnames and autonomous systems of the hops.
+func annotate(hops []Hop, opts TracerouteOptions) {
+	var wg sync.WaitGroup
+	for i := range hops {
+		h := &hops[i]
+		if h.Address == "" {
 			continue
 		}
-
-		// 4. Wait for Reply
-		if err := c.SetReadDeadline(time.Now().Add(1 * time.Second)); err != nil {
+		ip := net.ParseIP(h.Address)
+		if as, ok := opts.ASN.Lookup(ip); ok {
+			h.ASN, h.ASName = as.Number, as.Name
+		}
+		if !opts.ResolveNames {
 			continue
 		}
-		rb := make([]byte, 1500)
-		n, peer, err := c.ReadFrom(rb)
-
-		rtt := time.Since(start)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+			defer cancel()
+			if names, err := net.DefaultResolver.LookupAddr(ctx, h.Address); err == nil && len(names) > 0 {
+				h.Hostname = strings.TrimSuffix(names[0], ".")
+			}
+		}()
+	}
+	wg.Wait()
+}
 
+// newProber opens the socket of a traceroute method.
+func newProber(method string, dest net.IP, port int) (prober, error) {
+	switch method {
+	case MethodICMPRaw:
+		network := "ip4:icmp"
+		if dest.To4() == nil {
+			network = "ip6:ipv6-icmp"
+		}
+		p, err := newICMPProber(network, dest)
 		if err != nil {
-			// Timeout (Unreachable or no reply)
-			hops = append(hops, Hop{TTL: ttl, Address: "*", RTT: 0})
-			continue
+			return nil, err
 		}
+		return p, nil
+	case MethodICMPDgram, MethodUDP, MethodTCP:
+		return newSocketProber(method, dest, port)
+	}
+	return nil, fmt.Errorf("unknown traceroute method %q", method)
+}
+
+// icmpProber sends ICMP echo requests and reads the replies and ICMP errors
+// from the socket, which works for raw sockets and, except on Linux, for
+// ICMP datagram sockets.
+type icmpProber struct {
+	conn *icmp.PacketConn
+	dest net.Addr
+	id   int
+	raw  bool // Raw sockets also receive the replies to other processes
+	v6   bool
+}
+
+// newICMPProber listens on network, "ip4:icmp", "ip6:ipv6-icmp" for raw or
+// "udp4", "udp6" for datagram sockets.
+func newICMPProber(network string, dest net.IP) (*icmpProber, error) {
+	v6 := dest.To4() == nil
+	address := "0.0.0.0"
+	if v6 {
+		address = "::"
+	}
+	c, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	p := &icmpProber{conn: c, dest: &net.IPAddr{IP: dest}, id: os.Getpid() & 0xffff, raw: true, v6: v6}
+	if strings.HasPrefix(network, "udp") {
+		p.dest, p.raw = &net.UDPAddr{IP: dest}, false
+	}
+	return p, nil
+}
+
+func (p *icmpProber) probe(ttl, seq int, timeout time.Duration) (net.IP, bool, error) {
+	var err error
+	if p.v6 {
+		err = p.conn.IPv6PacketConn().SetHopLimit(ttl)
+	} else {
+		err = p.conn.IPv4PacketConn().SetTTL(ttl)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	wb, err := echoRequest(p.id, seq, p.v6)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := p.conn.WriteTo(wb, p.dest); err != nil {
+		return nil, false, err
+	}
 
-		// 5. Parse Reply
-		// We need to parse as IPv4 ICMP
-		rm, err := icmp.ParseMessage(ipv4.ICMPTypeEchoReply.Protocol(), rb[:n])
+	deadline := time.Now().Add(timeout)
+	if err := p.conn.SetReadDeadline(deadline); err != nil {
+		return nil, false, err
+	}
+	rb := make([]byte, 1500)
+	for {
+		n, peer, err := p.conn.ReadFrom(rb)
+		if err != nil {
+			return nil, false, err
+		}
+		rm, err := parseICMP(rb[:n], p.v6)
 		if err != nil {
 			continue
 		}
-
-		var hopIp string
-		if peer != nil {
-			hopIp = peer.String()
+		var from net.IP
+		switch a := peer.(type) {
+		case *net.IPAddr:
+			from = a.IP
+		case *net.UDPAddr:
+			from = a.IP
 		}
 
-		hops = append(hops, Hop{TTL: ttl, Address: hopIp, RTT: rtt})
-
-		// Analysis
-		if rm.Type == ipv4.ICMPTypeEchoReply {
-			// Reached destination
-			if hopIp == destAddr.String() {
-				break
+		switch body := rm.Body.(type) {
+		case *icmp.Echo:
+			// Datagram sockets may rewrite the ID, only raw sockets check it
+			if isEchoReply(rm) && body.Seq == seq && (!p.raw || body.ID == p.id) {
+				return from, true, nil
+			}
+		case *icmp.TimeExceeded:
+			if quotesEcho(body.Data, seq, p.v6) {
+				return from, false, nil
+			}
+		case *icmp.DstUnreach:
+			if quotesEcho(body.Data, seq, p.v6) {
+				return from, true, nil
 			}
 		}
-		// TimeExceeded is expected for intermediate hops
+	}
+}
+
+func (p *icmpProber) Close() error {
+	return p.conn.Close()
+}
+
+// echoRequest returns an ICMP or ICMPv6 echo request carrying the probe
+// sequence. The kernel calculates the ICMPv6 checksum.
+func echoRequest(id, seq int, v6 bool) ([]byte, error) {
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if v6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+	wm := icmp.Message{
+		Type: typ, Code: 0,
+		Body: &icmp.Echo{
+			ID: id, Seq: seq,
+			Data: []byte("NextcloudPerf"),
+		},
+	}
+	return wm.Marshal(nil)
+}
+
+// parseICMP parses an ICMP or ICMPv6 message without IP header.
+func parseICMP(b []byte, v6 bool) (*icmp.Message, error) {
+	if v6 {
+		return icmp.ParseMessage(ipv6.ICMPTypeEchoReply.Protocol(), b)
+	}
+	return icmp.ParseMessage(ipv4.ICMPTypeEchoReply.Protocol(), b)
+}
+
+func isEchoReply(m *icmp.Message) bool {
+	return m.Type == ipv4.ICMPTypeEchoReply || m.Type == ipv6.ICMPTypeEchoReply
+}
+
+// echoSeq returns the sequence of the ICMP echo request at the start of
+// data, or -1.
+func echoSeq(data []byte, v6 bool) int {
+	typ := byte(ipv4.ICMPTypeEcho)
+	if v6 {
+		typ = byte(ipv6.ICMPTypeEchoRequest)
+	}
+	if len(data) < 8 || data[0] != typ {
+		return -1
+	}
+	return int(data[6])<<8 | int(data[7])
+}
 
-		if hopIp == destAddr.String() {
-			break
+// quotesEcho reports whether the original datagram quoted in an ICMP error,
+// IP header followed by the first bytes of the ICMP message, is the echo
+// request with the given sequence.
+func quotesEcho(data []byte, seq int, v6 bool) bool {
+	hl := ipv6.HeaderLen
+	if !v6 {
+		if len(data) < ipv4.HeaderLen {
+			return false
 		}
+		hl = int(data[0]&0x0f) * 4
 	}
-	return hops, nil
+	return len(data) >= hl && echoSeq(data[hl:], v6) == seq
 }
@@ -0,0 +1,304 @@
+//go:build linux
+
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+// On Linux ICMP errors for UDP, TCP and ICMP datagram sockets end up in the
+// error queue of the socket if IP_RECVERR (IPV6_RECVERR) is set, together
+// with the address of the router that sent them. That is how tracepath and
+// traceroute -T work without root. ICMP datagram sockets need the group of the
+// user in net.ipv4.ping_group_range, which also applies to ICMPv6 and which
+// most distributions allow by default.
+
+// socketProber implements the unprivileged methods. ICMP and UDP probes are
+// sent over one socket, TCP probes each open a new connection.
+type socketProber struct {
+	method string
+	fd     int
+	dest   net.IP
+	port   int
+	v6     bool
+}
+
+func newSocketProber(method string, dest net.IP, port int) (prober, error) {
+	p := &socketProber{method: method, fd: -1, dest: dest, port: port, v6: dest.To4() == nil}
+
+	icmpProto := unix.IPPROTO_ICMP
+	if p.v6 {
+		icmpProto = unix.IPPROTO_ICMPV6
+	}
+	var err error
+	switch method {
+	case MethodICMPDgram:
+		p.fd, err = p.openSocket(unix.SOCK_DGRAM, icmpProto)
+	case MethodUDP:
+		p.fd, err = p.openSocket(unix.SOCK_DGRAM, unix.IPPROTO_UDP)
+	case MethodTCP:
+		if port <= 0 {
+			return nil, errors.New("no port")
+		}
+		var fd int
+		if fd, err = p.openSocket(unix.SOCK_STREAM, 0); err == nil {
+			unix.Close(fd)
+		}
+	default:
+		return nil, errUnsupported
+	}
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	return p, nil
+}
+
+// openSocket opens a nonblocking socket of the prober's family with the
+// error queue enabled.
+func (p *socketProber) openSocket(typ, proto int) (int, error) {
+	domain, level, opt := unix.AF_INET, unix.IPPROTO_IP, unix.IP_RECVERR
+	if p.v6 {
+		domain, level, opt = unix.AF_INET6, unix.IPPROTO_IPV6, unix.IPV6_RECVERR
+	}
+	fd, err := unix.Socket(domain, typ|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return -1, err
+	}
+	if err := unix.SetsockoptInt(fd, level, opt, 1); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// setTTL sets the TTL, or the hop limit for IPv6, of the packets sent on fd.
+func (p *socketProber) setTTL(fd, ttl int) error {
+	if p.v6 {
+		return unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, ttl)
+	}
+	return unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_TTL, ttl)
+}
+
+// sockaddr returns the address of the target with the given port.
+func (p *socketProber) sockaddr(port int) unix.Sockaddr {
+	if p.v6 {
+		sa := &unix.SockaddrInet6{Port: port}
+		copy(sa.Addr[:], p.dest.To16())
+		return sa
+	}
+	sa := &unix.SockaddrInet4{Port: port}
+	copy(sa.Addr[:], p.dest.To4())
+	return sa
+}
+
+// sockaddrIP returns the IP address of a socket address.
+func sockaddrIP(sa unix.Sockaddr) net.IP {
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		return net.IP(sa.Addr[:])
+	case *unix.SockaddrInet6:
+		return net.IP(sa.Addr[:])
+	}
+	return nil
+}
+
+func (p *socketProber) probe(ttl, seq int, timeout time.Duration) (net.IP, bool, error) {
+	deadline := time.Now().Add(timeout)
+	if p.method == MethodTCP {
+		return p.probeTCP(ttl, deadline)
+	}
+	if err := p.setTTL(p.fd, ttl); err != nil {
+		return nil, false, err
+	}
+
+	// ICMP probes are identified by the echo sequence, UDP probes by their
+	// destination port, which the error queue returns as the message address
+	port := 0
+	var payload []byte
+	if p.method == MethodICMPDgram {
+		payload, _ = echoRequest(0, seq, p.v6) // The kernel sets the ID
+	} else {
+		port = udpBasePort + seq
+		payload = []byte("NextcloudPerf")
+	}
+	if err := unix.Sendto(p.fd, payload, 0, p.sockaddr(port)); err != nil {
+		return nil, false, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		revents, err := wait(p.fd, unix.POLLIN, deadline)
+		if err != nil {
+			return nil, false, err
+		}
+		if revents&unix.POLLERR != 0 {
+			e, data, err := readErrQueue(p.fd, buf)
+			if err != nil {
+				continue
+			}
+			if p.method == MethodUDP && e.port == port || p.method == MethodICMPDgram && echoSeq(data, p.v6) == seq {
+				return e.offender, e.unreachable, nil
+			}
+			continue
+		}
+		n, from, err := unix.Recvfrom(p.fd, buf, unix.MSG_DONTWAIT)
+		if err != nil {
+			continue
+		}
+		addr := sockaddrIP(from)
+		if addr == nil {
+			continue
+		}
+		if p.method == MethodUDP {
+			return addr, true, nil // A service answered on the port
+		}
+		rm, err := parseICMP(buf[:n], p.v6)
+		if err != nil {
+			continue
+		}
+		if echo, ok := rm.Body.(*icmp.Echo); ok && isEchoReply(rm) && echo.Seq == seq {
+			return addr, true, nil
+		}
+	}
+}
+
+// probeTCP connects to the service port with the given TTL. A router on the
+// way answers the SYN with time exceeded, the target with SYN-ACK or RST.
+func (p *socketProber) probeTCP(ttl int, deadline time.Time) (net.IP, bool, error) {
+	fd, err := p.openSocket(unix.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	defer unix.Close(fd)
+	if err := p.setTTL(fd, ttl); err != nil {
+		return nil, false, err
+	}
+
+	err = unix.Connect(fd, p.sockaddr(p.port))
+	if err == nil {
+		return p.dest, true, nil
+	}
+	if err != unix.EINPROGRESS {
+		return nil, false, err
+	}
+	if _, err := wait(fd, unix.POLLOUT, deadline); err != nil {
+		return nil, false, err
+	}
+	if e, _, err := readErrQueue(fd, make([]byte, 64)); err == nil {
+		return e.offender, e.unreachable, nil
+	}
+	soErr, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ERROR)
+	if err != nil {
+		return nil, false, err
+	}
+	switch errno := unix.Errno(soErr); errno {
+	case 0, unix.ECONNREFUSED:
+		return p.dest, true, nil
+	default:
+		return nil, false, errno
+	}
+}
+
+func (p *socketProber) Close() error {
+	if p.fd < 0 {
+		return nil
+	}
+	return unix.Close(p.fd)
+}
+
+// wait polls fd for events until the deadline. Errors are always reported
+// as POLLERR in the returned events.
+func wait(fd int, events int16, deadline time.Time) (int16, error) {
+	for {
+		ms := int(time.Until(deadline).Milliseconds())
+		if ms <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		fds := []unix.PollFd{{Fd: int32(fd), Events: events}}
+		n, err := unix.Poll(fds, ms)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, os.NewSyscallError("poll", err)
+		}
+		if n > 0 {
+			return fds[0].Revents, nil
+		}
+	}
+}
+
+// icmpError is an ICMP or ICMPv6 error read from the error queue of a socket.
+type icmpError struct {
+	offender net.IP // Router or host that sent the error
+	port     int    // Destination port of the datagram that caused it
+
+	// Destination unreachable, which ends the trace and is the answer of the
+	// target to UDP probes, as opposed to the time exceeded of routers
+	unreachable bool
+
+	// Next-hop MTU of fragmentation needed (ICMPv6 packet too big) errors, or
+	// the MTU of the local route for packets the kernel refused to send
+	mtu int
+}
+
+// readErrQueue reads the next ICMP error from the error queue of fd into
+// buf and returns it with the quoted payload of the datagram that caused it.
+func readErrQueue(fd int, buf []byte) (icmpError, []byte, error) {
+	var e icmpError
+	oob := make([]byte, 512)
+	n, oobn, _, from, err := unix.Recvmsg(fd, buf, oob, unix.MSG_ERRQUEUE|unix.MSG_DONTWAIT)
+	if err != nil {
+		return e, nil, err
+	}
+	switch sa := from.(type) {
+	case *unix.SockaddrInet4:
+		e.port = sa.Port
+	case *unix.SockaddrInet6:
+		e.port = sa.Port
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return e, nil, err
+	}
+	for _, m := range msgs {
+		// struct sock_extended_err (16 bytes: errno, origin, type, code, pad,
+		// info), then the offender's sockaddr_in or sockaddr_in6
+		d := m.Data
+		if len(d) < 16 || !(m.Header.Level == unix.SOL_IP && m.Header.Type == unix.IP_RECVERR ||
+			m.Header.Level == unix.SOL_IPV6 && m.Header.Type == unix.IPV6_RECVERR) {
+			continue
+		}
+		info := int(binary.NativeEndian.Uint32(d[8:12]))
+		switch {
+		case d[4] == unix.SO_EE_ORIGIN_ICMP && len(d) >= 24:
+			e.offender = net.IPv4(d[20], d[21], d[22], d[23])
+			e.unreachable = d[5] == byte(ipv4.ICMPTypeDestinationUnreachable)
+			if e.unreachable && d[6] == 4 { // Fragmentation needed
+				e.mtu = info
+			}
+		case d[4] == unix.SO_EE_ORIGIN_ICMP6 && len(d) >= 40:
+			e.offender = net.IP(append([]byte(nil), d[24:40]...))
+			e.unreachable = d[5] == byte(ipv6.ICMPTypeDestinationUnreachable)
+			if d[5] == byte(ipv6.ICMPTypePacketTooBig) {
+				e.mtu = info
+			}
+		case d[4] == unix.SO_EE_ORIGIN_LOCAL && unix.Errno(binary.NativeEndian.Uint32(d[0:4])) == unix.EMSGSIZE:
+			e.mtu = info
+		default:
+			continue
+		}
+		return e, buf[:n], nil
+	}
+	return e, nil, fmt.Errorf("no ICMP error in error queue")
+}
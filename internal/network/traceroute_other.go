@@ -0,0 +1,23 @@
+//go:build !linux
+
+package network
+
+import "net"
+
+// newSocketProber opens the socket of an unprivileged traceroute method.
+// Outside of Linux only ICMP datagram sockets (macOS) are available, which
+// receive the ICMP errors like raw sockets.
+func newSocketProber(method string, dest net.IP, port int) (prober, error) {
+	if method != MethodICMPDgram {
+		return nil, errUnsupported
+	}
+	network := "udp4"
+	if dest.To4() == nil {
+		network = "udp6"
+	}
+	p, err := newICMPProber(network, dest)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,187 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RankByScore ranks batch targets by TransferScore.
+const RankByScore = "score"
+
+// BatchEntry is the outcome of one target of a batch run.
+type BatchEntry struct {
+	Rank       int      `json:"rank,omitempty"` // 1 is best; 0 if the target could not be ranked
+	Name       string   `json:"name"`
+	TargetURL  string   `json:"target_url"`
+	Profile    string   `json:"profile"`
+	ServerVer  string   `json:"server_ver"`
+	Score      *float64 `json:"score,omitempty"` // Value of the ranking metric
+	PingAvgMs  *float64 `json:"ping_avg_ms,omitempty"`
+	Passed     *bool    `json:"passed,omitempty"` // Threshold verdict, if thresholds were evaluated
+	Error      string   `json:"error,omitempty"`
+	ReportHTML string   `json:"report_html,omitempty"` // Per-target report, relative to the summary
+	ReportJSON string   `json:"report_json,omitempty"`
+}
+
+// BatchSummary ranks the targets of a batch run.
+type BatchSummary struct {
+	GeneratedAt    time.Time    `json:"generated_at"`
+	RankBy         string       `json:"rank_by"`
+	RankLabel      string       `json:"rank_label"`
+	RankUnit       string       `json:"rank_unit"`
+	HigherIsBetter bool         `json:"higher_is_better"`
+	Entries        []BatchEntry `json:"entries"`
+}
+
+// NewBatchEntry fills the entry fields derived from a finished report.
+func NewBatchEntry(name string, r ReportData) BatchEntry {
+	e := BatchEntry{Name: name, TargetURL: r.TargetURL, Profile: r.Profile, ServerVer: r.CloudCheck.Version, Error: r.Error}
+	if r.PingStats.SuccessCount > 0 {
+		avg := r.PingStats.AvgMs
+		e.PingAvgMs = &avg
+	}
+	if r.Verdict != nil {
+		passed := r.Verdict.Passed
+		e.Passed = &passed
+	}
+	return e
+}
+
+// TransferScore is the geometric mean of all WebDAV transfer speeds in MB/s.
+// The geometric mean keeps one very fast scenario from hiding a slow one.
+func TransferScore(r ReportData) (float64, bool) {
+	sum, n := 0.0, 0
+	for _, m := range Metrics(r) {
+		if strings.HasPrefix(m.Key, "scenario.") && strings.HasSuffix(m.Key, ".speed_mbps") && m.Value > 0 {
+			sum += math.Log(m.Value)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return math.Exp(sum / float64(n)), true
+}
+
+// RankBatch scores every entry by rankBy (RankByScore or a metric key of
+// Metrics) using reports[i] for entries[i], and sorts best first. Failed
+// runs and runs without the metric are listed last without a rank.
+func RankBatch(entries []BatchEntry, reports []ReportData, rankBy string) *BatchSummary {
+	s := &BatchSummary{GeneratedAt: time.Now(), RankBy: rankBy, RankLabel: "Transfer score", RankUnit: "MB/s", HigherIsBetter: true}
+	if rankBy != RankByScore {
+		s.RankLabel, s.RankUnit = rankBy, "" // Replaced by the metric's label once found
+	}
+
+	for i := range entries {
+		entries[i].Score, entries[i].Rank = nil, 0
+		r := reports[i]
+		if r.Error != "" {
+			continue
+		}
+		if rankBy == RankByScore {
+			if v, ok := TransferScore(r); ok {
+				entries[i].Score = &v
+			}
+			continue
+		}
+		for _, m := range Metrics(r) {
+			if m.Key == rankBy {
+				v := m.Value
+				entries[i].Score = &v
+				s.RankLabel, s.RankUnit, s.HigherIsBetter = m.Label, m.Unit, m.HigherIsBetter
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].Score, entries[j].Score
+		switch {
+		case a == nil || b == nil:
+			return a != nil && b == nil
+		case s.HigherIsBetter:
+			return *a > *b
+		default:
+			return *a < *b
+		}
+	})
+	for i := range entries {
+		if entries[i].Score != nil {
+			entries[i].Rank = i + 1
+		}
+	}
+	s.Entries = entries
+	return s
+}
+
+const batchTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Nextcloud Performance Batch Summary</title>
+    <style>{{.Style}}
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+    </style>
+</head>
+<body>
+    <div class="report-container">
+        <header>
+            <h1>Nextcloud Performance Batch Summary</h1>
+            <div class="meta">Generated: {{.Data.GeneratedAt.Format "2006-01-02 15:04:05"}} &ndash; {{len .Data.Entries}} targets, ranked by {{.Data.RankLabel}} ({{if .Data.HigherIsBetter}}higher{{else}}lower{{end}} is better)</div>
+        </header>
+        <div class="section">
+            <h2>Ranking</h2>
+            <table>
+                <tr><th>#</th><th>Target</th><th>Server</th><th>Profile</th><th>{{.Data.RankLabel}}</th><th>TCP Connect (avg)</th><th>Thresholds</th><th>Report</th></tr>
+                {{range .Data.Entries}}
+                <tr>
+                    <td>{{if .Rank}}{{.Rank}}{{else}}&ndash;{{end}}</td>
+                    <td><strong>{{.Name}}</strong><br><small>{{.TargetURL}}</small></td>
+                    <td>{{.ServerVer}}</td>
+                    <td>{{.Profile}}</td>
+                    <td class="num">{{if .Score}}{{printf "%.2f" (deref .Score)}} {{$.Data.RankUnit}}{{else if .Error}}<span style="color: #c0392b;">{{.Error}}</span>{{else}}--{{end}}</td>
+                    <td class="num">{{if .PingAvgMs}}{{printf "%.1f" (deref .PingAvgMs)}} ms{{else}}--{{end}}</td>
+                    <td>{{with .Passed}}{{verdictDot (derefBool .)}}{{else}}--{{end}}</td>
+                    <td>{{if .ReportHTML}}<a href="{{.ReportHTML}}">HTML</a>{{end}}{{if .ReportJSON}} <a href="{{.ReportJSON}}">JSON</a>{{end}}</td>
+                </tr>
+                {{end}}
+            </table>
+        </div>
+        <footer>
+            <small>Generated by Nextcloud Performance Tool (Open Source)</small>
+        </footer>
+    </div>
+</body>
+</html>
+`
+
+// GenerateBatchHTML renders the batch summary as a standalone HTML page.
+func GenerateBatchHTML(s *BatchSummary) ([]byte, error) {
+	funcMap := template.FuncMap{
+		"verdictDot": verdictDot,
+		"deref":      func(f *float64) float64 { return *f },
+		"derefBool":  func(b *bool) bool { return *b },
+	}
+	t, err := template.New("batch").Funcs(funcMap).Parse(batchTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = t.Execute(&buf, struct {
+		Style template.CSS
+		Data  *BatchSummary
+	}{
+		Style: template.CSS(cssStyle),
+		Data:  s,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,82 @@
+package report
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"nextcloud-perf/internal/network"
+)
+
+func TestRankBatch(t *testing.T) {
+	speeds := func(up, down float64, ping float64) ReportData {
+		return ReportData{
+			PingStats: network.DetailedPingStats{Count: 1, SuccessCount: 1, AvgMs: ping},
+			Scenarios: []ScenarioResult{{Name: "small", Upload: &SpeedResult{SpeedMBps: up}, Download: &SpeedResult{SpeedMBps: down}}},
+		}
+	}
+	reports := []ReportData{
+		speeds(10, 40, 30), // score 20
+		{Error: "Pre-flight Error"},
+		speeds(50, 50, 5),  // score 50
+		speeds(1, 100, 10), // score 10
+	}
+	names := []string{"a", "broken", "b", "c"}
+	entries := make([]BatchEntry, len(reports))
+	for i, r := range reports {
+		entries[i] = NewBatchEntry(names[i], r)
+	}
+
+	if score, _ := TransferScore(reports[0]); math.Abs(score-20) > 1e-9 {
+		t.Errorf("Expected geometric mean 20, got %f", score)
+	}
+
+	s := RankBatch(entries, reports, RankByScore)
+	var order []string
+	for _, e := range s.Entries {
+		order = append(order, e.Name)
+	}
+	if strings.Join(order, ",") != "b,a,c,broken" {
+		t.Errorf("Expected ranking b,a,c,broken, got %v", order)
+	}
+	if s.Entries[0].Rank != 1 || s.Entries[3].Rank != 0 {
+		t.Errorf("Expected failed runs to stay unranked, got %+v", s.Entries)
+	}
+
+	// Lower ping is better
+	entries = make([]BatchEntry, len(reports))
+	for i, r := range reports {
+		entries[i] = NewBatchEntry(names[i], r)
+	}
+	s = RankBatch(entries, reports, "network.ping_avg_ms")
+	if s.HigherIsBetter || s.Entries[0].Name != "b" || s.Entries[2].Name != "a" {
+		t.Errorf("Unexpected ping ranking: %+v", s.Entries)
+	}
+
+	// Every key a report yields can be ranked by, typos cannot
+	for _, r := range reports {
+		for _, m := range Metrics(r) {
+			if !IsMetricKey(m.Key) {
+				t.Errorf("Expected %s to be a known metric key", m.Key)
+			}
+		}
+	}
+	for _, key := range []string{"endpoint.status.ttfb_ms", "protocol.http2.small_files_mbps", "metadata.depth_1.p95_ms"} {
+		if !IsMetricKey(key) {
+			t.Errorf("Expected %s to be a known metric key", key)
+		}
+	}
+	for _, key := range []string{"network.ping_avg", "score", "scenario.*.upload.speed_mbps", "scenario.small.*.speed_mbps"} {
+		if IsMetricKey(key) {
+			t.Errorf("Expected %s to be rejected", key)
+		}
+	}
+
+	html, err := GenerateBatchHTML(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), "Pre-flight Error") {
+		t.Error("Expected error of failed target in summary")
+	}
+}
@@ -0,0 +1,266 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"nextcloud-perf/internal/benchmark"
+)
+
+// sampleSeries aggregates the interval samples of soak and load tests.
+type sampleSeries []benchmark.IntervalSample
+
+func (s sampleSeries) ops() int {
+	n := 0
+	for _, x := range s {
+		n += x.Ops
+	}
+	return n
+}
+
+func (s sampleSeries) failedOps() int {
+	n := 0
+	for _, x := range s {
+		n += x.Errors
+	}
+	return n
+}
+
+func (s sampleSeries) errorRate() float64 {
+	ops := s.ops()
+	if ops == 0 {
+		return 0
+	}
+	return float64(s.failedOps()) / float64(ops) * 100
+}
+
+// throughputMBps returns the mean combined throughput, weighted by interval length.
+func (s sampleSeries) throughputMBps() float64 {
+	var mb, secs float64
+	for _, x := range s {
+		mb += x.ThroughputMBps() * x.Length.Seconds()
+		secs += x.Length.Seconds()
+	}
+	if secs == 0 {
+		return 0
+	}
+	return mb / secs
+}
+
+func (s sampleSeries) worstP95() time.Duration {
+	var worst time.Duration
+	for _, x := range s {
+		worst = max(worst, x.P95)
+	}
+	return worst
+}
+
+// chartSeries is one line of a chart.
+type chartSeries struct {
+	Name   string
+	Color  string
+	Values []float64
+}
+
+// Chart geometry in SVG user units.
+const (
+	chartWidth  = 820
+	chartHeight = 180
+	chartLeft   = 70
+	chartRight  = 10
+	chartTop    = 10
+	chartBottom = 22
+)
+
+// SampleChart renders one time series of interval samples as an inline SVG
+// line chart: "throughput" (MB/s), "latency" (ms), "errors" (%) or "users"
+// (active virtual users).
+func SampleChart(samples []benchmark.IntervalSample, kind string) template.HTML {
+	if len(samples) == 0 {
+		return ""
+	}
+	var series []chartSeries
+	var unit string
+	pick := func(f func(i int) float64) []float64 {
+		v := make([]float64, len(samples))
+		for i := range samples {
+			v[i] = f(i)
+		}
+		return v
+	}
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	switch kind {
+	case "throughput":
+		unit = "MB/s"
+		series = []chartSeries{
+			{"Upload", "#27ae60", pick(func(i int) float64 { return samples[i].UploadMBps })},
+			{"Download", "#003d8f", pick(func(i int) float64 { return samples[i].DownloadMBps })},
+		}
+	case "latency":
+		unit = "ms"
+		series = []chartSeries{
+			{"p50", "#003d8f", pick(func(i int) float64 { return ms(samples[i].P50) })},
+			{"p95", "#d68910", pick(func(i int) float64 { return ms(samples[i].P95) })},
+		}
+	case "errors":
+		unit = "%"
+		series = []chartSeries{
+			{"Errors", "#c0392b", pick(func(i int) float64 { return samples[i].ErrorRate() })},
+		}
+	case "users":
+		unit = "users"
+		series = []chartSeries{
+			{"Users", "#7d3c98", pick(func(i int) float64 { return float64(samples[i].Users) })},
+		}
+	default:
+		return ""
+	}
+	ends := pick(func(i int) float64 { return (samples[i].Offset + samples[i].Length).Minutes() })
+	return lineChart(ends, series, unit)
+}
+
+// lineChart draws series over x values (minutes) with a y axis starting at 0.
+func lineChart(xs []float64, series []chartSeries, unit string) template.HTML {
+	maxX := xs[len(xs)-1]
+	maxY := 0.0
+	for _, s := range series {
+		for _, v := range s.Values {
+			maxY = math.Max(maxY, v)
+		}
+	}
+	maxY = niceCeil(maxY)
+
+	plotW := float64(chartWidth - chartLeft - chartRight)
+	plotH := float64(chartHeight - chartTop - chartBottom)
+	x := func(v float64) float64 {
+		if maxX <= 0 {
+			return chartLeft
+		}
+		return chartLeft + v/maxX*plotW
+	}
+	y := func(v float64) float64 { return chartTop + plotH - v/maxY*plotH }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" width="100%%" role="img" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+	for _, f := range []float64{0, 0.5, 1} {
+		gy := y(maxY * f)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e0e0e0"/>`, chartLeft, gy, chartWidth-chartRight, gy)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" font-size="11" fill="#666" text-anchor="end">%s</text>`, chartLeft-5, gy+4, template.HTMLEscapeString(formatAxis(maxY*f)+" "+unit))
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" fill="#666">0 min</text>`, chartLeft, chartHeight-5)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" fill="#666" text-anchor="end">%s min</text>`, chartWidth-chartRight, chartHeight-5, formatAxis(maxX))
+
+	for i, s := range series {
+		points := make([]string, len(s.Values))
+		for j, v := range s.Values {
+			points[j] = fmt.Sprintf("%.1f,%.1f", x(xs[j]), y(v))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, s.Color, strings.Join(points, " "))
+		if len(points) == 1 {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`, x(xs[0]), y(s.Values[0]), s.Color)
+		}
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" fill="%s">%s</text>`, chartLeft+10+i*90, chartTop+12, s.Color, template.HTMLEscapeString(s.Name))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// niceCeil rounds v up to 1, 2 or 5 times a power of ten, so axis labels stay readable.
+func niceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*exp {
+			return m * exp
+		}
+	}
+	return 10 * exp
+}
+
+func formatAxis(v float64) string {
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
+
+// phaseColors are the colors of the request phases in the waterfall chart.
+var phaseColors = map[string]string{
+	benchmark.RequestDNS:      "#16a085",
+	benchmark.RequestConnect:  "#d68910",
+	benchmark.RequestTLS:      "#7d3c98",
+	benchmark.RequestSend:     "#95a5a6",
+	benchmark.RequestTTFB:     "#27ae60",
+	benchmark.RequestTransfer: "#003d8f",
+}
+
+// Waterfall geometry in SVG user units.
+const (
+	waterfallLabel = 230 // Width of the endpoint labels
+	waterfallTotal = 60  // Room for the total behind the longest bar
+	waterfallRow   = 26
+	waterfallBar   = 16
+)
+
+// WaterfallChart renders the mean phases of each endpoint as an inline SVG
+// waterfall: one row per endpoint, each phase starting where the previous
+// one ended, all rows on the same time axis.
+func WaterfallChart(endpoints []benchmark.EndpointTiming) template.HTML {
+	if len(endpoints) == 0 {
+		return ""
+	}
+	maxMs := 0.0
+	for _, ep := range endpoints {
+		sum := 0.0
+		for _, p := range ep.Phases {
+			sum += durationMs(p.Mean)
+		}
+		maxMs = math.Max(maxMs, sum)
+	}
+	maxMs = niceCeil(maxMs)
+
+	plotW := float64(chartWidth - waterfallLabel - waterfallTotal - chartRight)
+	x := func(ms float64) float64 { return waterfallLabel + ms/maxMs*plotW }
+	top := chartTop + 20 // Below the legend
+	height := top + len(endpoints)*waterfallRow + chartBottom
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" width="100%%" role="img" xmlns="http://www.w3.org/2000/svg">`, chartWidth, height)
+	for i, name := range benchmark.RequestPhases {
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`, waterfallLabel+i*95, chartTop, phaseColors[name])
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="11" fill="#666">%s</text>`, waterfallLabel+i*95+14, chartTop+9, benchmark.RequestPhaseLabels[name])
+	}
+	for _, f := range []float64{0, 0.5, 1} {
+		gx := x(maxMs * f)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#e0e0e0"/>`, gx, top, gx, height-chartBottom)
+		anchor := "middle"
+		if f == 1 {
+			anchor = "end"
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" font-size="11" fill="#666" text-anchor="%s">%s ms</text>`, gx, height-5, anchor, formatAxis(maxMs*f))
+	}
+
+	for i, ep := range endpoints {
+		y := top + i*waterfallRow
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="12" fill="#333" text-anchor="end">%s</text>`, waterfallLabel-8, y+waterfallBar-3, template.HTMLEscapeString(ep.Method+" "+ep.Path))
+		if ep.Samples == 0 {
+			fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="12" fill="#c0392b">%s</text>`, waterfallLabel+4, y+waterfallBar-3, "failed")
+			continue
+		}
+		offset := 0.0
+		for _, p := range ep.Phases {
+			ms := durationMs(p.Mean)
+			if ms > 0 {
+				fmt.Fprintf(&b, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"><title>%s: %.1f ms</title></rect>`,
+					x(offset), y+2, math.Max(x(offset+ms)-x(offset), 1), waterfallBar, phaseColors[p.Name], benchmark.RequestPhaseLabels[p.Name], ms)
+			}
+			offset += ms
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" font-size="11" fill="#666">%.1f ms</text>`, x(offset)+4, y+waterfallBar-3, offset)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
@@ -0,0 +1,205 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+	"math"
+	"time"
+)
+
+// Comparison statuses of a MetricDelta
+const (
+	StatusImproved  = "improved"
+	StatusRegressed = "regressed"
+	StatusUnchanged = "unchanged"
+	StatusAdded     = "added"   // Only measured in the current run
+	StatusRemoved   = "removed" // Only measured in the base run
+)
+
+// Changes smaller than NoiseThreshold (relative) are reported as unchanged.
+// Regressions are colored yellow down to RegressionLimit of the base value and red below.
+const (
+	NoiseThreshold  = 0.05
+	RegressionLimit = 0.80
+)
+
+// MetricDelta compares one metric between two runs.
+type MetricDelta struct {
+	Key            string   `json:"key"`
+	Label          string   `json:"label"`
+	Unit           string   `json:"unit"`
+	HigherIsBetter bool     `json:"higher_is_better"`
+	Base           *float64 `json:"base,omitempty"`
+	Current        *float64 `json:"current,omitempty"`
+	Delta          float64  `json:"delta"`
+	Percent        float64  `json:"percent"` // Relative change in percent; 0 if Base is 0
+	Status         string   `json:"status"`
+	Color          string   `json:"color"`
+}
+
+// RunInfo identifies one side of a comparison.
+type RunInfo struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	TargetURL   string    `json:"target_url"`
+	ServerVer   string    `json:"server_ver"`
+	Profile     string    `json:"profile"`
+}
+
+// Comparison is the per-metric diff of two reports.
+type Comparison struct {
+	Base    RunInfo       `json:"base"`
+	Current RunInfo       `json:"current"`
+	Deltas  []MetricDelta `json:"deltas"`
+}
+
+// Regressions returns the deltas with status StatusRegressed.
+func (c *Comparison) Regressions() []MetricDelta {
+	var out []MetricDelta
+	for _, d := range c.Deltas {
+		if d.Status == StatusRegressed {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func runInfo(r ReportData) RunInfo {
+	return RunInfo{GeneratedAt: r.GeneratedAt, TargetURL: r.TargetURL, ServerVer: r.CloudCheck.Version, Profile: r.Profile}
+}
+
+// Compare diffs all metrics of current against base. Metrics keep the order
+// of the current run; metrics only present in base are appended.
+func Compare(base, current ReportData) *Comparison {
+	c := &Comparison{Base: runInfo(base), Current: runInfo(current)}
+
+	baseMetrics := map[string]Metric{}
+	for _, m := range Metrics(base) {
+		baseMetrics[m.Key] = m
+	}
+
+	seen := map[string]bool{}
+	for _, cur := range Metrics(current) {
+		seen[cur.Key] = true
+		if b, ok := baseMetrics[cur.Key]; ok {
+			c.Deltas = append(c.Deltas, compareMetric(b, cur))
+			continue
+		}
+		v := cur.Value
+		c.Deltas = append(c.Deltas, MetricDelta{Key: cur.Key, Label: cur.Label, Unit: cur.Unit, HigherIsBetter: cur.HigherIsBetter,
+			Current: &v, Status: StatusAdded, Color: ColorNone})
+	}
+	for _, b := range Metrics(base) {
+		if seen[b.Key] {
+			continue
+		}
+		v := b.Value
+		c.Deltas = append(c.Deltas, MetricDelta{Key: b.Key, Label: b.Label, Unit: b.Unit, HigherIsBetter: b.HigherIsBetter,
+			Base: &v, Status: StatusRemoved, Color: ColorNone})
+	}
+	return c
+}
+
+func compareMetric(base, cur Metric) MetricDelta {
+	b, v := base.Value, cur.Value
+	d := MetricDelta{Key: cur.Key, Label: cur.Label, Unit: cur.Unit, HigherIsBetter: cur.HigherIsBetter,
+		Base: &b, Current: &v, Delta: v - b}
+	if b != 0 {
+		d.Percent = (v - b) / math.Abs(b) * 100
+	}
+
+	// ratio > 1 means better than before, regardless of the metric direction
+	ratio := 1.0
+	switch {
+	case b == v:
+	case cur.HigherIsBetter && b > 0:
+		ratio = v / b
+	case !cur.HigherIsBetter && v > 0:
+		ratio = b / v
+	default:
+		ratio = math.Inf(1) // Improved from or to zero
+	}
+
+	switch {
+	case ratio > 1+NoiseThreshold:
+		d.Status = StatusImproved
+	case ratio < 1-NoiseThreshold:
+		d.Status = StatusRegressed
+	default:
+		d.Status = StatusUnchanged
+	}
+	d.Color = RatioColor(ratio, 1-NoiseThreshold, RegressionLimit)
+	return d
+}
+
+const comparisonTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Nextcloud Performance Comparison</title>
+    <style>{{.Style}}
+.delta-improved { color: #27ae60; font-weight: bold; }
+.delta-regressed { color: #c0392b; font-weight: bold; }
+.delta-unchanged, .delta-added, .delta-removed { color: var(--text-secondary); }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+    </style>
+</head>
+<body>
+    <div class="report-container">
+        <header>
+            <h1>Nextcloud Performance Comparison</h1>
+            <div class="meta">Base: {{.Data.Base.GeneratedAt.Format "2006-01-02 15:04:05"}} &ndash; {{.Data.Base.TargetURL}} {{.Data.Base.ServerVer}}{{if .Data.Base.Profile}} ({{.Data.Base.Profile}}){{end}}</div>
+            <div class="meta">Current: {{.Data.Current.GeneratedAt.Format "2006-01-02 15:04:05"}} &ndash; {{.Data.Current.TargetURL}} {{.Data.Current.ServerVer}}{{if .Data.Current.Profile}} ({{.Data.Current.Profile}}){{end}}</div>
+        </header>
+        {{template "deltas" .Data}}
+        <footer>
+            <small>Generated by Nextcloud Performance Tool (Open Source)</small>
+        </footer>
+    </div>
+</body>
+</html>
+{{define "deltas"}}
+        <div class="section">
+            <h2>Comparison</h2>
+            {{with .Regressions}}<div class="error-box"><strong>{{len .}} regression(s)</strong></div>{{end}}
+            <table>
+                <tr><th>Metric</th><th>Base</th><th>Current</th><th>Delta</th><th>Change</th><th></th></tr>
+                {{range .Deltas}}
+                <tr>
+                    <td>{{.Label}}</td>
+                    <td class="num">{{if .Base}}{{printf "%.2f" (deref .Base)}} {{.Unit}}{{else}}--{{end}}</td>
+                    <td class="num">{{if .Current}}{{printf "%.2f" (deref .Current)}} {{.Unit}}{{else}}--{{end}}</td>
+                    <td class="num">{{if and .Base .Current}}{{printf "%+.2f" .Delta}}{{end}}</td>
+                    <td class="num delta-{{.Status}}">{{if and .Base .Current (ne (deref .Base) 0.0)}}{{printf "%+.1f%%" .Percent}}{{else}}{{.Status}}{{end}}</td>
+                    <td>{{dot .Color}}</td>
+                </tr>
+                {{end}}
+            </table>
+        </div>
+{{end}}
+`
+
+// GenerateComparisonHTML renders a standalone HTML page for the comparison.
+func GenerateComparisonHTML(c *Comparison) ([]byte, error) {
+	funcMap := template.FuncMap{
+		"dot":   qualityDot,
+		"deref": func(f *float64) float64 { return *f },
+	}
+	t, err := template.New("comparison").Funcs(funcMap).Parse(comparisonTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = t.Execute(&buf, struct {
+		Style template.CSS
+		Data  *Comparison
+	}{
+		Style: template.CSS(cssStyle),
+		Data:  c,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
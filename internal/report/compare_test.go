@@ -0,0 +1,70 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"nextcloud-perf/internal/network"
+)
+
+func TestCompare(t *testing.T) {
+	base := ReportData{
+		GeneratedAt: time.Now().Add(-time.Hour),
+		PingStats:   network.DetailedPingStats{Count: 10, SuccessCount: 10, AvgMs: 20},
+		Scenarios: []ScenarioResult{
+			{Name: "small", Label: "Small Files", Upload: &SpeedResult{SpeedMBps: 10}, Download: &SpeedResult{SpeedMBps: 50}},
+			{Name: "large", Label: "Large File", Upload: &SpeedResult{SpeedMBps: 100}},
+		},
+	}
+	current := ReportData{
+		GeneratedAt: time.Now(),
+		PingStats:   network.DetailedPingStats{Count: 10, SuccessCount: 10, AvgMs: 10},
+		Scenarios: []ScenarioResult{
+			{Name: "small", Label: "Small Files", Upload: &SpeedResult{SpeedMBps: 5}, Download: &SpeedResult{SpeedMBps: 51}},
+			{Name: "medium", Label: "Medium Files", Upload: &SpeedResult{SpeedMBps: 30}},
+		},
+	}
+
+	cmp := Compare(base, current)
+	byKey := map[string]MetricDelta{}
+	for _, d := range cmp.Deltas {
+		byKey[d.Key] = d
+	}
+
+	tests := []struct {
+		key    string
+		status string
+		color  string
+	}{
+		{"network.ping_avg_ms", StatusImproved, ColorGood},
+		{"scenario.small.upload.speed_mbps", StatusRegressed, ColorBad},
+		{"scenario.small.download.speed_mbps", StatusUnchanged, ColorGood},
+		{"scenario.medium.upload.speed_mbps", StatusAdded, ColorNone},
+		{"scenario.large.upload.speed_mbps", StatusRemoved, ColorNone},
+	}
+	for _, tt := range tests {
+		d, ok := byKey[tt.key]
+		if !ok {
+			t.Errorf("Missing metric %s", tt.key)
+			continue
+		}
+		if d.Status != tt.status || d.Color != tt.color {
+			t.Errorf("%s: expected %s/%s, got %s/%s", tt.key, tt.status, tt.color, d.Status, d.Color)
+		}
+	}
+	if d := byKey["scenario.small.upload.speed_mbps"]; d.Percent != -50 || d.Delta != -5 {
+		t.Errorf("Expected -50%% / -5, got %.1f%% / %.1f", d.Percent, d.Delta)
+	}
+	if len(cmp.Regressions()) != 1 {
+		t.Errorf("Expected 1 regression, got %d", len(cmp.Regressions()))
+	}
+
+	html, err := GenerateComparisonHTML(cmp)
+	if err != nil {
+		t.Fatalf("GenerateComparisonHTML failed: %v", err)
+	}
+	if !strings.Contains(string(html), "-50.0%") || !strings.Contains(string(html), "1 regression(s)") {
+		t.Error("Expected comparison HTML to contain the regression")
+	}
+}
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Event types written to the NDJSON event stream.
+const (
+	EventLog    = "log"
+	EventResult = "result"
+)
+
+// Event is a single line of the NDJSON event stream.
+// Log events carry Message, result events carry Data.
+type Event struct {
+	SchemaVersion int         `json:"schema_version"`
+	Seq           int         `json:"seq"`
+	Time          time.Time   `json:"time"`
+	Type          string      `json:"type"`
+	Message       string      `json:"message,omitempty"`
+	Data          *ReportData `json:"data,omitempty"`
+}
+
+// EventWriter writes every Broadcast/SendResult step as one JSON object per line.
+// It is safe for concurrent use.
+type EventWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	seq int
+}
+
+// NewEventWriter creates an EventWriter writing to w.
+func NewEventWriter(w io.Writer) *EventWriter {
+	return &EventWriter{w: w}
+}
+
+// WriteLog records a progress message.
+func (e *EventWriter) WriteLog(msg string) error {
+	return e.write(Event{Type: EventLog, Message: msg})
+}
+
+// WriteResult records an intermediate or final result snapshot.
+func (e *EventWriter) WriteResult(data ReportData) error {
+	return e.write(Event{Type: EventResult, Data: &data})
+}
+
+func (e *EventWriter) write(ev Event) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.seq++
+	ev.SchemaVersion = SchemaVersion
+	ev.Seq = e.seq
+	ev.Time = time.Now()
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = e.w.Write(b)
+	return err
+}
@@ -6,6 +6,8 @@ import (
 	"html/template"
 	"time"
 
+	"nextcloud-perf/internal/benchmark"
+	"nextcloud-perf/internal/config"
 	"nextcloud-perf/internal/network"
 )
 
@@ -56,30 +58,174 @@ type ReportData struct {
 	RAM         RAMInfo   `json:"ram"`
 	Completed   bool      `json:"completed"`
 
-	LocalNetwork network.LocalNetworkInfo  `json:"local_network"`
-	PingStats    network.DetailedPingStats `json:"ping_stats"`
-	DNS          network.DNSResult         `json:"dns"`
-	Traceroute   []string                  `json:"traceroute"`
+	LocalNetwork    network.LocalNetworkInfo   `json:"local_network"`
+	PingStats       network.DetailedPingStats  `json:"ping_stats"`
+	ICMPPing        *network.DetailedPingStats `json:"icmp_ping,omitempty"`
+	DNS             network.DNSResult          `json:"dns"`
+	Traceroute      *network.TracerouteResult  `json:"traceroute,omitempty"`
+	TracerouteError string                     `json:"traceroute_error,omitempty"` // Why no method worked
+	DualStack       *network.DualStackResult   `json:"dual_stack,omitempty"`
+	PathMTU         *network.PathMTUResult     `json:"path_mtu,omitempty"`
+
+	// Request phases of status.php, an OCS call and a WebDAV PROPFIND
+	Endpoints []benchmark.EndpointTiming `json:"endpoints,omitempty"`
 
 	AdvancedNet  AdvancedNetworkInfo `json:"advanced_net"`
 	DiskIO       DiskResult          `json:"disk_io"`
 	CloudCheck   CloudStatus         `json:"cloud_check"`
 	PeakCPUUsage float64             `json:"peak_cpu_usage"`
 
-	SmallFiles      SpeedResult              `json:"small_files"`
-	SmallFilesDown  SpeedResult              `json:"small_files_down"`
-	MediumFiles     SpeedResult              `json:"medium_files"`
-	MediumFilesDown SpeedResult              `json:"medium_files_down"`
-	LargeFile       SpeedResult              `json:"large_file"`
-	LargeFileDown   SpeedResult              `json:"large_file_down"`
-	Speedtest       *network.SpeedtestResult `json:"speedtest,omitempty"`
-	Error           string                   `json:"error,omitempty"`
+	Profile   string                   `json:"profile"`
+	Protocol  string                   `json:"protocol,omitempty"` // HTTP protocol of the run if not HTTP/1.1
+	Scenarios []ScenarioResult         `json:"scenarios"`
+	Metadata  *MetadataResult          `json:"metadata,omitempty"`
+	Sync      *SyncResult              `json:"sync,omitempty"`
+	Protocols *ProtocolResult          `json:"protocols,omitempty"`
+	Soak      *SoakResult              `json:"soak,omitempty"`
+	Load      *LoadResult              `json:"load,omitempty"`
+	Speedtest *network.SpeedtestResult `json:"speedtest,omitempty"`
+	Error     string                   `json:"error,omitempty"`
+	Verdict   *Verdict                 `json:"verdict,omitempty"` // Set when thresholds were evaluated
+}
+
+// ScenarioResult holds the measured speeds of one profile scenario.
+// Upload or Download is nil if the scenario does not measure that direction.
+type ScenarioResult struct {
+	Name     string       `json:"name"`
+	Label    string       `json:"label"`
+	Count    int          `json:"count"`
+	Size     int64        `json:"size"`
+	Parallel int          `json:"parallel"`
+	Chunked  bool         `json:"chunked"`
+	Upload   *SpeedResult `json:"upload,omitempty"`
+	Download *SpeedResult `json:"download,omitempty"`
+
+	ChunkSize     int64        `json:"chunk_size,omitempty"`
+	ChunkParallel int          `json:"chunk_parallel,omitempty"`
+	Sequential    *SpeedResult `json:"sequential,omitempty"` // Sequential chunked upload for comparison
+}
+
+// ParallelGain returns how much faster the (parallel) upload was than the
+// sequential comparison upload, in percent. Returns 0 without comparison.
+func (s ScenarioResult) ParallelGain() float64 {
+	if s.Upload == nil || s.Sequential == nil || s.Sequential.SpeedMBps <= 0 {
+		return 0
+	}
+	return (s.Upload.SpeedMBps/s.Sequential.SpeedMBps - 1) * 100
+}
+
+// MetadataResult holds the PROPFIND listing benchmark of a folder tree.
+type MetadataResult struct {
+	Dirs        int             `json:"dirs"`
+	FilesPerDir int             `json:"files_per_dir"`
+	Setup       time.Duration   `json:"setup"`
+	Listings    []ListingResult `json:"listings"`
+	Errors      []string        `json:"errors"`
+}
+
+// ListingResult is the listing latency of the tree root at one PROPFIND depth.
+type ListingResult struct {
+	Depth   string                  `json:"depth"`
+	Entries int                     `json:"entries"`
+	Latency *benchmark.LatencyStats `json:"latency,omitempty"`
+	Errors  []string                `json:"errors"`
+}
+
+// SyncResult holds the replay of desktop client sync cycles on a folder tree.
+type SyncResult struct {
+	Dirs        int                     `json:"dirs"`
+	FilesPerDir int                     `json:"files_per_dir"`
+	FileSize    int64                   `json:"file_size"`
+	Changes     int                     `json:"changes"` // Per cycle, on the server and locally each
+	Renames     int                     `json:"renames"`
+	Deletes     int                     `json:"deletes"`
+	Setup       time.Duration           `json:"setup"`
+	Initial     benchmark.SyncCycle     `json:"initial"`
+	Idle        time.Duration           `json:"idle"` // Change check without changes
+	Cycles      []benchmark.SyncCycle   `json:"cycles"`
+	Latency     *benchmark.LatencyStats `json:"latency,omitempty"`
+	Errors      []string                `json:"errors"`
+}
+
+// ProtocolResult compares the same workload over several HTTP protocol
+// versions against the same server.
+type ProtocolResult struct {
+	Files     int                 `json:"files"`
+	FileSize  int64               `json:"file_size"`
+	Parallel  int                 `json:"parallel"`
+	LargeSize int64               `json:"large_size"`
+	Runs      []ProtocolRunResult `json:"runs"`
+}
+
+// ProtocolRunResult holds the speeds of the workload over one protocol.
+// Error is set if the protocol was not available.
+type ProtocolRunResult struct {
+	Protocol      string       `json:"protocol"`             // http/1.1, h2 or h3
+	Negotiated    string       `json:"negotiated,omitempty"` // Version the server answered with
+	Upload        *SpeedResult `json:"upload,omitempty"`     // Small files, in parallel
+	Download      *SpeedResult `json:"download,omitempty"`
+	LargeUpload   *SpeedResult `json:"large_upload,omitempty"`
+	LargeDownload *SpeedResult `json:"large_download,omitempty"`
+	Error         string       `json:"error,omitempty"`
+}
+
+// SoakResult holds the time series of a sustained mixed load test.
+type SoakResult struct {
+	Duration time.Duration              `json:"duration"` // Configured run time
+	Interval time.Duration              `json:"interval"`
+	FileSize int64                      `json:"file_size"`
+	Parallel int                        `json:"parallel"`
+	Mix      string                     `json:"mix"` // Weights as upload:download:list, e.g. "1:2:1"
+	Samples  []benchmark.IntervalSample `json:"samples"`
+	Errors   []string                   `json:"errors"`
+}
+
+// LoadResult holds the time series and per-user latencies of a multi-user load test.
+type LoadResult struct {
+	Users       int                        `json:"users"`       // Virtual users at full load
+	Accounts    int                        `json:"accounts"`    // Distinct accounts used by the virtual users
+	Provisioned bool                       `json:"provisioned"` // Accounts were created for the run and deleted afterwards
+	RampUp      time.Duration              `json:"ramp_up"`
+	Duration    time.Duration              `json:"duration"`
+	Interval    time.Duration              `json:"interval"`
+	ThinkTime   time.Duration              `json:"think_time"`
+	FileSize    int64                      `json:"file_size"`
+	Samples     []benchmark.IntervalSample `json:"samples"`
+	PerUser     []benchmark.UserResult     `json:"per_user"`
+	Errors      []string                   `json:"errors"`
+}
+
+// IsLarge reports whether the scenario is rated with the large-file thresholds.
+func (s ScenarioResult) IsLarge() bool {
+	return s.Chunked || s.Size >= 64*1024*1024
+}
+
+// Scenario returns the result with the given name, or nil.
+func (r *ReportData) Scenario(name string) *ScenarioResult {
+	for i := range r.Scenarios {
+		if r.Scenarios[i].Name == name {
+			return &r.Scenarios[i]
+		}
+	}
+	return nil
 }
 
 type SpeedResult struct {
 	SpeedMBps float64       `json:"speed_mbps"`
 	Duration  time.Duration `json:"duration"`
 	Errors    []string      `json:"errors"`
+
+	Latency *benchmark.LatencyStats `json:"latency,omitempty"` // Per-request latency distribution
+	Retries int                     `json:"retries,omitempty"` // Requests repeated after transient failures
+	Resumes int                     `json:"resumes,omitempty"` // Chunked uploads resumed after a failure
+
+	Checksum  string `json:"checksum,omitempty"`  // Checksum type downloads were verified with, empty if only the size was checked
+	Corrupted int    `json:"corrupted,omitempty"` // Downloads whose size or checksum differed from the upload
+}
+
+// FormatMs formats a duration as milliseconds for the report.
+func FormatMs(d time.Duration) string {
+	return fmt.Sprintf("%.1f ms", float64(d.Microseconds())/1000)
 }
 
 // GetPingQualityDot returns an HTML span with a colored dot indicating ping quality.
@@ -104,35 +250,68 @@ func GetLossQualityDot(p network.DetailedPingStats) template.HTML {
 	return template.HTML(fmt.Sprintf(`<span style="display:inline-block;width:10px;height:10px;border-radius:50%%;background-color:%s;margin-left:5px;vertical-align:middle;box-shadow:0 0 5px %s;"></span>`, color, color))
 }
 
+// Quality colors shared by the report, the comparison view and the UI.
+const (
+	ColorGood = "#2ecc71" // Green
+	ColorWarn = "#f1c40f" // Yellow
+	ColorBad  = "#e74c3c" // Red
+	ColorNone = "#bdc3c7" // Gray, not measured
+)
+
+// RatioColor maps a ratio of achieved to expected performance to a quality
+// color: above green is good, above yellow is acceptable, everything else is bad.
+func RatioColor(ratio, green, yellow float64) string {
+	if ratio > green {
+		return ColorGood
+	}
+	if ratio > yellow {
+		return ColorWarn
+	}
+	return ColorBad
+}
+
 func (s SpeedResult) GetQualityColor(limitMBps float64, isLarge bool) string {
 	if s.SpeedMBps <= 0 || limitMBps <= 0 {
-		return "#bdc3c7" // Gray
+		return ColorNone
 	}
 	ratio := s.SpeedMBps / limitMBps
 	if isLarge {
-		if ratio > 0.70 {
-			return "#2ecc71"
-		} // Green
-		if ratio > 0.40 {
-			return "#f1c40f"
-		} // Yellow
-	} else {
-		if ratio > 0.15 {
-			return "#2ecc71"
-		} // Green
-		if ratio > 0.07 {
-			return "#f1c40f"
-		} // Yellow
+		return RatioColor(ratio, 0.70, 0.40)
 	}
-	return "#e74c3c" // Red
+	return RatioColor(ratio, 0.15, 0.07)
 }
 
 func (s SpeedResult) GetQualityDot(limitMBps float64, isLarge bool) template.HTML {
-	color := s.GetQualityColor(limitMBps, isLarge)
+	return qualityDot(s.GetQualityColor(limitMBps, isLarge))
+}
+
+// verdictDot renders a green or red dot for a threshold rule result.
+func verdictDot(passed bool) template.HTML {
+	if passed {
+		return qualityDot(ColorGood)
+	}
+	return qualityDot(ColorBad)
+}
+
+// qualityDot renders a colored status dot.
+func qualityDot(color string) template.HTML {
 	return template.HTML(fmt.Sprintf(`<span style="display:inline-block;width:10px;height:10px;border-radius:50%%;background-color:%s;margin-left:5px;vertical-align:middle;box-shadow:0 0 5px %s;"></span>`, color, color))
 }
 
-func GetCombinedConclusion(up, down SpeedResult, limitUp, limitDown float64, isLarge bool) template.HTML {
+// GetCombinedConclusion rates a scenario by its worst direction.
+// Directions that were not measured (nil) are ignored.
+func GetCombinedConclusion(up, down *SpeedResult, limitUp, limitDown float64, isLarge bool) template.HTML {
+	if up == nil && down == nil {
+		return ""
+	}
+	if up == nil {
+		up = down
+		limitUp = limitDown
+	}
+	if down == nil {
+		down = up
+		limitDown = limitUp
+	}
 	qUp := up.GetQualityColor(limitUp, isLarge)
 	qDown := down.GetQualityColor(limitDown, isLarge)
 
@@ -141,15 +320,15 @@ func GetCombinedConclusion(up, down SpeedResult, limitUp, limitDown float64, isL
 	text := "Excellent connection"
 	class := "text-green"
 
-	if qUp == "#e74c3c" || qDown == "#e74c3c" {
+	if qUp == ColorBad || qDown == ColorBad {
 		key = "conc_optimize"
 		text = "Needs optimization"
 		class = "text-red"
-	} else if qUp == "#f1c40f" || qDown == "#f1c40f" {
+	} else if qUp == ColorWarn || qDown == ColorWarn {
 		key = "conc_solid"
 		text = "Solid performance"
 		class = "text-yellow"
-	} else if qUp == "#bdc3c7" || qDown == "#bdc3c7" {
+	} else if qUp == ColorNone || qDown == ColorNone {
 		return ""
 	}
 
@@ -232,6 +411,7 @@ th { background-color: #f2f2f2; }
     font-weight: bold;
 }
 .report-container { position: relative; }
+.chart { display: block; margin: 5px 0 15px; }
 `
 
 const htmlTemplate = `
@@ -258,6 +438,26 @@ const htmlTemplate = `
             </div>
         </header>
 
+        {{with .Data.Verdict}}
+        <div class="section">
+            <h2><span data-i18n="section_verdict">Threshold Check</span>{{if .Thresholds}} &ndash; {{.Thresholds}}{{end}}</h2>
+            {{if .Passed}}<div style="background: #eafaf1; border-left: 4px solid #27ae60; padding: 15px; color: #1e8449;"><strong data-i18n="verdict_passed">All thresholds passed</strong></div>
+            {{else}}<div class="error-box"><strong><span data-i18n="verdict_failed">Thresholds violated:</span> {{len .Failed}} / {{len .Results}}</strong></div>{{end}}
+            <table>
+                <tr><th data-i18n="th_rule">Rule</th><th>Metric</th><th data-i18n="th_value">Value</th><th data-i18n="th_limit">Limit</th><th data-i18n="th_result">Result</th></tr>
+                {{range .Results}}
+                <tr>
+                    <td>{{.Rule}}</td>
+                    <td>{{.Label}}</td>
+                    <td>{{if .Value}}{{printf "%.2f" (deref .Value)}} {{.Unit}}{{else}}--{{end}}</td>
+                    <td>{{if .Min}}&ge; {{printf "%.2f" (deref .Min)}} {{end}}{{if .Max}}&le; {{printf "%.2f" (deref .Max)}}{{end}}</td>
+                    <td>{{verdictDot .Passed}} {{.Message}}</td>
+                </tr>
+                {{end}}
+            </table>
+        </div>
+        {{end}}
+
         <div class="section">
             <h2 data-i18n="section_system_info">System Information</h2>
             <div class="grid">
@@ -280,7 +480,7 @@ const htmlTemplate = `
                     <div class="metric-label"><span data-i18n="label_primary_if">Primary Interface:</span> {{.Data.LocalNetwork.PrimaryIF}}</div>
                     {{range .Data.LocalNetwork.Interfaces}}
                     <div style="font-size: 0.85em; margin-top: 5px;">
-                        <strong>{{.Name}}</strong> ({{.Type}}): {{.IPAddress}}
+                        <strong>{{.Name}}</strong> ({{.Type}}): {{.IPAddress}}{{if and .IPv6 (ne .IPv6 .IPAddress)}}, {{.IPv6}}{{end}}
                         {{if and .LinkSpeed (ne .LinkSpeed "Unknown")}}
                         <br><span style="color: #27ae60; font-weight: bold; font-size: 0.9em;">Speed: {{.LinkSpeed}}</span>
                         {{end}}
@@ -299,6 +499,7 @@ const htmlTemplate = `
 
 
 
+        {{if not .Data.Load}}
         <div class="section">
             <h2 data-i18n="section_network_diagnostics">Network Diagnostics</h2>
             <div class="grid">
@@ -315,6 +516,14 @@ const htmlTemplate = `
                     <div class="metric-label"><span data-i18n="label_min">Min:</span> {{printf "%.2f" .Data.PingStats.MinMs}} | <span data-i18n="label_max">Max:</span> {{printf "%.2f" .Data.PingStats.MaxMs}}</div>
                     <div class="metric-label"><span data-i18n="label_packet_loss">Loss:</span> {{printf "%.1f%%" .Data.PingStats.PacketLoss}} {{getLossQualityDot .Data.PingStats}}</div>
                 </div>
+                {{with .Data.ICMPPing}}
+                <div class="card">
+                    <div class="metric-label"><span data-i18n="label_icmp_ping">ICMP Ping</span> ({{.Count}} packets, {{.Method}})</div>
+                    <div class="metric-value"><span data-i18n="label_avg">Avg:</span> {{printf "%.2f ms" .AvgMs}} {{getPingQualityDot .}}</div>
+                    <div class="metric-label"><span data-i18n="label_min">Min:</span> {{printf "%.2f" .MinMs}} | <span data-i18n="label_max">Max:</span> {{printf "%.2f" .MaxMs}}</div>
+                    <div class="metric-label"><span data-i18n="label_packet_loss">Loss:</span> {{printf "%.1f%%" .PacketLoss}} {{getLossQualityDot .}}</div>
+                </div>
+                {{end}}
                 <div class="card">
                     <div class="metric-label">Advanced Stats</div>
                     <div class="metric-label">SSL Handshake: <span class="metric-value" style="font-size: 1em;">{{printf "%.1f ms" .Data.AdvancedNet.TLSHandshakeMs}}</span></div>
@@ -344,15 +553,97 @@ const htmlTemplate = `
                 </details>
             </div>
             
-            {{if .Data.Traceroute}}
+            {{with .Data.Traceroute}}
+            <h3>Traceroute <span class="metric-label">(<span data-i18n="label_traceroute_method">Method:</span> {{.Method}}{{if gt .Cycles 1}} · {{.Cycles}} <span data-i18n="label_probes_per_hop">probes per hop</span>{{end}})</span></h3>
+            {{template "hops" .}}
+            {{else}}{{if .Data.TracerouteError}}
             <h3>Traceroute</h3>
-            <div class="code-box">
-                {{range .Data.Traceroute}}
-                <div>{{.}}</div>
-                {{end}}
+            <div class="error-box">{{.Data.TracerouteError}}</div>
+            {{end}}{{end}}
+
+            {{with .Data.DualStack}}
+            <h3 data-i18n="section_dual_stack">IPv4 / IPv6</h3>
+            <table>
+                <thead><tr><th></th>{{range .Families}}<th>{{.Name}}</th>{{end}}</tr></thead>
+                <tbody>
+                    <tr>
+                        <td data-i18n="label_dns">DNS Resolution</td>
+                        {{range .Families}}
+                        <td>{{if .DNS.Error}}<span class="fail-dot">{{.DNS.Error}}</span>{{else}}{{printf "%.2f ms" .DNS.ResolutionTime}}{{range .DNS.ResolvedIPs}}<br>{{.}}{{end}}{{end}}</td>
+                        {{end}}
+                    </tr>
+                    <tr>
+                        <td data-i18n="label_tcp_connect">TCP Connect</td>
+                        {{range .Families}}
+                        <td>{{with .Ping}}{{if .SuccessCount}}{{printf "%.2f ms" .AvgMs}} {{getPingQualityDot .}}<br>{{end}}<span data-i18n="label_packet_loss">Loss:</span> {{printf "%.1f%%" .PacketLoss}} {{getLossQualityDot .}}{{else}}-{{end}}</td>
+                        {{end}}
+                    </tr>
+                    <tr>
+                        <td>Traceroute</td>
+                        {{range .Families}}
+                        <td>{{with .Traceroute}}<span class="metric-label"><span data-i18n="label_traceroute_method">Method:</span> {{.Method}}</span>
+                            <details><summary style="cursor:pointer;">{{len .Hops}} <span data-i18n="label_hops">hops</span></summary>{{template "hops" .}}</details>
+                            {{else}}{{if .TracerouteError}}<span class="fail-dot">{{.TracerouteError}}</span>{{else}}-{{end}}{{end}}</td>
+                        {{end}}
+                    </tr>
+                </tbody>
+            </table>
+            {{with .HappyEyeballs}}
+            <div class="metric-label" style="margin-top:10px;">
+                <span data-i18n="label_happy_eyeballs">Dual-stack connect (Happy Eyeballs):</span> {{printf "%.2f ms" .AvgMs}} (max {{printf "%.2f ms" .MaxMs}}) · IPv6 {{.IPv6Count}} / IPv4 {{.IPv4Count}} / {{.Count}}
             </div>
             {{end}}
+            {{with .FallbackPenaltyMs}}
+            <div class="metric-label"><span data-i18n="label_fallback_delay">Fallback delay:</span> <span class="{{if ge (deref .) 100.0}}text-red{{else if ge (deref .) 25.0}}text-yellow{{else}}text-green{{end}}">{{printf "%.0f ms" (deref .)}}</span></div>
+            {{if ge (deref .) 100.0}}<div class="error-box" data-i18n="hint_ipv6_fallback">One address family is broken or much slower: connections wait for the fallback to the other one.</div>{{end}}
+            {{end}}
+            {{end}}
+
+            {{with .Data.PathMTU}}
+            <h3>Path MTU</h3>
+            {{if .PathMTU}}
+            <div class="metric-label"><span data-i18n="label_path_mtu">Largest packet to the server:</span> <span class="{{if .BlackHole}}text-red{{else if lt .PathMTU .LocalMTU}}text-yellow{{else}}text-green{{end}}">{{.PathMTU}} B</span>
+                (<span data-i18n="label_interface_mtu">Interface MTU:</span> {{.LocalMTU}} B · <span data-i18n="label_traceroute_method">Method:</span> {{.Method}})</div>
+            {{if .ReportedMTU}}<div class="metric-label"><span data-i18n="label_reported_mtu">Reported too small (ICMP):</span> {{.ReportedMTU}} B</div>{{end}}
+            {{else}}
+            <div class="error-box">{{.Error}}</div>
+            {{end}}
+            {{if .TCPMSS}}<div class="metric-label">TCP MSS: {{.TCPMSS}} B ({{.TCPMTU}} B <span data-i18n="label_tcp_packets">packets</span>)</div>{{end}}
+            {{if .BlackHole}}{{if .TCPAffected}}
+            <div class="error-box" data-i18n="hint_mtu_black_hole">MTU black hole: larger packets are dropped without ICMP "fragmentation needed", so TCP cannot adapt and transfers stall. Lower the MTU or clamp the TCP MSS on the VPN or PPPoE link, or let ICMP through.</div>
+            {{else}}
+            <div class="metric-label text-yellow" data-i18n="hint_mtu_black_hole_clamped">Larger packets are dropped without ICMP error, but the TCP MSS keeps connections to the server below the limit. HTTP/3 over UDP may still be affected.</div>
+            {{end}}{{end}}
+            {{end}}
         </div>
+        {{end}}
+
+        {{with .Data.Endpoints}}
+        <div class="section">
+            <h2 data-i18n="section_endpoints">Request Phases</h2>
+            <div class="meta" data-i18n="label_endpoints_meta">Every request on a new connection. Chart: average per phase, table: median per phase.</div>
+            <div class="card">
+                {{waterfallChart .}}
+                <table>
+                    <tr><th>Endpoint</th><th data-i18n="th_requests">Requests</th><th>DNS</th><th>TCP</th><th>TLS</th><th>Send</th><th>TTFB</th><th>Transfer</th><th>Total</th><th data-i18n="th_bottleneck">Bottleneck</th></tr>
+                    {{range .}}
+                    <tr>
+                        <td>{{.Method}} {{.Path}}</td>
+                        {{if .Samples}}
+                        <td>{{.Samples}}{{if .Failed}} <span class="text-red">(+{{.Failed}} <span data-i18n="label_failed_requests">failed</span>)</span>{{end}}</td>
+                        {{range .Phases}}<td>{{ms .Median}}</td>{{end}}
+                        <td>{{ms .Total.Median}}<div style="font-size: 0.8em; color: #666;">{{ms .Total.Min}} &ndash; {{ms .Total.Max}}</div></td>
+                        <td>{{if .ServerBound}}<span class="health-tag tag-red" data-i18n="tag_server">Server</span>{{else}}<span class="health-tag tag-blue" data-i18n="tag_network">Network</span>{{end}}</td>
+                        {{else}}
+                        <td colspan="9" class="text-red">{{.Error}}</td>
+                        {{end}}
+                    </tr>
+                    {{end}}
+                </table>
+                <div class="metric-label" style="margin-top: 10px;" data-i18n="hint_endpoints">TTFB is the time the server takes to process the request plus one round trip, which is about the TCP connect time. If it dominates, PHP, the database or caching on the server are slow; if DNS, TCP and TLS dominate, it is the network distance or the connection setup.</div>
+            </div>
+        </div>
+        {{end}}
 
         {{$limitUp := 0.0}}{{$limitDown := 0.0}}
         {{if .Data.Speedtest}}
@@ -392,93 +683,245 @@ const htmlTemplate = `
         </div>
         {{end}}
 
+        {{if not .Data.Load}}
         <div class="section">
-            <h2>WebDAV Benchmark</h2>
+            <h2 data-i18n="section_webdav_benchmark">WebDAV Benchmark</h2>
+            {{if .Data.Profile}}<div class="meta"><span data-i18n="label_profile">Profile:</span> {{.Data.Profile}}{{with .Data.Protocol}} &middot; <span data-i18n="label_protocol">Protocol:</span> {{.}}{{end}}</div>{{end}}
             <div class="grid">
+                {{range .Data.Scenarios}}
                 <div class="card">
-                    <div class="metric-label">Small Files (5 x 512KB)</div>
+                    <div class="metric-label">{{.Label}}</div>
+                    {{if .Upload}}
                     <div style="margin-top: 10px;">
-                        <span style="color: #27ae60; font-weight: bold;">Upload:</span> {{printf "%.2f MB/s" .Data.SmallFiles.SpeedMBps}}
-                        {{.Data.SmallFiles.GetQualityDot $limitUp false}}
-                        <span style="font-size: 0.8em; color: #666;">({{printf "%.2fs" .Data.SmallFiles.Duration.Seconds}})</span>
-                    </div>
-                    <div style="margin-top: 5px;">
-                        <span style="color: #003d8f; font-weight: bold;">Download:</span> {{printf "%.2f MB/s" .Data.SmallFilesDown.SpeedMBps}}
-                        {{.Data.SmallFilesDown.GetQualityDot $limitDown false}}
-                        <span style="font-size: 0.8em; color: #666;">({{printf "%.2fs" .Data.SmallFilesDown.Duration.Seconds}})</span>
-                    </div>
-                    {{getCombinedConclusion .Data.SmallFiles .Data.SmallFilesDown $limitUp $limitDown false}}
-
-                    {{if .Data.SmallFiles.Errors}}
-                    <div class="error-box">
-                        <strong>Up Errors:</strong><br>
-                        {{range .Data.SmallFiles.Errors}}- {{.}}<br>{{end}}
+                        <span style="color: #27ae60; font-weight: bold;" data-i18n="label_upload">Upload:</span> {{printf "%.2f MB/s" .Upload.SpeedMBps}}
+                        {{.Upload.GetQualityDot $limitUp .IsLarge}}
+                        <span style="font-size: 0.8em; color: #666;">({{printf "%.2fs" .Upload.Duration.Seconds}})</span>
                     </div>
+                    {{template "recovery" .Upload}}
+                    {{template "latency" .Upload.Latency}}
                     {{end}}
-                     {{if .Data.SmallFilesDown.Errors}}
-                    <div class="error-box">
-                        <strong>Down Errors:</strong><br>
-                        {{range .Data.SmallFilesDown.Errors}}- {{.}}<br>{{end}}
+                    {{if and .Sequential .Upload}}
+                    <div style="margin-top: 5px; font-size: 0.9em;">
+                        <span data-i18n="label_sequential">Sequential:</span> {{printf "%.2f MB/s" .Sequential.SpeedMBps}}
+                        <span style="font-size: 0.8em; color: #666;">({{printf "%+.0f%%" .ParallelGain}} <span data-i18n="label_parallel_gain">with parallel chunks</span>)</span>
                     </div>
                     {{end}}
-                </div>
-                <div class="card">
-                    <div class="metric-label">Medium Files (3 x 5MB)</div>
-                      <div style="margin-top: 10px;">
-                        <span style="color: #27ae60; font-weight: bold;">Upload:</span> {{printf "%.2f MB/s" .Data.MediumFiles.SpeedMBps}}
-                        {{.Data.MediumFiles.GetQualityDot $limitUp false}}
-                        <span style="font-size: 0.8em; color: #666;">({{printf "%.2fs" .Data.MediumFiles.Duration.Seconds}})</span>
-                    </div>
+                    {{if .Download}}
                     <div style="margin-top: 5px;">
-                        <span style="color: #003d8f; font-weight: bold;">Download:</span> {{printf "%.2f MB/s" .Data.MediumFilesDown.SpeedMBps}}
-                        {{.Data.MediumFilesDown.GetQualityDot $limitDown false}}
-                         <span style="font-size: 0.8em; color: #666;">({{printf "%.2fs" .Data.MediumFilesDown.Duration.Seconds}})</span>
+                        <span style="color: #003d8f; font-weight: bold;" data-i18n="label_download">Download:</span> {{printf "%.2f MB/s" .Download.SpeedMBps}}
+                        {{.Download.GetQualityDot $limitDown .IsLarge}}
+                        <span style="font-size: 0.8em; color: #666;">({{printf "%.2fs" .Download.Duration.Seconds}})</span>
                     </div>
-                    {{getCombinedConclusion .Data.MediumFiles .Data.MediumFilesDown $limitUp $limitDown false}}
+                    {{template "recovery" .Download}}
+                    {{template "integrity" .Download}}
+                    {{template "latency" .Download.Latency}}
+                    {{end}}
+                    {{getCombinedConclusion .Upload .Download $limitUp $limitDown .IsLarge}}
 
-                    {{if .Data.MediumFiles.Errors}}
+                    {{if and .Upload .Upload.Errors}}
                     <div class="error-box">
                         <strong>Up Errors:</strong><br>
-                        {{range .Data.MediumFiles.Errors}}- {{.}}<br>{{end}}
+                        {{range .Upload.Errors}}- {{.}}<br>{{end}}
                     </div>
                     {{end}}
-                     {{if .Data.MediumFilesDown.Errors}}
+                    {{if and .Download .Download.Errors}}
                     <div class="error-box">
                         <strong>Down Errors:</strong><br>
-                        {{range .Data.MediumFilesDown.Errors}}- {{.}}<br>{{end}}
+                        {{range .Download.Errors}}- {{.}}<br>{{end}}
                     </div>
                     {{end}}
                 </div>
-                <div class="card">
-                     <div class="metric-label">Large File (256MB Chunked)</div>
-                      <div style="margin-top: 10px;">
-                        <span style="color: #27ae60; font-weight: bold;">Upload:</span> {{printf "%.2f MB/s" .Data.LargeFile.SpeedMBps}}
-                        {{.Data.LargeFile.GetQualityDot $limitUp true}}
-                        <span style="font-size: 0.8em; color: #666;">({{printf "%.2fs" .Data.LargeFile.Duration.Seconds}})</span>
-                    </div>
-                    <div style="margin-top: 5px;">
-                         <span style="color: #003d8f; font-weight: bold;">Download:</span> {{printf "%.2f MB/s" .Data.LargeFileDown.SpeedMBps}}
-                         {{.Data.LargeFileDown.GetQualityDot $limitDown true}}
-                          <span style="font-size: 0.8em; color: #666;">({{printf "%.2fs" .Data.LargeFileDown.Duration.Seconds}})</span>
-                    </div>
-                    {{getCombinedConclusion .Data.LargeFile .Data.LargeFileDown $limitUp $limitDown true}}
+                {{end}}
+            </div>
+            {{with .Data.Metadata}}
+            <div class="card" style="margin-top: 20px;">
+                <div class="metric-label"><span data-i18n="label_metadata">Directory Listing (PROPFIND)</span> &ndash; {{.Dirs}} &times; {{.FilesPerDir}}</div>
+                <table>
+                    <tr><th>Depth</th><th data-i18n="th_entries">Entries</th><th>p50</th><th>p90</th><th>p99</th><th>Max</th></tr>
+                    {{range .Listings}}
+                    <tr>
+                        <td>{{.Depth}}</td>
+                        {{if .Errors}}<td colspan="5">{{range .Errors}}{{.}} {{end}}</td>
+                        {{else if .Latency}}<td>{{.Entries}}</td><td>{{ms .Latency.P50}}</td><td>{{ms .Latency.P90}}</td><td>{{ms .Latency.P99}}</td><td>{{ms .Latency.Max}}</td>
+                        {{else}}<td colspan="5">--</td>{{end}}
+                    </tr>
+                    {{end}}
+                </table>
+                {{if .Errors}}
+                <div class="error-box">
+                    {{range .Errors}}- {{.}}<br>{{end}}
+                </div>
+                {{end}}
+            </div>
+            {{end}}
+        </div>
+        {{end}}
 
-                     {{if .Data.LargeFile.Errors}}
-                    <div class="error-box">
-                         <strong>Up Errors:</strong><br>
-                        {{range .Data.LargeFile.Errors}}- {{.}}<br>{{end}}
-                    </div>
+        {{with .Data.Sync}}
+        <div class="section">
+            <h2 data-i18n="section_sync">Sync Cycles</h2>
+            <div class="meta">{{.Dirs}} &times; {{.FilesPerDir}} <span data-i18n="label_sync_files">files</span> ({{size .FileSize}}) &middot; <span data-i18n="label_sync_per_cycle">Per cycle:</span> {{.Changes}} <span data-i18n="label_sync_changes">changes per side</span>, {{.Renames}} <span data-i18n="label_sync_renames">renames</span>, {{.Deletes}} <span data-i18n="label_sync_deletes">deletes</span>, 1 <span data-i18n="label_sync_conflict">conflict</span></div>
+            <div class="grid">
+                <div class="card">
+                    <div class="metric-label" data-i18n="label_sync_cycle">Sync cycle time (median)</div>
+                    <div class="metric-value">{{ms .CycleMedian}}</div>
+                    <div class="metric-label">Max: {{ms .CycleMax}} &middot; {{len .Cycles}} <span data-i18n="label_sync_cycles">cycles</span></div>
+                </div>
+                <div class="card">
+                    <div class="metric-label" data-i18n="label_sync_initial">Initial sync</div>
+                    <div class="metric-value">{{ms .Initial.Duration}}</div>
+                    <div class="metric-label">{{.Initial.Requests}} <span data-i18n="label_sync_requests">requests</span></div>
+                </div>
+                <div class="card">
+                    <div class="metric-label" data-i18n="label_sync_idle">Change check without changes</div>
+                    <div class="metric-value">{{ms .Idle}}</div>
+                </div>
+                <div class="card">
+                    <div class="metric-label" data-i18n="label_sync_conflicts">Conflicts detected</div>
+                    <div class="metric-value {{if lt .Conflicts (len .Cycles)}}text-red{{else}}text-green{{end}}">{{.Conflicts}} / {{len .Cycles}}</div>
+                </div>
+            </div>
+            {{with .PhaseMeans}}
+            <div class="card" style="margin-top: 20px;">
+                <div class="metric-label" data-i18n="label_sync_phases">Phases per cycle (average)</div>
+                <table>
+                    <tr><th data-i18n="th_phase">Phase</th><th data-i18n="th_duration">Duration</th><th data-i18n="th_requests">Requests</th><th data-i18n="th_items">Items</th><th data-i18n="th_errors">Errors</th></tr>
+                    {{range .}}
+                    <tr><td data-i18n="phase_{{.Name}}">{{.Name}}</td><td>{{ms .Duration}}</td><td>{{.Requests}}</td><td>{{.Items}}</td><td>{{.Errors}}</td></tr>
                     {{end}}
-                     {{if .Data.LargeFileDown.Errors}}
-                    <div class="error-box">
-                         <strong>Down Errors:</strong><br>
-                        {{range .Data.LargeFileDown.Errors}}- {{.}}<br>{{end}}
-                    </div>
+                </table>
+            </div>
+            {{end}}
+            {{if .Errors}}
+            <div class="error-box">
+                {{range .Errors}}- {{.}}<br>{{end}}
+            </div>
+            {{end}}
+        </div>
+        {{end}}
+
+        {{with .Data.Protocols}}
+        {{$p := .}}
+        <div class="section">
+            <h2 data-i18n="section_protocols">HTTP Protocol Comparison</h2>
+            <div class="meta">{{.Files}} &times; {{size .FileSize}} (<span data-i18n="label_protocols_parallel">parallel:</span> {{.Parallel}}) &middot; 1 &times; {{size .LargeSize}} &middot; <span data-i18n="label_protocols_baseline">Differences relative to the first protocol</span></div>
+            <div class="card">
+                <table>
+                    <tr><th data-i18n="th_protocol">Protocol</th><th data-i18n="th_small_upload">Small files upload</th><th data-i18n="th_small_download">Small files download</th><th data-i18n="th_large_upload">Large file upload</th><th data-i18n="th_large_download">Large file download</th><th>p50 (PUT / GET)</th></tr>
+                    {{range .Runs}}
+                    {{$run := .}}
+                    <tr>
+                        <td>{{.Protocol}}{{with .Negotiated}}<div style="font-size: 0.8em; color: #666;">{{.}}</div>{{end}}</td>
+                        {{if and .Error (not .Upload)}}<td colspan="5" class="text-red">{{.Error}}</td>
+                        {{else}}
+                        {{range protocolWorkloads}}
+                        <td>{{with $run.Speed .}}{{printf "%.2f MB/s" .SpeedMBps}}{{else}}--{{end}}{{with $p.Change $run .}} <span class="{{if gt (deref .) 5.0}}text-green{{else if lt (deref .) -5.0}}text-red{{end}}" style="font-size: 0.8em;">{{printf "%+.0f%%" (deref .)}}</span>{{end}}</td>
+                        {{end}}
+                        <td>{{if and .Upload .Upload.Latency}}{{ms .Upload.Latency.P50}}{{else}}--{{end}} / {{if and .Download .Download.Latency}}{{ms .Download.Latency.P50}}{{else}}--{{end}}</td>
+                        {{end}}
+                    </tr>
                     {{end}}
+                </table>
+            </div>
+            {{range .Runs}}{{if and .Error .Upload}}
+            <div class="error-box"><strong>{{.Protocol}}:</strong> {{.Error}}</div>
+            {{end}}{{end}}
+        </div>
+        {{end}}
+
+        {{with .Data.Soak}}
+        <div class="section">
+            <h2 data-i18n="section_soak">Soak Test</h2>
+            <div class="meta">{{.Duration}} &middot; {{.Parallel}} <span data-i18n="label_soak_workers">workers</span> &middot; {{size .FileSize}} &middot; <span data-i18n="label_soak_mix">Mix (upload:download:list)</span> {{.Mix}} &middot; <span data-i18n="label_soak_interval">Interval</span> {{.Interval}}</div>
+            <div class="grid">
+                <div class="card">
+                    <div class="metric-label" data-i18n="label_soak_throughput">Throughput (avg)</div>
+                    <div class="metric-value">{{printf "%.2f MB/s" .ThroughputMBps}}</div>
+                    {{with .ThroughputDrift}}<div class="metric-label"><span data-i18n="label_soak_drift">Last vs. first third:</span> <span class="{{if lt (deref .) -20.0}}text-red{{else if lt (deref .) -10.0}}text-yellow{{else}}text-green{{end}}">{{printf "%+.1f%%" (deref .)}}</span></div>{{end}}
                 </div>
+                <div class="card">
+                    <div class="metric-label" data-i18n="label_soak_p95">Latency p95 (worst interval)</div>
+                    <div class="metric-value">{{ms .WorstP95}}</div>
+                </div>
+                <div class="card">
+                    <div class="metric-label" data-i18n="label_soak_errors">Error Rate</div>
+                    <div class="metric-value">{{printf "%.2f%%" .ErrorRate}}</div>
+                    <div class="metric-label">{{.FailedOps}} / {{.Ops}} <span data-i18n="label_soak_ops">operations</span></div>
+                </div>
+            </div>
+            <div class="card" style="margin-top: 20px;">
+                <div class="metric-label" data-i18n="chart_soak_throughput">Throughput per interval</div>
+                {{sampleChart .Samples "throughput"}}
+                <div class="metric-label" data-i18n="chart_soak_latency">Request latency per interval</div>
+                {{sampleChart .Samples "latency"}}
+                <div class="metric-label" data-i18n="chart_soak_errors">Failed operations per interval</div>
+                {{sampleChart .Samples "errors"}}
             </div>
+            {{if .Errors}}
+            <div class="error-box">
+                {{range .Errors}}- {{.}}<br>{{end}}
+            </div>
+            {{end}}
         </div>
-        
+        {{end}}
+
+        {{with .Data.Load}}
+        <div class="section">
+            <h2 data-i18n="section_load">Load Test</h2>
+            <div class="meta">{{.Users}} <span data-i18n="label_load_users">virtual users</span> ({{.Accounts}} <span data-i18n="label_load_accounts">accounts</span>{{if .Provisioned}}, <span data-i18n="label_load_provisioned">provisioned for the run</span>{{end}}) &middot; <span data-i18n="label_load_ramp_up">Ramp-up</span> {{.RampUp}} &middot; {{.Duration}} &middot; {{size .FileSize}} &middot; <span data-i18n="label_load_think_time">Think time</span> {{.ThinkTime}} &middot; <span data-i18n="label_soak_interval">Interval</span> {{.Interval}}</div>
+            <div class="grid">
+                <div class="card">
+                    <div class="metric-label" data-i18n="label_load_peak">Peak throughput</div>
+                    <div class="metric-value">{{printf "%.2f MB/s" .PeakThroughputMBps}}</div>
+                    <div class="metric-label"><span data-i18n="label_soak_throughput">Throughput (avg)</span>: {{printf "%.2f MB/s" .ThroughputMBps}}</div>
+                </div>
+                <div class="card">
+                    <div class="metric-label" data-i18n="label_load_saturation">Saturation point</div>
+                    {{if .SaturationUsers}}<div class="metric-value text-yellow">{{.SaturationUsers}} <span data-i18n="label_load_users_short">users</span></div>
+                    {{else}}<div class="metric-value text-green" data-i18n="label_load_not_saturated">Not reached</div>{{end}}
+                    <div class="metric-label" data-i18n="label_load_saturation_hint">90% of the peak throughput, with more users later</div>
+                </div>
+                <div class="card">
+                    <div class="metric-label" data-i18n="label_load_user_p95">Latency p95 per user (median)</div>
+                    <div class="metric-value">{{ms .UserP95Median}}</div>
+                    <div class="metric-label"><span data-i18n="label_soak_p95">Latency p95 (worst interval)</span>: {{ms .WorstP95}}</div>
+                </div>
+                <div class="card">
+                    <div class="metric-label" data-i18n="label_soak_errors">Error Rate</div>
+                    <div class="metric-value">{{printf "%.2f%%" .ErrorRate}}</div>
+                    <div class="metric-label">{{.FailedOps}} / {{.Ops}} <span data-i18n="label_soak_ops">operations</span></div>
+                </div>
+            </div>
+            <div class="card" style="margin-top: 20px;">
+                <div class="metric-label" data-i18n="chart_load_users">Active virtual users</div>
+                {{sampleChart .Samples "users"}}
+                <div class="metric-label" data-i18n="chart_soak_throughput">Throughput per interval</div>
+                {{sampleChart .Samples "throughput"}}
+                <div class="metric-label" data-i18n="chart_soak_latency">Request latency per interval</div>
+                {{sampleChart .Samples "latency"}}
+                <div class="metric-label" data-i18n="chart_soak_errors">Failed operations per interval</div>
+                {{sampleChart .Samples "errors"}}
+            </div>
+            <details style="margin-top: 20px;">
+                <summary style="cursor:pointer; color: #003d8f; font-weight:bold;" data-i18n="summary_load_users">Latency per virtual user</summary>
+                <table>
+                    <tr><th data-i18n="th_user">User</th><th data-i18n="th_cycles">Sync cycles</th><th data-i18n="th_errors">Errors</th><th>p50</th><th>p95</th><th>p99</th><th>Max</th></tr>
+                    {{range .UsersByP95}}
+                    <tr>
+                        <td>{{.Name}}</td><td>{{.Cycles}}</td><td>{{.Errors}} / {{.Ops}}</td>
+                        {{with .Latency}}<td>{{ms .P50}}</td><td>{{ms .P95}}</td><td>{{ms .P99}}</td><td>{{ms .Max}}</td>{{else}}<td colspan="4">--</td>{{end}}
+                    </tr>
+                    {{end}}
+                </table>
+            </details>
+            {{if .Errors}}
+            <div class="error-box">
+                {{range .Errors}}- {{.}}<br>{{end}}
+            </div>
+            {{end}}
+        </div>
+        {{end}}
+
         <footer>
             <small data-i18n="footer">Generated by Nextcloud Performance Tool (Open Source)</small>
         </footer>
@@ -504,6 +947,33 @@ const htmlTemplate = `
                 section_network_diagnostics: "Network Diagnostics",
                 label_dns: "DNS Resolution",
                 label_tcp_connect: "TCP Connect",
+                label_icmp_ping: "ICMP Ping",
+                label_traceroute_method: "Method:",
+                label_probes_per_hop: "probes per hop",
+                label_path_mtu: "Largest packet to the server:",
+                label_interface_mtu: "Interface MTU:",
+                label_reported_mtu: "Reported too small (ICMP):",
+                label_tcp_packets: "packets",
+                hint_mtu_black_hole: "MTU black hole: larger packets are dropped without ICMP \"fragmentation needed\", so TCP cannot adapt and transfers stall. Lower the MTU or clamp the TCP MSS on the VPN or PPPoE link, or let ICMP through.",
+                hint_mtu_black_hole_clamped: "Larger packets are dropped without ICMP error, but the TCP MSS keeps connections to the server below the limit. HTTP/3 over UDP may still be affected.",
+                label_hops: "hops",
+                th_hop: "Hop",
+                th_host: "Host",
+                th_loss: "Loss",
+                th_sent: "Sent",
+                th_avg_ms: "Avg (ms)",
+                th_stddev: "StdDev",
+                section_endpoints: "Request Phases",
+                label_endpoints_meta: "Every request on a new connection. Chart: average per phase, table: median per phase.",
+                th_bottleneck: "Bottleneck",
+                label_failed_requests: "failed",
+                tag_server: "Server",
+                tag_network: "Network",
+                hint_endpoints: "TTFB is the time the server takes to process the request plus one round trip, which is about the TCP connect time. If it dominates, PHP, the database or caching on the server are slow; if DNS, TCP and TLS dominate, it is the network distance or the connection setup.",
+                section_dual_stack: "IPv4 / IPv6",
+                label_happy_eyeballs: "Dual-stack connect (Happy Eyeballs):",
+                label_fallback_delay: "Fallback delay:",
+                hint_ipv6_fallback: "One address family is broken or much slower: connections wait for the fallback to the other one.",
                 label_avg: "Avg:",
                 label_min: "Min:",
                 label_max: "Max:",
@@ -518,11 +988,90 @@ const htmlTemplate = `
                 label_upload_speed: "Upload Speed",
                 label_download_speed: "Download Speed",
                 section_webdav_benchmark: "WebDAV Benchmark",
-                label_small_files: "Small Files (5 x 512KB)",
+                label_profile: "Profile:",
+                label_protocol: "Protocol:",
                 label_upload: "Upload:",
+                label_phases: "Phases (avg):",
+                th_latency: "Latency",
+                th_requests: "Requests",
+                label_metadata: "Directory Listing (PROPFIND)",
+                label_sequential: "Sequential:",
+                label_recovery: "Recovered:",
+                label_retries: "retries",
+                label_resumes: "resumes",
+                label_corrupted: "Corrupted downloads:",
+                label_corrupted_hint: "(truncated or checksum mismatch)",
+                label_verified: "Verified:",
+                label_parallel_gain: "with parallel chunks",
+                th_entries: "Entries",
+                section_verdict: "Threshold Check",
+                verdict_passed: "All thresholds passed",
+                verdict_failed: "Thresholds violated:",
+                th_rule: "Rule",
+                th_value: "Value",
+                th_limit: "Limit",
+                th_result: "Result",
                 label_download: "Download:",
-                label_medium_files: "Medium Files (3 x 5MB)",
-                label_large_file: "Large File (256MB Chunked)",
+                section_sync: "Sync Cycles",
+                label_sync_files: "files",
+                label_sync_per_cycle: "Per cycle:",
+                label_sync_changes: "changes per side",
+                label_sync_renames: "renames",
+                label_sync_deletes: "deletes",
+                label_sync_conflict: "conflict",
+                label_sync_cycle: "Sync cycle time (median)",
+                label_sync_cycles: "cycles",
+                label_sync_initial: "Initial sync",
+                label_sync_requests: "requests",
+                label_sync_idle: "Change check without changes",
+                label_sync_conflicts: "Conflicts detected",
+                label_sync_phases: "Phases per cycle (average)",
+                th_phase: "Phase",
+                th_duration: "Duration",
+                th_items: "Items",
+                phase_discovery: "Discovery",
+                phase_download: "Download",
+                phase_upload: "Upload",
+                phase_conflict: "Conflict",
+                phase_rename: "Rename",
+                phase_delete: "Delete",
+                section_protocols: "HTTP Protocol Comparison",
+                label_protocols_parallel: "parallel:",
+                label_protocols_baseline: "Differences relative to the first protocol",
+                th_protocol: "Protocol",
+                th_small_upload: "Small files upload",
+                th_small_download: "Small files download",
+                th_large_upload: "Large file upload",
+                th_large_download: "Large file download",
+                section_soak: "Soak Test",
+                label_soak_workers: "workers",
+                label_soak_mix: "Mix (upload:download:list)",
+                label_soak_interval: "Interval",
+                label_soak_throughput: "Throughput (avg)",
+                label_soak_drift: "Last vs. first third:",
+                label_soak_p95: "Latency p95 (worst interval)",
+                label_soak_errors: "Error Rate",
+                label_soak_ops: "operations",
+                chart_soak_throughput: "Throughput per interval",
+                chart_soak_latency: "Request latency per interval",
+                chart_soak_errors: "Failed operations per interval",
+                section_load: "Load Test",
+                label_load_users: "virtual users",
+                label_load_users_short: "users",
+                label_load_accounts: "accounts",
+                label_load_provisioned: "provisioned for the run",
+                label_load_ramp_up: "Ramp-up",
+                label_load_think_time: "Think time",
+                label_load_peak: "Peak throughput",
+                label_load_saturation: "Saturation point",
+                label_load_not_saturated: "Not reached",
+                label_load_saturation_hint: "90% of the peak throughput, with more users later",
+                label_load_user_p95: "Latency p95 per user (median)",
+                chart_load_users: "Active virtual users",
+                summary_load_users: "Latency per virtual user",
+                th_user: "User",
+                th_cycles: "Sync cycles",
+                th_errors: "Errors",
                 footer: "Generated by Nextcloud Performance Tool (Open Source)",
                 conc_excellent: "Excellent connection",
                 conc_solid: "Solid performance",
@@ -547,6 +1096,33 @@ const htmlTemplate = `
                 section_network_diagnostics: "Netzwerkdiagnose",
                 label_dns: "DNS-Auflösung",
                 label_tcp_connect: "TCP Verbindung",
+                label_icmp_ping: "ICMP-Ping",
+                label_traceroute_method: "Methode:",
+                label_probes_per_hop: "Proben pro Hop",
+                label_path_mtu: "Größtes Paket zum Server:",
+                label_interface_mtu: "MTU der Schnittstelle:",
+                label_reported_mtu: "Als zu klein gemeldet (ICMP):",
+                label_tcp_packets: "Pakete",
+                hint_mtu_black_hole: "MTU-Black-Hole: Größere Pakete werden ohne ICMP \"Fragmentation needed\" verworfen, TCP kann sich nicht anpassen und Übertragungen bleiben hängen. MTU senken oder TCP-MSS auf der VPN- bzw. PPPoE-Strecke begrenzen (MSS-Clamping) oder ICMP durchlassen.",
+                hint_mtu_black_hole_clamped: "Größere Pakete werden ohne ICMP-Fehler verworfen, die TCP-MSS hält Verbindungen zum Server aber unter der Grenze. HTTP/3 über UDP kann trotzdem betroffen sein.",
+                label_hops: "Hops",
+                th_hop: "Hop",
+                th_host: "Host",
+                th_loss: "Verlust",
+                th_sent: "Gesendet",
+                th_avg_ms: "Durchschn. (ms)",
+                th_stddev: "Std.-Abw.",
+                section_endpoints: "Anfragephasen",
+                label_endpoints_meta: "Jede Anfrage über eine neue Verbindung. Diagramm: Durchschnitt je Phase, Tabelle: Median je Phase.",
+                th_bottleneck: "Engpass",
+                label_failed_requests: "fehlgeschlagen",
+                tag_server: "Server",
+                tag_network: "Netzwerk",
+                hint_endpoints: "TTFB ist die Verarbeitungszeit des Servers plus eine Round-Trip-Zeit, die etwa der TCP-Verbindungszeit entspricht. Überwiegt sie, sind PHP, die Datenbank oder das Caching auf dem Server langsam; überwiegen DNS, TCP und TLS, liegt es an der Netzwerkstrecke oder am Verbindungsaufbau.",
+                section_dual_stack: "IPv4 / IPv6",
+                label_happy_eyeballs: "Dual-Stack-Verbindung (Happy Eyeballs):",
+                label_fallback_delay: "Fallback-Verzögerung:",
+                hint_ipv6_fallback: "Eine Adressfamilie ist gestört oder deutlich langsamer: Verbindungen warten auf den Fallback zur anderen.",
                 label_avg: "Durschn.:",
                 label_min: "Min:",
                 label_max: "Max:",
@@ -561,11 +1137,90 @@ const htmlTemplate = `
                 label_upload_speed: "Upload Geschwindigkeit",
                 label_download_speed: "Download Geschwindigkeit",
                 section_webdav_benchmark: "WebDAV Benchmark",
-                label_small_files: "Kleine Dateien (5 x 512KB)",
+                label_profile: "Profil:",
+                label_protocol: "Protokoll:",
                 label_upload: "Upload:",
+                label_phases: "Phasen (Ø):",
+                th_latency: "Latenz",
+                th_requests: "Anfragen",
+                label_metadata: "Verzeichnis-Listing (PROPFIND)",
+                label_sequential: "Sequentiell:",
+                label_recovery: "Wiederhergestellt:",
+                label_retries: "Wiederholungen",
+                label_resumes: "Fortsetzungen",
+                label_corrupted: "Beschädigte Downloads:",
+                label_corrupted_hint: "(abgeschnitten oder Prüfsumme falsch)",
+                label_verified: "Geprüft:",
+                label_parallel_gain: "mit parallelen Chunks",
+                th_entries: "Einträge",
+                section_verdict: "Schwellwert-Prüfung",
+                verdict_passed: "Alle Schwellwerte eingehalten",
+                verdict_failed: "Schwellwerte verletzt:",
+                th_rule: "Regel",
+                th_value: "Wert",
+                th_limit: "Grenze",
+                th_result: "Ergebnis",
                 label_download: "Download:",
-                label_medium_files: "Mittlere Dateien (3 x 5MB)",
-                label_large_file: "Große Datei (256MB Chunked)",
+                section_sync: "Sync-Zyklen",
+                label_sync_files: "Dateien",
+                label_sync_per_cycle: "Pro Zyklus:",
+                label_sync_changes: "Änderungen je Seite",
+                label_sync_renames: "Umbenennungen",
+                label_sync_deletes: "Löschungen",
+                label_sync_conflict: "Konflikt",
+                label_sync_cycle: "Sync-Zykluszeit (Median)",
+                label_sync_cycles: "Zyklen",
+                label_sync_initial: "Erstsynchronisation",
+                label_sync_requests: "Anfragen",
+                label_sync_idle: "Änderungsprüfung ohne Änderungen",
+                label_sync_conflicts: "Erkannte Konflikte",
+                label_sync_phases: "Phasen pro Zyklus (Ø)",
+                th_phase: "Phase",
+                th_duration: "Dauer",
+                th_items: "Objekte",
+                phase_discovery: "Erkennung",
+                phase_download: "Download",
+                phase_upload: "Upload",
+                phase_conflict: "Konflikt",
+                phase_rename: "Umbenennen",
+                phase_delete: "Löschen",
+                section_protocols: "HTTP-Protokollvergleich",
+                label_protocols_parallel: "parallel:",
+                label_protocols_baseline: "Abweichungen relativ zum ersten Protokoll",
+                th_protocol: "Protokoll",
+                th_small_upload: "Kleine Dateien Upload",
+                th_small_download: "Kleine Dateien Download",
+                th_large_upload: "Große Datei Upload",
+                th_large_download: "Große Datei Download",
+                section_soak: "Dauerlasttest",
+                label_soak_workers: "Worker",
+                label_soak_mix: "Mix (Upload:Download:Listing)",
+                label_soak_interval: "Intervall",
+                label_soak_throughput: "Durchsatz (Ø)",
+                label_soak_drift: "Letztes vs. erstes Drittel:",
+                label_soak_p95: "Latenz p95 (schlechtestes Intervall)",
+                label_soak_errors: "Fehlerrate",
+                label_soak_ops: "Operationen",
+                chart_soak_throughput: "Durchsatz pro Intervall",
+                chart_soak_latency: "Anfragelatenz pro Intervall",
+                chart_soak_errors: "Fehlgeschlagene Operationen pro Intervall",
+                section_load: "Lasttest",
+                label_load_users: "virtuelle Benutzer",
+                label_load_users_short: "Benutzer",
+                label_load_accounts: "Konten",
+                label_load_provisioned: "für den Lauf angelegt",
+                label_load_ramp_up: "Hochlauf",
+                label_load_think_time: "Denkzeit",
+                label_load_peak: "Spitzendurchsatz",
+                label_load_saturation: "Sättigungspunkt",
+                label_load_not_saturated: "Nicht erreicht",
+                label_load_saturation_hint: "90% des Spitzendurchsatzes, danach mehr Benutzer",
+                label_load_user_p95: "Latenz p95 pro Benutzer (Median)",
+                chart_load_users: "Aktive virtuelle Benutzer",
+                summary_load_users: "Latenz pro virtuellem Benutzer",
+                th_user: "Benutzer",
+                th_cycles: "Sync-Zyklen",
+                th_errors: "Fehler",
                 footer: "Generiert vom Nextcloud Performance Tool (Open Source)",
                 conc_excellent: "Exzellente Verbindung",
                 conc_solid: "Solide Leistung",
@@ -600,6 +1255,45 @@ const htmlTemplate = `
     </script>
 </body>
 </html>
+{{define "recovery"}}{{if or .Retries .Resumes}}
+                    <div style="font-size: 0.8em; color: #e67e22;"><span data-i18n="label_recovery">Recovered:</span> {{.Retries}} <span data-i18n="label_retries">retries</span>, {{.Resumes}} <span data-i18n="label_resumes">resumes</span></div>
+{{end}}{{end}}
+{{define "integrity"}}{{if .Corrupted}}
+                    <div style="font-size: 0.8em;" class="text-red"><span data-i18n="label_corrupted">Corrupted downloads:</span> {{.Corrupted}} <span data-i18n="label_corrupted_hint">(truncated or checksum mismatch)</span></div>
+{{else if .Checksum}}
+                    <div style="font-size: 0.8em; color: #666;"><span data-i18n="label_verified">Verified:</span> {{.Checksum}}</div>
+{{end}}{{end}}
+{{define "hops"}}
+            <table>
+                <thead><tr><th data-i18n="th_hop">Hop</th><th data-i18n="th_host">Host</th><th>AS</th><th data-i18n="th_loss">Loss</th><th data-i18n="th_sent">Sent</th><th data-i18n="th_avg_ms">Avg (ms)</th><th>Min</th><th>Max</th><th data-i18n="th_stddev">StdDev</th></tr></thead>
+                <tbody>
+                    {{range .Hops}}
+                    <tr>
+                        <td>{{.TTL}}</td>
+                        <td>{{if .Address}}{{if .Hostname}}{{.Hostname}} ({{.Address}}){{else}}{{.Address}}{{end}}{{range .Others}}<br>{{.}}{{end}}{{else}}*{{end}}</td>
+                        <td>{{if .ASN}}AS{{.ASN}} {{.ASName}}{{else}}-{{end}}</td>
+                        <td class="{{if ge .PacketLoss 10.0}}text-red{{else if gt .PacketLoss 0.0}}text-yellow{{else}}text-green{{end}}">{{printf "%.1f%%" .PacketLoss}}</td>
+                        <td>{{.Sent}}</td>
+                        {{if .Received}}<td>{{printf "%.2f" .AvgMs}}</td><td>{{printf "%.2f" .MinMs}}</td><td>{{printf "%.2f" .MaxMs}}</td><td>{{printf "%.2f" .StdDevMs}}</td>{{else}}<td>-</td><td>-</td><td>-</td><td>-</td>{{end}}
+                    </tr>
+                    {{end}}
+                </tbody>
+            </table>
+{{end}}
+{{define "latency"}}{{if .}}
+                    <details style="margin-top: 5px; font-size: 0.8em; color: #666;">
+                        <summary>p50 {{ms .P50}} · p90 {{ms .P90}} · p99 {{ms .P99}} · max {{ms .Max}}</summary>
+                        <div style="margin-top: 5px;"><span data-i18n="label_phases">Phases (avg):</span>
+                            DNS {{ms .Phases.DNS}} · TCP {{ms .Phases.Connect}} · TLS {{ms .Phases.TLS}} · Send {{ms .Phases.Send}} · TTFB {{ms .Phases.TTFB}} · Transfer {{ms .Phases.Transfer}}
+                        </div>
+                        <table>
+                            <tr><th data-i18n="th_latency">Latency</th><th data-i18n="th_requests">Requests</th></tr>
+                            {{range .Histogram}}
+                            <tr><td>{{if .UpperBound}}&le; {{ms .UpperBound}}{{else}}&gt;{{end}}</td><td>{{.Count}}</td></tr>
+                            {{end}}
+                        </table>
+                    </details>
+{{end}}{{end}}
 `
 
 func GenerateHTML(data ReportData) ([]byte, error) {
@@ -607,6 +1301,13 @@ func GenerateHTML(data ReportData) ([]byte, error) {
 		"getPingQualityDot":     GetPingQualityDot,
 		"getLossQualityDot":     GetLossQualityDot,
 		"getCombinedConclusion": GetCombinedConclusion,
+		"ms":                    FormatMs,
+		"verdictDot":            verdictDot,
+		"deref":                 func(f *float64) float64 { return *f },
+		"sampleChart":           SampleChart,
+		"waterfallChart":        WaterfallChart,
+		"size":                  func(n int64) string { return config.ByteSize(n).String() },
+		"protocolWorkloads":     func() []string { return ProtocolWorkloads },
 	}
 
 	t, err := template.New("report").Funcs(funcMap).Parse(htmlTemplate)
@@ -0,0 +1,388 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"nextcloud-perf/internal/benchmark"
+	"nextcloud-perf/internal/network"
+)
+
+func TestGenerateHTML(t *testing.T) {
+	data := ReportData{
+		GeneratedAt: time.Now(),
+		TargetURL:   "https://cloud.example.com",
+		Profile:     "standard",
+		Speedtest:   &network.SpeedtestResult{UploadMBps: 10, DownloadMBps: 50},
+		Scenarios: []ScenarioResult{
+			{Name: "small", Label: "Small Files (5 x 512KB)", Upload: &SpeedResult{SpeedMBps: 5, Latency: &benchmark.LatencyStats{Count: 5, P50: 42 * time.Millisecond}}, Download: &SpeedResult{SpeedMBps: 20}},
+			{Name: "large", Label: "Large File (256MB Chunked)", Chunked: true, Upload: &SpeedResult{SpeedMBps: 1, Errors: []string{"chunk upload failed"}}},
+		},
+	}
+
+	html, err := GenerateHTML(data)
+	if err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	out := string(html)
+	for _, want := range []string{"Small Files (5 x 512KB)", "Large File (256MB Chunked)", "chunk upload failed", "conc_excellent", "conc_optimize", "p50 42.0 ms"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+}
+
+func TestIntegrityReport(t *testing.T) {
+	data := ReportData{
+		GeneratedAt: time.Now(),
+		TargetURL:   "https://cloud.example.com",
+		Scenarios: []ScenarioResult{
+			{Name: "small", Label: "Small Files", Download: &SpeedResult{SpeedMBps: 20, Checksum: "SHA256"}},
+			{Name: "large", Label: "Large File", Download: &SpeedResult{SpeedMBps: 5, Checksum: "SHA256", Corrupted: 1, Errors: []string{"size mismatch: /test/large.bin: received 10 of 20 bytes"}}},
+		},
+	}
+	html, err := GenerateHTML(data)
+	if err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	out := string(html)
+	for _, want := range []string{"label_verified", "label_corrupted", "size mismatch"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+
+	metrics := map[string]float64{}
+	for _, m := range Metrics(data) {
+		metrics[m.Key] = m.Value
+	}
+	if v, ok := metrics["scenario.small.download.corrupted"]; !ok || v != 0 || metrics["scenario.large.download.corrupted"] != 1 {
+		t.Errorf("Unexpected corrupted download metrics: %v", metrics)
+	}
+}
+
+func TestSoakReport(t *testing.T) {
+	soak := &SoakResult{Duration: 3 * time.Minute, Interval: time.Minute, FileSize: 1 << 20, Parallel: 4, Mix: "1:2:1"}
+	for i, mbps := range []float64{30, 24, 15} {
+		soak.Samples = append(soak.Samples, benchmark.IntervalSample{
+			Offset: time.Duration(i) * time.Minute, Length: time.Minute, Ops: 100, Errors: i,
+			UploadMBps: mbps / 3, DownloadMBps: mbps * 2 / 3, P50: 20 * time.Millisecond, P95: time.Duration(i+1) * 100 * time.Millisecond,
+		})
+	}
+	data := ReportData{GeneratedAt: time.Now(), TargetURL: "https://cloud.example.com", Soak: soak}
+
+	html, err := GenerateHTML(data)
+	if err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	out := string(html)
+	for _, want := range []string{"section_soak", "23.00 MB/s", "-50.0%", "1.00%", "3 / 300", "300.0 ms"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+	if n := strings.Count(out, "<polyline"); n != 5 {
+		t.Errorf("Expected 5 chart lines, got %d", n)
+	}
+
+	metrics := map[string]float64{}
+	for _, m := range Metrics(data) {
+		metrics[m.Key] = m.Value
+	}
+	if metrics["soak.throughput_drift"] != -50 || metrics["soak.error_rate"] != 1 || metrics["soak.p95_ms"] != 300 {
+		t.Errorf("Unexpected soak metrics: %v", metrics)
+	}
+}
+
+func TestLoadReport(t *testing.T) {
+	// Throughput stops growing at 40 of 80 users
+	load := &LoadResult{Users: 80, Accounts: 80, Provisioned: true, RampUp: 4 * time.Minute, Duration: 6 * time.Minute, Interval: time.Minute, FileSize: 256 << 10}
+	for i, s := range []struct {
+		users int
+		mbps  float64
+	}{{20, 10}, {40, 19}, {60, 20}, {80, 20}, {80, 19}, {80, 18}} {
+		load.Samples = append(load.Samples, benchmark.IntervalSample{
+			Offset: time.Duration(i) * time.Minute, Length: time.Minute, Users: s.users, Ops: 100,
+			UploadMBps: s.mbps / 2, DownloadMBps: s.mbps / 2, P95: time.Duration(i+1) * 50 * time.Millisecond,
+		})
+	}
+	load.PerUser = []benchmark.UserResult{
+		{Name: "vu001", Cycles: 10, Ops: 40, Latency: &benchmark.LatencyStats{Count: 40, P95: 80 * time.Millisecond}},
+		{Name: "vu002", Cycles: 10, Ops: 40, Errors: 1, Latency: &benchmark.LatencyStats{Count: 40, P95: 120 * time.Millisecond}},
+		{Name: "vu003", Ops: 1, Errors: 1},
+	}
+	if n := load.SaturationUsers(); n != 40 {
+		t.Errorf("Expected saturation at 40 users, got %d", n)
+	}
+	if order := load.UsersByP95(); order[0].Name != "vu002" || order[2].Name != "vu003" {
+		t.Errorf("Unexpected order: %+v", order)
+	}
+
+	data := ReportData{GeneratedAt: time.Now(), TargetURL: "https://cloud.example.com", Profile: "load", Load: load}
+	html, err := GenerateHTML(data)
+	if err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	out := string(html)
+	for _, want := range []string{"section_load", "20.00 MB/s", "40 <span", "120.0 ms", "vu003"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+	if strings.Contains(out, `<h2 data-i18n="section_webdav_benchmark">`) {
+		t.Error("Expected no WebDAV benchmark section in a load report")
+	}
+
+	metrics := map[string]float64{}
+	for _, m := range Metrics(data) {
+		metrics[m.Key] = m.Value
+	}
+	if metrics["load.saturation_users"] != 40 || metrics["load.peak_throughput_mbps"] != 20 || metrics["load.p95_ms"] != 300 || metrics["load.user_p95_ms"] != 120 {
+		t.Errorf("Unexpected load metrics: %v", metrics)
+	}
+
+	// Throughput grew until all users were active
+	for i := range load.Samples {
+		load.Samples[i].Users = (i + 1) * 10
+		load.Samples[i].UploadMBps, load.Samples[i].DownloadMBps = float64(i+1), float64(i+1)
+	}
+	if n := load.SaturationUsers(); n != 0 {
+		t.Errorf("Expected no saturation, got %d users", n)
+	}
+}
+
+func TestSyncReport(t *testing.T) {
+	cycle := func(ms int, conflicts int) benchmark.SyncCycle {
+		d := time.Duration(ms) * time.Millisecond
+		return benchmark.SyncCycle{Duration: d, Requests: 30, Conflicts: conflicts, Phases: []benchmark.SyncPhase{
+			{Name: benchmark.PhaseDiscovery, Duration: d / 2, Requests: 4, Items: 3},
+			{Name: benchmark.PhaseUpload, Duration: d / 2, Requests: 26, Items: 26},
+		}}
+	}
+	sync := &SyncResult{
+		Dirs: 10, FilesPerDir: 20, FileSize: 16 * 1024, Changes: 10, Renames: 5, Deletes: 5,
+		Initial: benchmark.SyncCycle{Duration: 2 * time.Second, Requests: 212},
+		Idle:    12 * time.Millisecond,
+		Cycles:  []benchmark.SyncCycle{cycle(400, 1), cycle(300, 1), cycle(900, 0)},
+	}
+	data := ReportData{GeneratedAt: time.Now(), TargetURL: "https://cloud.example.com", Sync: sync}
+
+	html, err := GenerateHTML(data)
+	if err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	out := string(html)
+	for _, want := range []string{"section_sync", "400.0 ms", "900.0 ms", "212", "2 / 3", "phase_discovery", "266.7 ms"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+
+	metrics := map[string]float64{}
+	for _, m := range Metrics(data) {
+		metrics[m.Key] = m.Value
+	}
+	if metrics["sync.cycle_ms"] != 400 || metrics["sync.cycle_max_ms"] != 900 || metrics["sync.initial_ms"] != 2000 || metrics["sync.idle_ms"] != 12 {
+		t.Errorf("Unexpected sync metrics: %v", metrics)
+	}
+}
+
+func TestProtocolReport(t *testing.T) {
+	speed := func(mbps float64) *SpeedResult { return &SpeedResult{SpeedMBps: mbps} }
+	data := ReportData{
+		GeneratedAt: time.Now(),
+		TargetURL:   "https://cloud.example.com",
+		Protocols: &ProtocolResult{Files: 20, FileSize: 256 * 1024, Parallel: 8, LargeSize: 64 << 20, Runs: []ProtocolRunResult{
+			{Protocol: "http/1.1", Negotiated: "HTTP/1.1", Upload: speed(10), Download: speed(20), LargeUpload: speed(50), LargeDownload: speed(100)},
+			{Protocol: "h2", Negotiated: "HTTP/2.0", Upload: speed(15), Download: speed(16), LargeUpload: speed(50), LargeDownload: speed(90)},
+			{Protocol: "h3", Error: "h3 not available: timeout: no recent network activity"},
+		}},
+	}
+
+	html, err := GenerateHTML(data)
+	if err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	out := string(html)
+	for _, want := range []string{"section_protocols", "HTTP/2.0", "15.00 MB/s", "&#43;50%", "-20%", "no recent network activity"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+
+	metrics := map[string]float64{}
+	for _, m := range Metrics(data) {
+		metrics[m.Key] = m.Value
+	}
+	if metrics["protocol.http1.upload_mbps"] != 10 || metrics["protocol.h2.large_download_mbps"] != 90 {
+		t.Errorf("Unexpected protocol metrics: %v", metrics)
+	}
+	if _, ok := metrics["protocol.h3.upload_mbps"]; ok {
+		t.Error("Expected no metrics for an unavailable protocol")
+	}
+}
+
+func TestNetworkDiagnosticsReport(t *testing.T) {
+	data := ReportData{
+		GeneratedAt: time.Now(),
+		TargetURL:   "https://cloud.example.com",
+		ICMPPing:    &network.DetailedPingStats{Method: network.MethodICMPDgram, Count: 10, SuccessCount: 10, AvgMs: 12.5, MaxMs: 20},
+		Traceroute: &network.TracerouteResult{Method: network.MethodTCP, Cycles: 10, Hops: []network.Hop{
+			{TTL: 1, Address: "192.168.1.1", Hostname: "router.lan", Sent: 10, Received: 10, AvgMs: 1},
+			{TTL: 2, Sent: 10, PacketLoss: 100},
+			{TTL: 3, Address: "203.0.113.10", ASN: 64500, ASName: "EXAMPLE-NET", Sent: 10, Received: 9, PacketLoss: 10, AvgMs: 12, StdDevMs: 1.5},
+		}},
+	}
+
+	html, err := GenerateHTML(data)
+	if err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	out := string(html)
+	for _, want := range []string{"label_icmp_ping", "icmp-dgram", "12.50 ms", "label_traceroute_method", "203.0.113.10", "router.lan", "AS64500", "10.0%"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+	metrics := map[string]float64{}
+	for _, m := range Metrics(data) {
+		metrics[m.Key] = m.Value
+	}
+	if loss, ok := metrics["network.icmp_packet_loss"]; !ok || loss != 0 || metrics["network.icmp_ping_avg_ms"] != 12.5 {
+		t.Errorf("Unexpected ICMP metrics: %v", metrics)
+	}
+
+	// Without any working method the reason is shown instead of the hops
+	data.Traceroute = nil
+	data.TracerouteError = "no traceroute method available (udp: no replies)"
+	if html, err = GenerateHTML(data); err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	if !strings.Contains(string(html), "udp: no replies") {
+		t.Error("Expected the traceroute error in the report")
+	}
+}
+
+func TestPathMTUReport(t *testing.T) {
+	data := ReportData{
+		GeneratedAt: time.Now(),
+		TargetURL:   "https://cloud.example.com",
+		PathMTU: &network.PathMTUResult{Family: network.IPv4, Method: network.MethodUDP, LocalMTU: 1500, PathMTU: 1420,
+			TCPMSS: 1460, TCPMTU: 1500, BlackHole: true},
+	}
+	html, err := GenerateHTML(data)
+	if err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	out := string(html)
+	for _, want := range []string{"1420 B", "TCP MSS: 1460 B", `data-i18n="hint_mtu_black_hole"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+
+	// MSS clamping below the path MTU keeps TCP working
+	data.PathMTU.TCPMSS, data.PathMTU.TCPMTU = 1380, 1420
+	if html, err = GenerateHTML(data); err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	if out := string(html); strings.Contains(out, `data-i18n="hint_mtu_black_hole"`) || !strings.Contains(out, `data-i18n="hint_mtu_black_hole_clamped"`) {
+		t.Error("Expected the clamped hint instead of the black hole warning")
+	}
+
+	metrics := map[string]float64{}
+	for _, m := range Metrics(data) {
+		metrics[m.Key] = m.Value
+	}
+	if metrics["network.path_mtu"] != 1420 {
+		t.Errorf("Unexpected path MTU metric: %v", metrics)
+	}
+}
+
+func TestEndpointsReport(t *testing.T) {
+	phases := func(ms ...int) []benchmark.PhaseStats {
+		var out []benchmark.PhaseStats
+		for i, name := range benchmark.RequestPhases {
+			d := time.Duration(ms[i]) * time.Millisecond
+			out = append(out, benchmark.PhaseStats{Name: name, DurationStats: benchmark.DurationStats{Min: d, Median: d, Mean: d, Max: d}})
+		}
+		return out
+	}
+	data := ReportData{
+		GeneratedAt: time.Now(),
+		TargetURL:   "https://cloud.example.com",
+		Endpoints: []benchmark.EndpointTiming{
+			{Name: benchmark.EndpointStatus, Method: "GET", Path: "/status.php", Samples: 10, Phases: phases(2, 20, 40, 0, 25, 1),
+				Total: benchmark.DurationStats{Median: 88 * time.Millisecond, Mean: 88 * time.Millisecond}},
+			{Name: benchmark.EndpointOCS, Method: "GET", Path: "/ocs/v1.php/cloud/capabilities", Samples: 9, Failed: 1, Phases: phases(2, 20, 40, 0, 420, 3),
+				Total: benchmark.DurationStats{Median: 485 * time.Millisecond, Mean: 485 * time.Millisecond}},
+			{Name: benchmark.EndpointPropfind, Method: "PROPFIND", Path: "/remote.php/dav/files/admin/", Failed: 10, Error: "PROPFIND failed with status 403"},
+		},
+	}
+	html, err := GenerateHTML(data)
+	if err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	out := string(html)
+	for _, want := range []string{`data-i18n="section_endpoints"`, "<svg", "<title>TTFB: 420.0 ms</title>", "GET /status.php", "88.0 ms", "PROPFIND failed with status 403"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+	// status.php is dominated by the connection setup, the OCS call by the server
+	if strings.Count(out, `data-i18n="tag_network"`) != 1 || strings.Count(out, `data-i18n="tag_server"`) != 1 {
+		t.Error("Expected one network-bound and one server-bound endpoint")
+	}
+
+	metrics := map[string]float64{}
+	for _, m := range Metrics(data) {
+		metrics[m.Key] = m.Value
+	}
+	if metrics["endpoint.ocs.ttfb_ms"] != 420 || metrics["endpoint.status.total_ms"] != 88 {
+		t.Errorf("Unexpected endpoint metrics: %v", metrics)
+	}
+	if _, ok := metrics["endpoint.propfind.total_ms"]; ok {
+		t.Error("Expected no metrics for a failed endpoint")
+	}
+}
+
+func TestDualStackReport(t *testing.T) {
+	data := ReportData{
+		GeneratedAt: time.Now(),
+		TargetURL:   "https://cloud.example.com",
+		DualStack: &network.DualStackResult{
+			Families: []network.FamilyResult{
+				{Family: network.IPv4, DNS: network.DNSResult{ResolutionTime: 5, ResolvedIPs: []string{"203.0.113.10"}},
+					Ping:       &network.DetailedPingStats{Count: 5, SuccessCount: 5, AvgMs: 20},
+					Traceroute: &network.TracerouteResult{Method: network.MethodUDP, Hops: []network.Hop{{TTL: 1, Address: "203.0.113.10"}}}},
+				{Family: network.IPv6, DNS: network.DNSResult{ResolutionTime: 6, ResolvedIPs: []string{"2001:db8::10"}},
+					Ping:            &network.DetailedPingStats{Count: 5, PacketLoss: 100},
+					TracerouteError: "no traceroute method available (udp: no replies)"},
+			},
+			HappyEyeballs: &network.HappyEyeballsResult{Count: 5, AvgMs: 320, MaxMs: 330, IPv4Count: 5},
+		},
+	}
+
+	html, err := GenerateHTML(data)
+	if err != nil {
+		t.Fatalf("GenerateHTML failed: %v", err)
+	}
+	out := string(html)
+	for _, want := range []string{"section_dual_stack", "2001:db8::10", "udp: no replies", "320.00 ms", "300 ms", "hint_ipv6_fallback"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+
+	metrics := map[string]float64{}
+	for _, m := range Metrics(data) {
+		metrics[m.Key] = m.Value
+	}
+	if metrics["network.ipv4.ping_avg_ms"] != 20 || metrics["network.ipv6.packet_loss"] != 100 || metrics["network.fallback_delay_ms"] != 300 {
+		t.Errorf("Unexpected dual-stack metrics: %v", metrics)
+	}
+	if _, ok := metrics["network.ipv6.ping_avg_ms"]; ok {
+		t.Error("Expected no IPv6 connect time without successful connects")
+	}
+}
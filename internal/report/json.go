@@ -0,0 +1,234 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"nextcloud-perf/internal/network"
+)
+
+// SchemaVersion identifies the layout of the JSON export.
+// Bump it whenever fields of ReportData are renamed, removed or change meaning.
+//
+// History:
+//   - 1: fixed small/medium/large SpeedResult fields
+//   - 2: profile-driven Scenarios list
+//   - 3: structured traceroute hops, replacing the preformatted lines
+const SchemaVersion = 3
+
+// JSONReport is the envelope written by GenerateJSON.
+type JSONReport struct {
+	SchemaVersion int        `json:"schema_version"`
+	Tool          string     `json:"tool"`
+	Report        ReportData `json:"report"`
+}
+
+// GenerateJSON renders data as an indented, versioned JSON document.
+func GenerateJSON(data ReportData) ([]byte, error) {
+	return json.MarshalIndent(JSONReport{
+		SchemaVersion: SchemaVersion,
+		Tool:          "nextcloud-perf",
+		Report:        data,
+	}, "", "  ")
+}
+
+// ParseJSON reads a document produced by GenerateJSON.
+// Older schema versions are migrated, newer ones are rejected.
+func ParseJSON(b []byte) (ReportData, error) {
+	var head struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(b, &head); err != nil {
+		return ReportData{}, fmt.Errorf("failed to parse report: %w", err)
+	}
+	if head.SchemaVersion == 0 {
+		return ReportData{}, fmt.Errorf("missing schema_version, not a nextcloud-perf JSON report")
+	}
+	if head.SchemaVersion > SchemaVersion {
+		return ReportData{}, fmt.Errorf("report schema version %d is newer than supported version %d", head.SchemaVersion, SchemaVersion)
+	}
+	if head.SchemaVersion < 3 {
+		var err error
+		if b, err = migrateTraceroute(b); err != nil {
+			return ReportData{}, fmt.Errorf("failed to parse v%d report: %w", head.SchemaVersion, err)
+		}
+	}
+
+	var doc JSONReport
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return ReportData{}, fmt.Errorf("failed to parse report: %w", err)
+	}
+	if doc.SchemaVersion == 1 {
+		var legacy struct {
+			Report struct {
+				SmallFiles      SpeedResult `json:"small_files"`
+				SmallFilesDown  SpeedResult `json:"small_files_down"`
+				MediumFiles     SpeedResult `json:"medium_files"`
+				MediumFilesDown SpeedResult `json:"medium_files_down"`
+				LargeFile       SpeedResult `json:"large_file"`
+				LargeFileDown   SpeedResult `json:"large_file_down"`
+			} `json:"report"`
+		}
+		if err := json.Unmarshal(b, &legacy); err != nil {
+			return ReportData{}, fmt.Errorf("failed to parse v1 report: %w", err)
+		}
+		l := legacy.Report
+		doc.Report.Profile = "standard"
+		doc.Report.Scenarios = []ScenarioResult{
+			{Name: "small", Label: "Small Files (5 x 512KB)", Count: 5, Size: 512 * 1024, Parallel: 5, Upload: &l.SmallFiles, Download: &l.SmallFilesDown},
+			{Name: "medium", Label: "Medium Files (3 x 5MB)", Count: 3, Size: 5 * 1024 * 1024, Parallel: 1, Upload: &l.MediumFiles, Download: &l.MediumFilesDown},
+			{Name: "large", Label: "Large File (256MB Chunked)", Count: 1, Size: 256 * 1024 * 1024, Parallel: 1, Chunked: true, Upload: &l.LargeFile, Download: &l.LargeFileDown},
+		}
+	}
+	return doc.Report, nil
+}
+
+// migrateTraceroute converts the traceroute lines of v1 and v2 reports,
+// "TTL: address (RTT)" with "*" for unanswered hops, to the structured hops
+// of v3.
+func migrateTraceroute(b []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if doc["report"] == nil {
+		return b, nil
+	}
+	var rpt map[string]json.RawMessage
+	if err := json.Unmarshal(doc["report"], &rpt); err != nil {
+		return nil, err
+	}
+	var lines []string
+	var method string
+	if raw, ok := rpt["traceroute"]; ok {
+		if err := json.Unmarshal(raw, &lines); err != nil {
+			return nil, fmt.Errorf("traceroute: %w", err)
+		}
+	}
+	if raw, ok := rpt["traceroute_method"]; ok {
+		if err := json.Unmarshal(raw, &method); err != nil {
+			return nil, fmt.Errorf("traceroute_method: %w", err)
+		}
+	}
+	delete(rpt, "traceroute")
+	delete(rpt, "traceroute_method")
+
+	if len(lines) > 0 {
+		tr := network.TracerouteResult{Method: method, Cycles: 1}
+		for _, line := range lines {
+			var h network.Hop
+			var addr, rtt string
+			if _, err := fmt.Sscanf(line, "%d: %s (%s", &h.TTL, &addr, &rtt); err != nil {
+				return nil, fmt.Errorf("traceroute line %q: %w", line, err)
+			}
+			h.Sent = 1
+			if d, err := time.ParseDuration(strings.TrimSuffix(rtt, ")")); err == nil && addr != "*" {
+				h.Address, h.Received = addr, 1
+				ms := d.Seconds() * 1000
+				h.MinMs, h.AvgMs, h.MaxMs = ms, ms, ms
+			} else {
+				h.PacketLoss = 100
+			}
+			tr.Hops = append(tr.Hops, h)
+		}
+		raw, err := json.Marshal(tr)
+		if err != nil {
+			return nil, err
+		}
+		rpt["traceroute"] = raw
+	}
+
+	var err error
+	if doc["report"], err = json.Marshal(rpt); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) describing the JSON export.
+// It is derived from the Go types so it cannot drift from GenerateJSON.
+func JSONSchema() ([]byte, error) {
+	root := schemaFor(reflect.TypeOf(JSONReport{}))
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "Nextcloud Performance Report"
+	props := root["properties"].(map[string]any)
+	props["schema_version"] = map[string]any{"const": SchemaVersion}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+func schemaFor(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]any{"type": "integer", "description": "duration in nanoseconds"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": []string{"array", "null"}, "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, omitEmpty := jsonFieldName(f)
+			if name == "-" {
+				continue
+			}
+			props[name] = schemaFor(f.Type)
+			if !omitEmpty && f.Type.Kind() != reflect.Pointer {
+				required = append(required, name)
+			}
+		}
+		s := map[string]any{"type": "object", "properties": props}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	}
+	return map[string]any{}
+}
+
+func jsonFieldName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "" {
+		return f.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	omitEmpty := false
+	for _, p := range parts[1:] {
+		if p == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty
+}
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	in := ReportData{
+		GeneratedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		TargetURL:   "https://cloud.example.com",
+		Completed:   true,
+		Scenarios: []ScenarioResult{
+			{Name: "large", Chunked: true, Upload: &SpeedResult{SpeedMBps: 42.5, Duration: 3 * time.Second}},
+		},
+	}
+
+	b, err := GenerateJSON(in)
+	if err != nil {
+		t.Fatalf("GenerateJSON failed: %v", err)
+	}
+	if !bytes.Contains(b, []byte(`"schema_version": 3`)) {
+		t.Errorf("Expected schema_version in output, got %s", b)
+	}
+
+	out, err := ParseJSON(b)
+	if err != nil {
+		t.Fatalf("ParseJSON failed: %v", err)
+	}
+	if out.TargetURL != in.TargetURL || !out.GeneratedAt.Equal(in.GeneratedAt) {
+		t.Errorf("Round trip mismatch: %+v", out)
+	}
+	if sc := out.Scenario("large"); sc == nil || sc.Upload == nil || sc.Upload.SpeedMBps != 42.5 {
+		t.Errorf("Scenario round trip mismatch: %+v", out.Scenarios)
+	}
+
+	if _, err := ParseJSON([]byte(`{"schema_version": 999, "report": {}}`)); err == nil {
+		t.Error("Expected error for newer schema version")
+	}
+}
+
+func TestParseJSONv1(t *testing.T) {
+	v1 := `{"schema_version": 1, "report": {"target_url": "https://cloud.example.com",
+		"large_file": {"speed_mbps": 12.5}, "large_file_down": {"speed_mbps": 30}}}`
+	out, err := ParseJSON([]byte(v1))
+	if err != nil {
+		t.Fatalf("ParseJSON failed: %v", err)
+	}
+	sc := out.Scenario("large")
+	if sc == nil || sc.Upload.SpeedMBps != 12.5 || sc.Download.SpeedMBps != 30 {
+		t.Errorf("v1 migration failed: %+v", out.Scenarios)
+	}
+}
+
+func TestParseJSONv2Traceroute(t *testing.T) {
+	v2 := `{"schema_version": 2, "report": {"traceroute_method": "udp",
+		"traceroute": ["1: 192.168.1.1 (1.5ms)", "2: * (0s)", "3: 203.0.113.10 (12ms)"]}}`
+	out, err := ParseJSON([]byte(v2))
+	if err != nil {
+		t.Fatalf("ParseJSON failed: %v", err)
+	}
+	tr := out.Traceroute
+	if tr == nil || tr.Method != "udp" || len(tr.Hops) != 3 {
+		t.Fatalf("v2 traceroute migration failed: %+v", tr)
+	}
+	if h := tr.Hops[0]; h.TTL != 1 || h.Address != "192.168.1.1" || h.AvgMs != 1.5 || h.Received != 1 {
+		t.Errorf("Unexpected first hop %+v", h)
+	}
+	if h := tr.Hops[1]; h.Address != "" || h.Sent != 1 || h.Received != 0 || h.PacketLoss != 100 {
+		t.Errorf("Expected an unanswered second hop, got %+v", h)
+	}
+
+	out, err = ParseJSON([]byte(`{"schema_version": 2, "report": {"traceroute": null}}`))
+	if err != nil || out.Traceroute != nil {
+		t.Errorf("Expected no traceroute, got %+v (%v)", out.Traceroute, err)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	b, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema failed: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatalf("Schema is not valid JSON: %v", err)
+	}
+	report := schema["properties"].(map[string]any)["report"].(map[string]any)
+	props := report["properties"].(map[string]any)
+	if _, ok := props["scenarios"]; !ok {
+		t.Error("Expected scenarios in schema properties")
+	}
+}
+
+func TestEventWriter(t *testing.T) {
+	var buf bytes.Buffer
+	ew := NewEventWriter(&buf)
+	if err := ew.WriteLog("Starting Benchmark..."); err != nil {
+		t.Fatal(err)
+	}
+	if err := ew.WriteResult(ReportData{TargetURL: "https://cloud.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 NDJSON lines, got %d", len(lines))
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil {
+		t.Fatalf("Invalid NDJSON line: %v", err)
+	}
+	if ev.Seq != 2 || ev.Type != EventResult || ev.Data == nil || ev.Data.TargetURL != "https://cloud.example.com" {
+		t.Errorf("Unexpected event: %+v", ev)
+	}
+}
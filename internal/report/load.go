@@ -0,0 +1,90 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"nextcloud-perf/internal/benchmark"
+)
+
+// saturationShare is the share of the peak throughput from which on the
+// instance counts as saturated.
+const saturationShare = 0.9
+
+// Ops returns the number of operations over all samples.
+func (l *LoadResult) Ops() int { return sampleSeries(l.Samples).ops() }
+
+// FailedOps returns the number of failed operations over all samples.
+func (l *LoadResult) FailedOps() int { return sampleSeries(l.Samples).failedOps() }
+
+// ErrorRate returns the failed operations of the whole run in percent.
+func (l *LoadResult) ErrorRate() float64 { return sampleSeries(l.Samples).errorRate() }
+
+// ThroughputMBps returns the mean combined throughput over all samples.
+func (l *LoadResult) ThroughputMBps() float64 { return sampleSeries(l.Samples).throughputMBps() }
+
+// WorstP95 returns the highest per-interval p95 latency.
+func (l *LoadResult) WorstP95() time.Duration { return sampleSeries(l.Samples).worstP95() }
+
+// PeakThroughputMBps returns the highest combined throughput of an interval.
+func (l *LoadResult) PeakThroughputMBps() float64 {
+	peak := 0.0
+	for _, s := range l.Samples {
+		peak = max(peak, s.ThroughputMBps())
+	}
+	return peak
+}
+
+// SaturationUsers returns the active users of the first interval that
+// reached 90% of the peak throughput, if the users kept growing by more than
+// a tenth afterwards: from there on, more users no longer added throughput.
+// Returns 0 if the throughput grew until all users were active, i.e. the
+// instance did not saturate during the run.
+func (l *LoadResult) SaturationUsers() int {
+	peak, maxUsers := l.PeakThroughputMBps(), 0
+	for _, s := range l.Samples {
+		maxUsers = max(maxUsers, s.Users)
+	}
+	if peak <= 0 {
+		return 0
+	}
+	for _, s := range l.Samples {
+		if s.ThroughputMBps() >= peak*saturationShare {
+			if float64(s.Users) < float64(maxUsers)*saturationShare {
+				return s.Users
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// UsersByP95 returns the per-user results ordered by descending p95 latency,
+// users without latency data last.
+func (l *LoadResult) UsersByP95() []benchmark.UserResult {
+	users := append([]benchmark.UserResult(nil), l.PerUser...)
+	p95 := func(u benchmark.UserResult) time.Duration {
+		if u.Latency == nil {
+			return -1
+		}
+		return u.Latency.P95
+	}
+	sort.SliceStable(users, func(i, j int) bool { return p95(users[i]) > p95(users[j]) })
+	return users
+}
+
+// UserP95Median returns the median of the per-user p95 latencies, the
+// latency a typical user sees in their slowest requests.
+func (l *LoadResult) UserP95Median() time.Duration {
+	var p95s []time.Duration
+	for _, u := range l.PerUser {
+		if u.Latency != nil {
+			p95s = append(p95s, u.Latency.P95)
+		}
+	}
+	if len(p95s) == 0 {
+		return 0
+	}
+	sort.Slice(p95s, func(i, j int) bool { return p95s[i] < p95s[j] })
+	return p95s[len(p95s)/2]
+}
@@ -0,0 +1,214 @@
+package report
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"nextcloud-perf/internal/benchmark"
+)
+
+// Metric is a single comparable number extracted from a report.
+// Keys are stable across runs so that reports can be compared and checked.
+type Metric struct {
+	Key            string  `json:"key"` // e.g. "scenario.small.upload.speed_mbps"
+	Label          string  `json:"label"`
+	Unit           string  `json:"unit"`
+	Value          float64 `json:"value"`
+	HigherIsBetter bool    `json:"higher_is_better"`
+}
+
+// MetricKeys lists the keys Metrics can return as path.Match patterns, "*"
+// stands for the name of a scenario, address family, endpoint or protocol.
+var MetricKeys = []string{
+	"network.ping_avg_ms", "network.ping_max_ms", "network.packet_loss",
+	"network.icmp_ping_avg_ms", "network.icmp_ping_max_ms", "network.icmp_packet_loss",
+	"network.dns_ms", "network.*.dns_ms", "network.*.ping_avg_ms", "network.*.packet_loss",
+	"network.dual_stack_connect_ms", "network.fallback_delay_ms", "network.path_mtu", "network.tls_handshake_ms",
+	"endpoint.*.ttfb_ms", "endpoint.*.total_ms",
+	"speedtest.upload_mbps", "speedtest.download_mbps",
+	"client.disk_write_mbps", "client.disk_read_mbps",
+	"scenario.*.upload.speed_mbps", "scenario.*.upload.p*_ms",
+	"scenario.*.download.speed_mbps", "scenario.*.download.p*_ms", "scenario.*.download.corrupted",
+	"scenario.*.sequential.speed_mbps",
+	"metadata.depth_*.p*_ms",
+	"sync.initial_ms", "sync.idle_ms", "sync.cycle_ms", "sync.cycle_max_ms",
+	"protocol.*.*_mbps", "protocol.*.upload.p*_ms", "protocol.*.download.p*_ms",
+	"soak.throughput_mbps", "soak.p95_ms", "soak.error_rate", "soak.throughput_drift",
+	"load.throughput_mbps", "load.peak_throughput_mbps", "load.saturation_users",
+	"load.p95_ms", "load.user_p95_ms", "load.error_rate",
+}
+
+// IsMetricKey reports whether key matches one of MetricKeys. Patterns are
+// not keys themselves, so keys containing "*" are rejected.
+func IsMetricKey(key string) bool {
+	if strings.Contains(key, "*") {
+		return false
+	}
+	for _, pattern := range MetricKeys {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Metrics returns all measured values of a report in display order.
+// Values that were not measured (failed or skipped) are omitted.
+func Metrics(r ReportData) []Metric {
+	var m []Metric
+	add := func(key, label, unit string, value float64, higherIsBetter bool) {
+		m = append(m, Metric{Key: key, Label: label, Unit: unit, Value: value, HigherIsBetter: higherIsBetter})
+	}
+
+	if r.PingStats.SuccessCount > 0 {
+		add("network.ping_avg_ms", "TCP Connect (avg)", "ms", r.PingStats.AvgMs, false)
+		add("network.ping_max_ms", "TCP Connect (max)", "ms", r.PingStats.MaxMs, false)
+	}
+	if r.PingStats.Count > 0 {
+		add("network.packet_loss", "Packet Loss", "%", r.PingStats.PacketLoss, false)
+	}
+	if r.ICMPPing != nil && r.ICMPPing.SuccessCount > 0 {
+		add("network.icmp_ping_avg_ms", "ICMP Ping (avg)", "ms", r.ICMPPing.AvgMs, false)
+		add("network.icmp_ping_max_ms", "ICMP Ping (max)", "ms", r.ICMPPing.MaxMs, false)
+	}
+	if r.ICMPPing != nil && r.ICMPPing.Count > 0 {
+		add("network.icmp_packet_loss", "ICMP Packet Loss", "%", r.ICMPPing.PacketLoss, false)
+	}
+	if r.DNS.Error == "" && r.DNS.ResolutionTime > 0 {
+		add("network.dns_ms", "DNS Resolution", "ms", r.DNS.ResolutionTime, false)
+	}
+	if r.DualStack != nil {
+		for _, f := range r.DualStack.Families {
+			name := f.Name()
+			prefix := "network." + strings.ToLower(name)
+			if f.DNS.Error == "" {
+				add(prefix+".dns_ms", name+" DNS Resolution", "ms", f.DNS.ResolutionTime, false)
+			}
+			if f.Ping != nil && f.Ping.SuccessCount > 0 {
+				add(prefix+".ping_avg_ms", name+" TCP Connect (avg)", "ms", f.Ping.AvgMs, false)
+			}
+			if f.Ping != nil && f.Ping.Count > 0 {
+				add(prefix+".packet_loss", name+" Packet Loss", "%", f.Ping.PacketLoss, false)
+			}
+		}
+		if he := r.DualStack.HappyEyeballs; he != nil && he.Count > len(he.Errors) {
+			add("network.dual_stack_connect_ms", "Dual-Stack Connect (avg)", "ms", he.AvgMs, false)
+		}
+		if penalty := r.DualStack.FallbackPenaltyMs(); penalty != nil {
+			add("network.fallback_delay_ms", "Dual-Stack Fallback Delay", "ms", *penalty, false)
+		}
+	}
+	if r.PathMTU != nil && r.PathMTU.PathMTU > 0 {
+		add("network.path_mtu", "Path MTU", "B", float64(r.PathMTU.PathMTU), true)
+	}
+	if r.AdvancedNet.TLSHandshakeMs > 0 {
+		add("network.tls_handshake_ms", "TLS Handshake", "ms", r.AdvancedNet.TLSHandshakeMs, false)
+	}
+	for _, ep := range r.Endpoints {
+		if ep.Samples == 0 {
+			continue
+		}
+		prefix := "endpoint." + ep.Name
+		add(prefix+".ttfb_ms", ep.Path+" TTFB (median)", "ms", durationMs(ep.Phase(benchmark.RequestTTFB).Median), false)
+		add(prefix+".total_ms", ep.Path+" Request (median)", "ms", durationMs(ep.Total.Median), false)
+	}
+	if r.Speedtest != nil {
+		add("speedtest.upload_mbps", "Speedtest Upload", "MB/s", r.Speedtest.UploadMBps, true)
+		add("speedtest.download_mbps", "Speedtest Download", "MB/s", r.Speedtest.DownloadMBps, true)
+	}
+	if r.DiskIO.WriteMBps > 0 {
+		add("client.disk_write_mbps", "Client Disk Write", "MB/s", r.DiskIO.WriteMBps, true)
+		add("client.disk_read_mbps", "Client Disk Read", "MB/s", r.DiskIO.ReadMBps, true)
+	}
+
+	for _, sc := range r.Scenarios {
+		for _, dir := range []struct {
+			name string
+			res  *SpeedResult
+		}{{"upload", sc.Upload}, {"download", sc.Download}} {
+			if dir.res == nil || dir.res.SpeedMBps <= 0 {
+				continue
+			}
+			prefix := fmt.Sprintf("scenario.%s.%s", sc.Name, dir.name)
+			label := fmt.Sprintf("%s %s", sc.Label, dir.name)
+			add(prefix+".speed_mbps", label, "MB/s", dir.res.SpeedMBps, true)
+			addLatency(add, prefix, label, dir.res.Latency)
+		}
+		if sc.Download != nil && sc.Download.SpeedMBps > 0 {
+			add(fmt.Sprintf("scenario.%s.download.corrupted", sc.Name), sc.Label+" corrupted downloads", "files", float64(sc.Download.Corrupted), false)
+		}
+		if sc.Sequential != nil && sc.Sequential.SpeedMBps > 0 {
+			add(fmt.Sprintf("scenario.%s.sequential.speed_mbps", sc.Name), sc.Label+" sequential upload", "MB/s", sc.Sequential.SpeedMBps, true)
+		}
+	}
+
+	if r.Metadata != nil {
+		for _, l := range r.Metadata.Listings {
+			if len(l.Errors) > 0 {
+				continue
+			}
+			addLatency(add, "metadata.depth_"+l.Depth, "PROPFIND depth "+l.Depth, l.Latency)
+		}
+	}
+
+	if r.Sync != nil && len(r.Sync.Cycles) > 0 {
+		add("sync.initial_ms", "Initial Sync", "ms", durationMs(r.Sync.Initial.Duration), false)
+		add("sync.idle_ms", "Sync Change Check (idle)", "ms", durationMs(r.Sync.Idle), false)
+		add("sync.cycle_ms", "Sync Cycle (median)", "ms", durationMs(r.Sync.CycleMedian()), false)
+		add("sync.cycle_max_ms", "Sync Cycle (max)", "ms", durationMs(r.Sync.CycleMax()), false)
+	}
+
+	if r.Protocols != nil {
+		for _, run := range r.Protocols.Runs {
+			for _, w := range ProtocolWorkloads {
+				if res := run.Speed(w); res != nil && res.SpeedMBps > 0 {
+					add(fmt.Sprintf("protocol.%s.%s_mbps", run.MetricName(), w), fmt.Sprintf("%s %s", run.Protocol, strings.ReplaceAll(w, "_", " ")), "MB/s", res.SpeedMBps, true)
+				}
+			}
+			for _, w := range []string{ProtocolUpload, ProtocolDownload} {
+				if res := run.Speed(w); res != nil {
+					addLatency(add, fmt.Sprintf("protocol.%s.%s", run.MetricName(), w), run.Protocol+" "+w, res.Latency)
+				}
+			}
+		}
+	}
+
+	if r.Soak != nil && r.Soak.Ops() > 0 {
+		add("soak.throughput_mbps", "Soak Throughput (avg)", "MB/s", r.Soak.ThroughputMBps(), true)
+		add("soak.p95_ms", "Soak p95 (worst interval)", "ms", durationMs(r.Soak.WorstP95()), false)
+		add("soak.error_rate", "Soak Error Rate", "%", r.Soak.ErrorRate(), false)
+		if drift := r.Soak.ThroughputDrift(); drift != nil {
+			add("soak.throughput_drift", "Soak Throughput Drift", "%", *drift, true)
+		}
+	}
+
+	if r.Load != nil && r.Load.Ops() > 0 {
+		add("load.throughput_mbps", "Load Throughput (avg)", "MB/s", r.Load.ThroughputMBps(), true)
+		add("load.peak_throughput_mbps", "Load Throughput (peak)", "MB/s", r.Load.PeakThroughputMBps(), true)
+		// Without saturation the instance handled at least all tested users
+		saturation := r.Load.SaturationUsers()
+		if saturation == 0 {
+			saturation = r.Load.Users
+		}
+		add("load.saturation_users", "Load Saturation Point", "users", float64(saturation), true)
+		add("load.p95_ms", "Load p95 (worst interval)", "ms", durationMs(r.Load.WorstP95()), false)
+		add("load.user_p95_ms", "Load p95 per user (median)", "ms", durationMs(r.Load.UserP95Median()), false)
+		add("load.error_rate", "Load Error Rate", "%", r.Load.ErrorRate(), false)
+	}
+	return m
+}
+
+func addLatency(add func(key, label, unit string, value float64, higherIsBetter bool), prefix, label string, l *benchmark.LatencyStats) {
+	if l == nil || l.Count == 0 {
+		return
+	}
+	add(prefix+".p50_ms", label+" p50", "ms", durationMs(l.P50), false)
+	add(prefix+".p95_ms", label+" p95", "ms", durationMs(l.P95), false)
+	add(prefix+".p99_ms", label+" p99", "ms", durationMs(l.P99), false)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
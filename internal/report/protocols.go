@@ -0,0 +1,64 @@
+package report
+
+import "strings"
+
+// Workloads of a ProtocolRunResult, used as keys by Speed and Change.
+const (
+	ProtocolUpload        = "upload"
+	ProtocolDownload      = "download"
+	ProtocolLargeUpload   = "large_upload"
+	ProtocolLargeDownload = "large_download"
+)
+
+// ProtocolWorkloads lists the workloads of a protocol run in display order.
+var ProtocolWorkloads = []string{ProtocolUpload, ProtocolDownload, ProtocolLargeUpload, ProtocolLargeDownload}
+
+// Speed returns the result of one workload, or nil if it did not run.
+func (r ProtocolRunResult) Speed(workload string) *SpeedResult {
+	switch workload {
+	case ProtocolUpload:
+		return r.Upload
+	case ProtocolDownload:
+		return r.Download
+	case ProtocolLargeUpload:
+		return r.LargeUpload
+	case ProtocolLargeDownload:
+		return r.LargeDownload
+	}
+	return nil
+}
+
+// MetricName returns the protocol as used in metric keys: http1, h2 or h3.
+func (r ProtocolRunResult) MetricName() string {
+	if strings.HasPrefix(r.Protocol, "http/1") {
+		return "http1"
+	}
+	return r.Protocol
+}
+
+// Baseline returns the first run with results, usually HTTP/1.1, that the
+// other protocols are compared against. Returns nil if no protocol worked.
+func (p *ProtocolResult) Baseline() *ProtocolRunResult {
+	for i := range p.Runs {
+		if p.Runs[i].Upload != nil && p.Runs[i].Upload.SpeedMBps > 0 {
+			return &p.Runs[i]
+		}
+	}
+	return nil
+}
+
+// Change returns how much faster the workload ran over r than over the
+// baseline, in percent. Returns nil for the baseline itself and if either
+// speed is missing.
+func (p *ProtocolResult) Change(r ProtocolRunResult, workload string) *float64 {
+	base := p.Baseline()
+	if base == nil || base.Protocol == r.Protocol {
+		return nil
+	}
+	b, s := base.Speed(workload), r.Speed(workload)
+	if b == nil || s == nil || b.SpeedMBps <= 0 || s.SpeedMBps <= 0 {
+		return nil
+	}
+	change := (s.SpeedMBps/b.SpeedMBps - 1) * 100
+	return &change
+}
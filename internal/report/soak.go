@@ -0,0 +1,38 @@
+package report
+
+import "time"
+
+// Ops returns the number of operations over all samples.
+func (s *SoakResult) Ops() int { return sampleSeries(s.Samples).ops() }
+
+// FailedOps returns the number of failed operations over all samples.
+func (s *SoakResult) FailedOps() int { return sampleSeries(s.Samples).failedOps() }
+
+// ErrorRate returns the failed operations of the whole run in percent.
+func (s *SoakResult) ErrorRate() float64 { return sampleSeries(s.Samples).errorRate() }
+
+// ThroughputMBps returns the mean combined throughput over all samples.
+func (s *SoakResult) ThroughputMBps() float64 { return sampleSeries(s.Samples).throughputMBps() }
+
+// WorstP95 returns the highest per-interval p95 latency.
+func (s *SoakResult) WorstP95() time.Duration { return sampleSeries(s.Samples).worstP95() }
+
+// ThroughputDrift compares the mean throughput of the last third of the
+// samples with the first third, in percent; negative values mean the server
+// got slower. Returns nil with fewer than three samples.
+func (s *SoakResult) ThroughputDrift() *float64 {
+	n := len(s.Samples) / 3
+	if n == 0 {
+		return nil
+	}
+	var first, last float64
+	for i := 0; i < n; i++ {
+		first += s.Samples[i].ThroughputMBps()
+		last += s.Samples[len(s.Samples)-n+i].ThroughputMBps()
+	}
+	if first <= 0 {
+		return nil
+	}
+	drift := (last/first - 1) * 100
+	return &drift
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"nextcloud-perf/internal/benchmark"
+)
+
+// CycleMedian returns the median sync cycle time of the incremental cycles.
+func (s *SyncResult) CycleMedian() time.Duration {
+	if len(s.Cycles) == 0 {
+		return 0
+	}
+	d := make([]time.Duration, len(s.Cycles))
+	for i, c := range s.Cycles {
+		d[i] = c.Duration
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	return d[len(d)/2]
+}
+
+// CycleMax returns the slowest incremental sync cycle.
+func (s *SyncResult) CycleMax() time.Duration {
+	var m time.Duration
+	for _, c := range s.Cycles {
+		m = max(m, c.Duration)
+	}
+	return m
+}
+
+// Conflicts returns the number of conflicts detected over all cycles. Each
+// cycle provokes one; fewer mean the server accepted stale uploads.
+func (s *SyncResult) Conflicts() int {
+	n := 0
+	for _, c := range s.Cycles {
+		n += c.Conflicts
+	}
+	return n
+}
+
+// PhaseMeans returns the phases of the incremental cycles in execution order
+// with their duration, requests and items averaged over all cycles and their
+// errors summed up.
+func (s *SyncResult) PhaseMeans() []benchmark.SyncPhase {
+	var out []benchmark.SyncPhase
+	index := map[string]int{}
+	counts := map[string]int{}
+	for _, c := range s.Cycles {
+		for _, p := range c.Phases {
+			i, ok := index[p.Name]
+			if !ok {
+				i = len(out)
+				index[p.Name] = i
+				out = append(out, benchmark.SyncPhase{Name: p.Name})
+			}
+			out[i].Duration += p.Duration
+			out[i].Requests += p.Requests
+			out[i].Items += p.Items
+			out[i].Errors += p.Errors
+			counts[p.Name]++
+		}
+	}
+	for i := range out {
+		n := counts[out[i].Name]
+		out[i].Duration /= time.Duration(n)
+		out[i].Requests /= n
+		out[i].Items /= n
+	}
+	return out
+}
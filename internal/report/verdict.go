@@ -0,0 +1,93 @@
+package report
+
+import (
+	"fmt"
+	"path"
+
+	"nextcloud-perf/internal/config"
+)
+
+// RuleResult is the outcome of one threshold rule for one metric.
+type RuleResult struct {
+	Rule    string   `json:"rule"`
+	Metric  string   `json:"metric"`
+	Label   string   `json:"label"`
+	Unit    string   `json:"unit"`
+	Value   *float64 `json:"value,omitempty"` // nil if the metric was not measured
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+	Passed  bool     `json:"passed"`
+	Message string   `json:"message"`
+}
+
+// Verdict is the pass/fail result of evaluating thresholds against a report.
+type Verdict struct {
+	Thresholds string       `json:"thresholds"` // Name of the thresholds set
+	Passed     bool         `json:"passed"`
+	Results    []RuleResult `json:"results"`
+}
+
+// Failed returns the rule results that did not pass.
+func (v *Verdict) Failed() []RuleResult {
+	var out []RuleResult
+	for _, r := range v.Results {
+		if !r.Passed {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Evaluate checks every rule of t against the metrics of r. A rule whose
+// metric was not measured fails unless it is optional; a failed benchmark
+// fails the verdict as a whole.
+func Evaluate(t *config.Thresholds, r ReportData) *Verdict {
+	v := &Verdict{Thresholds: t.Name, Passed: true}
+	metrics := Metrics(r)
+
+	if r.Error != "" {
+		v.Passed = false
+		v.Results = append(v.Results, RuleResult{Rule: "benchmark", Label: "Benchmark completed", Message: r.Error})
+	}
+
+	for _, rule := range t.Rules {
+		matched := false
+		for _, m := range metrics {
+			if ok, _ := path.Match(rule.Metric, m.Key); !ok {
+				continue
+			}
+			matched = true
+			res := checkRule(rule, m)
+			v.Passed = v.Passed && res.Passed
+			v.Results = append(v.Results, res)
+		}
+		if !matched {
+			res := RuleResult{Rule: rule.DisplayName(), Metric: rule.Metric, Label: rule.Metric, Min: rule.Min, Max: rule.Max, Passed: rule.Optional}
+			if rule.Optional {
+				res.Message = "not measured (optional)"
+			} else {
+				res.Message = "not measured"
+			}
+			v.Passed = v.Passed && res.Passed
+			v.Results = append(v.Results, res)
+		}
+	}
+	return v
+}
+
+func checkRule(rule config.ThresholdRule, m Metric) RuleResult {
+	value := m.Value
+	res := RuleResult{Rule: rule.DisplayName(), Metric: m.Key, Label: m.Label, Unit: m.Unit,
+		Value: &value, Min: rule.Min, Max: rule.Max, Passed: true}
+	switch {
+	case rule.Min != nil && value < *rule.Min:
+		res.Passed = false
+		res.Message = fmt.Sprintf("%.2f %s is below minimum %.2f", value, m.Unit, *rule.Min)
+	case rule.Max != nil && value > *rule.Max:
+		res.Passed = false
+		res.Message = fmt.Sprintf("%.2f %s exceeds maximum %.2f", value, m.Unit, *rule.Max)
+	default:
+		res.Message = "ok"
+	}
+	return res
+}
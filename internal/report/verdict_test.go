@@ -0,0 +1,82 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"nextcloud-perf/internal/config"
+	"nextcloud-perf/internal/network"
+)
+
+func TestEvaluate(t *testing.T) {
+	rpt := ReportData{
+		PingStats: network.DetailedPingStats{Count: 10, SuccessCount: 9, AvgMs: 20, PacketLoss: 10},
+		Scenarios: []ScenarioResult{
+			{Name: "small", Label: "Small Files", Upload: &SpeedResult{SpeedMBps: 10}},
+			{Name: "large", Label: "Large File", Upload: &SpeedResult{SpeedMBps: 100}},
+		},
+	}
+	f := func(v float64) *float64 { return &v }
+
+	th := &config.Thresholds{Name: "ci", Rules: []config.ThresholdRule{
+		{Metric: "scenario.*.upload.speed_mbps", Min: f(50)},
+		{Metric: "network.packet_loss", Max: f(1)},
+		{Metric: "network.ping_avg_ms", Max: f(50)},
+		{Metric: "network.tls_handshake_ms", Max: f(200), Optional: true},
+	}}
+	v := Evaluate(th, rpt)
+	if v.Passed {
+		t.Fatal("Expected verdict to fail")
+	}
+	if len(v.Results) != 5 {
+		t.Fatalf("Expected 5 results (wildcard matches 2), got %d: %+v", len(v.Results), v.Results)
+	}
+
+	passed := map[string]bool{}
+	for _, r := range v.Results {
+		passed[r.Metric] = r.Passed
+	}
+	want := map[string]bool{
+		"scenario.small.upload.speed_mbps": false,
+		"scenario.large.upload.speed_mbps": true,
+		"network.packet_loss":              false,
+		"network.ping_avg_ms":              true,
+		"network.tls_handshake_ms":         true,
+	}
+	for key, ok := range want {
+		if passed[key] != ok {
+			t.Errorf("%s: expected passed=%v, got %v", key, ok, passed[key])
+		}
+	}
+	if len(v.Failed()) != 2 {
+		t.Errorf("Expected 2 failed rules, got %d", len(v.Failed()))
+	}
+
+	// Unmeasured metrics fail unless optional
+	v = Evaluate(&config.Thresholds{Rules: []config.ThresholdRule{{Metric: "speedtest.upload_mbps", Min: f(1)}}}, rpt)
+	if v.Passed {
+		t.Error("Expected missing required metric to fail")
+	}
+
+	rpt.Error = "Pre-flight Error"
+	v = Evaluate(&config.Thresholds{Rules: []config.ThresholdRule{{Metric: "network.ping_avg_ms", Max: f(50)}}}, rpt)
+	if v.Passed {
+		t.Error("Expected failed benchmark to fail the verdict")
+	}
+}
+
+func TestGenerateHTMLWithVerdict(t *testing.T) {
+	value := 12.0
+	rpt := ReportData{Verdict: &Verdict{Thresholds: "ci", Results: []RuleResult{
+		{Rule: "Large upload", Label: "Large File upload", Unit: "MB/s", Value: &value, Message: "12.00 MB/s is below minimum 50.00"},
+	}}}
+	html, err := GenerateHTML(rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Threshold Check", "Large upload", "below minimum 50.00"} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+}
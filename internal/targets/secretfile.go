@@ -0,0 +1,125 @@
+package targets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrBadPassphrase is returned if the secrets file cannot be decrypted.
+var ErrBadPassphrase = errors.New("wrong passphrase or corrupted secrets file")
+
+// kdfIterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256.
+const kdfIterations = 600_000
+
+// secretFile is the on-disk format of secrets.enc: a JSON map of target
+// name to password, sealed with AES-256-GCM under a PBKDF2-derived key.
+type secretFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+func deriveKey(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func getPassphrase(passphrase PassphraseFunc) (string, error) {
+	if passphrase == nil {
+		return "", ErrNoPassphrase
+	}
+	p, err := passphrase()
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", ErrNoPassphrase
+	}
+	return p, nil
+}
+
+// readSecrets decrypts secrets.enc. A missing file is an empty map, but the
+// passphrase is still required so that a new file is never written without one.
+func (s *Store) readSecrets(passphrase PassphraseFunc) (map[string]string, error) {
+	pass, err := getPassphrase(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filepath.Join(s.dir, secretsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f secretFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", secretsFile, err)
+	}
+	if f.Version != 1 || f.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("unsupported %s format (version %d, %s)", secretsFile, f.Version, f.KDF)
+	}
+	aead, err := deriveKey(pass, f.Salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+// writeSecrets encrypts secrets with a fresh salt and nonce.
+func (s *Store) writeSecrets(secrets map[string]string, passphrase PassphraseFunc) error {
+	pass, err := getPassphrase(passphrase)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	f := secretFile{Version: 1, KDF: "pbkdf2-sha256", Iterations: kdfIterations, Salt: make([]byte, 16)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	aead, err := deriveKey(pass, f.Salt, f.Iterations)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Data = aead.Seal(nil, f.Nonce, plain, nil)
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, secretsFile), b)
+}
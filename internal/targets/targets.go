@@ -0,0 +1,328 @@
+// Package targets stores named benchmark targets (URL, user, profile) so that
+// they can be re-run without entering credentials again. Passwords are kept
+// in the OS keyring or, where no keyring is available, in a local file
+// encrypted with a passphrase; the target list itself holds no secrets.
+package targets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zalando/go-keyring"
+
+	"nextcloud-perf/internal/config"
+)
+
+// Storage backends for target passwords.
+const (
+	StorageKeyring = "keyring" // OS keyring (Secret Service, macOS Keychain, Windows Credential Manager)
+	StorageFile    = "file"    // secrets.enc, encrypted with a passphrase
+)
+
+// PassphraseEnv supplies the passphrase of the encrypted secrets file.
+const PassphraseEnv = "NEXTCLOUD_PERF_PASSPHRASE"
+
+// keyringService is the service name of all keyring entries.
+const keyringService = "nextcloud-perf"
+
+const (
+	indexFile   = "targets.json"
+	secretsFile = "secrets.enc"
+)
+
+var (
+	// ErrNotFound is returned for unknown target names.
+	ErrNotFound = errors.New("target not found")
+	// ErrNoPassphrase is returned if the encrypted file is needed but no passphrase was given.
+	ErrNoPassphrase = errors.New("a passphrase is required for the encrypted secrets file (set " + PassphraseEnv + ")")
+	// ErrURLMismatch is returned if a run overrides the URL of a target whose
+	// stored password it would use: the password only goes to its own server.
+	ErrURLMismatch = errors.New("URL differs from the saved target; its stored password is only sent to the saved URL")
+)
+
+// validName keeps names usable as keyring keys, CLI arguments and in URLs.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Target is a saved benchmark target without its password.
+type Target struct {
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	User      string    `json:"user"`
+	Profile   string    `json:"profile,omitempty"` // Default profile for runs of this target
+	Storage   string    `json:"storage"`           // StorageKeyring or StorageFile
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks the fields a caller has to provide.
+func (t Target) Validate() error {
+	if !validName.MatchString(t.Name) {
+		return fmt.Errorf("invalid target name %q (letters, digits, '.', '_' and '-', max 64 chars)", t.Name)
+	}
+	if t.URL == "" {
+		return errors.New("URL is required")
+	}
+	if t.User == "" {
+		return errors.New("username is required")
+	}
+	return nil
+}
+
+// CheckURL returns ErrURLMismatch if url is set and is not the URL of t.
+func (t Target) CheckURL(url string) error {
+	if url != "" && strings.TrimRight(url, "/") != strings.TrimRight(t.URL, "/") {
+		return ErrURLMismatch
+	}
+	return nil
+}
+
+// PassphraseFunc returns the passphrase of the encrypted secrets file. It is
+// only called if that file is actually needed.
+type PassphraseFunc func() (string, error)
+
+// EnvPassphrase reads the passphrase from PassphraseEnv.
+func EnvPassphrase() (string, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+	return "", ErrNoPassphrase
+}
+
+// Keyring is the subset of the OS keyring used by the store.
+type Keyring interface {
+	Get(service, user string) (string, error)
+	Set(service, user, password string) error
+	Delete(service, user string) error
+}
+
+type osKeyring struct{}
+
+func (osKeyring) Get(service, user string) (string, error) { return keyring.Get(service, user) }
+func (osKeyring) Set(service, user, pass string) error     { return keyring.Set(service, user, pass) }
+func (osKeyring) Delete(service, user string) error        { return keyring.Delete(service, user) }
+
+// Store keeps the target list and the encrypted secrets file in a directory.
+type Store struct {
+	dir     string
+	keyring Keyring
+	mu      sync.Mutex
+}
+
+// DefaultDir is the targets directory below config.DataDir.
+func DefaultDir() string {
+	return filepath.Join(config.DataDir(), "targets")
+}
+
+// Open creates dir if needed and returns a store using the OS keyring.
+func Open(dir string) (*Store, error) {
+	return OpenWithKeyring(dir, osKeyring{})
+}
+
+// OpenWithKeyring is like Open with a custom keyring, e.g. for tests.
+func OpenWithKeyring(dir string, kr Keyring) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create targets directory: %w", err)
+	}
+	return &Store{dir: dir, keyring: kr}, nil
+}
+
+// Dir returns the directory of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// List returns all targets sorted by name.
+func (s *Store) List() ([]Target, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Get returns the target with the given name.
+func (s *Store) Get(name string) (Target, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.load()
+	if err != nil {
+		return Target{}, err
+	}
+	i := find(list, name)
+	if i < 0 {
+		return Target{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return list[i], nil
+}
+
+// Save adds or replaces a target and stores its password. With storage ""
+// the keyring is tried first and the encrypted file is the fallback.
+func (s *Store) Save(t Target, password, storage string, passphrase PassphraseFunc) (Target, error) {
+	if err := t.Validate(); err != nil {
+		return Target{}, err
+	}
+	if password == "" {
+		return Target{}, errors.New("password is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.load()
+	if err != nil {
+		return Target{}, err
+	}
+
+	switch storage {
+	case StorageKeyring:
+		if err := s.keyring.Set(keyringService, t.Name, password); err != nil {
+			return Target{}, fmt.Errorf("failed to store password in the OS keyring: %w", err)
+		}
+	case StorageFile:
+		if err := s.setFileSecret(t.Name, password, passphrase); err != nil {
+			return Target{}, err
+		}
+	case "":
+		storage = StorageKeyring
+		if kerr := s.keyring.Set(keyringService, t.Name, password); kerr != nil {
+			storage = StorageFile
+			if err := s.setFileSecret(t.Name, password, passphrase); err != nil {
+				return Target{}, fmt.Errorf("OS keyring unavailable (%v), encrypted file: %w", kerr, err)
+			}
+		}
+	default:
+		return Target{}, fmt.Errorf("unknown storage %q (use %s or %s)", storage, StorageKeyring, StorageFile)
+	}
+
+	t.Storage = storage
+	t.UpdatedAt = time.Now().UTC()
+	if i := find(list, t.Name); i >= 0 {
+		if list[i].Storage != storage {
+			s.deleteSecret(list[i], passphrase) // Best effort, the old copy is unused from now on
+		}
+		list[i] = t
+	} else {
+		list = append(list, t)
+	}
+	if err := s.write(list); err != nil {
+		return Target{}, err
+	}
+	return t, nil
+}
+
+// Password returns the stored password of a target.
+func (s *Store) Password(name string, passphrase PassphraseFunc) (string, error) {
+	t, err := s.Get(name)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.Storage == StorageKeyring {
+		p, err := s.keyring.Get(keyringService, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password of %q from the OS keyring: %w", name, err)
+		}
+		return p, nil
+	}
+	secrets, err := s.readSecrets(passphrase)
+	if err != nil {
+		return "", err
+	}
+	p, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("no password stored for %q in %s", name, secretsFile)
+	}
+	return p, nil
+}
+
+// Delete removes a target and its password.
+func (s *Store) Delete(name string, passphrase PassphraseFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.load()
+	if err != nil {
+		return err
+	}
+	i := find(list, name)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err := s.deleteSecret(list[i], passphrase); err != nil {
+		return err
+	}
+	return s.write(append(list[:i], list[i+1:]...))
+}
+
+func (s *Store) deleteSecret(t Target, passphrase PassphraseFunc) error {
+	if t.Storage == StorageKeyring {
+		if err := s.keyring.Delete(keyringService, t.Name); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return fmt.Errorf("failed to delete password from the OS keyring: %w", err)
+		}
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, secretsFile)); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	secrets, err := s.readSecrets(passphrase)
+	if err != nil {
+		return err
+	}
+	delete(secrets, t.Name)
+	return s.writeSecrets(secrets, passphrase)
+}
+
+func (s *Store) setFileSecret(name, password string, passphrase PassphraseFunc) error {
+	secrets, err := s.readSecrets(passphrase)
+	if err != nil {
+		return err
+	}
+	secrets[name] = password
+	return s.writeSecrets(secrets, passphrase)
+}
+
+func find(list []Target, name string) int {
+	for i, t := range list {
+		if t.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Store) load() ([]Target, error) {
+	b, err := os.ReadFile(filepath.Join(s.dir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Target
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", indexFile, err)
+	}
+	return list, nil
+}
+
+func (s *Store) write(list []Target) error {
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, indexFile), b)
+}
+
+// writeFileAtomic replaces path via a temporary file so readers never see a partial file.
+func writeFileAtomic(path string, b []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,141 @@
+package targets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+type memKeyring struct {
+	entries map[string]string
+	err     error // Returned by every call if set, like a missing Secret Service
+}
+
+func (k *memKeyring) Get(service, user string) (string, error) {
+	if k.err != nil {
+		return "", k.err
+	}
+	p, ok := k.entries[service+"/"+user]
+	if !ok {
+		return "", keyring.ErrNotFound
+	}
+	return p, nil
+}
+
+func (k *memKeyring) Set(service, user, pass string) error {
+	if k.err != nil {
+		return k.err
+	}
+	k.entries[service+"/"+user] = pass
+	return nil
+}
+
+func (k *memKeyring) Delete(service, user string) error {
+	if k.err != nil {
+		return k.err
+	}
+	if _, ok := k.entries[service+"/"+user]; !ok {
+		return keyring.ErrNotFound
+	}
+	delete(k.entries, service+"/"+user)
+	return nil
+}
+
+func passphrase(p string) PassphraseFunc {
+	return func() (string, error) { return p, nil }
+}
+
+func TestKeyringStorage(t *testing.T) {
+	kr := &memKeyring{entries: map[string]string{}}
+	store, err := OpenWithKeyring(t.TempDir(), kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := store.Save(Target{Name: "prod", URL: "https://cloud.example.com", User: "admin", Profile: "quick"}, "s3cret", "", nil)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if saved.Storage != StorageKeyring {
+		t.Errorf("Expected keyring storage, got %q", saved.Storage)
+	}
+	pass, err := store.Password("prod", nil)
+	if err != nil || pass != "s3cret" {
+		t.Errorf("Expected s3cret, got %q (%v)", pass, err)
+	}
+
+	// The target list must not contain the password
+	b, _ := os.ReadFile(filepath.Join(store.Dir(), indexFile))
+	if strings.Contains(string(b), "s3cret") {
+		t.Error("Expected no password in the target list")
+	}
+
+	if err := store.Delete("prod", nil); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if len(kr.entries) != 0 {
+		t.Errorf("Expected keyring entry to be deleted, got %v", kr.entries)
+	}
+	if _, err := store.Get("prod"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestEncryptedFileFallback(t *testing.T) {
+	kr := &memKeyring{err: errors.New("no secret service")}
+	store, err := OpenWithKeyring(t.TempDir(), kr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := Target{Name: "staging", URL: "https://staging.example.com", User: "bench"}
+
+	if _, err := store.Save(target, "pw1", "", nil); !errors.Is(err, ErrNoPassphrase) {
+		t.Fatalf("Expected ErrNoPassphrase without keyring and passphrase, got %v", err)
+	}
+	saved, err := store.Save(target, "pw1", "", passphrase("correct horse"))
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if saved.Storage != StorageFile {
+		t.Errorf("Expected file storage, got %q", saved.Storage)
+	}
+	if _, err := store.Save(Target{Name: "other", URL: "https://other.example.com", User: "x"}, "pw2", StorageFile, passphrase("correct horse")); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	b, _ := os.ReadFile(filepath.Join(store.Dir(), secretsFile))
+	if strings.Contains(string(b), "pw1") {
+		t.Error("Expected the secrets file to be encrypted")
+	}
+	if _, err := store.Password("staging", passphrase("wrong")); !errors.Is(err, ErrBadPassphrase) {
+		t.Errorf("Expected ErrBadPassphrase, got %v", err)
+	}
+	for name, want := range map[string]string{"staging": "pw1", "other": "pw2"} {
+		if pass, err := store.Password(name, passphrase("correct horse")); err != nil || pass != want {
+			t.Errorf("%s: expected %q, got %q (%v)", name, want, pass, err)
+		}
+	}
+
+	if err := store.Delete("staging", passphrase("correct horse")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	list, _ := store.List()
+	if len(list) != 1 || list[0].Name != "other" {
+		t.Errorf("Expected only 'other' to remain, got %+v", list)
+	}
+}
+
+func TestTargetValidate(t *testing.T) {
+	for _, name := range []string{"", "../etc", "a b", strings.Repeat("x", 65)} {
+		if err := (Target{Name: name, URL: "https://x", User: "u"}).Validate(); err == nil {
+			t.Errorf("Expected error for name %q", name)
+		}
+	}
+	if err := (Target{Name: "ok-1.2_3", URL: "https://x", User: "u"}).Validate(); err != nil {
+		t.Errorf("Expected valid target, got %v", err)
+	}
+}
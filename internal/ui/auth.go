@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"nextcloud-perf/internal/webdav"
+)
+
+// loginTTL matches the lifetime of a Login Flow v2 token on the server.
+// App passwords of logins that were never used for a run are revoked after it.
+const loginTTL = 20 * time.Minute
+
+// browserLogin is a Login Flow v2 started from the UI. The app password
+// never leaves the tool; the browser only refers to it by id.
+type browserLogin struct {
+	url     string
+	client  *webdav.Client
+	flow    *webdav.LoginFlow
+	creds   *webdav.LoginCredentials // Set once the user granted access
+	started time.Time
+}
+
+// revoke deletes the app password of a login that was not used for a run.
+func (l *browserLogin) revoke() {
+	if l.creds == nil {
+		return
+	}
+	client := webdav.NewClient(l.url, l.creds.LoginName, l.creds.AppPassword, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := client.RevokeAppPassword(ctx); err != nil {
+		log.Printf("Failed to revoke unused app password: %v", err)
+	}
+}
+
+// pruneLogins drops expired logins. s.loginsMu must be held.
+func (s *Server) pruneLogins() {
+	for id, l := range s.logins {
+		if time.Since(l.started) > loginTTL {
+			delete(s.logins, id)
+			go l.revoke()
+		}
+	}
+}
+
+// loginCredentials returns the credentials of a completed login for url.
+func (s *Server) loginCredentials(id, url string) (*webdav.LoginCredentials, error) {
+	s.loginsMu.Lock()
+	defer s.loginsMu.Unlock()
+	s.pruneLogins()
+	l := s.logins[id]
+	switch {
+	case l == nil:
+		return nil, errors.New("login expired, please authenticate again")
+	case l.creds == nil:
+		return nil, errors.New("login not completed yet")
+	case strings.TrimRight(l.url, "/") != strings.TrimRight(url, "/"):
+		return nil, errors.New("login belongs to a different URL")
+	}
+	return l.creds, nil
+}
+
+// forgetLogin drops a login whose app password is now owned by a run.
+func (s *Server) forgetLogin(id string) {
+	s.loginsMu.Lock()
+	defer s.loginsMu.Unlock()
+	delete(s.logins, id)
+}
+
+// HandleLoginStart starts a Login Flow v2 for {"url": ...} and returns the
+// page the user has to open in the browser.
+func (s *Server) HandleLoginStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	req.URL = strings.TrimRight(req.URL, "/")
+	if err := validateTargetURL(req.URL); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	client := webdav.NewClient(req.URL, "", "", nil)
+	flow, err := client.StartLoginFlow(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), 502)
+		return
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	s.loginsMu.Lock()
+	s.pruneLogins()
+	s.logins[id] = &browserLogin{url: req.URL, client: client, flow: flow, started: time.Now()}
+	s.loginsMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"id": id, "login_url": flow.LoginURL}); err != nil {
+		log.Printf("Failed to write login flow: %v", err)
+	}
+}
+
+// HandleLoginPoll reports whether the user of login ?id= has granted access.
+func (s *Server) HandleLoginPoll(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	s.loginsMu.Lock()
+	s.pruneLogins()
+	l := s.logins[id]
+	s.loginsMu.Unlock()
+	if l == nil {
+		http.Error(w, "Login expired, please authenticate again", 404)
+		return
+	}
+
+	resp := map[string]string{"status": "pending"}
+	s.loginsMu.Lock()
+	creds := l.creds
+	s.loginsMu.Unlock()
+	if creds == nil {
+		c, err := l.client.PollLogin(r.Context(), l.flow)
+		switch {
+		case errors.Is(err, webdav.ErrLoginPending):
+		case err != nil:
+			http.Error(w, err.Error(), 502)
+			return
+		default:
+			s.loginsMu.Lock()
+			l.creds = c
+			s.loginsMu.Unlock()
+			creds = c
+		}
+	}
+	if creds != nil {
+		resp = map[string]string{"status": "done", "user": creds.LoginName}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to write login status: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"nextcloud-perf/internal/history"
+	"nextcloud-perf/internal/report"
+)
+
+// saveHistory persists a completed run. Failures only affect the history,
+// so they are logged and reported to the browser but do not fail the run.
+func (s *Server) saveHistory(data report.ReportData, html []byte) {
+	if s.History == nil {
+		return
+	}
+	e, err := s.History.Save(data, html)
+	if err != nil {
+		log.Printf("Failed to save run history: %v", err)
+		s.Broadcast(fmt.Sprintf("Warning: Failed to save run history: %v", err))
+		return
+	}
+	log.Printf("Saved run %s to %s", e.ID, s.History.Dir())
+}
+
+// historyStore writes an error and returns nil if the history is unavailable.
+func (s *Server) historyStore(w http.ResponseWriter) *history.Store {
+	if s.History == nil {
+		http.Error(w, "Run history is not available", 503)
+	}
+	return s.History
+}
+
+func historyError(w http.ResponseWriter, err error) {
+	if errors.Is(err, history.ErrNotFound) {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	http.Error(w, err.Error(), 500)
+}
+
+// HandleHistory lists stored runs, newest first, optionally filtered by ?target=.
+func (s *Server) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	store := s.historyStore(w)
+	if store == nil {
+		return
+	}
+	entries, err := store.List(r.URL.Query().Get("target"))
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	targets, err := store.Targets()
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		Targets []string        `json:"targets"`
+		Runs    []history.Entry `json:"runs"`
+	}{
+		Targets: targets,
+		Runs:    entries,
+	}); err != nil {
+		log.Printf("Failed to write history: %v", err)
+	}
+}
+
+// HandleHistoryView shows the stored HTML report of ?id= in the browser.
+func (s *Server) HandleHistoryView(w http.ResponseWriter, r *http.Request) {
+	store := s.historyStore(w)
+	if store == nil {
+		return
+	}
+	b, err := store.HTML(r.URL.Query().Get("id"))
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	if _, err := w.Write(b); err != nil {
+		log.Printf("Failed to write history report: %v", err)
+	}
+}
+
+// HandleHistoryDownload serves the stored report of ?id= as an attachment
+// in ?format=html (default) or json.
+func (s *Server) HandleHistoryDownload(w http.ResponseWriter, r *http.Request) {
+	store := s.historyStore(w)
+	if store == nil {
+		return
+	}
+	id := r.URL.Query().Get("id")
+
+	var b []byte
+	var err error
+	contentType, ext := "text/html", "html"
+	switch r.URL.Query().Get("format") {
+	case "", "html":
+		b, err = store.HTML(id)
+	case "json":
+		b, err = store.JSON(id)
+		contentType, ext = "application/json", "json"
+	default:
+		http.Error(w, "Unsupported format (use html or json)", 400)
+		return
+	}
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=Nextcloud_Perf_Report_%s.%s", id, ext))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(b)))
+	if _, err := w.Write(b); err != nil {
+		log.Printf("Failed to write history download: %v", err)
+	}
+}
+
+// HandleHistoryDelete removes the run ?id=. Only POST and DELETE are accepted.
+func (s *Server) HandleHistoryDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	store := s.historyStore(w)
+	if store == nil {
+		return
+	}
+	if err := store.Delete(r.URL.Query().Get("id")); err != nil {
+		historyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleHistoryCompare renders the comparison of the runs ?base= and ?current=.
+func (s *Server) HandleHistoryCompare(w http.ResponseWriter, r *http.Request) {
+	store := s.historyStore(w)
+	if store == nil {
+		return
+	}
+	base, err := store.Report(r.URL.Query().Get("base"))
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	current, err := store.Report(r.URL.Query().Get("current"))
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	b, err := report.GenerateComparisonHTML(report.Compare(base, current))
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	if _, err := w.Write(b); err != nil {
+		log.Printf("Failed to write comparison: %v", err)
+	}
+}
@@ -12,11 +12,16 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"nextcloud-perf/internal/config"
+	"nextcloud-perf/internal/history"
+	"nextcloud-perf/internal/metrics"
 	"nextcloud-perf/internal/report"
+	"nextcloud-perf/internal/targets"
 	"nextcloud-perf/internal/workflow"
 )
 
@@ -41,15 +46,86 @@ type Client struct {
 	resChan chan report.ReportData
 }
 
+// maxEventLogLines bounds the log events kept for /report/events.
+const maxEventLogLines = 5000
+
+// eventBuffer keeps the NDJSON event log of a run for /report/events. It is
+// written by the benchmark and read by HTTP handlers at the same time.
+// Every result event carries the full report, so only the latest one is
+// kept, and only the last maxEventLogLines log events.
+type eventBuffer struct {
+	mu     sync.Mutex
+	lines  [][]byte // Events in order, at most one of them a result
+	logs   int      // Log events in lines
+	result int      // Index of the result event in lines, -1 if none
+}
+
+func newEventBuffer() *eventBuffer {
+	return &eventBuffer{result: -1}
+}
+
+// Write takes one event per call, as written by report.EventWriter.
+func (b *eventBuffer) Write(p []byte) (int, error) {
+	var ev struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(p, &ev); err != nil {
+		return 0, err
+	}
+	line := bytes.Clone(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ev.Type == report.EventResult {
+		if b.result >= 0 {
+			b.lines = append(b.lines[:b.result], b.lines[b.result+1:]...)
+		}
+		b.result = len(b.lines)
+	} else if b.logs++; b.logs > maxEventLogLines {
+		drop := 0
+		if b.result == 0 {
+			drop = 1
+		}
+		b.lines = append(b.lines[:drop], b.lines[drop+1:]...)
+		if b.result > 0 {
+			b.result--
+		}
+		b.logs--
+	}
+	b.lines = append(b.lines, line)
+	return len(p), nil
+}
+
+func (b *eventBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Join(b.lines, nil)
+}
+
 type Server struct {
+	Host         string // Interface to listen on, loopback by default: the UI has no authentication
 	Port         int
 	LogChan      chan string
 	ResultChan   chan report.ReportData
 	LatestReport []byte
+	LatestData   *report.ReportData // Last completed result, used for JSON export
 	ReportMu     sync.RWMutex
 	ReadyChan    chan struct{} // Signals when server is ready to accept connections
 	cancelFunc   context.CancelFunc
 	runMu        sync.Mutex
+	History      *history.Store     // Completed runs, nil if the data directory is unavailable
+	lastSaved    time.Time          // GeneratedAt of the run last stored in History
+	Metrics      *metrics.Collector // Prometheus metrics served on /metrics
+	Targets      *targets.Store     // Saved targets, nil if the data directory is unavailable
+
+	// Login Flow v2 sessions started from the browser, by id
+	logins   map[string]*browserLogin
+	loginsMu sync.Mutex
+
+	// NDJSON event log of the current (or last) run
+	eventLog    *eventBuffer
+	eventWriter *report.EventWriter
+	eventsMu    sync.RWMutex
 	
 	// Client management for broadcasting
 	clients    map[string]*Client
@@ -60,6 +136,7 @@ type Server struct {
 
 func NewServer(port int) *Server {
 	s := &Server{
+		Host:       config.DefaultServerHost,
 		Port:       port,
 		LogChan:    make(chan string, 100),
 		ResultChan: make(chan report.ReportData, 1),
@@ -67,6 +144,19 @@ func NewServer(port int) *Server {
 		clients:    make(map[string]*Client),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		Metrics:    metrics.NewCollector(),
+		logins:     make(map[string]*browserLogin),
+	}
+
+	if store, err := history.Open(history.DefaultDir()); err != nil {
+		log.Printf("Run history disabled: %v", err)
+	} else {
+		s.History = store
+	}
+	if store, err := targets.Open(targets.DefaultDir()); err != nil {
+		log.Printf("Saved targets disabled: %v", err)
+	} else {
+		s.Targets = store
 	}
 	
 	// Start broadcaster goroutine
@@ -121,6 +211,11 @@ func (s *Server) broadcaster() {
 }
 
 func (s *Server) Broadcast(msg string) {
+	if ev := s.events(); ev != nil {
+		if err := ev.WriteLog(msg); err != nil {
+			log.Printf("Failed to record event: %v", err)
+		}
+	}
 	select {
 	case s.LogChan <- msg:
 	default:
@@ -128,9 +223,41 @@ func (s *Server) Broadcast(msg string) {
 }
 
 func (s *Server) SendResult(data report.ReportData) {
+	if ev := s.events(); ev != nil {
+		if err := ev.WriteResult(data); err != nil {
+			log.Printf("Failed to record event: %v", err)
+		}
+	}
+	s.Metrics.Observe(data)
+	if data.Completed {
+		s.ReportMu.Lock()
+		s.LatestData = &data
+		html := s.LatestReport
+		// The workflow sends the completed result more than once; store it only once
+		save := !data.GeneratedAt.Equal(s.lastSaved)
+		s.lastSaved = data.GeneratedAt
+		s.ReportMu.Unlock()
+		if save {
+			s.saveHistory(data, html)
+		}
+	}
 	s.ResultChan <- data
 }
 
+func (s *Server) events() *report.EventWriter {
+	s.eventsMu.RLock()
+	defer s.eventsMu.RUnlock()
+	return s.eventWriter
+}
+
+// resetEvents starts a fresh event log for a new run.
+func (s *Server) resetEvents() {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	s.eventLog = newEventBuffer()
+	s.eventWriter = report.NewEventWriter(s.eventLog)
+}
+
 func (s *Server) SaveReport(html []byte) {
 	s.ReportMu.Lock()
 	defer s.ReportMu.Unlock()
@@ -211,6 +338,17 @@ func (s *Server) HandleEvents(w http.ResponseWriter, r *http.Request) {
 func (s *Server) HandleDownloadReport(w http.ResponseWriter, r *http.Request) {
 	s.ReportMu.RLock()
 	defer s.ReportMu.RUnlock()
+
+	switch r.URL.Query().Get("format") {
+	case "", "html":
+	case "json":
+		s.writeJSONReport(w)
+		return
+	default:
+		http.Error(w, "Unsupported format (use html or json)", 400)
+		return
+	}
+
 	if len(s.LatestReport) == 0 {
 		http.Error(w, "No report available", 404)
 		return
@@ -223,20 +361,97 @@ func (s *Server) HandleDownloadReport(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// writeJSONReport serves the last completed result. Callers must hold ReportMu.
+func (s *Server) writeJSONReport(w http.ResponseWriter) {
+	if s.LatestData == nil {
+		http.Error(w, "No report available", 404)
+		return
+	}
+	b, err := report.GenerateJSON(*s.LatestData)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename=Nextcloud_Perf_Report.json")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(b)))
+	if _, err := w.Write(b); err != nil {
+		log.Printf("Failed to write report download: %v", err)
+	}
+}
+
+// HandleEventLog serves the NDJSON event stream of the current or last run.
+func (s *Server) HandleEventLog(w http.ResponseWriter, r *http.Request) {
+	s.eventsMu.RLock()
+	buf := s.eventLog
+	s.eventsMu.RUnlock()
+	if buf == nil {
+		http.Error(w, "No events recorded", 404)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", "attachment; filename=Nextcloud_Perf_Events.ndjson")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Failed to write event log: %v", err)
+	}
+}
+
+// HandleSchema serves the JSON Schema of the JSON report export.
+func (s *Server) HandleSchema(w http.ResponseWriter, r *http.Request) {
+	b, err := report.JSONSchema()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	if _, err := w.Write(b); err != nil {
+		log.Printf("Failed to write schema: %v", err)
+	}
+}
+
 type RunRequest struct {
-	URL  string `json:"url"`
-	User string `json:"user"`
-	Pass string `json:"pass"`
+	URL     string `json:"url"`
+	User    string `json:"user"`
+	Pass    string `json:"pass"`
+	Profile string `json:"profile,omitempty"` // Profile name, empty selects the default
+	AuthID  string `json:"auth_id,omitempty"` // Completed browser login replacing User/Pass
+	Target  string `json:"target,omitempty"`  // Saved target replacing User/Pass (and URL/Profile if empty)
+
+	Passphrase string `json:"passphrase,omitempty"` // For targets in the encrypted secrets file
 }
 
 // Validate performs input validation to prevent SSRF and injection attacks
 func (r *RunRequest) Validate() error {
-	// URL validation
-	if r.URL == "" {
+	if err := validateTargetURL(r.URL); err != nil {
+		return err
+	}
+	
+	// Username validation
+	if r.User == "" {
+		return errors.New("username is required")
+	}
+	if len(r.User) > 255 {
+		return errors.New("username too long (max 255 chars)")
+	}
+	
+	// Password validation
+	if r.Pass == "" {
+		return errors.New("password is required")
+	}
+	if len(r.Pass) > 1024 {
+		return errors.New("password too long (max 1024 chars)")
+	}
+	
+	return nil
+}
+
+// validateTargetURL checks the Nextcloud URL entered in the browser
+func validateTargetURL(rawURL string) error {
+	if rawURL == "" {
 		return errors.New("URL is required")
 	}
 	
-	parsedURL, err := url.Parse(r.URL)
+	parsedURL, err := url.Parse(rawURL)
 	if err != nil {
 		return fmt.Errorf("invalid URL format: %w", err)
 	}
@@ -262,22 +477,6 @@ func (r *RunRequest) Validate() error {
 		log.Printf("Warning: Testing against private IP address: %s", hostname)
 	}
 	
-	// Username validation
-	if r.User == "" {
-		return errors.New("username is required")
-	}
-	if len(r.User) > 255 {
-		return errors.New("username too long (max 255 chars)")
-	}
-	
-	// Password validation
-	if r.Pass == "" {
-		return errors.New("password is required")
-	}
-	if len(r.Pass) > 1024 {
-		return errors.New("password too long (max 1024 chars)")
-	}
-	
 	return nil
 }
 
@@ -288,11 +487,34 @@ func (s *Server) HandleRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Target != "" {
+		if err := s.resolveTarget(&req); err != nil {
+			targetsError(w, err)
+			return
+		}
+	}
+
+	// A browser login supplies an app password that is revoked after the run
+	revoke := false
+	if req.AuthID != "" {
+		creds, err := s.loginCredentials(req.AuthID, req.URL)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		req.User, req.Pass, revoke = creds.LoginName, creds.AppPassword, true
+	}
+
 	// Validate input
 	if err := req.Validate(); err != nil {
 		http.Error(w, err.Error(), 400)
 		return
 	}
+	profile, err := findProfile(req.Profile)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
 
 	s.runMu.Lock()
 	if s.cancelFunc != nil {
@@ -300,9 +522,16 @@ func (s *Server) HandleRun(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Benchmark already running", 409)
 		return
 	}
+	if revoke {
+		s.forgetLogin(req.AuthID) // Revoked by the run from now on
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	s.cancelFunc = cancel
+	s.resetEvents()
+	s.ReportMu.Lock()
+	s.LatestReport = nil // Never store the previous HTML with the new run
+	s.ReportMu.Unlock()
 	
 	// Synchronization channel to ensure goroutine has started before unlock
 	started := make(chan struct{})
@@ -316,9 +545,12 @@ func (s *Server) HandleRun(w http.ResponseWriter, r *http.Request) {
 		}()
 
 		opts := workflow.BenchmarkOptions{
-			URL:  req.URL,
-			User: req.User,
-			Pass: req.Pass,
+			URL:     req.URL,
+			User:    req.User,
+			Pass:    req.Pass,
+			Profile: profile,
+
+			RevokeAppPassword: revoke,
 		}
 		workflow.Run(ctx, opts, s)
 	}()
@@ -329,6 +561,38 @@ func (s *Server) HandleRun(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// findProfile looks up a profile by name. Unlike config.ResolveProfile it
+// never reads arbitrary file paths, since the name comes from the browser.
+func findProfile(name string) (*config.BenchmarkProfile, error) {
+	if name == "" {
+		return config.DefaultProfile(), nil
+	}
+	for _, p := range config.AvailableProfiles() {
+		if p.Name == name {
+			p := p
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown profile %q", name)
+}
+
+// HandleProfiles lists the selectable benchmark profiles.
+func (s *Server) HandleProfiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(struct {
+		Default  string                    `json:"default"`
+		Profiles []config.BenchmarkProfile `json:"profiles"`
+	}{
+		Default:  config.DefaultProfileName,
+		Profiles: config.AvailableProfiles(),
+	}); err != nil {
+		log.Printf("Failed to write profiles: %v", err)
+	}
+}
+
 func (s *Server) HandleCancel(w http.ResponseWriter, r *http.Request) {
 	s.runMu.Lock()
 	if s.cancelFunc != nil {
@@ -345,9 +609,23 @@ func (s *Server) Listen() {
 	http.HandleFunc("/events", s.HandleEvents)
 	http.HandleFunc("/run", s.HandleRun)
 	http.HandleFunc("/run/cancel", s.HandleCancel)
+	http.HandleFunc("/auth/login", s.HandleLoginStart)
+	http.HandleFunc("/auth/poll", s.HandleLoginPoll)
+	http.HandleFunc("/targets", s.HandleTargets)
+	http.HandleFunc("/targets/save", s.HandleTargetSave)
+	http.HandleFunc("/targets/delete", s.HandleTargetDelete)
+	http.HandleFunc("/profiles", s.HandleProfiles)
 	http.HandleFunc("/report/download", s.HandleDownloadReport)
+	http.HandleFunc("/report/events", s.HandleEventLog)
+	http.HandleFunc("/report/schema.json", s.HandleSchema)
+	http.Handle("/metrics", s.Metrics)
+	http.HandleFunc("/history", s.HandleHistory)
+	http.HandleFunc("/history/view", s.HandleHistoryView)
+	http.HandleFunc("/history/download", s.HandleHistoryDownload)
+	http.HandleFunc("/history/delete", s.HandleHistoryDelete)
+	http.HandleFunc("/history/compare", s.HandleHistoryCompare)
 
-	addr := fmt.Sprintf(":%d", s.Port)
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.Fatal(fmt.Errorf("failed to listen on %s: %v", addr, err))
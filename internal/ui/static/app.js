@@ -75,12 +75,21 @@ evtSource.addEventListener("message", function (event) {
         else if (msg.includes("Traceroute")) {
             simplifiedMsg = translations[currentLang].status_traceroute || "Tracing network path...";
         }
+        else if (msg.startsWith("Comparing IPv4 and IPv6")) {
+            simplifiedMsg = translations[currentLang].status_dualstack || "Comparing IPv4 and IPv6...";
+        }
+        else if (msg.startsWith("Discovering Path MTU")) {
+            simplifiedMsg = translations[currentLang].status_pmtu || "Discovering path MTU...";
+        }
         else if (msg.includes("Reference Speedtest") || msg.includes("Running Reference")) {
             simplifiedMsg = translations[currentLang].status_speedtest || "Running speed test...";
         }
         else if (msg.includes("Ref Speed")) {
             simplifiedMsg = translations[currentLang].status_speedtest_done || "Speed test completed";
         }
+        else if (msg.startsWith("Measuring request phases")) {
+            simplifiedMsg = translations[currentLang].status_endpoints || "Measuring request phases...";
+        }
         // Connection
         else if (msg.includes("Connecting")) {
             simplifiedMsg = translations[currentLang].status_connecting || "Connecting to Nextcloud...";
@@ -89,14 +98,24 @@ evtSource.addEventListener("message", function (event) {
             simplifiedMsg = translations[currentLang].status_connected || "Connected successfully";
         }
         // Benchmarks
-        else if (msg.includes("Starting Small Files Test")) {
-            simplifiedMsg = translations[currentLang].status_small_files || "Testing small files...";
+        else if (msg.match(/^Starting scenario \d+\/\d+: /)) {
+            const label = msg.replace(/^Starting scenario \d+\/\d+: /, '').replace(/\.\.\.$/, '');
+            simplifiedMsg = (translations[currentLang].status_scenario || "Testing") + " " + label + "...";
+        }
+        else if (msg.startsWith("Starting metadata test")) {
+            simplifiedMsg = translations[currentLang].status_metadata || "Testing directory listings...";
         }
-        else if (msg.includes("Starting Medium Files Test")) {
-            simplifiedMsg = translations[currentLang].status_medium_files || "Testing medium files...";
+        else if (msg.startsWith("Starting sync cycle test") || msg.match(/^Sync cycle \d+:/)) {
+            const cycle = msg.match(/^Sync cycle (\d+):/);
+            simplifiedMsg = (translations[currentLang].status_sync || "Simulating sync client") + (cycle ? " (" + cycle[1] + ")" : "") + "...";
         }
-        else if (msg.includes("Starting Large File Test")) {
-            simplifiedMsg = translations[currentLang].status_large_file || "Testing large file...";
+        else if (msg.startsWith("Starting protocol comparison") || msg.match(/^Protocol \S+: transferring/)) {
+            const proto = msg.match(/^Protocol (\S+): transferring/);
+            simplifiedMsg = (translations[currentLang].status_protocols || "Comparing HTTP protocols") + (proto ? " (" + proto[1] + ")" : "") + "...";
+        }
+        else if (msg.startsWith("Starting soak test") || msg.startsWith("Soak interval")) {
+            const soak = msg.match(/^Soak interval (\d+\/\d+)/);
+            simplifiedMsg = (translations[currentLang].status_soak || "Running sustained load test") + (soak ? " (" + soak[1] + ")" : "") + "...";
         }
         else if (msg.includes("chunk")) {
             simplifiedMsg = translations[currentLang].status_uploading || "Uploading large file...";
@@ -143,27 +162,30 @@ evtSource.addEventListener("message", function (event) {
         if (msg.includes("Speedtest:")) setProgress(15);
         if (msg.includes("Ref Speed")) setProgress(18);
     }
-    if (msg.includes("Starting Small Files Test") || msg.includes("Medium Files") || msg.includes("Large File") || msg.includes("chunk")) {
+    // Benchmark scenarios share the 50-90% range of the progress bar
+    const scenarioMatch = msg.match(/^Starting scenario (\d+)\/(\d+)/);
+    if (scenarioMatch) {
         setStage('benchmark');
-        if (msg.includes("Starting Small Files Test")) setProgress(52);
-        if (msg.includes("Small Files Upload")) setProgress(55);
-        if (msg.includes("Starting Small Files Download")) setProgress(57);
-        if (msg.includes("Small Files Download")) setProgress(60);
-        if (msg.includes("Starting Medium Files Test")) setProgress(62);
-        if (msg.includes("Medium Files Upload")) setProgress(65);
-        if (msg.includes("Starting Medium Files Download")) setProgress(67);
-        if (msg.includes("Medium Files Download")) setProgress(70);
-        if (msg.includes("Starting Large File Test")) setProgress(72);
-        if (msg.includes("chunk")) {
-            const match = msg.match(/chunk (\d+)/);
-            if (match) {
-                const chunkNum = parseInt(match[1]);
-                setProgress(72 + Math.min(Math.floor(chunkNum * 0.2), 10));
-            }
-        }
-        if (msg.includes("Large File Upload")) setProgress(82);
-        if (msg.includes("Starting Large File Download")) setProgress(85);
-        if (msg.includes("Large File Download")) setProgress(90);
+        const idx = parseInt(scenarioMatch[1]);
+        const total = parseInt(scenarioMatch[2]);
+        setProgress(50 + Math.floor(((idx - 1) / total) * 40));
+    }
+    if (msg.startsWith("Starting metadata test")) {
+        setStage('benchmark');
+        setProgress(88);
+    }
+    if (msg.startsWith("Starting sync cycle test")) {
+        setStage('benchmark');
+        setProgress(89);
+    }
+    if (msg.startsWith("Starting protocol comparison")) {
+        setStage('benchmark');
+        setProgress(89);
+    }
+    const soakMatch = msg.match(/^Soak interval (\d+)\/(\d+)/);
+    if (soakMatch) {
+        setStage('benchmark');
+        setProgress(89 + Math.floor((parseInt(soakMatch[1]) / parseInt(soakMatch[2])) * 2));
     }
 
     if (msg.includes("Cleanup") || msg.includes("Generating Report") || msg.includes("Report Ready")) {
@@ -282,47 +304,11 @@ evtSource.addEventListener("result", function (event) {
         }
 
         // Populate results (Transfer Speeds)
-        if (data.small_files) {
-            console.log("Small Files Data:", data.small_files);
-            const speedVal = (data.small_files.speed_mbps && data.small_files.speed_mbps > 0) ? (data.small_files.speed_mbps.toFixed(2) + " MB/s") : "--";
-            console.log("Setting resSmall to:", speedVal);
-            setSafeText('resSmall', speedVal);
-            if (data.small_files.duration > 0) setSafeText('durSmallUp', (data.small_files.duration / 1000000000).toFixed(1) + "s");
-        }
-        if (data.small_files_down) {
-            console.log("Small Files Down Data:", data.small_files_down);
-            const speedVal = (data.small_files_down.speed_mbps && data.small_files_down.speed_mbps > 0) ? (data.small_files_down.speed_mbps.toFixed(2) + " MB/s") : "--";
-            console.log("Setting resSmallDown to:", speedVal);
-            setSafeText('resSmallDown', speedVal);
-            if (data.small_files_down.duration > 0) setSafeText('durSmallDown', (data.small_files_down.duration / 1000000000).toFixed(1) + "s");
-        }
-        if (data.medium_files) {
-            console.log("Medium Files Data:", data.medium_files);
-            const speedVal = (data.medium_files.speed_mbps && data.medium_files.speed_mbps > 0) ? (data.medium_files.speed_mbps.toFixed(2) + " MB/s") : "--";
-            console.log("Setting resMedium to:", speedVal);
-            setSafeText('resMedium', speedVal);
-            if (data.medium_files.duration > 0) setSafeText('durMedUp', (data.medium_files.duration / 1000000000).toFixed(1) + "s");
-        }
-        if (data.medium_files_down) {
-            console.log("Medium Files Down Data:", data.medium_files_down);
-            const speedVal = (data.medium_files_down.speed_mbps && data.medium_files_down.speed_mbps > 0) ? (data.medium_files_down.speed_mbps.toFixed(2) + " MB/s") : "--";
-            console.log("Setting resMediumDown to:", speedVal);
-            setSafeText('resMediumDown', speedVal);
-            if (data.medium_files_down.duration > 0) setSafeText('durMedDown', (data.medium_files_down.duration / 1000000000).toFixed(1) + "s");
-        }
-        if (data.large_file) {
-            console.log("Large File Data:", data.large_file);
-            const speedVal = (data.large_file.speed_mbps && data.large_file.speed_mbps > 0) ? (data.large_file.speed_mbps.toFixed(2) + " MB/s") : "--";
-            console.log("Setting resLarge to:", speedVal);
-            setSafeText('resLarge', speedVal);
-            if (data.large_file.duration > 0) setSafeText('durLargeUp', (data.large_file.duration / 1000000000).toFixed(1) + "s");
-        }
-        if (data.large_file_down) {
-            console.log("Large File Down Data:", data.large_file_down);
-            const speedVal = (data.large_file_down.speed_mbps && data.large_file_down.speed_mbps > 0) ? (data.large_file_down.speed_mbps.toFixed(2) + " MB/s") : "--";
-            console.log("Setting resLargeDown to:", speedVal);
-            setSafeText('resLargeDown', speedVal);
-            if (data.large_file_down.duration > 0) setSafeText('durLargeDown', (data.large_file_down.duration / 1000000000).toFixed(1) + "s");
+        if (data.scenarios) {
+            renderScenarios(data.scenarios);
+        }
+        if (data.metadata) {
+            renderMetadata(data.metadata);
         }
 
         // Network Stats
@@ -385,7 +371,9 @@ evtSource.addEventListener("result", function (event) {
             const advEl = document.getElementById('advNetStats');
             if (advEl) advEl.style.display = 'grid';
             setSafeText('valSSL', (a.tls_handshake_ms || 0).toFixed(1) + " ms");
-            setSafeText('valMTU', a.mtu ? a.mtu + " B" : "Unknown");
+            let mtu = a.mtu ? a.mtu + " B" : "Unknown";
+            if (data.path_mtu && data.path_mtu.black_hole) mtu += " (black hole)";
+            setSafeText('valMTU', mtu);
             const vpnEl = document.getElementById('valVPN');
             if (a.vpn_detected) {
                 vpnEl.innerText = "VPN: " + (a.vpn_type || "Detected");
@@ -402,9 +390,9 @@ evtSource.addEventListener("result", function (event) {
             const tBox = document.getElementById('tracerouteBox');
             if (tBox) {
                 tBox.innerHTML = '';
-                data.traceroute.forEach(hop => {
+                (data.traceroute.hops || []).forEach(hop => {
                     const div = document.createElement('div');
-                    div.innerText = hop;
+                    div.innerText = formatHop(hop);
                     tBox.appendChild(div);
                 });
             }
@@ -465,7 +453,7 @@ evtSource.addEventListener("result", function (event) {
 
                     row.innerHTML = `
                         <div style="font-weight: bold; color: #333;">${iface.name} <span style="color: #666; font-weight: normal;">(${iface.type})</span></div>
-                        <div style="color: #666; font-size: 0.85em; margin-top: 2px;">${iface.ip_address}</div>
+                        <div style="color: #666; font-size: 0.85em; margin-top: 2px;">${iface.ip_address}${iface.ipv6_address && iface.ipv6_address !== iface.ip_address ? '<br>' + iface.ipv6_address : ''}</div>
                         ${speedHtml}
                     `;
                     listEl.appendChild(row);
@@ -511,9 +499,9 @@ evtSource.addEventListener("result", function (event) {
             const trBox = document.getElementById('tracerouteBox');
             if (trBox) {
                 trBox.innerHTML = '';
-                data.traceroute.forEach(line => {
+                (data.traceroute.hops || []).forEach(hop => {
                     const div = document.createElement('div');
-                    div.innerText = line;
+                    div.innerText = formatHop(hop);
                     trBox.appendChild(div);
                 });
             }
@@ -537,81 +525,442 @@ evtSource.addEventListener("result", function (event) {
 
                 const limitUp = Math.min(uMBps, 10);
                 const limitDown = Math.min(dMBps, 50);
-
-                if (data.small_files && data.small_files_down) {
-                    const qSUp = updateQualityDot('qSmallUp', data.small_files.speed_mbps, limitUp, false);
-                    const qSDown = updateQualityDot('qSmallDown', data.small_files_down.speed_mbps, limitDown, false);
-                    updateConclusion('concSmall', qSUp, qSDown);
-                }
-
-                if (data.medium_files && data.medium_files_down) {
-                    const qMUp = updateQualityDot('qMedUp', data.medium_files.speed_mbps, limitUp, false);
-                    const qMDown = updateQualityDot('qMedDown', data.medium_files_down.speed_mbps, limitDown, false);
-                    updateConclusion('concMed', qMUp, qMDown);
-                }
-
-                if (data.large_file && data.large_file_down) {
-                    const qLUp = updateQualityDot('qLargeUp', data.large_file.speed_mbps, limitUp, true);
-                    const qLDown = updateQualityDot('qLargeDown', data.large_file_down.speed_mbps, limitDown, true);
-                    updateConclusion('concLarge', qLUp, qLDown);
-                }
+                rateScenarios(data.scenarios, limitUp, limitDown);
 
                 if (s.isp) setSafeText('resProvider', s.isp);
                 if (s.server_name) setSafeText('resStServer', s.server_name);
             }
         } else {
             // Falls Speedtest nicht verfügbar ist, zeige trotzdem die Upload/Download-Daten
-            if (data.small_files && data.small_files_down) {
-                const limitUp = 10; // Default limit
-                const limitDown = 50; // Default limit
-                
-                const qSUp = updateQualityDot('qSmallUp', data.small_files.speed_mbps, limitUp, false);
-                const qSDown = updateQualityDot('qSmallDown', data.small_files_down.speed_mbps, limitDown, false);
-                updateConclusion('concSmall', qSUp, qSDown);
-            }
-
-            if (data.medium_files && data.medium_files_down) {
-                const limitUp = 10;
-                const limitDown = 50;
-                
-                const qMUp = updateQualityDot('qMedUp', data.medium_files.speed_mbps, limitUp, false);
-                const qMDown = updateQualityDot('qMedDown', data.medium_files_down.speed_mbps, limitDown, false);
-                updateConclusion('concMed', qMUp, qMDown);
-            }
-
-            if (data.large_file && data.large_file_down) {
-                const limitUp = 10;
-                const limitDown = 50;
-                
-                const qLUp = updateQualityDot('qLargeUp', data.large_file.speed_mbps, limitUp, true);
-                const qLDown = updateQualityDot('qLargeDown', data.large_file_down.speed_mbps, limitDown, true);
-                updateConclusion('concLarge', qLUp, qLDown);
-            }
+            rateScenarios(data.scenarios, 10, 50); // Default limits
         }
     } catch (err) {
         console.error("Result processing error", err);
     }
 });
 
+// One traceroute hop as a line like mtr prints it.
+function formatHop(hop) {
+    if (!hop.address) return `${hop.ttl}: *`;
+    let text = `${hop.ttl}: ${hop.hostname ? `${hop.hostname} (${hop.address})` : hop.address}`;
+    if (hop.asn) text += ` AS${hop.asn}`;
+    text += ` ${hop.avg_ms.toFixed(2)}ms`;
+    if (hop.sent > 1) text += ` · Loss ${hop.packet_loss.toFixed(1)}% · StdDev ${hop.stddev_ms.toFixed(2)}ms`;
+    return text;
+}
+
+function scenarioSpeed(res) {
+    return (res && res.speed_mbps > 0) ? res.speed_mbps.toFixed(2) + " MB/s" : "--";
+}
+
+function scenarioDuration(res) {
+    if (!res || !(res.duration > 0)) return "--";
+    let text = (res.duration / 1000000000).toFixed(1) + "s";
+    if (res.latency) {
+        const ms = ns => (ns / 1000000).toFixed(0) + "ms";
+        text += ` · p50 ${ms(res.latency.p50)} / p99 ${ms(res.latency.p99)}`;
+    }
+    return text;
+}
+
+// Renders one perf card per profile scenario. Cards are created on first
+// sight of a scenario and updated in place afterwards.
+function renderScenarios(scenarios) {
+    const grid = document.getElementById('perfGrid');
+    if (!grid) return;
+    scenarios.forEach(sc => {
+        let card = document.getElementById('scenario-' + sc.name);
+        if (!card) {
+            card = document.createElement('div');
+            card.id = 'scenario-' + sc.name;
+            card.className = 'premium-card perf-card';
+            card.innerHTML = `
+                <div class="metric-label"></div>
+                <div class="perf-metric" data-dir="upload">
+                    <span data-i18n="label_upload">${translations[currentLang].label_upload}</span>
+                    <span class="value">--</span>
+                    <div class="quality-indicator quality-none"></div>
+                    <span class="duration">--</span>
+                </div>
+                <div class="perf-metric" data-dir="download">
+                    <span data-i18n="label_download">${translations[currentLang].label_download}</span>
+                    <span class="value">--</span>
+                    <div class="quality-indicator quality-none"></div>
+                    <span class="duration">--</span>
+                </div>
+                <div class="conclusion-text"></div>`;
+            card.querySelector('.metric-label').innerText = sc.label;
+            grid.appendChild(card);
+        }
+        [['upload', sc.upload], ['download', sc.download]].forEach(([dir, res]) => {
+            const row = card.querySelector(`[data-dir="${dir}"]`);
+            row.style.display = res ? '' : 'none';
+            row.querySelector('.value').innerText = scenarioSpeed(res);
+            row.querySelector('.duration').innerText = scenarioDuration(res);
+        });
+        if (sc.sequential && sc.upload && sc.sequential.speed_mbps > 0) {
+            const gain = (sc.upload.speed_mbps / sc.sequential.speed_mbps - 1) * 100;
+            const t = translations[currentLang];
+            card.querySelector('[data-dir="upload"] .duration').innerText +=
+                ` · ${t.label_sequential || "Sequential:"} ${scenarioSpeed(sc.sequential)} (${gain >= 0 ? '+' : ''}${gain.toFixed(0)}%)`;
+        }
+    });
+}
+
+// Renders the PROPFIND listing latency per depth as an extra perf card.
+function renderMetadata(meta) {
+    const grid = document.getElementById('perfGrid');
+    if (!grid) return;
+    let card = document.getElementById('scenario-metadata');
+    if (!card) {
+        card = document.createElement('div');
+        card.id = 'scenario-metadata';
+        card.className = 'premium-card perf-card';
+        grid.appendChild(card);
+    }
+    const t = translations[currentLang];
+    const ms = ns => (ns / 1000000).toFixed(0) + "ms";
+    card.innerHTML = `<div class="metric-label"></div>`;
+    card.querySelector('.metric-label').innerText = `${t.label_metadata || "Directory Listing"} (${meta.dirs} x ${meta.files_per_dir})`;
+    (meta.listings || []).forEach(l => {
+        const row = document.createElement('div');
+        row.className = 'perf-metric';
+        const text = (l.errors && l.errors.length) ? "--" :
+            (l.latency ? `p50 ${ms(l.latency.p50)} / p99 ${ms(l.latency.p99)}` : "--");
+        row.innerHTML = `<span></span><span class="value"></span><span class="duration"></span>`;
+        row.children[0].innerText = `Depth ${l.depth}`;
+        row.children[1].innerText = text;
+        row.children[2].innerText = l.entries ? `${l.entries} ${t.label_entries || "entries"}` : "";
+        card.appendChild(row);
+    });
+}
+
+// Applies the quality rating to every rendered scenario card.
+function rateScenarios(scenarios, limitUp, limitDown) {
+    if (!scenarios) return;
+    scenarios.forEach(sc => {
+        const card = document.getElementById('scenario-' + sc.name);
+        if (!card) return;
+        const isLarge = sc.chunked || sc.size >= 64 * 1024 * 1024;
+        const qualities = [];
+        [['upload', sc.upload, limitUp], ['download', sc.download, limitDown]].forEach(([dir, res, limit]) => {
+            if (!res) return;
+            const dot = card.querySelector(`[data-dir="${dir}"] .quality-indicator`);
+            dot.id = `q-${sc.name}-${dir}`;
+            qualities.push(updateQualityDot(dot.id, res.speed_mbps, limit, isLarge) || 'none');
+        });
+        const conc = card.querySelector('.conclusion-text');
+        conc.id = 'conc-' + sc.name;
+        if (qualities.length > 0) {
+            updateConclusion(conc.id, qualities[0], qualities[qualities.length - 1]);
+        }
+    });
+}
+
+// Fills the profile dropdown from /profiles.
+async function loadProfiles() {
+    const select = document.getElementById('profile');
+    if (!select) return;
+    try {
+        const resp = await fetch('/profiles');
+        const data = await resp.json();
+        select.innerHTML = '';
+        data.profiles.forEach(p => {
+            const opt = document.createElement('option');
+            opt.value = p.name;
+            opt.innerText = p.name;
+            opt.dataset.description = p.description || '';
+            if (p.name === data.default) opt.selected = true;
+            select.appendChild(opt);
+        });
+        const showDescription = () => {
+            const opt = select.options[select.selectedIndex];
+            setSafeText('profileDescription', opt ? opt.dataset.description : '');
+        };
+        select.addEventListener('change', showDescription);
+        showDescription();
+    } catch (e) {
+        console.error("Failed to load profiles", e);
+    }
+}
+
+document.addEventListener('DOMContentLoaded', loadProfiles);
+
+// Lists stored runs from /history, filtered by the selected target.
+async function loadHistory() {
+    const select = document.getElementById('historyTarget');
+    const rows = document.getElementById('historyRows');
+    if (!select || !rows) return;
+    const t = translations[currentLang];
+    const target = select.value;
+    try {
+        const resp = await fetch('/history?target=' + encodeURIComponent(target));
+        if (!resp.ok) {
+            document.getElementById('historyCard').style.display = 'none';
+            return;
+        }
+        const data = await resp.json();
+
+        select.innerHTML = '';
+        const all = document.createElement('option');
+        all.value = '';
+        all.innerText = t.history_all_targets || "All targets";
+        select.appendChild(all);
+        (data.targets || []).forEach(u => {
+            const opt = document.createElement('option');
+            opt.value = u;
+            opt.innerText = u;
+            if (u === target) opt.selected = true;
+            select.appendChild(opt);
+        });
+
+        const runs = data.runs || [];
+        rows.innerHTML = '';
+        document.getElementById('historyEmpty').style.display = runs.length ? 'none' : 'block';
+        document.getElementById('historyTable').style.display = runs.length ? 'table' : 'none';
+        runs.forEach((run, i) => {
+            // Runs are newest first, so the previous run of a target comes later in the list
+            const prev = runs.slice(i + 1).find(r => r.target_url.replace(/\/+$/, '').toLowerCase() ===
+                run.target_url.replace(/\/+$/, '').toLowerCase());
+            const tr = document.createElement('tr');
+            tr.innerHTML = `<td></td><td></td><td></td><td class="actions"></td>`;
+            tr.children[0].innerText = new Date(run.generated_at).toLocaleString(currentLang);
+            tr.children[1].innerText = run.target_url;
+            tr.children[2].innerText = run.profile || '--';
+            if (run.error) tr.children[2].innerText += ' (' + run.error + ')';
+
+            const id = encodeURIComponent(run.id);
+            const actions = tr.children[3];
+            const link = (href, label, download) => {
+                const a = document.createElement('a');
+                a.href = href;
+                a.target = '_blank';
+                a.innerText = label;
+                if (download) a.setAttribute('download', '');
+                actions.appendChild(a);
+            };
+            link('/history/view?id=' + id, t.btn_view || "View");
+            link('/history/download?id=' + id, 'HTML', true);
+            link('/history/download?format=json&id=' + id, t.btn_download_json || "JSON", true);
+            if (prev) {
+                link(`/history/compare?base=${encodeURIComponent(prev.id)}&current=${id}`, t.btn_compare_prev || "vs. previous");
+            }
+            const del = document.createElement('button');
+            del.innerText = t.btn_delete || "Delete";
+            del.onclick = () => deleteRun(run.id);
+            actions.appendChild(del);
+            rows.appendChild(tr);
+        });
+    } catch (e) {
+        console.error("Failed to load history", e);
+    }
+}
+
+async function deleteRun(id) {
+    if (!confirm(translations[currentLang].confirm_delete)) return;
+    try {
+        await fetch('/history/delete?id=' + encodeURIComponent(id), { method: 'POST' });
+    } catch (e) {
+        console.error("Failed to delete run", e);
+    }
+    loadHistory();
+}
+
+document.addEventListener('DOMContentLoaded', loadHistory);
+
+// Saved targets: the passwords stay in the tool, the browser only knows the names
+let savedTargets = [];
+
+async function loadSavedTargets() {
+    const select = document.getElementById('savedTarget');
+    if (!select) return;
+    const previous = select.value;
+    try {
+        const resp = await fetch('/targets');
+        if (!resp.ok) return;
+        savedTargets = await resp.json();
+    } catch (e) {
+        console.error("Failed to load saved targets", e);
+        return;
+    }
+    select.innerHTML = '';
+    const none = document.createElement('option');
+    none.value = '';
+    none.innerText = translations[currentLang].saved_target_new;
+    select.appendChild(none);
+    savedTargets.forEach(t => {
+        const opt = document.createElement('option');
+        opt.value = t.name;
+        opt.innerText = t.name + ' (' + t.user + ' @ ' + t.url + ')';
+        select.appendChild(opt);
+    });
+    select.value = savedTargets.some(t => t.name === previous) ? previous : '';
+    document.getElementById('savedTargetGroup').style.display = savedTargets.length ? 'block' : 'none';
+    selectSavedTarget();
+}
+
+function selectSavedTarget() {
+    const name = document.getElementById('savedTarget').value;
+    const target = savedTargets.find(t => t.name === name);
+    const manual = target ? 'none' : 'block';
+    ['loginFlowGroup', 'userGroup', 'passGroup', 'saveTargetGroup'].forEach(id => {
+        document.getElementById(id).style.display = manual;
+    });
+    document.getElementById('deleteTargetBtn').style.display = target ? 'block' : 'none';
+    const saving = document.getElementById('saveTarget').checked;
+    document.getElementById('passphraseGroup').style.display =
+        (target && target.storage === 'file') || (!target && saving) ? 'block' : 'none';
+    if (!target) return;
+
+    document.getElementById('url').value = target.url;
+    const profile = document.getElementById('profile');
+    if (target.profile && [...profile.options].some(o => o.value === target.profile)) {
+        profile.value = target.profile;
+        profile.dispatchEvent(new Event('change'));
+    }
+}
+
+function toggleSaveTarget() {
+    const saving = document.getElementById('saveTarget').checked;
+    document.getElementById('saveTargetName').style.display = saving ? 'block' : 'none';
+    document.getElementById('passphraseGroup').style.display = saving ? 'block' : 'none';
+}
+
+async function deleteSavedTarget() {
+    const name = document.getElementById('savedTarget').value;
+    if (!name || !confirm(translations[currentLang].confirm_delete_target)) return;
+    try {
+        const resp = await fetch('/targets/delete', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ name, passphrase: document.getElementById('passphrase').value })
+        });
+        if (!resp.ok) alert(await resp.text());
+    } catch (e) {
+        console.error("Failed to delete target", e);
+    }
+    document.getElementById('savedTarget').value = '';
+    loadSavedTargets();
+}
+
+document.addEventListener('DOMContentLoaded', loadSavedTargets);
+
+// Login Flow v2: the server keeps the app password, the browser only knows its id
+let authID = null;
+let loginPollTimer = null;
+
+function setLoginStatus(text, done) {
+    const el = document.getElementById('loginFlowStatus');
+    el.innerText = text;
+    el.classList.toggle('done', !!done);
+}
+
+function resetBrowserLogin() {
+    if (loginPollTimer) clearInterval(loginPollTimer);
+    loginPollTimer = null;
+    authID = null;
+    document.getElementById('loginFlowBtn').disabled = false;
+    if (!document.getElementById('savedTarget').value) {
+        ['userGroup', 'passGroup', 'saveTargetGroup'].forEach(id => {
+            document.getElementById(id).style.display = 'block';
+        });
+    }
+    setLoginStatus(translations[currentLang].login_flow_hint);
+}
+
+async function startBrowserLogin() {
+    const url = document.getElementById('url').value.replace(/\/+$/, '');
+    if (!url || url === 'https:') {
+        alert(translations[currentLang].please_fill);
+        return;
+    }
+    resetBrowserLogin();
+    // Open the window right away, popup blockers reject windows opened after an await
+    const win = window.open('', '_blank');
+    document.getElementById('loginFlowBtn').disabled = true;
+
+    try {
+        const res = await fetch('/auth/login', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ url })
+        });
+        if (!res.ok) throw new Error(await res.text());
+        const flow = await res.json();
+        if (win) win.location = flow.login_url; else window.open(flow.login_url, '_blank');
+        setLoginStatus(translations[currentLang].login_flow_waiting);
+
+        loginPollTimer = setInterval(async () => {
+            try {
+                const poll = await fetch('/auth/poll?id=' + encodeURIComponent(flow.id));
+                if (!poll.ok) throw new Error(await poll.text());
+                const status = await poll.json();
+                if (status.status !== 'done') return;
+                clearInterval(loginPollTimer);
+                loginPollTimer = null;
+                authID = flow.id;
+                document.getElementById('userGroup').style.display = 'none';
+                document.getElementById('passGroup').style.display = 'none';
+                document.getElementById('saveTargetGroup').style.display = 'none';
+                setLoginStatus(translations[currentLang].login_flow_done + ' ' + status.user, true);
+            } catch (e) {
+                resetBrowserLogin();
+                setLoginStatus(translations[currentLang].login_flow_failed + ' ' + e.message);
+            }
+        }, 2000);
+    } catch (e) {
+        if (win) win.close();
+        resetBrowserLogin();
+        setLoginStatus(translations[currentLang].login_flow_failed + ' ' + e.message);
+    }
+}
+
 async function startTest() {
-    const url = document.getElementById('url').value;
+    const url = document.getElementById('url').value.replace(/\/+$/, '');
     const user = document.getElementById('user').value;
     const pass = document.getElementById('pass').value;
+    const profile = document.getElementById('profile').value;
+
+    const target = document.getElementById('savedTarget').value;
+    const passphrase = document.getElementById('passphrase').value;
 
-    if (!url || !user || !pass) {
+    if (!url || (!authID && !target && (!user || !pass))) {
         alert(translations[currentLang].please_fill);
         return;
     }
+    let body = { url, user, pass, profile };
+    if (target) {
+        body = { url, target, passphrase, profile };
+    } else if (authID) {
+        body = { url, auth_id: authID, profile };
+    } else if (document.getElementById('saveTarget').checked) {
+        const name = document.getElementById('saveTargetName').value.trim();
+        if (!name) {
+            alert(translations[currentLang].please_fill);
+            return;
+        }
+        const resp = await fetch('/targets/save', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ name, url, user, pass, profile, passphrase })
+        });
+        if (!resp.ok) {
+            alert(translations[currentLang].save_target_failed + ' ' + await resp.text());
+            return;
+        }
+    }
 
     document.getElementById('loginCard').style.display = 'none';
+    document.getElementById('historyCard').style.display = 'none';
     document.getElementById('progressCard').style.display = 'block';
 
     try {
         await fetch('/run', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ url, user, pass })
+            body: JSON.stringify(body)
         });
+        authID = null; // The app password is revoked after this run
     } catch (e) {
         alert("Error: " + e);
         location.reload();
@@ -632,6 +981,10 @@ function resetUI() {
     document.getElementById('resultsCard').style.display = 'none';
     document.getElementById('progressCard').style.display = 'none';
     document.getElementById('loginCard').style.display = 'block';
+    document.getElementById('historyCard').style.display = 'block';
+    loadHistory();
+    resetBrowserLogin();
+    loadSavedTargets();
 
     setProgress(0);
     if (logDiv) logDiv.innerHTML = '';
@@ -651,10 +1004,12 @@ function resetUI() {
     if (listEl) listEl.innerHTML = '';
     const dnsIPs = document.getElementById('dnsIPs');
     if (dnsIPs) dnsIPs.innerHTML = '';
+    const perfGrid = document.getElementById('perfGrid');
+    if (perfGrid) perfGrid.innerHTML = '';
 
     // Reset labels to placeholder
     const labels = [
-        'resURL', 'ncStatusDetail', 'resSmall', 'resSmallDown', 'resMedium', 'resMediumDown', 'resLarge', 'resLargeDown',
+        'resURL', 'ncStatusDetail',
         'resPing', 'resPacketLoss', 'resDNS', 'diskWrite', 'diskRead',
         'sysOS', 'sysCPU', 'sysCPUUsage', 'sysCPUPeak', 'sysRAMTotal', 'sysRAMUsed', 'sysRAMFree',
         'resProvider', 'resStServer', 'refUp', 'refDown', 'netConnType', 'netPrimaryIF', 'valSSL', 'valMTU'
@@ -6,6 +6,7 @@ const translations = {
         label_url: "Nextcloud URL",
         label_username: "Username",
         label_password: "Password / App Token",
+        label_profile: "Benchmark Profile",
         placeholder_username: "Your Username",
         placeholder_password: "Your Password",
         btn_start: "Start Benchmark",
@@ -24,9 +25,6 @@ const translations = {
         label_upload_ref: "Upload (Ref)",
         label_download_ref: "Download (Ref)",
         header_transfer_speed: "Transfer Speed Results",
-        label_small_files: "Small Files",
-        label_medium_files: "Medium Files",
-        label_large_file: "Large File",
         label_upload: "Upload:",
         label_download: "Download:",
         header_network_summary: "Network Summary",
@@ -34,6 +32,7 @@ const translations = {
         label_packet_loss: "Packet Loss",
         label_dns: "DNS Resolution",
         btn_download_report: "Download Report",
+        btn_download_json: "JSON",
         btn_run_new: "Run New Test",
         conc_excellent: "Excellent connection",
         conc_solid: "Solid performance",
@@ -75,13 +74,44 @@ const translations = {
         status_dns: "Testing DNS resolution...",
         status_ping: "Measuring latency...",
         status_traceroute: "Tracing network path...",
+        status_dualstack: "Comparing IPv4 and IPv6...",
+        status_pmtu: "Discovering path MTU...",
+        status_endpoints: "Measuring request phases...",
         status_speedtest: "Running speed test...",
         status_speedtest_done: "Speed test completed",
         status_connecting: "Connecting to Nextcloud...",
         status_connected: "Connected successfully",
-        status_small_files: "Testing small files...",
-        status_medium_files: "Testing medium files...",
-        status_large_file: "Testing large file...",
+        status_scenario: "Testing",
+        status_metadata: "Testing directory listings...",
+        status_sync: "Simulating sync client",
+        status_protocols: "Comparing HTTP protocols",
+        status_soak: "Running sustained load test",
+        label_metadata: "Directory Listing",
+        label_entries: "entries",
+        header_history: "Previous Runs",
+        label_history_target: "Target",
+        history_all_targets: "All targets",
+        history_empty: "No runs stored yet.",
+        th_date: "Date",
+        th_profile: "Profile",
+        btn_view: "View",
+        btn_compare_prev: "vs. previous",
+        btn_delete: "Delete",
+        confirm_delete: "Delete this run from the history?",
+        btn_login_flow: "Authenticate in Browser",
+        login_flow_hint: "Log in via your Nextcloud (2FA/SSO supported). A temporary app password is created and revoked after the run.",
+        login_flow_waiting: "Waiting for login in the opened window...",
+        login_flow_done: "Authenticated as",
+        login_flow_failed: "Browser login failed:",
+        label_saved_target: "Saved Target",
+        saved_target_new: "New connection",
+        label_save_target: "Save as target (password in the OS keyring)",
+        placeholder_target_name: "Target name, e.g. production",
+        label_passphrase: "Passphrase (encrypted secrets file)",
+        passphrase_hint: "Only needed if no OS keyring is available and the password is kept in the encrypted file.",
+        confirm_delete_target: "Delete this saved target and its password?",
+        save_target_failed: "Saving the target failed:",
+        label_sequential: "Sequential:",
         status_uploading: "Uploading large file...",
         status_downloading: "Downloading test files...",
         status_cleanup: "Cleaning up...",
@@ -95,6 +125,7 @@ const translations = {
         label_url: "Nextcloud URL",
         label_username: "Benutzername",
         label_password: "Passwort / App Token",
+        label_profile: "Benchmark-Profil",
         placeholder_username: "Dein Benutzername",
         placeholder_password: "Dein Passwort",
         btn_start: "Benchmark Starten",
@@ -113,9 +144,6 @@ const translations = {
         label_upload_ref: "Upload (Ref)",
         label_download_ref: "Download (Ref)",
         header_transfer_speed: "Übertragungsgeschwindigkeit",
-        label_small_files: "Kleine Dateien",
-        label_medium_files: "Mittlere Dateien",
-        label_large_file: "Große Datei",
         label_upload: "Upload:",
         label_download: "Download:",
         header_network_summary: "Netzwerk-Zusammenfassung",
@@ -123,6 +151,7 @@ const translations = {
         label_packet_loss: "Packet Loss",
         label_dns: "DNS-Auflösung",
         btn_download_report: "Bericht herunterladen",
+        btn_download_json: "JSON",
         btn_run_new: "Neuen Test starten",
         conc_excellent: "Exzellente Verbindung",
         conc_solid: "Solide Leistung",
@@ -164,13 +193,44 @@ const translations = {
         status_dns: "DNS-Auflösung wird getestet...",
         status_ping: "Latenz wird gemessen...",
         status_traceroute: "Netzwerkpfad wird verfolgt...",
+        status_dualstack: "Vergleiche IPv4 und IPv6...",
+        status_pmtu: "Ermittle Path MTU...",
+        status_endpoints: "Messe Anfragephasen...",
         status_speedtest: "Geschwindigkeitstest läuft...",
         status_speedtest_done: "Geschwindigkeitstest abgeschlossen",
         status_connecting: "Verbindung mit Nextcloud wird hergestellt...",
         status_connected: "Erfolgreich verbunden",
-        status_small_files: "Kleine Dateien werden getestet...",
-        status_medium_files: "Mittlere Dateien werden getestet...",
-        status_large_file: "Große Datei wird getestet...",
+        status_scenario: "Teste",
+        status_metadata: "Teste Verzeichnis-Listings...",
+        status_sync: "Simuliere Sync-Client",
+        status_protocols: "Vergleiche HTTP-Protokolle",
+        status_soak: "Dauerlasttest läuft",
+        label_metadata: "Verzeichnis-Listing",
+        label_entries: "Einträge",
+        header_history: "Frühere Läufe",
+        label_history_target: "Ziel",
+        history_all_targets: "Alle Ziele",
+        history_empty: "Noch keine Läufe gespeichert.",
+        th_date: "Datum",
+        th_profile: "Profil",
+        btn_view: "Anzeigen",
+        btn_compare_prev: "vs. vorheriger",
+        btn_delete: "Löschen",
+        confirm_delete: "Diesen Lauf aus dem Verlauf löschen?",
+        btn_login_flow: "Im Browser anmelden",
+        login_flow_hint: "Anmeldung über Ihre Nextcloud (2FA/SSO möglich). Ein temporäres App-Passwort wird erstellt und nach dem Lauf widerrufen.",
+        login_flow_waiting: "Warte auf Anmeldung im geöffneten Fenster...",
+        login_flow_done: "Angemeldet als",
+        login_flow_failed: "Browser-Anmeldung fehlgeschlagen:",
+        label_saved_target: "Gespeichertes Ziel",
+        saved_target_new: "Neue Verbindung",
+        label_save_target: "Als Ziel speichern (Passwort im Schlüsselbund des Systems)",
+        placeholder_target_name: "Name des Ziels, z. B. produktion",
+        label_passphrase: "Passphrase (verschlüsselte Passwortdatei)",
+        passphrase_hint: "Nur nötig, wenn kein Schlüsselbund verfügbar ist und das Passwort in der verschlüsselten Datei liegt.",
+        confirm_delete_target: "Dieses gespeicherte Ziel samt Passwort löschen?",
+        save_target_failed: "Speichern des Ziels fehlgeschlagen:",
+        label_sequential: "Sequentiell:",
         status_uploading: "Große Datei wird hochgeladen...",
         status_downloading: "Test-Dateien werden heruntergeladen...",
         status_cleanup: "Aufräumen...",
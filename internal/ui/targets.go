@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"nextcloud-perf/internal/targets"
+)
+
+// targetsStore writes an error and returns nil if saved targets are unavailable.
+func (s *Server) targetsStore(w http.ResponseWriter) *targets.Store {
+	if s.Targets == nil {
+		http.Error(w, "Saved targets are not available", 503)
+	}
+	return s.Targets
+}
+
+func targetsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, targets.ErrNotFound):
+		http.Error(w, err.Error(), 404)
+	case errors.Is(err, targets.ErrNoPassphrase), errors.Is(err, targets.ErrBadPassphrase):
+		http.Error(w, err.Error(), 403)
+	case errors.Is(err, targets.ErrURLMismatch):
+		http.Error(w, err.Error(), 400)
+	default:
+		http.Error(w, err.Error(), 500)
+	}
+}
+
+// browserPassphrase uses the passphrase sent by the browser, falling back to
+// the environment of the tool.
+func browserPassphrase(p string) targets.PassphraseFunc {
+	return func() (string, error) {
+		if p != "" {
+			return p, nil
+		}
+		return targets.EnvPassphrase()
+	}
+}
+
+// resolveTarget fills URL, user, password and profile of req from the saved
+// target req.Target. An explicit profile in the request takes precedence; a
+// different URL is rejected, so the stored password cannot be sent elsewhere.
+func (s *Server) resolveTarget(req *RunRequest) error {
+	if s.Targets == nil {
+		return errors.New("saved targets are not available")
+	}
+	t, err := s.Targets.Get(req.Target)
+	if err != nil {
+		return err
+	}
+	if err := t.CheckURL(req.URL); err != nil {
+		return err
+	}
+	pass, err := s.Targets.Password(req.Target, browserPassphrase(req.Passphrase))
+	if err != nil {
+		return err
+	}
+	req.URL = t.URL
+	if req.Profile == "" {
+		req.Profile = t.Profile
+	}
+	req.User, req.Pass = t.User, pass
+	return nil
+}
+
+// HandleTargets lists the saved targets without their passwords.
+func (s *Server) HandleTargets(w http.ResponseWriter, r *http.Request) {
+	store := s.targetsStore(w)
+	if store == nil {
+		return
+	}
+	list, err := store.List()
+	if err != nil {
+		targetsError(w, err)
+		return
+	}
+	if list == nil {
+		list = []targets.Target{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		log.Printf("Failed to write targets: %v", err)
+	}
+}
+
+// HandleTargetSave stores a target and its password (POST only).
+func (s *Server) HandleTargetSave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	store := s.targetsStore(w)
+	if store == nil {
+		return
+	}
+	var req struct {
+		RunRequest
+		Name    string `json:"name"`
+		Storage string `json:"storage,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err := req.RunRequest.Validate(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if _, err := findProfile(req.Profile); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	t := targets.Target{Name: req.Name, URL: req.URL, User: req.User, Profile: req.Profile}
+	if err := t.Validate(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	saved, err := store.Save(t, req.Pass, req.Storage, browserPassphrase(req.Passphrase))
+	if err != nil {
+		targetsError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(saved); err != nil {
+		log.Printf("Failed to write target: %v", err)
+	}
+}
+
+// HandleTargetDelete removes a saved target and its password (POST or DELETE).
+func (s *Server) HandleTargetDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" && r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	store := s.targetsStore(w)
+	if store == nil {
+		return
+	}
+	var req struct {
+		Name       string `json:"name"`
+		Passphrase string `json:"passphrase,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err := store.Delete(req.Name, browserPassphrase(req.Passphrase)); err != nil {
+		targetsError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
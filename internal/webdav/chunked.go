@@ -0,0 +1,344 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nextcloud-perf/internal/config"
+)
+
+// ChunkOptions configures Chunking V2 uploads.
+type ChunkOptions struct {
+	ChunkSize int64 // Bytes per chunk, default config.DefaultChunkSize
+	Parallel  int   // Chunks in flight at once, default 1 (sequential)
+
+	TransferID     string // Resume the upload folder of an earlier attempt instead of starting a new one
+	ResumeAttempts int    // Times a failed upload is resumed within the call; requires data to be an io.Seeker
+	KeepPartial    bool   // Keep the upload folder on failure so it can be resumed later via TransferID
+
+	Checksum string // Checksum of the whole file ("SHA256:<hex>"), sent as OC-Checksum with the final MOVE
+}
+
+func (o ChunkOptions) withDefaults() ChunkOptions {
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = config.DefaultChunkSize
+	}
+	if o.Parallel <= 0 {
+		o.Parallel = 1
+	}
+	return o
+}
+
+// ChunkedUpload describes the outcome of a chunked upload.
+type ChunkedUpload struct {
+	TransferID string
+	Duration   time.Duration
+	Chunks     int // Chunks uploaded by this call
+	Skipped    int // Chunks already present on the server when resuming
+	Resumes    int // Times the upload was resumed after a failure
+}
+
+// ChunkedUploadError is returned when a chunked upload fails. Unless
+// ChunkOptions.KeepPartial was set, the upload folder has been deleted.
+type ChunkedUploadError struct {
+	TransferID string
+	Err        error
+}
+
+func (e *ChunkedUploadError) Error() string {
+	return fmt.Sprintf("chunked upload %s failed: %v", e.TransferID, e.Err)
+}
+
+func (e *ChunkedUploadError) Unwrap() error {
+	return e.Err
+}
+
+// UploadChunked performs a sequential Chunking V2 Upload with the default chunk size
+func (c *Client) UploadChunked(ctx context.Context, remotePath string, data io.Reader, totalSize int64) (time.Duration, error) {
+	up, err := c.UploadChunkedWithOptions(ctx, remotePath, data, totalSize, ChunkOptions{})
+	return up.Duration, err
+}
+
+// UploadChunkedWithOptions performs a Chunking V2 Upload with the given chunk size
+// and number of chunks uploaded in parallel. Memory use is bounded by
+// Parallel x ChunkSize bytes. The returned ChunkedUpload is never nil.
+func (c *Client) UploadChunkedWithOptions(ctx context.Context, remotePath string, data io.Reader, totalSize int64, opts ChunkOptions) (*ChunkedUpload, error) {
+	opts = opts.withDefaults()
+	up := &ChunkedUpload{TransferID: opts.TransferID}
+	if up.TransferID == "" {
+		up.TransferID = fmt.Sprintf("%d-%d", time.Now().Unix(), rand.Intn(100000))
+	}
+	uploadFolder := fmt.Sprintf("%s/remote.php/dav/uploads/%s/%s", c.BaseURL, c.Username, up.TransferID)
+
+	start := time.Now()
+	err := c.runChunkedUpload(ctx, up, uploadFolder, remotePath, data, totalSize, opts)
+	up.Duration = time.Since(start)
+
+	if err != nil {
+		if !opts.KeepPartial {
+			c.abortUpload(uploadFolder)
+		}
+		return up, &ChunkedUploadError{TransferID: up.TransferID, Err: err}
+	}
+	return up, nil
+}
+
+func (c *Client) runChunkedUpload(ctx context.Context, up *ChunkedUpload, uploadFolder, remotePath string, data io.Reader, totalSize int64, opts ChunkOptions) error {
+	// 1. Upload folder: reuse it when resuming, otherwise MKCOL
+	var existing map[int]int64
+	exists := false
+	if opts.TransferID != "" {
+		var err error
+		if existing, exists, err = c.existingChunks(ctx, uploadFolder); err != nil {
+			return err
+		}
+	}
+	if !exists {
+		if err := c.createUploadFolder(ctx, uploadFolder); err != nil {
+			return err
+		}
+	}
+
+	// 2. Upload Chunks, resuming from the chunks already on the server after a failure
+	c.LogFunc(fmt.Sprintf("Uploading Chunks (%d bytes, %d in parallel)...", opts.ChunkSize, opts.Parallel))
+	for {
+		sent, skipped, err := c.uploadChunks(ctx, uploadFolder, data, opts, existing)
+		up.Chunks += sent
+		up.Skipped += skipped
+		if err == nil {
+			break
+		}
+
+		seeker, ok := data.(io.Seeker)
+		if !ok || up.Resumes >= opts.ResumeAttempts || ctx.Err() != nil {
+			return err
+		}
+		up.Resumes++
+		c.LogFunc(fmt.Sprintf("Chunk upload failed (%v), resuming (%d/%d)...", err, up.Resumes, opts.ResumeAttempts))
+
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if existing, _, err = c.existingChunks(ctx, uploadFolder); err != nil {
+			return err
+		}
+	}
+
+	// 3. MOVE to final destination
+	return c.assembleChunks(ctx, uploadFolder, remotePath, totalSize, opts.Checksum)
+}
+
+func (c *Client) createUploadFolder(ctx context.Context, uploadFolder string) error {
+	c.LogFunc(fmt.Sprintf("MKCOL: %s", uploadFolder))
+	req, err := http.NewRequestWithContext(ctx, "MKCOL", uploadFolder, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 201 {
+		return fmt.Errorf("MKCOL failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// existingChunks lists the chunks in the upload folder by number and size.
+// exists is false if the folder is gone (e.g. expired by the server).
+func (c *Client) existingChunks(ctx context.Context, uploadFolder string) (chunks map[int]int64, exists bool, err error) {
+	resources, status, err := c.propfind(ctx, uploadFolder, DepthOne, []xml.Name{PropContentLength})
+	if status == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	chunks = map[int]int64{}
+	for _, r := range resources {
+		if idx, err := strconv.Atoi(path.Base(r.Href)); err == nil {
+			chunks[idx] = r.Size
+		}
+	}
+	c.LogFunc(fmt.Sprintf("Found %d existing chunks in %s", len(chunks), uploadFolder))
+	return chunks, true, nil
+}
+
+// uploadChunks reads data into pooled buffers and PUTs them as numbered chunks,
+// keeping at most opts.Parallel uploads in flight. Chunks listed in existing
+// with the expected size are skipped. The first failure cancels the rest.
+func (c *Client) uploadChunks(ctx context.Context, uploadFolder string, data io.Reader, opts ChunkOptions, existing map[int]int64) (sent, skipped int, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffers are allocated lazily and recycled through the pool
+	pool := make(chan []byte, opts.Parallel)
+	allocated := 0
+	getBuffer := func() []byte {
+		if allocated < opts.Parallel {
+			allocated++
+			return make([]byte, opts.ChunkSize)
+		}
+		select {
+		case buf := <-pool:
+			return buf
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	var uploaded atomic.Int64
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for chunkIndex := 1; ; chunkIndex++ {
+		buf := getBuffer()
+		if buf == nil {
+			break
+		}
+		n, err := io.ReadFull(data, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			fail(err)
+			break
+		}
+
+		if size, ok := existing[chunkIndex]; ok && size == int64(n) {
+			skipped++
+			pool <- buf
+		} else {
+			wg.Add(1)
+			go func(idx int, buf []byte, n int) {
+				defer wg.Done()
+				defer func() { pool <- buf }()
+				if err := c.putChunk(ctx, uploadFolder, idx, buf[:n]); err != nil {
+					fail(err)
+					return
+				}
+				uploaded.Add(1)
+			}(chunkIndex, buf, n)
+		}
+
+		// A short read is the last chunk
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return int(uploaded.Load()), skipped, firstErr
+	}
+	return int(uploaded.Load()), skipped, ctx.Err()
+}
+
+// putChunk uploads one chunk. Chunk names are numbered from 00001 so the
+// server assembles them in order regardless of upload completion order.
+func (c *Client) putChunk(ctx context.Context, uploadFolder string, idx int, chunk []byte) error {
+	chunkURL := fmt.Sprintf("%s/%05d", uploadFolder, idx)
+	req, err := http.NewRequestWithContext(ctx, "PUT", chunkURL, bytes.NewReader(chunk))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+
+	c.LogFunc(fmt.Sprintf("  > Uploading chunk %d...", idx))
+	resp, err := c.do(req)
+	if err != nil {
+		return NewChunkUploadError(idx, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return NewChunkUploadError(idx, fmt.Errorf("%s", resp.Status))
+	}
+	return nil
+}
+
+// assembleChunks MOVEs the virtual .file of the upload folder to remotePath.
+func (c *Client) assembleChunks(ctx context.Context, uploadFolder, remotePath string, totalSize int64, checksum string) error {
+	// CRITICAL FIX: Move source must be the /.file virtual file inside the upload folder
+	moveSource := uploadFolder + "/.file"
+
+	// Destination Header MUST be absolute URI
+	destHeaderVal := fmt.Sprintf("%s/remote.php/dav/files/%s/%s", c.BaseURL, c.Username, remotePath)
+
+	c.LogFunc(fmt.Sprintf("MOVE %s -> %s", moveSource, destHeaderVal))
+
+	moveReq, err := http.NewRequestWithContext(ctx, "MOVE", moveSource, nil)
+	if err != nil {
+		return err
+	}
+
+	moveReq.Header.Set("Destination", destHeaderVal)
+	moveReq.Header.Set("Overwrite", "T")
+	moveReq.Header.Set("OC-Total-Length", fmt.Sprintf("%d", totalSize)) // Required for validation
+	if checksum != "" {
+		moveReq.Header.Set(ChecksumHeader, checksum)
+	}
+	moveReq.Header.Set("User-Agent", "Mozilla/5.0 (Windows) mirall/3.15.3 (build 20250107) (Nextcloud Performance Tool)")
+	moveReq.SetBasicAuth(c.Username, c.Password)
+
+	// Use a tailored client with long timeout for the MOVE operation, on the
+	// same transport so that it uses the selected protocol and pool
+	moveClient := &http.Client{
+		Transport: c.Client.Transport,
+		Timeout:   10 * time.Minute,
+	}
+
+	moveResp, err := c.doWith(moveClient, moveReq)
+	if err != nil {
+		return err
+	} // If network fails completely
+	defer moveResp.Body.Close()
+
+	if moveResp.StatusCode < 200 || moveResp.StatusCode > 299 {
+		// Attempt to read body for error details
+		b, _ := io.ReadAll(moveResp.Body)
+		return fmt.Errorf("MOVE failed: %d %s - Dest: %s - Body: %s", moveResp.StatusCode, moveResp.Status, destHeaderVal, string(b))
+	}
+	return nil
+}
+
+// abortUpload deletes the upload folder of a failed transfer so its chunks do
+// not count against the user's quota. It uses its own timeout because the
+// upload context may already be cancelled.
+func (c *Client) abortUpload(uploadFolder string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c.LogFunc(fmt.Sprintf("Removing upload folder %s", uploadFolder))
+	req, err := http.NewRequestWithContext(ctx, "DELETE", uploadFolder, nil)
+	if err != nil {
+		return
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	resp, err := c.do(req)
+	if err != nil {
+		c.LogFunc(fmt.Sprintf("Failed to remove upload folder: %v", err))
+		return
+	}
+	resp.Body.Close()
+}
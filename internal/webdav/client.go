@@ -1,13 +1,10 @@
 package webdav
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"strings"
 	"time"
@@ -19,6 +16,8 @@ type Client struct {
 	Password string
 	Client   *http.Client
 	LogFunc  func(string)
+	Retry    RetryPolicy // Applied to idempotent requests
+	Protocol string      // HTTP protocol version, see WithProtocol
 }
 
 type CapabilitiesResponse struct {
@@ -53,26 +52,11 @@ func NewClient(url, user, pass string, logFunc func(string)) *Client {
 		Password: pass,
 		Client: &http.Client{
 			Timeout: 5 * time.Minute,
-			Transport: &http.Transport{
-				// Connection Pooling Configuration
-				MaxIdleConns:        100,              // Maximum idle connections across all hosts
-				MaxIdleConnsPerHost: 20,               // Maximum idle connections per host
-				MaxConnsPerHost:     50,               // Maximum connections per host (including active)
-				IdleConnTimeout:     90 * time.Second, // How long idle connections stay open
-				
-				// Timeout Configuration
-				TLSHandshakeTimeout:   10 * time.Second, // TLS handshake timeout
-				ResponseHeaderTimeout: 10 * time.Second, // Response header read timeout
-				ExpectContinueTimeout: 1 * time.Second,  // Time to wait for 100-Continue response
-				
-				// HTTP/2 Disabled (known Nextcloud performance issues with HTTP/2)
-				TLSNextProto: make(map[string]func(authority string, c *tls.Conn) http.RoundTripper),
-				
-				// Keep-Alive enabled (improves performance for multiple requests)
-				DisableKeepAlives: false,
-			},
+			Transport: newTransport(ProtocolHTTP1, nil), // HTTP/2 and HTTP/3 via WithProtocol
 		},
-		LogFunc: logFunc,
+		LogFunc:  logFunc,
+		Retry:    DefaultRetryPolicy(),
+		Protocol: ProtocolHTTP1,
 	}
 }
 
@@ -93,7 +77,7 @@ func (c *Client) GetStatus(ctx context.Context) (*StatusResponse, error) {
 		return nil, err
 	}
 
-	resp, err := c.Client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -125,7 +109,7 @@ func (c *Client) GetCapabilities(ctx context.Context) (*CapabilitiesResponse, er
 	req.Header.Set("OCS-APIRequest", "true")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows) mirall/3.15.3 (build 20250107) (Nextcloud Performance Tool)")
 
-	resp, err := c.Client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -149,6 +133,17 @@ func (c *Client) GetCapabilities(ctx context.Context) (*CapabilitiesResponse, er
 
 // UploadSimple performs a standard PUT upload
 func (c *Client) UploadSimple(ctx context.Context, remotePath string, data io.Reader, size int64) (time.Duration, error) {
+	return c.UploadWithChecksum(ctx, remotePath, data, size, "")
+}
+
+// ChecksumHeader carries the checksum of an upload as "<TYPE>:<hex>".
+// Nextcloud stores it and returns it in the oc:checksums property.
+const ChecksumHeader = "OC-Checksum"
+
+// UploadWithChecksum performs a standard PUT upload and sends checksum
+// ("SHA256:<hex>") as OC-Checksum header unless it is empty. Transient
+// failures are retried if data is an io.ReaderAt.
+func (c *Client) UploadWithChecksum(ctx context.Context, remotePath string, data io.Reader, size int64, checksum string) (time.Duration, error) {
 	// Construct full URL: BaseURL + /remote.php/dav/files/USER/ + remotePath
 	// NOTE: This assumes BaseURL is the root. Ideally we detect the webroot.
 	targetURL := fmt.Sprintf("%s/remote.php/dav/files/%s/%s", c.BaseURL, c.Username, remotePath)
@@ -156,15 +151,25 @@ func (c *Client) UploadSimple(ctx context.Context, remotePath string, data io.Re
 	start := time.Now()
 	c.LogFunc(fmt.Sprintf("PUT simple: %s (%d bytes)", targetURL, size))
 	req, err := http.NewRequestWithContext(ctx, "PUT", targetURL, data)
+	if err != nil {
+		return 0, err
+	}
 	if size > 0 {
 		req.ContentLength = size
 	}
-	if err != nil {
-		return 0, err
+	// Readers with random access can be replayed from the start on a retry,
+	// each attempt gets a reader of its own
+	if ra, ok := data.(io.ReaderAt); ok && req.GetBody == nil && size > 0 {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(ra, 0, size)), nil
+		}
 	}
 	req.SetBasicAuth(c.Username, c.Password)
+	if checksum != "" {
+		req.Header.Set(ChecksumHeader, checksum)
+	}
 
-	resp, err := c.Client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return 0, err
 	}
@@ -189,7 +194,7 @@ func (c *Client) Download(ctx context.Context, remotePath string) (io.ReadCloser
 	}
 	req.SetBasicAuth(c.Username, c.Password)
 
-	resp, err := c.Client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -202,121 +207,6 @@ func (c *Client) Download(ctx context.Context, remotePath string) (io.ReadCloser
 	return resp.Body, nil
 }
 
-// UploadChunked performs a Chunking V2 Upload
-func (c *Client) UploadChunked(ctx context.Context, remotePath string, data io.Reader, totalSize int64) (time.Duration, error) {
-	transferID := fmt.Sprintf("%d-%d", time.Now().Unix(), rand.Intn(100000))
-	uploadFolder := fmt.Sprintf("%s/remote.php/dav/uploads/%s/%s", c.BaseURL, c.Username, transferID)
-
-	start := time.Now()
-
-	// 1. MKCOL
-	c.LogFunc(fmt.Sprintf("MKCOL: %s", uploadFolder))
-	req, err := http.NewRequestWithContext(ctx, "MKCOL", uploadFolder, nil)
-	if err != nil {
-		return 0, err
-	}
-	req.SetBasicAuth(c.Username, c.Password)
-	resp, err := c.Client.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	resp.Body.Close()
-	if resp.StatusCode != 201 {
-		return 0, fmt.Errorf("MKCOL failed: %s", resp.Status)
-	}
-
-	// 2. Upload Chunks (25MB default chunk size as requested)
-	c.LogFunc("Uploading Chunks...")
-	chunkSize := int64(25 * 1024 * 1024)
-	buf := make([]byte, chunkSize)
-	chunkIndex := 0
-
-	for {
-		n, err := data.Read(buf)
-		if n == 0 {
-			if err == io.EOF {
-				break
-			}
-			return 0, err
-		}
-
-		// Upload Chunk
-		// Filename format: 00001, 00002... (5 digits usually? Or just numbers. Nextcloud accepts numbers)
-		// Standard recommendation: 0000000001 (padded). Let's use 5 padding.
-		chunkName := fmt.Sprintf("%05d", chunkIndex+1)
-		chunkURL := fmt.Sprintf("%s/%s", uploadFolder, chunkName)
-
-		// Create a reader for just this chunk
-		// We need to pass the running bytes.
-		// NOTE: In a real reader loop, 'buf[:n]' is the data.
-		// However, http.NewRequest needs a reader.
-		// We can't reuse 'buf' because it might change? No, we block until upload is done.
-
-		// PROBLEM: http.NewRequest with bytes.NewReader(buf[:n]) is fine.
-
-		// If n < chunkSize, this is the last chunk.
-
-		chunkReq, err := http.NewRequestWithContext(ctx, "PUT", chunkURL, bytes.NewReader(buf[:n]))
-		if err != nil {
-			return 0, err
-		}
-		chunkReq.SetBasicAuth(c.Username, c.Password)
-
-		c.LogFunc(fmt.Sprintf("  > Uploading chunk %d...", chunkIndex+1))
-		cRec, err := c.Client.Do(chunkReq)
-		if err != nil {
-			return 0, err
-		}
-		cRec.Body.Close()
-
-		if cRec.StatusCode < 200 || cRec.StatusCode > 299 {
-			return 0, fmt.Errorf("chunk upload failed: %s", cRec.Status)
-		}
-
-		chunkIndex++
-	}
-
-	// 3. MOVE to final destination
-	// CRITICAL FIX: Move source must be the /.file virtual file inside the upload folder
-	moveSource := uploadFolder + "/.file"
-
-	// Destination Header MUST be absolute URI
-	destHeaderVal := fmt.Sprintf("%s/remote.php/dav/files/%s/%s", c.BaseURL, c.Username, remotePath)
-
-	c.LogFunc(fmt.Sprintf("MOVE %s -> %s", moveSource, destHeaderVal))
-
-	moveReq, err := http.NewRequestWithContext(ctx, "MOVE", moveSource, nil)
-	if err != nil {
-		return 0, err
-	}
-
-	moveReq.Header.Set("Destination", destHeaderVal)
-	moveReq.Header.Set("Overwrite", "T")
-	moveReq.Header.Set("OC-Total-Length", fmt.Sprintf("%d", totalSize)) // Required for validation
-	moveReq.Header.Set("User-Agent", "Mozilla/5.0 (Windows) mirall/3.15.3 (build 20250107) (Nextcloud Performance Tool)")
-	moveReq.SetBasicAuth(c.Username, c.Password)
-
-	// Use a tailored client with long timeout for the MOVE operation
-	moveClient := &http.Client{
-		Timeout: 10 * time.Minute,
-	}
-
-	moveResp, err := moveClient.Do(moveReq)
-	if err != nil {
-		return 0, err
-	} // If network fails completely
-	defer moveResp.Body.Close()
-
-	if moveResp.StatusCode < 200 || moveResp.StatusCode > 299 {
-		// Attempt to read body for error details
-		b, _ := io.ReadAll(moveResp.Body)
-		// Debug Log
-		return 0, fmt.Errorf("MOVE failed: %d %s - Dest: %s - Body: %s", moveResp.StatusCode, moveResp.Status, destHeaderVal, string(b))
-	}
-
-	return time.Since(start), nil
-}
-
 // CreateDirectory creates a folder (MKCOL)
 func (c *Client) CreateDirectory(ctx context.Context, path string) error {
 	fullURL := fmt.Sprintf("%s/remote.php/dav/files/%s/%s", c.BaseURL, c.Username, path)
@@ -328,7 +218,7 @@ func (c *Client) CreateDirectory(ctx context.Context, path string) error {
 	}
 	req.SetBasicAuth(c.Username, c.Password)
 
-	resp, err := c.Client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -352,7 +242,7 @@ func (c *Client) Delete(ctx context.Context, path string) error {
 	}
 	req.SetBasicAuth(c.Username, c.Password)
 
-	resp, err := c.Client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -1,12 +1,25 @@
 package webdav
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+
+	"nextcloud-perf/internal/fakecloud"
 )
 
 func TestGetCapabilities(t *testing.T) {
@@ -91,3 +104,474 @@ func TestDownload(t *testing.T) {
 		t.Errorf("Expected content %q, got %q", expectedContent, string(content))
 	}
 }
+
+func TestTimingRecorder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 4096))
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "user", "pass", nil)
+	rec := &TimingRecorder{}
+	ctx := WithTimingRecorder(context.Background(), rec)
+
+	for i := 0; i < 2; i++ {
+		rc, err := client.Download(ctx, "test.bin")
+		if err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+		io.Copy(io.Discard, rc)
+		rc.Close()
+	}
+
+	samples := rec.Samples()
+	if len(samples) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(samples))
+	}
+	first, second := samples[0], samples[1]
+	if first.Method != "GET" || first.Status != 200 || first.Failed {
+		t.Errorf("Unexpected sample: %+v", first)
+	}
+	if first.Connect <= 0 || first.Total < first.TTFB {
+		t.Errorf("Expected connect time and total >= ttfb, got %+v", first)
+	}
+	if !second.Reused {
+		t.Error("Expected second request to reuse the connection")
+	}
+}
+
+func TestPropfind(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPFIND" {
+			t.Errorf("Expected PROPFIND, got %s", r.Method)
+		}
+		if r.Header.Get("Depth") != DepthOne {
+			t.Errorf("Expected Depth 1, got %s", r.Header.Get("Depth"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `<fileid xmlns="http://owncloud.org/ns"/>`) {
+			t.Errorf("Expected oc:fileid in request body, got %s", body)
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		io.WriteString(w, `<?xml version="1.0"?>
+<d:multistatus xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns">
+ <d:response><d:href>/remote.php/dav/files/user/tree/</d:href>
+  <d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype><d:getetag>&quot;dir1&quot;</d:getetag><oc:fileid>10</oc:fileid><oc:size>2048</oc:size></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>
+ </d:response>
+ <d:response><d:href>/remote.php/dav/files/user/tree/file%201.bin</d:href>
+  <d:propstat><d:prop><d:resourcetype/><d:getetag>&quot;f1&quot;</d:getetag><oc:fileid>11</oc:fileid><d:getcontentlength>1024</d:getcontentlength></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>
+  <d:propstat><d:prop><oc:size/></d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>
+ </d:response>
+</d:multistatus>`)
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "user", "pass", nil)
+	res, err := client.Propfind(context.Background(), "tree", DepthOne, nil)
+	if err != nil {
+		t.Fatalf("Propfind failed: %v", err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("Expected 2 resources, got %d", len(res))
+	}
+	if !res[0].IsCollection || res[0].ETag != "dir1" || res[0].Size != 2048 {
+		t.Errorf("Unexpected folder entry: %+v", res[0])
+	}
+	if res[1].IsCollection || res[1].Href != "/remote.php/dav/files/user/tree/file 1.bin" || res[1].FileID != "11" || res[1].Size != 1024 {
+		t.Errorf("Unexpected file entry: %+v", res[1])
+	}
+
+	tsForbidden := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer tsForbidden.Close()
+	_, err = NewClient(tsForbidden.URL, "user", "pass", nil).Propfind(context.Background(), "tree", DepthInfinity, nil)
+	if !errors.Is(err, ErrPROPFINDFailed) {
+		t.Errorf("Expected ErrPROPFINDFailed, got %v", err)
+	}
+}
+
+func TestUploadChunkedParallel(t *testing.T) {
+	var mu sync.Mutex
+	chunks := map[string]int{}
+	inFlight, maxInFlight := 0, 0
+	moved := false
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "MKCOL":
+			w.WriteHeader(http.StatusCreated)
+		case "PUT":
+			mu.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+			n, _ := io.Copy(io.Discard, r.Body)
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			chunks[r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]] = int(n)
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+		case "MOVE":
+			moved = r.Header.Get("OC-Total-Length") == "2500"
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "user", "pass", nil)
+	data := strings.NewReader(strings.Repeat("x", 2500))
+	_, err := client.UploadChunkedWithOptions(context.Background(), "big.bin", data, 2500, ChunkOptions{ChunkSize: 1000, Parallel: 3})
+	if err != nil {
+		t.Fatalf("UploadChunkedWithOptions failed: %v", err)
+	}
+
+	if len(chunks) != 3 || chunks["00001"] != 1000 || chunks["00002"] != 1000 || chunks["00003"] != 500 {
+		t.Errorf("Unexpected chunks: %v", chunks)
+	}
+	if maxInFlight < 2 || maxInFlight > 3 {
+		t.Errorf("Expected 2-3 chunks in flight, got %d", maxInFlight)
+	}
+	if !moved {
+		t.Error("Expected MOVE with OC-Total-Length 2500")
+	}
+}
+
+func TestUploadChunkedFailure(t *testing.T) {
+	deleted := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "MKCOL":
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == "DELETE":
+			deleted = r.URL.Path
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/00002"):
+			w.WriteHeader(http.StatusInsufficientStorage)
+		case r.Method == "MOVE":
+			t.Error("MOVE must not be sent after a failed chunk")
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "user", "pass", nil)
+	data := strings.NewReader(strings.Repeat("x", 5000))
+	_, err := client.UploadChunkedWithOptions(context.Background(), "big.bin", data, 5000, ChunkOptions{ChunkSize: 1000, Parallel: 2})
+	if !errors.Is(err, ErrChunkUploadFailed) {
+		t.Errorf("Expected ErrChunkUploadFailed, got %v", err)
+	}
+	var uploadErr *ChunkedUploadError
+	if !errors.As(err, &uploadErr) || deleted != "/remote.php/dav/uploads/user/"+uploadErr.TransferID {
+		t.Errorf("Expected upload folder to be deleted, got %q (err %v)", deleted, err)
+	}
+}
+
+func TestRetryIdempotentRequests(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts[r.Method]++
+		n := attempts[r.Method]
+		mu.Unlock()
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "user", "pass", nil)
+	client.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	rec := &TimingRecorder{}
+	ctx := WithTimingRecorder(context.Background(), rec)
+
+	rc, err := client.Download(ctx, "file.bin")
+	if err != nil {
+		t.Fatalf("Expected download to succeed after retries, got %v", err)
+	}
+	rc.Close()
+	if attempts["GET"] != 3 {
+		t.Errorf("Expected 3 GET attempts, got %d", attempts["GET"])
+	}
+	if samples := rec.Samples(); len(samples) != 3 || samples[2].Attempt != 3 {
+		t.Errorf("Expected 3 recorded attempts, got %+v", samples)
+	}
+
+	req, _ := http.NewRequest("MOVE", ts.URL+"/x", nil)
+	resp, err := client.do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if attempts["MOVE"] != 1 {
+		t.Errorf("Expected MOVE not to be retried, got %d attempts", attempts["MOVE"])
+	}
+}
+
+func TestRetryMKCOLAfterLostResponse(t *testing.T) {
+	var mu sync.Mutex
+	created := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "MKCOL" && created:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.Method == "MKCOL":
+			// Create the folder, then lose the response
+			created = true
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		default:
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "user", "pass", nil)
+	client.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	data := bytes.Repeat([]byte("x"), 4096)
+	if _, err := client.UploadChunkedWithOptions(context.Background(), "file.bin", bytes.NewReader(data), int64(len(data)), ChunkOptions{ChunkSize: 1024}); err != nil {
+		t.Errorf("Expected the 405 of a retried MKCOL to count as created, got %v", err)
+	}
+}
+
+func TestUploadChunkedResume(t *testing.T) {
+	var mu sync.Mutex
+	stored := map[string]int{}
+	puts := 0
+	failedOnce := false
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		switch r.Method {
+		case "MKCOL", "MOVE":
+			w.WriteHeader(http.StatusCreated)
+		case "PUT":
+			puts++
+			if name == "00002" && !failedOnce {
+				failedOnce = true
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			n, _ := io.Copy(io.Discard, r.Body)
+			stored[name] = int(n)
+			w.WriteHeader(http.StatusCreated)
+		case "PROPFIND":
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<d:multistatus xmlns:d="DAV:"><d:response><d:href>`+r.URL.Path+`/</d:href></d:response>`)
+			for chunk, size := range stored {
+				fmt.Fprintf(w, `<d:response><d:href>%s/%s</d:href><d:propstat><d:prop><d:getcontentlength>%d</d:getcontentlength></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, r.URL.Path, chunk, size)
+			}
+			io.WriteString(w, `</d:multistatus>`)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL, "user", "pass", nil)
+	data := strings.NewReader(strings.Repeat("x", 3000))
+	up, err := client.UploadChunkedWithOptions(context.Background(), "big.bin", data, 3000, ChunkOptions{ChunkSize: 1000, ResumeAttempts: 1})
+	if err != nil {
+		t.Fatalf("Expected resumed upload to succeed, got %v", err)
+	}
+	if up.Resumes != 1 || up.Skipped != 1 {
+		t.Errorf("Expected 1 resume skipping chunk 1, got %+v", up)
+	}
+	// 00001, failed 00002, then 00002 and 00003 after resume
+	if puts != 4 || len(stored) != 3 {
+		t.Errorf("Expected 4 PUTs and 3 stored chunks, got %d PUTs and %v", puts, stored)
+	}
+}
+
+func TestLoginFlow(t *testing.T) {
+	fake := fakecloud.New(fakecloud.Options{})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	ctx := context.Background()
+
+	client := NewClient(ts.URL, "", "", nil)
+	flow, err := client.StartLoginFlow(ctx)
+	if err != nil {
+		t.Fatalf("StartLoginFlow failed: %v", err)
+	}
+	if _, err := client.PollLogin(ctx, flow); !errors.Is(err, ErrLoginPending) {
+		t.Fatalf("Expected ErrLoginPending before login, got %v", err)
+	}
+
+	// The user opens the login page in the browser and grants access
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		resp, err := http.Get(flow.LoginURL)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	creds, err := client.WaitForLogin(ctx, flow, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitForLogin failed: %v", err)
+	}
+	if creds.LoginName != fakecloud.DefaultUser || creds.AppPassword == "" || creds.AppPassword == fakecloud.DefaultPassword {
+		t.Errorf("Unexpected credentials: %+v", creds)
+	}
+	if _, err := client.PollLogin(ctx, flow); !errors.Is(err, ErrLoginPending) {
+		t.Errorf("Expected credentials to be handed out only once, got %v", err)
+	}
+
+	app := NewClient(ts.URL, creds.LoginName, creds.AppPassword, nil)
+	if _, err := app.GetCapabilities(ctx); err != nil {
+		t.Fatalf("Expected the app password to work, got %v", err)
+	}
+	if err := app.RevokeAppPassword(ctx); err != nil {
+		t.Fatalf("RevokeAppPassword failed: %v", err)
+	}
+	if _, err := app.GetCapabilities(ctx); err == nil {
+		t.Error("Expected the revoked app password to be rejected")
+	}
+
+	regular := NewClient(ts.URL, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+	if err := regular.RevokeAppPassword(ctx); err == nil {
+		t.Error("Expected revoking a regular password to fail")
+	}
+}
+
+func TestUserProvisioning(t *testing.T) {
+	fake := fakecloud.New(fakecloud.Options{})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	ctx := context.Background()
+
+	admin := NewClient(ts.URL, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+	if err := admin.CreateUser(ctx, "load-1", "s3cret-Pass"); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if err := admin.CreateUser(ctx, "load-1", "s3cret-Pass"); err == nil || !strings.Contains(err.Error(), "User already exists") {
+		t.Errorf("Expected the OCS message for a duplicate user, got %v", err)
+	}
+
+	user := NewClient(ts.URL, "load-1", "s3cret-Pass", nil)
+	if err := user.CreateDirectory(ctx, "work"); err != nil {
+		t.Fatalf("Expected the new account to work, got %v", err)
+	}
+	if err := user.CreateUser(ctx, "load-2", "s3cret-Pass"); err == nil {
+		t.Error("Expected a regular user to be refused")
+	}
+
+	if err := admin.DeleteUser(ctx, "load-1"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if fake.HasUser("load-1") {
+		t.Error("Expected the account to be gone")
+	}
+	if err := user.CreateDirectory(ctx, "work"); err == nil {
+		t.Error("Expected the deleted account to be rejected")
+	}
+	if err := admin.DeleteUser(ctx, "load-1"); err == nil {
+		t.Error("Expected deleting a missing user to fail")
+	}
+}
+
+func TestConditionalUploadAndMove(t *testing.T) {
+	fake := fakecloud.New(fakecloud.Options{})
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+	ctx := context.Background()
+	c := NewClient(ts.URL, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+
+	etag, err := c.UploadIfMatch(ctx, "a.txt", strings.NewReader("v1"), 2, "")
+	if err != nil || etag == "" {
+		t.Fatalf("Expected the new file to be created with an ETag, got %q, %v", etag, err)
+	}
+	if _, err := c.UploadIfMatch(ctx, "a.txt", strings.NewReader("v1"), 2, ""); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Expected If-None-Match to reject the existing file, got %v", err)
+	}
+	newETag, err := c.UploadIfMatch(ctx, "a.txt", strings.NewReader("v2"), 2, etag)
+	if err != nil || newETag == etag {
+		t.Fatalf("Expected the update to yield a new ETag, got %q, %v", newETag, err)
+	}
+	if _, err := c.UploadIfMatch(ctx, "a.txt", strings.NewReader("v3"), 2, etag); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Expected the stale ETag to be rejected, got %v", err)
+	}
+
+	if _, err := c.UploadIfMatch(ctx, "b.txt", strings.NewReader("b"), 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Move(ctx, "a.txt", "b.txt", false); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Expected MOVE without overwrite to keep b.txt, got %v", err)
+	}
+	if err := c.Move(ctx, "a.txt", "c.txt", false); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if fake.Exists(fakecloud.DefaultUser, "a.txt") || !fake.Exists(fakecloud.DefaultUser, "c.txt") {
+		t.Error("Expected a.txt to be renamed to c.txt")
+	}
+	if err := c.Move(ctx, "missing.txt", "d.txt", true); !errors.Is(err, ErrMOVEFailed) {
+		t.Errorf("Expected a MOVE error for a missing source, got %v", err)
+	}
+}
+
+func TestWithProtocol(t *testing.T) {
+	fake := fakecloud.New(fakecloud.Options{})
+	ts := httptest.NewUnstartedServer(fake)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	// HTTP/3 on a UDP port with the same certificate
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("UDP not available: %v", err)
+	}
+	h3 := &http3.Server{Handler: fake, TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: ts.TLS.Certificates})}
+	go h3.Serve(udp)
+	defer h3.Close()
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		protocol, url, proto string
+	}{
+		{ProtocolHTTP1, ts.URL, "HTTP/1.1"},
+		{ProtocolHTTP2, ts.URL, "HTTP/2.0"},
+		{ProtocolHTTP3, "https://" + udp.LocalAddr().String(), "HTTP/3.0"},
+	} {
+		base := NewClient(tc.url, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+		base.Client.Transport.(*http.Transport).TLSClientConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig
+		client, err := base.WithProtocol(tc.protocol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proto, err := client.NegotiatedProtocol(ctx); err != nil || proto != tc.proto {
+			t.Errorf("%s: expected %s, got %q (%v)", tc.protocol, tc.proto, proto, err)
+		}
+		// Chunked uploads also send the final MOVE over the selected protocol
+		name := "proto-" + strings.ReplaceAll(tc.protocol, "/", "") + ".bin"
+		if _, err := client.UploadChunkedWithOptions(ctx, name, strings.NewReader(strings.Repeat("x", 3000)), 3000, ChunkOptions{ChunkSize: 1024}); err != nil {
+			t.Errorf("%s: chunked upload failed: %v", tc.protocol, err)
+		}
+		client.CloseIdleConnections()
+	}
+
+	// HTTP/1.1 only servers fail the HTTP/2 check instead of silently downgrading
+	plain := httptest.NewUnstartedServer(fake)
+	plain.Config.ErrorLog = log.New(io.Discard, "", 0)
+	plain.StartTLS()
+	defer plain.Close()
+	base := NewClient(plain.URL, fakecloud.DefaultUser, fakecloud.DefaultPassword, nil)
+	base.Client.Transport.(*http.Transport).TLSClientConfig = plain.Client().Transport.(*http.Transport).TLSClientConfig
+	client, _ := base.WithProtocol(ProtocolHTTP2)
+	if _, err := client.NegotiatedProtocol(ctx); err == nil {
+		t.Error("Expected HTTP/2 to fail against an HTTP/1.1 server")
+	}
+	if _, err := NewClient("http://localhost", "", "", nil).WithProtocol(ProtocolHTTP3); err == nil {
+		t.Error("Expected HTTP/3 to require https")
+	}
+}
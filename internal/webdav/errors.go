@@ -7,15 +7,16 @@ import (
 
 // Sentinel errors for WebDAV operations
 var (
-	ErrMOVEFailed        = errors.New("MOVE operation failed")
-	ErrChunkUploadFailed = errors.New("chunk upload failed")
-	ErrMKCOLFailed       = errors.New("MKCOL operation failed")
-	ErrDeleteFailed      = errors.New("DELETE operation failed")
-	ErrUnauthorized      = errors.New("authentication failed")
-	ErrNotFound          = errors.New("resource not found")
-	ErrPUTFailed         = errors.New("PUT operation failed")
-	ErrGETFailed         = errors.New("GET operation failed")
-	ErrPROPFINDFailed    = errors.New("PROPFIND operation failed")
+	ErrMOVEFailed         = errors.New("MOVE operation failed")
+	ErrChunkUploadFailed  = errors.New("chunk upload failed")
+	ErrMKCOLFailed        = errors.New("MKCOL operation failed")
+	ErrDeleteFailed       = errors.New("DELETE operation failed")
+	ErrUnauthorized       = errors.New("authentication failed")
+	ErrNotFound           = errors.New("resource not found")
+	ErrPUTFailed          = errors.New("PUT operation failed")
+	ErrGETFailed          = errors.New("GET operation failed")
+	ErrPROPFINDFailed     = errors.New("PROPFIND operation failed")
+	ErrPreconditionFailed = errors.New("precondition failed")
 )
 
 // NewMOVEError wraps ErrMOVEFailed with additional context
@@ -47,3 +48,8 @@ func NewPUTError(statusCode int, path string) error {
 func NewGETError(statusCode int, path string) error {
 	return fmt.Errorf("%w: HTTP %d for path %s", ErrGETFailed, statusCode, path)
 }
+
+// NewPROPFINDError wraps ErrPROPFINDFailed with additional context
+func NewPROPFINDError(statusCode int, path string) error {
+	return fmt.Errorf("%w: HTTP %d for path %s", ErrPROPFINDFailed, statusCode, path)
+}
@@ -0,0 +1,140 @@
+package webdav
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// loginFlowUserAgent names the app password in the user's security settings.
+const loginFlowUserAgent = "Nextcloud Performance Tool"
+
+// ErrLoginPending is returned by PollLogin while the user has not granted access yet.
+var ErrLoginPending = errors.New("login not completed yet")
+
+// LoginFlow is a started Login Flow v2. The user opens LoginURL in a browser,
+// logs in (including 2FA/SSO) and grants access; the tool polls meanwhile.
+type LoginFlow struct {
+	LoginURL     string
+	PollEndpoint string
+	Token        string
+}
+
+// LoginCredentials is the app password issued at the end of a login flow.
+type LoginCredentials struct {
+	Server      string `json:"server"`
+	LoginName   string `json:"loginName"`
+	AppPassword string `json:"appPassword"`
+}
+
+// StartLoginFlow starts a Login Flow v2 on the server (POST /index.php/login/v2).
+// The client's credentials are not used.
+func (c *Client) StartLoginFlow(ctx context.Context) (*LoginFlow, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/index.php/login/v2", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", loginFlowUserAgent)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("login flow returned: %s", resp.Status)
+	}
+
+	var body struct {
+		Poll struct {
+			Token    string `json:"token"`
+			Endpoint string `json:"endpoint"`
+		} `json:"poll"`
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to parse login flow: %w", err)
+	}
+	if body.Login == "" || body.Poll.Token == "" || body.Poll.Endpoint == "" {
+		return nil, errors.New("login flow response is incomplete")
+	}
+	return &LoginFlow{LoginURL: body.Login, PollEndpoint: body.Poll.Endpoint, Token: body.Poll.Token}, nil
+}
+
+// PollLogin checks once whether the user has granted access. It returns
+// ErrLoginPending until then. The server hands out the credentials only once.
+func (c *Client) PollLogin(ctx context.Context, flow *LoginFlow) (*LoginCredentials, error) {
+	form := url.Values{"token": {flow.Token}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, "POST", flow.PollEndpoint, strings.NewReader(form))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", loginFlowUserAgent)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		io.Copy(io.Discard, resp.Body)
+		return nil, ErrLoginPending
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("login poll returned: %s", resp.Status)
+	}
+
+	var creds LoginCredentials
+	if err := json.NewDecoder(resp.Body).Decode(&creds); err != nil {
+		return nil, fmt.Errorf("failed to parse login credentials: %w", err)
+	}
+	if creds.LoginName == "" || creds.AppPassword == "" {
+		return nil, errors.New("login credentials are incomplete")
+	}
+	return &creds, nil
+}
+
+// WaitForLogin polls every interval until the user has granted access or ctx ends.
+func (c *Client) WaitForLogin(ctx context.Context, flow *LoginFlow, interval time.Duration) (*LoginCredentials, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		creds, err := c.PollLogin(ctx, flow)
+		if !errors.Is(err, ErrLoginPending) {
+			return creds, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RevokeAppPassword deletes the app password the client authenticates with
+// (DELETE /ocs/v2.php/core/apppassword). Regular passwords cannot be revoked.
+func (c *Client) RevokeAppPassword(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.BaseURL+"/ocs/v2.php/core/apppassword", nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	req.Header.Set("OCS-APIRequest", "true")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("revoking app password returned: %s", resp.Status)
+	}
+	return nil
+}
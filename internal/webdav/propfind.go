@@ -0,0 +1,155 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PROPFIND depth values
+const (
+	DepthZero     = "0"
+	DepthOne      = "1"
+	DepthInfinity = "infinity"
+)
+
+// XML namespaces used by Nextcloud properties
+const (
+	NamespaceDAV       = "DAV:"
+	NamespaceOwnCloud  = "http://owncloud.org/ns"
+	NamespaceNextcloud = "http://nextcloud.org/ns"
+)
+
+// Common properties for PROPFIND requests
+var (
+	PropETag          = xml.Name{Space: NamespaceDAV, Local: "getetag"}
+	PropLastModified  = xml.Name{Space: NamespaceDAV, Local: "getlastmodified"}
+	PropContentLength = xml.Name{Space: NamespaceDAV, Local: "getcontentlength"}
+	PropResourceType  = xml.Name{Space: NamespaceDAV, Local: "resourcetype"}
+	PropFileID        = xml.Name{Space: NamespaceOwnCloud, Local: "fileid"}
+	PropSize          = xml.Name{Space: NamespaceOwnCloud, Local: "size"}
+	PropPermissions   = xml.Name{Space: NamespaceOwnCloud, Local: "permissions"}
+	PropChecksums     = xml.Name{Space: NamespaceOwnCloud, Local: "checksums"}
+)
+
+// DefaultProps is the property set requested by the desktop sync client for discovery.
+var DefaultProps = []xml.Name{PropResourceType, PropETag, PropLastModified, PropContentLength, PropFileID, PropSize, PropPermissions}
+
+// Resource is one entry of a PROPFIND multistatus response.
+type Resource struct {
+	Href         string
+	IsCollection bool
+	ETag         string
+	FileID       string
+	Size         int64               // oc:size, or getcontentlength for files
+	Props        map[xml.Name]string // All returned properties by name
+}
+
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"href"`
+		Propstats []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				Values []struct {
+					XMLName xml.Name
+					Text    string `xml:",chardata"`
+					Inner   string `xml:",innerxml"`
+				} `xml:",any"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// propfindBody builds the request body. Each property declares its own
+// default namespace so arbitrary custom properties can be requested.
+func propfindBody(props []xml.Name) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?><d:propfind xmlns:d="DAV:"><d:prop>`)
+	for _, p := range props {
+		fmt.Fprintf(&sb, `<%s xmlns="%s"/>`, p.Local, p.Space)
+	}
+	sb.WriteString(`</d:prop></d:propfind>`)
+	return sb.String()
+}
+
+// Propfind lists the properties of remotePath (relative to the user's files)
+// and, depending on depth, its children. If props is empty, DefaultProps are requested.
+func (c *Client) Propfind(ctx context.Context, remotePath string, depth string, props []xml.Name) ([]Resource, error) {
+	if len(props) == 0 {
+		props = DefaultProps
+	}
+	targetURL := fmt.Sprintf("%s/remote.php/dav/files/%s/%s", c.BaseURL, c.Username, strings.TrimPrefix(remotePath, "/"))
+	resources, _, err := c.propfind(ctx, targetURL, depth, props)
+	return resources, err
+}
+
+// propfind sends a PROPFIND to an absolute URL and also returns the response status.
+func (c *Client) propfind(ctx context.Context, targetURL string, depth string, props []xml.Name) ([]Resource, int, error) {
+	c.LogFunc(fmt.Sprintf("PROPFIND (depth %s): %s", depth, targetURL))
+
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", targetURL, strings.NewReader(propfindBody(props)))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		io.Copy(io.Discard, resp.Body)
+		return nil, resp.StatusCode, NewPROPFINDError(resp.StatusCode, req.URL.Path)
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("%w: invalid multistatus response: %v", ErrPROPFINDFailed, err)
+	}
+	return parseMultistatus(&ms), resp.StatusCode, nil
+}
+
+func parseMultistatus(ms *multistatus) []Resource {
+	resources := make([]Resource, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		href := r.Href
+		if u, err := url.PathUnescape(href); err == nil {
+			href = u
+		}
+		res := Resource{Href: href, Props: map[xml.Name]string{}}
+		for _, ps := range r.Propstats {
+			// Missing properties are reported in a separate 404 propstat
+			if ps.Status != "" && !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			for _, v := range ps.Prop.Values {
+				// Text for plain values, raw XML for nested ones like resourcetype
+				val := strings.TrimSpace(v.Text)
+				if val == "" {
+					val = strings.TrimSpace(v.Inner)
+				}
+				res.Props[v.XMLName] = val
+			}
+		}
+		res.IsCollection = strings.Contains(res.Props[PropResourceType], "collection")
+		res.ETag = strings.Trim(res.Props[PropETag], `"`)
+		res.FileID = res.Props[PropFileID]
+		size := res.Props[PropSize]
+		if size == "" {
+			size = res.Props[PropContentLength]
+		}
+		res.Size, _ = strconv.ParseInt(size, 10, 64)
+		resources = append(resources, res)
+	}
+	return resources
+}
@@ -0,0 +1,124 @@
+package webdav
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// HTTP protocol versions selectable with Client.WithProtocol, named by
+// their ALPN identifiers.
+const (
+	ProtocolHTTP1 = "http/1.1"
+	ProtocolHTTP2 = "h2"
+	ProtocolHTTP3 = "h3"
+)
+
+// Protocols lists the selectable protocols in comparison order.
+var Protocols = []string{ProtocolHTTP1, ProtocolHTTP2, ProtocolHTTP3}
+
+// protoNames maps the protocols to the version reported in http.Response.Proto.
+var protoNames = map[string]string{
+	ProtocolHTTP1: "HTTP/1.1",
+	ProtocolHTTP2: "HTTP/2.0",
+	ProtocolHTTP3: "HTTP/3.0",
+}
+
+// newTransport returns the pooled transport used by NewClient. HTTP/2 is
+// used only if protocol is ProtocolHTTP2, then also without TLS (h2c).
+func newTransport(protocol string, tlsConfig *tls.Config) *http.Transport {
+	protocols := new(http.Protocols)
+	if protocol == ProtocolHTTP2 {
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+	} else {
+		protocols.SetHTTP1(true)
+	}
+	return &http.Transport{
+		TLSClientConfig: tlsConfig,
+		Protocols:       protocols,
+
+		// Connection Pooling Configuration
+		MaxIdleConns:        100,              // Maximum idle connections across all hosts
+		MaxIdleConnsPerHost: 20,               // Maximum idle connections per host
+		MaxConnsPerHost:     50,               // Maximum connections per host (including active)
+		IdleConnTimeout:     90 * time.Second, // How long idle connections stay open
+
+		// Timeout Configuration
+		TLSHandshakeTimeout:   10 * time.Second, // TLS handshake timeout
+		ResponseHeaderTimeout: 10 * time.Second, // Response header read timeout
+		ExpectContinueTimeout: 1 * time.Second,  // Time to wait for 100-Continue response
+
+		// Keep-Alive enabled (improves performance for multiple requests)
+		DisableKeepAlives: false,
+	}
+}
+
+// WithProtocol returns a copy of the client that talks the given protocol
+// over its own connection pool. The TLS configuration of c is kept. HTTP/3
+// runs over QUIC and therefore requires an https URL.
+func (c *Client) WithProtocol(protocol string) (*Client, error) {
+	protocol = strings.ToLower(protocol)
+	var tlsConfig *tls.Config
+	switch t := c.Client.Transport.(type) {
+	case *http.Transport:
+		tlsConfig = t.TLSClientConfig
+	case *http3.Transport:
+		tlsConfig = t.TLSClientConfig
+	}
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.NextProtos = nil // Set by the transport for the protocol
+	}
+
+	var rt http.RoundTripper
+	switch protocol {
+	case ProtocolHTTP1, ProtocolHTTP2:
+		rt = newTransport(protocol, tlsConfig)
+	case ProtocolHTTP3:
+		if !strings.HasPrefix(strings.ToLower(c.BaseURL), "https://") {
+			return nil, fmt.Errorf("HTTP/3 requires an https URL")
+		}
+		rt = &http3.Transport{TLSClientConfig: tlsConfig}
+	default:
+		return nil, fmt.Errorf("unknown protocol %q, use %s", protocol, strings.Join(Protocols, ", "))
+	}
+
+	clone := *c
+	clone.Protocol = protocol
+	clone.Client = &http.Client{Timeout: c.Client.Timeout, Transport: rt}
+	return &clone, nil
+}
+
+// CloseIdleConnections closes the idle connections of the client's pool, so
+// that the next request has to connect again.
+func (c *Client) CloseIdleConnections() {
+	c.Client.CloseIdleConnections()
+}
+
+// NegotiatedProtocol requests status.php and returns the protocol version
+// the server answered with, e.g. "HTTP/2.0". It fails if that is not the
+// selected protocol, e.g. because the server does not offer HTTP/2.
+func (c *Client) NegotiatedProtocol(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/status.php", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if want := protoNames[c.Protocol]; want != "" && resp.Proto != want {
+		return resp.Proto, fmt.Errorf("server answered with %s instead of %s", resp.Proto, want)
+	}
+	return resp.Proto, nil
+}
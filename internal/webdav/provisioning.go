@@ -0,0 +1,65 @@
+package webdav
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ocsUsersPath is the user endpoint of the OCS provisioning API.
+const ocsUsersPath = "/ocs/v2.php/cloud/users"
+
+// CreateUser creates an account via the OCS provisioning API. The client
+// must be authenticated as an administrator.
+func (c *Client) CreateUser(ctx context.Context, userID, password string) error {
+	form := url.Values{"userid": {userID}, "password": {password}}
+	return c.ocsUsers(ctx, "POST", ocsUsersPath, strings.NewReader(form.Encode()))
+}
+
+// DeleteUser deletes an account and its files via the OCS provisioning API.
+func (c *Client) DeleteUser(ctx context.Context, userID string) error {
+	return c.ocsUsers(ctx, "DELETE", ocsUsersPath+"/"+url.PathEscape(userID), nil)
+}
+
+func (c *Client) ocsUsers(ctx context.Context, method, path string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	req.Header.Set("OCS-APIRequest", "true")
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 401 {
+		return ErrUnauthorized
+	}
+	if resp.StatusCode == 200 {
+		return nil
+	}
+
+	// OCS v2 reports the reason in the meta block, e.g. "User already exists"
+	var ocs struct {
+		OCS struct {
+			Meta struct {
+				Message string `json:"message"`
+			} `json:"meta"`
+		} `json:"ocs"`
+	}
+	msg := resp.Status
+	if json.NewDecoder(resp.Body).Decode(&ocs) == nil && ocs.OCS.Meta.Message != "" {
+		msg += ": " + ocs.OCS.Meta.Message
+	}
+	return fmt.Errorf("provisioning API %s %s returned %s", method, path, msg)
+}
@@ -0,0 +1,137 @@
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nextcloud-perf/internal/config"
+)
+
+// RetryPolicy controls how often failed idempotent requests are repeated.
+// The delay before attempt n+1 is BaseDelay * 2^(n-1) with full jitter, capped at MaxDelay.
+type RetryPolicy struct {
+	MaxAttempts int // Total attempts including the first; <= 1 disables retries
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used by NewClient.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: config.DefaultRetryAttempts,
+		BaseDelay:   config.RetryBaseDelay,
+		MaxDelay:    config.RetryMaxDelay,
+	}
+}
+
+// backoff returns the jittered delay after the given failed attempt (1-based).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// idempotentMethods may be repeated without changing the outcome.
+// MOVE and POST are never retried. MKCOL is not idempotent: a retry after a
+// lost response finds the collection created and gets 405, which
+// doWithRetry turns into the 201 of the earlier attempt.
+var idempotentMethods = map[string]bool{
+	"GET": true, "HEAD": true, "OPTIONS": true, "PUT": true, "DELETE": true, "PROPFIND": true, "MKCOL": true,
+}
+
+// canRetry reports whether req is idempotent and its body can be replayed.
+func canRetry(req *http.Request) bool {
+	if !idempotentMethods[req.Method] {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// shouldRetry reports whether the outcome of an attempt is a transient failure.
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the delay requested by a Retry-After header in seconds, or 0.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// doWithRetry sends req via hc, repeating idempotent requests on transient
+// failures according to c.Retry. Every attempt is recorded as its own timing sample.
+func (c *Client) doWithRetry(hc *http.Client, req *http.Request) (*http.Response, error) {
+	maxAttempts := c.Retry.MaxAttempts
+	if maxAttempts < 1 || !canRetry(req) {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := c.send(hc, req, attempt)
+		if attempt > 1 && req.Method == "MKCOL" && resp != nil && resp.StatusCode == http.StatusMethodNotAllowed {
+			resp.StatusCode, resp.Status = http.StatusCreated, "201 Created"
+		}
+		if attempt >= maxAttempts || !shouldRetry(resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		delay := c.Retry.backoff(attempt)
+		reason := fmt.Sprint(err)
+		if resp != nil {
+			if ra := retryAfter(resp); ra > 0 && (c.Retry.MaxDelay == 0 || ra <= c.Retry.MaxDelay) {
+				delay = ra
+			}
+			reason = resp.Status
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		c.LogFunc(fmt.Sprintf("Retrying %s %s in %v (attempt %d/%d): %s", req.Method, req.URL.Path, delay.Round(time.Millisecond), attempt+1, maxAttempts, reason))
+
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,92 @@
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// UploadIfMatch uploads like the desktop sync client: the PUT only succeeds
+// if the server-side file still has the given ETag, or, with an empty etag,
+// if the file does not exist yet. A file changed in the meantime by another
+// client yields ErrPreconditionFailed. Returns the ETag of the new version.
+func (c *Client) UploadIfMatch(ctx context.Context, remotePath string, data io.Reader, size int64, etag string) (string, error) {
+	targetURL := c.filesURL(remotePath)
+	condition := "If-None-Match *"
+	if etag != "" {
+		condition = fmt.Sprintf("If-Match %q", etag)
+	}
+	c.LogFunc(fmt.Sprintf("PUT (%s): %s (%d bytes)", condition, targetURL, size))
+	req, err := http.NewRequestWithContext(ctx, "PUT", targetURL, data)
+	if err != nil {
+		return "", err
+	}
+	if size > 0 {
+		req.ContentLength = size
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	if etag == "" {
+		req.Header.Set("If-None-Match", "*")
+	} else {
+		req.Header.Set("If-Match", `"`+etag+`"`)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return "", fmt.Errorf("%w: %s changed on the server", ErrPreconditionFailed, remotePath)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return "", NewPUTError(resp.StatusCode, remotePath)
+	}
+	// Nextcloud sends the ETag of the new version in OC-ETag and ETag
+	newETag := resp.Header.Get("OC-ETag")
+	if newETag == "" {
+		newETag = resp.Header.Get("ETag")
+	}
+	return strings.Trim(newETag, `"`), nil
+}
+
+// Move renames a file or folder within the user's files. Without overwrite
+// an existing destination yields ErrPreconditionFailed.
+func (c *Client) Move(ctx context.Context, from, to string, overwrite bool) error {
+	c.LogFunc(fmt.Sprintf("MOVE %s -> %s", from, to))
+	req, err := http.NewRequestWithContext(ctx, "MOVE", c.filesURL(from), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	req.Header.Set("Destination", c.filesURL(to))
+	if overwrite {
+		req.Header.Set("Overwrite", "T")
+	} else {
+		req.Header.Set("Overwrite", "F")
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return fmt.Errorf("%w: %s exists", ErrPreconditionFailed, to)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return NewMOVEError(resp.StatusCode, string(b))
+	}
+	return nil
+}
+
+// filesURL returns the WebDAV URL of a path relative to the user's files.
+func (c *Client) filesURL(remotePath string) string {
+	return fmt.Sprintf("%s/remote.php/dav/files/%s/%s", c.BaseURL, c.Username, strings.TrimPrefix(remotePath, "/"))
+}
@@ -0,0 +1,198 @@
+package webdav
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestTiming holds the phases of a single HTTP request, measured via httptrace.
+// Phases that did not happen (e.g. DNS and TLS on a reused connection) are zero.
+type RequestTiming struct {
+	Method   string        `json:"method"`
+	Path     string        `json:"path"`
+	Status   int           `json:"status"`
+	Attempt  int           `json:"attempt"`  // 1 for the first try, > 1 for retries
+	Reused   bool          `json:"reused"`   // Connection was taken from the pool
+	DNS      time.Duration `json:"dns"`      // DNS lookup
+	Connect  time.Duration `json:"connect"`  // TCP connect
+	TLS      time.Duration `json:"tls"`      // TLS handshake
+	Send     time.Duration `json:"send"`     // Writing request headers and body
+	TTFB     time.Duration `json:"ttfb"`     // Request written until first response byte (server processing)
+	Transfer time.Duration `json:"transfer"` // First response byte until body fully read
+	Total    time.Duration `json:"total"`
+	Failed   bool          `json:"failed"`
+}
+
+// TimingRecorder collects a RequestTiming for every request the Client makes
+// with a context returned by WithTimingRecorder. It is safe for concurrent use.
+type TimingRecorder struct {
+	mu      sync.Mutex
+	samples []RequestTiming
+}
+
+// Samples returns a copy of all recorded timings.
+func (r *TimingRecorder) Samples() []RequestTiming {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RequestTiming(nil), r.samples...)
+}
+
+// Drain returns all recorded timings and clears the recorder, so that a long
+// run can be split into intervals.
+func (r *TimingRecorder) Drain() []RequestTiming {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	samples := r.samples
+	r.samples = nil
+	return samples
+}
+
+func (r *TimingRecorder) add(t RequestTiming) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = append(r.samples, t)
+}
+
+type recorderKey struct{}
+
+// WithTimingRecorder returns a context that makes the Client record per-request timings into rec.
+func WithTimingRecorder(ctx context.Context, rec *TimingRecorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, rec)
+}
+
+func recorderFrom(ctx context.Context) *TimingRecorder {
+	rec, _ := ctx.Value(recorderKey{}).(*TimingRecorder)
+	return rec
+}
+
+// requestTracer captures httptrace timestamps of one request.
+type requestTracer struct {
+	mu                    sync.Mutex
+	start                 time.Time
+	dnsStart, dnsDone     time.Time
+	connStart, connDone   time.Time
+	tlsStart, tlsDone     time.Time
+	gotConn, wroteRequest time.Time
+	firstByte             time.Time
+	reused                bool
+}
+
+func (t *requestTracer) set(field *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if field.IsZero() {
+		*field = time.Now()
+	}
+}
+
+func (t *requestTracer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart:      func(string, string) { t.set(&t.connStart) },
+		ConnectDone:       func(string, string, error) { t.set(&t.connDone) },
+		TLSHandshakeStart: func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+			t.set(&t.gotConn)
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte) },
+	}
+}
+
+func since(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+// finish converts the timestamps into a RequestTiming ending at end.
+func (t *requestTracer) finish(req *http.Request, attempt, status int, end time.Time, failed bool) RequestTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timing := RequestTiming{
+		Method:  req.Method,
+		Path:    req.URL.Path,
+		Status:  status,
+		Attempt: attempt,
+		Reused:  t.reused,
+		DNS:     since(t.dnsStart, t.dnsDone),
+		Connect: since(t.connStart, t.connDone),
+		TLS:     since(t.tlsStart, t.tlsDone),
+		Send:    since(t.gotConn, t.wroteRequest),
+		TTFB:    since(t.wroteRequest, t.firstByte),
+		Total:   since(t.start, end),
+		Failed:  failed,
+	}
+	if !t.firstByte.IsZero() {
+		timing.Transfer = since(t.firstByte, end)
+	}
+	return timing
+}
+
+// timedBody records the request timing once the response body is fully read or closed.
+type timedBody struct {
+	io.ReadCloser
+	once   sync.Once
+	record func()
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.record)
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.record)
+	return err
+}
+
+// do sends req with c.Client, retrying transient failures of idempotent requests.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	return c.doWithRetry(c.Client, req)
+}
+
+// doWith is like do but uses hc, e.g. a client with a longer timeout.
+func (c *Client) doWith(hc *http.Client, req *http.Request) (*http.Response, error) {
+	return c.doWithRetry(hc, req)
+}
+
+// send performs a single attempt and records its timing if the request
+// context carries a TimingRecorder.
+func (c *Client) send(hc *http.Client, req *http.Request, attempt int) (*http.Response, error) {
+	rec := recorderFrom(req.Context())
+	if rec == nil {
+		return hc.Do(req)
+	}
+
+	tracer := &requestTracer{start: time.Now()}
+	traced := req.WithContext(httptrace.WithClientTrace(req.Context(), tracer.trace()))
+	resp, err := hc.Do(traced)
+	if err != nil {
+		rec.add(tracer.finish(req, attempt, 0, time.Now(), true))
+		return nil, err
+	}
+
+	failed := resp.StatusCode >= 400
+	resp.Body = &timedBody{
+		ReadCloser: resp.Body,
+		record: func() {
+			rec.add(tracer.finish(req, attempt, resp.StatusCode, time.Now(), failed))
+		},
+	}
+	return resp, nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"nextcloud-perf/internal/benchmark"
+	"nextcloud-perf/internal/config"
 	"nextcloud-perf/internal/network"
 	"nextcloud-perf/internal/report"
 	"nextcloud-perf/internal/system"
@@ -22,10 +23,32 @@ type Reporter interface {
 }
 
 // BenchmarkOptions contains the necessary credentials and target for the benchmark.
+// A nil Profile runs the default profile.
 type BenchmarkOptions struct {
-	URL  string
-	User string
-	Pass string
+	URL     string
+	User    string
+	Pass    string
+	Profile *config.BenchmarkProfile
+
+	// Thresholds are evaluated against the finished report if set
+	Thresholds *config.Thresholds
+
+	// Protocol selects the HTTP version of all requests: config.ProtocolHTTP1
+	// (default), ProtocolHTTP2 or ProtocolHTTP3.
+	Protocol string
+
+	// RevokeAppPassword deletes Pass on the server after the run. Set it
+	// for app passwords issued by a login flow just for this benchmark.
+	RevokeAppPassword bool
+
+	// MTRCycles > 1 probes every traceroute hop that many times for loss
+	// and latency per hop. ASN, if set, adds the autonomous system of the hops.
+	MTRCycles int
+	ASN       *network.ASNDatabase
+
+	// EndpointSamples is the number of requests per endpoint for the request
+	// phase breakdown, 0 means config.DefaultEndpointSamples.
+	EndpointSamples int
 }
 
 // Helper to convert []error to []string
@@ -54,9 +77,15 @@ func formatBytes(b uint64) string {
 
 // Run executes the full benchmark suite.
 func Run(ctx context.Context, opts BenchmarkOptions, reporter Reporter) {
+	profile := opts.Profile
+	if profile == nil {
+		profile = config.DefaultProfile()
+	}
+
 	rpt := report.ReportData{
 		GeneratedAt: time.Now(),
 		TargetURL:   opts.URL,
+		Profile:     profile.Name,
 	}
 
 	reporter.Broadcast("Starting Benchmark...")
@@ -72,6 +101,24 @@ func Run(ctx context.Context, opts BenchmarkOptions, reporter Reporter) {
 	client := webdav.NewClient(opts.URL, opts.User, opts.Pass, func(msg string) {
 		reporter.Broadcast(msg)
 	})
+	if opts.RevokeAppPassword {
+		defer revokeAppPassword(client, reporter)
+	}
+	if opts.Protocol != "" && opts.Protocol != config.ProtocolHTTP1 {
+		pc, err := client.WithProtocol(opts.Protocol)
+		var negotiated string
+		if err == nil {
+			negotiated, err = pc.NegotiatedProtocol(ctx)
+		}
+		if err != nil {
+			rpt.Error = fmt.Sprintf("Pre-flight Error: %s not available: %v", opts.Protocol, err)
+			reporter.Broadcast(rpt.Error)
+			return
+		}
+		client = pc
+		rpt.Protocol = opts.Protocol
+		reporter.Broadcast(fmt.Sprintf("Using protocol %s (%s)", opts.Protocol, negotiated))
+	}
 
 	status, err := client.GetStatus(ctx)
 	if err != nil {
@@ -94,24 +141,7 @@ func Run(ctx context.Context, opts BenchmarkOptions, reporter Reporter) {
 	reporter.SendResult(rpt)
 
 	// 1. SYSTEM INFO
-	reporter.Broadcast("Collecting System Information...")
-	sys, err := system.GetSystemInfo()
-	if err != nil {
-		reporter.Broadcast(fmt.Sprintf("Warning: Could not get system info: %v", err))
-		rpt.SystemOS = "Unknown"
-		rpt.CPU = report.CPUInfo{Model: "Unknown", Usage: 0}
-		rpt.RAM = report.RAMInfo{Total: "Unknown", Free: "Unknown", Used: "Unknown", Usage: 0}
-	} else {
-		rpt.SystemOS = sys.OS
-		rpt.CPU = report.CPUInfo{Model: sys.CPUModel, Usage: sys.CPUUsage}
-		rpt.PeakCPUUsage = sys.CPUUsage
-		rpt.RAM = report.RAMInfo{
-			Total: formatBytes(sys.RAMTotal),
-			Free:  formatBytes(sys.RAMFree),
-			Used:  formatBytes(sys.RAMUsed),
-			Usage: sys.RAMUsage,
-		}
-	}
+	collectSystemInfo(&rpt, reporter)
 	reporter.SendResult(rpt)
 
 	// DISK I/O
@@ -137,34 +167,34 @@ func Run(ctx context.Context, opts BenchmarkOptions, reporter Reporter) {
 	reporter.SendResult(rpt)
 
 	// 1c. REFERENCE SPEEDTEST
-	reporter.Broadcast("Running Reference Speedtest (Speedtest.net)...")
-	stRes, err := network.RunSpeedtest(func(msg string) {
-		reporter.Broadcast("Speedtest: " + msg)
-	})
-	if err != nil {
-		reporter.Broadcast(fmt.Sprintf("Speedtest Warning: %v", err))
-		// Ensure we send an empty result with error so UI knows it finished/failed
-		rpt.Speedtest = &network.SpeedtestResult{Error: err.Error()}
-		reporter.SendResult(rpt)
+	if profile.SkipSpeedtest {
+		reporter.Broadcast("Reference Speedtest skipped by profile.")
 	} else {
-		rpt.Speedtest = stRes
-		reporter.Broadcast(fmt.Sprintf("Ref Speed: %.2f Mbps Down / %.2f Mbps Up", stRes.DownloadSpeed, stRes.UploadSpeed))
-		reporter.SendResult(rpt)
+		reporter.Broadcast("Running Reference Speedtest (Speedtest.net)...")
+		stRes, err := network.RunSpeedtest(func(msg string) {
+			reporter.Broadcast("Speedtest: " + msg)
+		})
+		if err != nil {
+			reporter.Broadcast(fmt.Sprintf("Speedtest Warning: %v", err))
+			// Ensure we send an empty result with error so UI knows it finished/failed
+			rpt.Speedtest = &network.SpeedtestResult{Error: err.Error()}
+			reporter.SendResult(rpt)
+		} else {
+			rpt.Speedtest = stRes
+			reporter.Broadcast(fmt.Sprintf("Ref Speed: %.2f Mbps Down / %.2f Mbps Up", stRes.DownloadSpeed, stRes.UploadSpeed))
+			reporter.SendResult(rpt)
+		}
 	}
 
 	// 1d. EXTENDED NETWORK INFO
-	reporter.Broadcast("Detecting Advanced Network Stats (SSL, VPN, MTU)...")
+	reporter.Broadcast("Detecting Advanced Network Stats (VPN, Proxy)...")
 	extNet := network.GetExtendedNetworkInfo()
 	rpt.AdvancedNet.VPNDetected = extNet.VPNDetected
 	rpt.AdvancedNet.VPNType = extNet.VPNType
 	rpt.AdvancedNet.ProxyDetected = extNet.ProxyDetected
-	rpt.AdvancedNet.MTU = extNet.MTU
+	// MTU is left to the path MTU discovery: the smallest interface MTU is
+	// not the path MTU and would hide a failed discovery behind a number
 
-	tlsDur, errTLS := network.MeasureTLSHandshake(opts.URL)
-	if errTLS == nil {
-		rpt.AdvancedNet.TLSHandshakeMs = float64(tlsDur.Milliseconds())
-		reporter.Broadcast(fmt.Sprintf("SSL Handshake: %.1f ms", rpt.AdvancedNet.TLSHandshakeMs))
-	}
 	if extNet.VPNDetected {
 		reporter.Broadcast(fmt.Sprintf("VPN Detected: %s", extNet.VPNType))
 	}
@@ -224,20 +254,41 @@ func Run(ctx context.Context, opts BenchmarkOptions, reporter Reporter) {
 		reporter.SendResult(rpt)
 	}
 
-	// C. Traceroute
-	reporter.Broadcast("Running Traceroute (may require admin)...")
-	hops, err := network.RunTraceroute(hostOnly, 15)
+	// C. ICMP Ping, unprivileged where the system allows it
+	reporter.Broadcast("Running ICMP Ping (10 packets)...")
+	icmpStats, err := network.MeasureICMPPing(hostOnly, 10, 2*time.Second)
 	if err != nil {
-		reporter.Broadcast(fmt.Sprintf("Traceroute: Skipped (%v)", err))
+		reporter.Broadcast(fmt.Sprintf("ICMP Ping: Skipped (%v)", err))
 	} else {
-		reporter.Broadcast(fmt.Sprintf("Traceroute: Found %d hops", len(hops)))
-		for _, h := range hops {
-			hh := fmt.Sprintf("%d: %s (%v)", h.TTL, h.Address, h.RTT)
-			rpt.Traceroute = append(rpt.Traceroute, hh)
-		}
+		rpt.ICMPPing = &icmpStats
+		reporter.Broadcast(fmt.Sprintf("ICMP Ping (%s): Avg=%.2fms | Min=%.2fms | Max=%.2fms | Loss=%.1f%%",
+			icmpStats.Method, icmpStats.AvgMs, icmpStats.MinMs, icmpStats.MaxMs, icmpStats.PacketLoss))
 		reporter.SendResult(rpt)
 	}
 
+	// D. IPv4 and IPv6 paths with traceroute, falling back to unprivileged
+	// methods without root
+	runDualStack(hostOnly, tcpTarget, network.TracerouteOptions{
+		MaxHops:      config.DefaultTracerouteMaxHops,
+		Cycles:       opts.MTRCycles,
+		Interval:     config.DefaultMTRInterval,
+		ResolveNames: true,
+		ASN:          opts.ASN,
+	}, &rpt, reporter)
+
+	// E. Path MTU, MTU black holes stall large transfers
+	runPathMTU(hostOnly, tcpTarget, &rpt, reporter)
+
+	// Request phases: network or server? status.php needs no login, so it and
+	// its TLS handshake are measured before the login is checked below. The
+	// authenticated endpoints follow once it succeeded, failed logins would
+	// trip the brute-force protection of the server.
+	samples := opts.EndpointSamples
+	if samples <= 0 {
+		samples = config.DefaultEndpointSamples
+	}
+	runEndpoints(ctx, client, samples, &rpt, reporter, benchmark.EndpointStatus)
+
 	// 3. WEBDAV
 	reporter.Broadcast("Connecting to Nextcloud WebDAV...")
 	// Client already created in pre-flight
@@ -249,6 +300,7 @@ func Run(ctx context.Context, opts BenchmarkOptions, reporter Reporter) {
 	}
 	rpt.ServerVer = caps.Ocs.Data.Version.String
 	reporter.Broadcast(fmt.Sprintf("Connected! Server: Nextcloud %s", rpt.ServerVer))
+	runEndpoints(ctx, client, samples, &rpt, reporter, benchmark.EndpointOCS, benchmark.EndpointPropfind)
 
 	testFolder := fmt.Sprintf("perf-test-%d", time.Now().Unix())
 	reporter.Broadcast("Creating test directory...")
@@ -278,76 +330,34 @@ func Run(ctx context.Context, opts BenchmarkOptions, reporter Reporter) {
 	defer close(monitorDone)
 
 	// 4. BENCHMARKS
-	// Small Files: 5 x 512KB
-	reporter.Broadcast("Starting Small Files Test (5 x 512KB)...")
-	resSmall, err := benchmark.RunSmallFiles(ctx, client, testFolder, "test_small_", 5, 512*1024, 5)
-	if err != nil {
-		rpt.SmallFiles.Errors = []string{err.Error()}
-		reporter.Broadcast(fmt.Sprintf("Small Files Error: %v", err))
-	} else {
-		rpt.SmallFiles = report.SpeedResult{SpeedMBps: resSmall.SpeedMBps, Duration: resSmall.Duration, Errors: errsToStrings(resSmall.Errors)}
-		reporter.Broadcast(fmt.Sprintf("Small Files Upload: %.2f MB/s", resSmall.SpeedMBps))
-	}
-
-	// Small Files Download
-	reporter.Broadcast("Starting Small Files Download (5 x 512KB)...")
-	resSmallDown, err := benchmark.RunDownloadSmallFiles(ctx, client, testFolder, "test_small_", 5, 5)
-	if err != nil {
-		rpt.SmallFilesDown.Errors = []string{err.Error()}
-		reporter.Broadcast(fmt.Sprintf("Download Error: %v", err))
-	} else {
-		rpt.SmallFilesDown = report.SpeedResult{SpeedMBps: resSmallDown.SpeedMBps, Duration: resSmallDown.Duration, Errors: errsToStrings(resSmallDown.Errors)}
-		reporter.Broadcast(fmt.Sprintf("Small Files Download: %.2f MB/s", resSmallDown.SpeedMBps))
+	reporter.Broadcast(fmt.Sprintf("Running profile %q (%d scenarios)...", profile.Name, len(profile.Scenarios)))
+	for i, sc := range profile.Scenarios {
+		if ctx.Err() != nil {
+			reporter.Broadcast("Benchmark cancelled, skipping remaining scenarios.")
+			break
+		}
+		rpt.Scenarios = append(rpt.Scenarios, runScenario(ctx, client, testFolder, sc, checksumType(profile), i+1, len(profile.Scenarios), reporter))
+		reporter.SendResult(rpt) // Send updated results
 	}
-	reporter.SendResult(rpt) // Send updated results
 
-	// Medium Files: 3 x 5MB (sequential for accurate speed measurement)
-	reporter.Broadcast("Starting Medium Files Test (3 x 5MB)...")
-	resMedium, err := benchmark.RunSmallFiles(ctx, client, testFolder, "test_medium_", 3, 5*1024*1024, 1)
-	if err != nil {
-		rpt.MediumFiles.Errors = []string{err.Error()}
-		reporter.Broadcast(fmt.Sprintf("Medium Files Error: %v", err))
-	} else {
-		rpt.MediumFiles = report.SpeedResult{SpeedMBps: resMedium.SpeedMBps, Duration: resMedium.Duration, Errors: errsToStrings(resMedium.Errors)}
-		reporter.Broadcast(fmt.Sprintf("Medium Files Upload: %.2f MB/s", resMedium.SpeedMBps))
+	if profile.Metadata != nil && ctx.Err() == nil {
+		rpt.Metadata = runMetadata(ctx, client, testFolder, *profile.Metadata, reporter)
+		reporter.SendResult(rpt)
 	}
 
-	// Medium Files Download
-	reporter.Broadcast("Starting Medium Files Download (3 x 5MB)...")
-	resMediumDown, err := benchmark.RunDownloadSmallFiles(ctx, client, testFolder, "test_medium_", 3, 1)
-	if err != nil {
-		rpt.MediumFilesDown.Errors = []string{err.Error()}
-		reporter.Broadcast(fmt.Sprintf("Medium Download Error: %v", err))
-	} else {
-		rpt.MediumFilesDown = report.SpeedResult{SpeedMBps: resMediumDown.SpeedMBps, Duration: resMediumDown.Duration, Errors: errsToStrings(resMediumDown.Errors)}
-		reporter.Broadcast(fmt.Sprintf("Medium Files Download: %.2f MB/s", resMediumDown.SpeedMBps))
+	if profile.Sync != nil && ctx.Err() == nil {
+		rpt.Sync = runSync(ctx, client, testFolder, *profile.Sync, reporter)
+		reporter.SendResult(rpt)
 	}
-	reporter.SendResult(rpt) // Send updated results
 
-	// Large File: 256MB with Chunking
-	reporter.Broadcast("Starting Large File Test (256MB with Chunking)...")
-	resLarge, err := benchmark.RunLargeFile(ctx, client, testFolder, 256*1024*1024, true)
-	if err != nil {
-		rpt.LargeFile.Errors = []string{err.Error()}
-		reporter.Broadcast(fmt.Sprintf("Large File Error: %v", err))
+	if profile.Protocols != nil && ctx.Err() == nil {
+		runProtocols(ctx, client, testFolder, *profile.Protocols, checksumType(profile), &rpt, reporter)
 	}
-	rpt.LargeFile = report.SpeedResult{SpeedMBps: resLarge.SpeedMBps, Duration: resLarge.Duration, Errors: errsToStrings(resLarge.Errors)}
-	if len(resLarge.Errors) > 0 {
-		reporter.Broadcast(fmt.Sprintf("Large File Warning: %v", resLarge.Errors))
-	}
-	reporter.Broadcast(fmt.Sprintf("Large File Upload: %.2f MB/s", resLarge.SpeedMBps))
 
-	// Large File Download
-	reporter.Broadcast("Starting Large File Download...")
-	resLargeDown, err := benchmark.RunDownloadLargeFile(ctx, client, testFolder)
-	if err != nil {
-		rpt.LargeFileDown.Errors = []string{err.Error()}
-		reporter.Broadcast(fmt.Sprintf("Large Download Error: %v", err))
-	} else {
-		rpt.LargeFileDown = report.SpeedResult{SpeedMBps: resLargeDown.SpeedMBps, Duration: resLargeDown.Duration, Errors: errsToStrings(resLargeDown.Errors)}
-		reporter.Broadcast(fmt.Sprintf("Large File Download: %.2f MB/s", resLargeDown.SpeedMBps))
+	if profile.Soak != nil && ctx.Err() == nil {
+		runSoak(ctx, client, testFolder, *profile.Soak, &rpt, reporter)
+		reporter.SendResult(rpt)
 	}
-	reporter.SendResult(rpt) // Send updated results
 
 	// CLEANUP FIRST (before report)
 	reporter.Broadcast("Cleaning up test files...")
@@ -356,6 +366,15 @@ func Run(ctx context.Context, opts BenchmarkOptions, reporter Reporter) {
 	}
 	reporter.Broadcast("Cleanup complete.")
 
+	if opts.Thresholds != nil {
+		rpt.Verdict = report.Evaluate(opts.Thresholds, rpt)
+		if rpt.Verdict.Passed {
+			reporter.Broadcast(fmt.Sprintf("Threshold check passed (%d rules)", len(rpt.Verdict.Results)))
+		} else {
+			reporter.Broadcast(fmt.Sprintf("Threshold check FAILED: %d of %d rules violated", len(rpt.Verdict.Failed()), len(rpt.Verdict.Results)))
+		}
+	}
+
 	// GENERATE REPORT
 	reporter.Broadcast("Generating Report...")
 	htmlBytes, err := report.GenerateHTML(rpt)
@@ -371,3 +390,36 @@ func Run(ctx context.Context, opts BenchmarkOptions, reporter Reporter) {
 	rpt.Completed = true
 	reporter.SendResult(rpt)
 }
+
+// collectSystemInfo fills the client OS, CPU and RAM of rpt.
+func collectSystemInfo(rpt *report.ReportData, reporter Reporter) {
+	reporter.Broadcast("Collecting System Information...")
+	sys, err := system.GetSystemInfo()
+	if err != nil {
+		reporter.Broadcast(fmt.Sprintf("Warning: Could not get system info: %v", err))
+		rpt.SystemOS = "Unknown"
+		rpt.CPU = report.CPUInfo{Model: "Unknown", Usage: 0}
+		rpt.RAM = report.RAMInfo{Total: "Unknown", Free: "Unknown", Used: "Unknown", Usage: 0}
+	} else {
+		rpt.SystemOS = sys.OS
+		rpt.CPU = report.CPUInfo{Model: sys.CPUModel, Usage: sys.CPUUsage}
+		rpt.PeakCPUUsage = sys.CPUUsage
+		rpt.RAM = report.RAMInfo{
+			Total: formatBytes(sys.RAMTotal),
+			Free:  formatBytes(sys.RAMFree),
+			Used:  formatBytes(sys.RAMUsed),
+			Usage: sys.RAMUsage,
+		}
+	}
+}
+
+// revokeAppPassword deletes the run's app password, also after a cancelled run.
+func revokeAppPassword(client *webdav.Client, reporter Reporter) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := client.RevokeAppPassword(ctx); err != nil {
+		reporter.Broadcast(fmt.Sprintf("Warning: Could not revoke app password: %v", err))
+		return
+	}
+	reporter.Broadcast("App password revoked.")
+}
@@ -0,0 +1,74 @@
+package workflow
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"nextcloud-perf/internal/network"
+	"nextcloud-perf/internal/report"
+)
+
+// runDualStack measures the IPv4 and IPv6 paths to host separately: A and
+// AAAA resolution, TCP connects to tcpTarget (host:port) and traceroute per
+// family, then dual-stack connects if the host has both. The traceroute of
+// the report is the IPv4 one, or the IPv6 one for IPv6-only hosts.
+func runDualStack(host, tcpTarget string, trOpts network.TracerouteOptions, rpt *report.ReportData, reporter Reporter) {
+	reporter.Broadcast("Comparing IPv4 and IPv6 paths...")
+	_, tcpPort, _ := net.SplitHostPort(tcpTarget)
+	trOpts.Port, _ = strconv.Atoi(tcpPort)
+	if trOpts.Cycles > 1 {
+		reporter.Broadcast(fmt.Sprintf("Traceroute probes every hop %d times (MTR mode)", trOpts.Cycles))
+	}
+	rpt.DualStack = &network.DualStackResult{}
+
+	resolved := 0
+	for _, family := range network.Families {
+		name := network.FamilyName(family)
+		fr := network.FamilyResult{Family: family, DNS: network.MeasureDNSFamily(family, host)}
+		records := "A"
+		if family == network.IPv6 {
+			records = "AAAA"
+		}
+		if fr.DNS.Error != "" {
+			reporter.Broadcast(fmt.Sprintf("%s DNS: No %s records (%s)", name, records, fr.DNS.Error))
+			rpt.DualStack.Families = append(rpt.DualStack.Families, fr)
+			continue
+		}
+		resolved++
+		reporter.Broadcast(fmt.Sprintf("%s DNS: %d %s records in %.2fms", name, len(fr.DNS.ResolvedIPs), records, fr.DNS.ResolutionTime))
+
+		if ping, err := network.MeasureFamilyTCPPing(family, tcpTarget, 5, 2*time.Second); err == nil {
+			fr.Ping = &ping
+			reporter.Broadcast(fmt.Sprintf("%s Ping: Avg=%.2fms | Loss=%.1f%%", name, ping.AvgMs, ping.PacketLoss))
+		}
+
+		tr, err := network.RunTraceroute(family, host, trOpts)
+		if err != nil {
+			fr.TracerouteError = err.Error()
+			reporter.Broadcast(fmt.Sprintf("%s Traceroute: Skipped (%v)", name, err))
+		} else {
+			fr.Traceroute = &tr
+			reporter.Broadcast(fmt.Sprintf("%s Traceroute (%s): Found %d hops", name, tr.Method, len(tr.Hops)))
+		}
+		rpt.DualStack.Families = append(rpt.DualStack.Families, fr)
+
+		if rpt.Traceroute == nil && rpt.TracerouteError == "" {
+			rpt.Traceroute = fr.Traceroute
+			rpt.TracerouteError = fr.TracerouteError
+		}
+		reporter.SendResult(*rpt)
+	}
+
+	if resolved == len(network.Families) {
+		he := network.MeasureHappyEyeballs(tcpTarget, 5, 5*time.Second)
+		rpt.DualStack.HappyEyeballs = &he
+		msg := fmt.Sprintf("Dual-Stack Ping: Avg=%.2fms | IPv6 %d, IPv4 %d of %d connects", he.AvgMs, he.IPv6Count, he.IPv4Count, he.Count)
+		if penalty := rpt.DualStack.FallbackPenaltyMs(); penalty != nil {
+			msg += fmt.Sprintf(" | Fallback delay %.0fms", *penalty)
+		}
+		reporter.Broadcast(msg)
+	}
+	reporter.SendResult(*rpt)
+}
@@ -0,0 +1,54 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"nextcloud-perf/internal/benchmark"
+	"nextcloud-perf/internal/report"
+	"nextcloud-perf/internal/webdav"
+)
+
+// runEndpoints breaks requests to the named endpoints (status.php, the OCS
+// API and WebDAV) down into their phases, which shows whether the network or
+// the server (PHP, database) makes requests slow, and adds them to the
+// report. The TLS handshake of status.php is also reported as the SSL
+// handshake time.
+func runEndpoints(ctx context.Context, client *webdav.Client, samples int, rpt *report.ReportData, reporter Reporter, names ...string) {
+	reporter.Broadcast(fmt.Sprintf("Measuring request phases (%d requests per endpoint, new connection each)...", samples))
+	endpoints, err := benchmark.RunEndpoints(ctx, client, samples, names...)
+	if err != nil {
+		reporter.Broadcast(fmt.Sprintf("Request phases Warning: %v", err))
+	}
+	for _, ep := range endpoints {
+		if ep.Samples == 0 {
+			reporter.Broadcast(fmt.Sprintf("%s %s: failed (%s)", ep.Method, ep.Path, ep.Error))
+			continue
+		}
+		phases := make([]string, len(ep.Phases))
+		for i, p := range ep.Phases {
+			phases[i] = fmt.Sprintf("%s %.1f", benchmark.RequestPhaseLabels[p.Name], durationMs(p.Median))
+		}
+		reporter.Broadcast(fmt.Sprintf("%s %s: %.1f ms median (%s ms)", ep.Method, ep.Path, durationMs(ep.Total.Median), strings.Join(phases, " · ")))
+		if ep.Failed > 0 {
+			reporter.Broadcast(fmt.Sprintf("%s %s Warning: %d of %d requests failed: %s", ep.Method, ep.Path, ep.Failed, samples, ep.Error))
+		}
+	}
+	for _, ep := range endpoints {
+		if ep.Name != benchmark.EndpointStatus || ep.Samples == 0 {
+			continue
+		}
+		if tls := ep.Phase(benchmark.RequestTLS).Median; tls > 0 {
+			rpt.AdvancedNet.TLSHandshakeMs = durationMs(tls)
+			reporter.Broadcast(fmt.Sprintf("SSL Handshake: %.1f ms", rpt.AdvancedNet.TLSHandshakeMs))
+		}
+	}
+	rpt.Endpoints = append(rpt.Endpoints, endpoints...)
+	reporter.SendResult(*rpt)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,300 @@
+package workflow
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"nextcloud-perf/internal/benchmark"
+	"nextcloud-perf/internal/config"
+	"nextcloud-perf/internal/report"
+	"nextcloud-perf/internal/system"
+	"nextcloud-perf/internal/webdav"
+)
+
+// provisionParallel limits the concurrent requests to the provisioning API.
+const provisionParallel = 8
+
+// LoadOptions configures RunLoad. URL, User and Pass are used for the
+// pre-flight check and, without Accounts, to create the test accounts via
+// the provisioning API, which requires an administrator.
+type LoadOptions struct {
+	URL  string
+	User string
+	Pass string
+
+	Scenario config.LoadScenario // Must be validated
+
+	// Existing test accounts, shared round-robin if there are fewer than users
+	Accounts []config.Account
+
+	// Thresholds are evaluated against the finished report if set
+	Thresholds *config.Thresholds
+}
+
+// RunLoad runs a multi-user load test: it creates the test accounts unless
+// given, ramps up the virtual sync clients, and deletes the accounts, or
+// only the working folders of given accounts, afterwards.
+func RunLoad(ctx context.Context, opts LoadOptions, reporter Reporter) {
+	sc := opts.Scenario
+	rpt := report.ReportData{
+		GeneratedAt: time.Now(),
+		TargetURL:   opts.URL,
+		Profile:     "load",
+	}
+
+	reporter.Broadcast("Starting Load Test...")
+	defer func() {
+		reporter.SendResult(rpt)
+		reporter.Broadcast("Benchmark Logic Finished.")
+	}()
+
+	reporter.Broadcast("Pre-flight: Checking Nextcloud availability...")
+	admin := webdav.NewClient(opts.URL, opts.User, opts.Pass, func(msg string) {
+		reporter.Broadcast(msg)
+	})
+	status, err := admin.GetStatus(ctx)
+	if err != nil {
+		errMsg := fmt.Sprintf("Pre-flight Error: %v", err)
+		reporter.Broadcast(errMsg)
+		rpt.Error = errMsg
+		return
+	}
+	rpt.CloudCheck = report.CloudStatus{
+		Status:      status.ProductName,
+		Version:     status.VersionString,
+		Maintenance: status.Maintenance,
+		Edition:     status.Edition,
+	}
+	if status.Maintenance {
+		reporter.Broadcast("Warning: Server is in maintenance mode!")
+	}
+	reporter.Broadcast(fmt.Sprintf("Detected: %s %s", status.ProductName, status.VersionString))
+	collectSystemInfo(&rpt, reporter)
+	reporter.SendResult(rpt)
+
+	runID := time.Now().Unix()
+	accounts := opts.Accounts
+	provisioned := len(accounts) == 0
+	if provisioned {
+		reporter.Broadcast(fmt.Sprintf("Creating %d test accounts...", sc.Users))
+		accounts, err = provisionAccounts(ctx, admin, runID, sc.Users)
+		// Also created accounts of a failed provisioning are removed
+		defer deleteAccounts(admin, accounts, reporter)
+		if err != nil {
+			errMsg := fmt.Sprintf("Failed to create test accounts: %v", err)
+			reporter.Broadcast(errMsg)
+			rpt.Error = errMsg
+			return
+		}
+	}
+
+	users := make([]benchmark.VirtualUser, sc.Users)
+	distinct := map[string]bool{}
+	for i := range users {
+		acc := accounts[i%len(accounts)]
+		distinct[acc.User] = true
+		users[i] = benchmark.VirtualUser{
+			Name:   fmt.Sprintf("vu%03d (%s)", i+1, acc.User),
+			Client: webdav.NewClient(opts.URL, acc.User, acc.Pass, nil),
+			Folder: fmt.Sprintf("perf-load-%d-%03d", runID, i+1),
+		}
+	}
+	if !provisioned {
+		// Deleted users take their files along, given accounts keep theirs
+		defer deleteFolders(users, reporter)
+	}
+
+	rpt.Load = &report.LoadResult{
+		Users:       sc.Users,
+		Accounts:    len(distinct),
+		Provisioned: provisioned,
+		RampUp:      time.Duration(sc.RampUp),
+		Duration:    time.Duration(sc.Duration),
+		Interval:    time.Duration(sc.Interval),
+		ThinkTime:   time.Duration(sc.ThinkTime),
+		FileSize:    int64(sc.FileSize),
+	}
+	reporter.Broadcast(fmt.Sprintf("Starting load test: %d virtual users on %d accounts, ramp-up %s, %s in total, sampling every %s...",
+		sc.Users, len(distinct), sc.RampUp, sc.Duration, sc.Interval))
+
+	stopMonitor := monitorCPU()
+	intervals := int((sc.Duration + sc.Interval - 1) / sc.Interval)
+	res, err := benchmark.RunLoad(ctx, users, benchmark.LoadOptions{
+		RampUp:    time.Duration(sc.RampUp),
+		Duration:  time.Duration(sc.Duration),
+		Interval:  time.Duration(sc.Interval),
+		FileSize:  int64(sc.FileSize),
+		Files:     sc.Files,
+		ThinkTime: time.Duration(sc.ThinkTime),
+	}, func(sample benchmark.IntervalSample) {
+		// Replace instead of appending in place: earlier reports may still be read by the reporter
+		load := *rpt.Load
+		load.Samples = append(append([]benchmark.IntervalSample(nil), load.Samples...), sample)
+		rpt.Load = &load
+		reporter.Broadcast(fmt.Sprintf("Load interval %d/%d: %d users, %.2f MB/s, p95 %.1f ms, %d ops, %.1f%% errors",
+			len(load.Samples), intervals, sample.Users, sample.ThroughputMBps(),
+			float64(sample.P95.Microseconds())/1000, sample.Ops, sample.ErrorRate()))
+		reporter.SendResult(rpt)
+	})
+	rpt.PeakCPUUsage = max(rpt.PeakCPUUsage, stopMonitor())
+	if err != nil {
+		errMsg := fmt.Sprintf("Load test failed: %v", err)
+		reporter.Broadcast(errMsg)
+		rpt.Error = errMsg
+		return
+	}
+
+	load := *rpt.Load
+	load.PerUser = res.Users
+	load.Errors = errsToStrings(res.Errors)
+	rpt.Load = &load
+	if n := load.SaturationUsers(); n > 0 {
+		reporter.Broadcast(fmt.Sprintf("Load test finished: peak %.2f MB/s, saturated at %d users, %.2f%% errors", load.PeakThroughputMBps(), n, load.ErrorRate()))
+	} else {
+		reporter.Broadcast(fmt.Sprintf("Load test finished: peak %.2f MB/s, not saturated with %d users, %.2f%% errors", load.PeakThroughputMBps(), sc.Users, load.ErrorRate()))
+	}
+	if rpt.PeakCPUUsage > 90 {
+		reporter.Broadcast(fmt.Sprintf("Warning: Client CPU peaked at %.0f%%, the results may be limited by this machine.", rpt.PeakCPUUsage))
+	}
+
+	if opts.Thresholds != nil {
+		rpt.Verdict = report.Evaluate(opts.Thresholds, rpt)
+		if rpt.Verdict.Passed {
+			reporter.Broadcast(fmt.Sprintf("Threshold check passed (%d rules)", len(rpt.Verdict.Results)))
+		} else {
+			reporter.Broadcast(fmt.Sprintf("Threshold check FAILED: %d of %d rules violated", len(rpt.Verdict.Failed()), len(rpt.Verdict.Results)))
+		}
+	}
+
+	reporter.Broadcast("Generating Report...")
+	htmlBytes, err := report.GenerateHTML(rpt)
+	if err != nil {
+		reporter.Broadcast("Failed to generate report: " + err.Error())
+	} else {
+		reporter.SaveReport(htmlBytes)
+	}
+	reporter.Broadcast("Report Ready!")
+	rpt.Completed = true
+	reporter.SendResult(rpt)
+}
+
+// provisionAccounts creates n accounts with random passwords. On error the
+// accounts created so far are returned along with it.
+func provisionAccounts(ctx context.Context, admin *webdav.Client, runID int64, n int) ([]config.Account, error) {
+	accounts := make([]config.Account, n)
+	created := make([]bool, n)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	sem := make(chan struct{}, provisionParallel)
+	for i := range accounts {
+		pass, err := randomPassword()
+		if err != nil {
+			return nil, err
+		}
+		accounts[i] = config.Account{User: fmt.Sprintf("perf-load-%d-%03d", runID, i+1), Pass: pass}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := admin.CreateUser(ctx, accounts[i].User, accounts[i].Pass); err != nil {
+				once.Do(func() { firstErr = fmt.Errorf("%s: %w", accounts[i].User, err) })
+				return
+			}
+			created[i] = true
+		}(i)
+	}
+	wg.Wait()
+
+	var ok []config.Account
+	for i, c := range created {
+		if c {
+			ok = append(ok, accounts[i])
+		}
+	}
+	return ok, firstErr
+}
+
+// randomPassword returns a password that passes the default password policy.
+func randomPassword() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b) + "-Aa1", nil
+}
+
+// deleteAccounts removes the provisioned accounts, also after a cancelled run.
+func deleteAccounts(admin *webdav.Client, accounts []config.Account, reporter Reporter) {
+	if len(accounts) == 0 {
+		return
+	}
+	reporter.Broadcast(fmt.Sprintf("Deleting %d test accounts...", len(accounts)))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	sem := make(chan struct{}, provisionParallel)
+	for _, acc := range accounts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(user string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := admin.DeleteUser(ctx, user); err != nil {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+				reporter.Broadcast(fmt.Sprintf("Warning: Could not delete test account %s: %v", user, err))
+			}
+		}(acc.User)
+	}
+	wg.Wait()
+	if failed == 0 {
+		reporter.Broadcast("Test accounts deleted.")
+	}
+}
+
+// deleteFolders removes the working folders of the virtual users.
+func deleteFolders(users []benchmark.VirtualUser, reporter Reporter) {
+	reporter.Broadcast("Cleaning up test files...")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	for _, u := range users {
+		if err := u.Client.Delete(ctx, u.Folder); err != nil {
+			reporter.Broadcast(fmt.Sprintf("Warning: Cleanup of %s failed: %v", u.Folder, err))
+		}
+	}
+	reporter.Broadcast("Cleanup complete.")
+}
+
+// monitorCPU samples the local CPU usage until the returned function is
+// called, which returns the peak usage.
+func monitorCPU() func() float64 {
+	done := make(chan struct{})
+	peak := make(chan float64, 1)
+	go func() {
+		ticker := time.NewTicker(config.CPUMonitorInterval)
+		defer ticker.Stop()
+		p := 0.0
+		for {
+			select {
+			case <-done:
+				peak <- p
+				return
+			case <-ticker.C:
+				p = max(p, system.GetCurrentCPUUsage())
+			}
+		}
+	}()
+	return func() float64 {
+		close(done)
+		return <-peak
+	}
+}
@@ -0,0 +1,52 @@
+package workflow
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"nextcloud-perf/internal/network"
+	"nextcloud-perf/internal/report"
+)
+
+// runPathMTU discovers the path MTU to host over the first address family it
+// resolved to in the dual-stack comparison and warns about MTU black holes,
+// which let small requests through but stall uploads.
+func runPathMTU(host, tcpTarget string, rpt *report.ReportData, reporter Reporter) {
+	family := ""
+	if rpt.DualStack != nil {
+		for _, f := range rpt.DualStack.Families {
+			if f.DNS.Error == "" {
+				family = f.Family
+				break
+			}
+		}
+	}
+	if family == "" {
+		return
+	}
+
+	reporter.Broadcast(fmt.Sprintf("Discovering Path MTU (%s)...", network.FamilyName(family)))
+	_, tcpPort, _ := net.SplitHostPort(tcpTarget)
+	port, _ := strconv.Atoi(tcpPort)
+	pm, err := network.DiscoverPathMTU(family, host, port)
+	if err != nil {
+		pm.Error = err.Error()
+		reporter.Broadcast(fmt.Sprintf("Path MTU: Skipped (%v)", err))
+	} else {
+		rpt.AdvancedNet.MTU = pm.PathMTU
+		reporter.Broadcast(fmt.Sprintf("Path MTU (%s): %d bytes (interface %d bytes)", pm.Method, pm.PathMTU, pm.LocalMTU))
+	}
+	if pm.TCPMSS > 0 {
+		reporter.Broadcast(fmt.Sprintf("TCP MSS: %d bytes (%d byte packets)", pm.TCPMSS, pm.TCPMTU))
+	}
+	if pm.BlackHole {
+		msg := fmt.Sprintf("Warning: MTU black hole, packets above %d bytes are dropped without ICMP error", pm.PathMTU)
+		if pm.TCPAffected() {
+			msg += ". Large transfers may stall!"
+		}
+		reporter.Broadcast(msg)
+	}
+	rpt.PathMTU = &pm
+	reporter.SendResult(*rpt)
+}
@@ -0,0 +1,297 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"nextcloud-perf/internal/benchmark"
+	"nextcloud-perf/internal/config"
+	"nextcloud-perf/internal/report"
+	"nextcloud-perf/internal/webdav"
+)
+
+// toSpeedResult converts a benchmark result into its report representation.
+// A run that was cut short keeps what it measured next to the error.
+func toSpeedResult(res *benchmark.Result, err error) *report.SpeedResult {
+	out := &report.SpeedResult{}
+	if res != nil {
+		*out = report.SpeedResult{
+			SpeedMBps: res.SpeedMBps,
+			Duration:  res.Duration,
+			Errors:    errsToStrings(res.Errors),
+			Latency:   res.Latency,
+			Retries:   res.Retries,
+			Resumes:   res.Resumes,
+			Corrupted: res.Corrupted,
+		}
+	}
+	if err != nil && !slices.Contains(out.Errors, err.Error()) {
+		out.Errors = append(out.Errors, err.Error())
+	}
+	return out
+}
+
+// checksumType maps the checksum setting of a profile to the benchmark checksum type.
+func checksumType(p *config.BenchmarkProfile) string {
+	switch p.Checksum {
+	case config.ChecksumMD5:
+		return benchmark.ChecksumMD5
+	case config.ChecksumNone:
+		return ""
+	}
+	return benchmark.ChecksumSHA256
+}
+
+// runScenario uploads and/or downloads the files of one profile scenario.
+// Download-only scenarios still upload their files first, but that upload is not reported.
+// Uploads send the given checksum type, downloads are verified against it.
+func runScenario(ctx context.Context, client *webdav.Client, testFolder string, sc config.Scenario, checksum string, idx, total int, reporter Reporter) report.ScenarioResult {
+	label := sc.DisplayLabel()
+	size := int64(sc.Size)
+	res := report.ScenarioResult{
+		Name:     sc.Name,
+		Label:    label,
+		Count:    sc.Count,
+		Size:     size,
+		Parallel: sc.Parallel,
+		Chunked:  sc.Chunked,
+	}
+	if sc.Chunked {
+		res.ChunkSize = int64(sc.ChunkSize)
+		res.ChunkParallel = sc.ChunkParallel
+	}
+
+	reporter.Broadcast(fmt.Sprintf("Starting scenario %d/%d: %s...", idx, total, label))
+
+	fileName := sc.Name + ".bin"
+	prefix := sc.Name + "_"
+
+	var up *benchmark.Result
+	var err error
+	chunkOpts := webdav.ChunkOptions{ChunkSize: int64(sc.ChunkSize), Parallel: sc.ChunkParallel, ResumeAttempts: config.DefaultResumeAttempts}
+	if sc.Chunked {
+		up, err = benchmark.RunChunkedFile(ctx, client, testFolder, fileName, size, chunkOpts, checksum)
+	} else {
+		up, err = benchmark.RunSmallFiles(ctx, client, testFolder, prefix, sc.Count, size, sc.Parallel, checksum)
+	}
+
+	if sc.Uploads() {
+		res.Upload = toSpeedResult(up, err)
+		if len(res.Upload.Errors) > 0 {
+			reporter.Broadcast(fmt.Sprintf("%s Upload Warning: %v", label, res.Upload.Errors))
+		}
+		reporter.Broadcast(fmt.Sprintf("%s Upload: %.2f MB/s", label, res.Upload.SpeedMBps))
+
+		if sc.CompareSequential {
+			reporter.Broadcast(fmt.Sprintf("Starting %s sequential comparison upload...", label))
+			chunkOpts.Parallel = 1
+			seq, err := benchmark.RunChunkedFile(ctx, client, testFolder, sc.Name+"_sequential.bin", size, chunkOpts, checksum)
+			res.Sequential = toSpeedResult(seq, err)
+			reporter.Broadcast(fmt.Sprintf("%s Sequential Upload: %.2f MB/s (parallel %+.0f%%)", label, res.Sequential.SpeedMBps, res.ParallelGain()))
+		}
+	} else if setup := toSpeedResult(up, err); len(setup.Errors) > 0 {
+		reporter.Broadcast(fmt.Sprintf("%s Setup Warning: %v", label, setup.Errors))
+	}
+
+	if sc.Downloads() {
+		reporter.Broadcast(fmt.Sprintf("Starting %s Download...", label))
+		var down *benchmark.Result
+		if sc.Chunked {
+			down, err = benchmark.RunDownloadLargeFile(ctx, client, testFolder, fileName, size, checksum)
+		} else {
+			down, err = benchmark.RunDownloadSmallFiles(ctx, client, testFolder, prefix, sc.Count, size, sc.Parallel, checksum)
+		}
+		res.Download = toSpeedResult(down, err)
+		res.Download.Checksum = checksum
+		if len(res.Download.Errors) > 0 {
+			reporter.Broadcast(fmt.Sprintf("%s Download Warning: %v", label, res.Download.Errors))
+		}
+		if res.Download.Corrupted > 0 {
+			reporter.Broadcast(fmt.Sprintf("%s Download Warning: %d of %d files truncated or corrupted", label, res.Download.Corrupted, sc.Count))
+		}
+		reporter.Broadcast(fmt.Sprintf("%s Download: %.2f MB/s", label, res.Download.SpeedMBps))
+	}
+
+	return res
+}
+
+// runMetadata builds the folder tree of the metadata scenario and measures PROPFIND listings.
+func runMetadata(ctx context.Context, client *webdav.Client, testFolder string, m config.MetadataScenario, reporter Reporter) *report.MetadataResult {
+	reporter.Broadcast(fmt.Sprintf("Starting metadata test: creating %d directories x %d files...", m.Dirs, m.Files))
+	out := &report.MetadataResult{Dirs: m.Dirs, FilesPerDir: m.Files}
+
+	res, err := benchmark.RunMetadata(ctx, client, testFolder, m.Dirs, m.Files, int64(m.FileSize), m.Iterations, m.Parallel)
+	if err != nil {
+		out.Errors = []string{err.Error()}
+		reporter.Broadcast(fmt.Sprintf("Metadata test failed: %v", err))
+		return out
+	}
+	out.Setup = res.Setup
+	out.Errors = errsToStrings(res.Errors)
+	if len(res.Errors) > 0 {
+		reporter.Broadcast(fmt.Sprintf("Metadata tree Warning: %d errors while creating tree", len(res.Errors)))
+	}
+
+	for _, l := range res.Listings {
+		out.Listings = append(out.Listings, report.ListingResult{
+			Depth:   l.Depth,
+			Entries: l.Entries,
+			Latency: l.Latency,
+			Errors:  errsToStrings(l.Errors),
+		})
+		if len(l.Errors) > 0 {
+			reporter.Broadcast(fmt.Sprintf("PROPFIND depth %s Warning: %v", l.Depth, l.Errors[0]))
+		} else if l.Latency != nil {
+			reporter.Broadcast(fmt.Sprintf("PROPFIND depth %s: %d entries, p50 %.1f ms, p99 %.1f ms",
+				l.Depth, l.Entries, float64(l.Latency.P50.Microseconds())/1000, float64(l.Latency.P99.Microseconds())/1000))
+		}
+	}
+	return out
+}
+
+// runSoak loops the mixed soak workload and sends the report after every
+// sampling interval so that the time series can be followed live.
+func runSoak(ctx context.Context, client *webdav.Client, testFolder string, s config.SoakScenario, rpt *report.ReportData, reporter Reporter) {
+	reporter.Broadcast(fmt.Sprintf("Starting soak test: %s mixed load with %d workers, sampling every %s...", s.Duration, s.Parallel, s.Interval))
+	rpt.Soak = &report.SoakResult{
+		Duration: time.Duration(s.Duration),
+		Interval: time.Duration(s.Interval),
+		FileSize: int64(s.FileSize),
+		Parallel: s.Parallel,
+		Mix:      fmt.Sprintf("%d:%d:%d", s.Mix.Upload, s.Mix.Download, s.Mix.List),
+	}
+
+	opts := benchmark.SoakOptions{
+		Duration: time.Duration(s.Duration),
+		Interval: time.Duration(s.Interval),
+		FileSize: int64(s.FileSize),
+		Files:    s.Files,
+		Parallel: s.Parallel,
+		Upload:   s.Mix.Upload,
+		Download: s.Mix.Download,
+		List:     s.Mix.List,
+	}
+	intervals := int((opts.Duration + opts.Interval - 1) / opts.Interval)
+	res, err := benchmark.RunSoak(ctx, client, testFolder, opts, func(sample benchmark.IntervalSample) {
+		// Replace instead of appending in place: earlier reports may still be read by the reporter
+		soak := *rpt.Soak
+		soak.Samples = append(append([]benchmark.IntervalSample(nil), soak.Samples...), sample)
+		rpt.Soak = &soak
+		reporter.Broadcast(fmt.Sprintf("Soak interval %d/%d: up %.2f MB/s, down %.2f MB/s, p95 %.1f ms, %d ops, %.1f%% errors",
+			len(soak.Samples), intervals, sample.UploadMBps, sample.DownloadMBps,
+			float64(sample.P95.Microseconds())/1000, sample.Ops, sample.ErrorRate()))
+		reporter.SendResult(*rpt)
+	})
+	if err != nil {
+		rpt.Soak.Errors = []string{err.Error()}
+		reporter.Broadcast(fmt.Sprintf("Soak test failed: %v", err))
+		return
+	}
+	rpt.Soak.Errors = errsToStrings(res.Errors)
+	if drift := rpt.Soak.ThroughputDrift(); drift != nil {
+		reporter.Broadcast(fmt.Sprintf("Soak test finished: %.2f MB/s avg, %.2f%% errors, throughput drift %+.1f%%", rpt.Soak.ThroughputMBps(), rpt.Soak.ErrorRate(), *drift))
+	} else {
+		reporter.Broadcast(fmt.Sprintf("Soak test finished: %.2f MB/s avg, %.2f%% errors", rpt.Soak.ThroughputMBps(), rpt.Soak.ErrorRate()))
+	}
+}
+
+// runSync replays desktop client sync cycles on a folder tree of the sync scenario.
+func runSync(ctx context.Context, client *webdav.Client, testFolder string, s config.SyncScenario, reporter Reporter) *report.SyncResult {
+	reporter.Broadcast(fmt.Sprintf("Starting sync cycle test: %d directories x %d files, %d cycles...", s.Dirs, s.Files, s.Cycles))
+	out := &report.SyncResult{Dirs: s.Dirs, FilesPerDir: s.Files, FileSize: int64(s.FileSize), Changes: s.Changes, Renames: s.Renames, Deletes: s.Deletes}
+
+	res, err := benchmark.RunSync(ctx, client, testFolder, benchmark.SyncOptions{
+		Dirs:        s.Dirs,
+		FilesPerDir: s.Files,
+		FileSize:    int64(s.FileSize),
+		Changes:     s.Changes,
+		Renames:     s.Renames,
+		Deletes:     s.Deletes,
+		Cycles:      s.Cycles,
+		Parallel:    s.Parallel,
+	})
+	if res == nil {
+		out.Errors = []string{err.Error()}
+		reporter.Broadcast(fmt.Sprintf("Sync cycle test failed: %v", err))
+		return out
+	}
+	out.Setup, out.Initial, out.Idle, out.Cycles, out.Latency = res.Setup, res.Initial, res.Idle, res.Cycles, res.Latency
+	out.Errors = errsToStrings(res.Errors)
+	if err != nil {
+		out.Errors = append(out.Errors, err.Error())
+		reporter.Broadcast(fmt.Sprintf("Sync cycle test failed: %v", err))
+		return out
+	}
+
+	reporter.Broadcast(fmt.Sprintf("Initial sync: %.0f ms, %d requests; change check without changes: %.1f ms",
+		float64(res.Initial.Duration.Microseconds())/1000, res.Initial.Requests, float64(res.Idle.Microseconds())/1000))
+	for i, c := range res.Cycles {
+		reporter.Broadcast(fmt.Sprintf("Sync cycle %d: %.0f ms, %d requests, %d conflicts", i+1, float64(c.Duration.Microseconds())/1000, c.Requests, c.Conflicts))
+	}
+	if len(res.Errors) > 0 {
+		reporter.Broadcast(fmt.Sprintf("Sync cycle test Warning: %v", res.Errors[0]))
+	}
+	return out
+}
+
+// runProtocols runs the comparison workload over each protocol version and
+// sends the report after each one. The protocol names of config and webdav
+// are the same ALPN identifiers.
+func runProtocols(ctx context.Context, client *webdav.Client, testFolder string, c config.ProtocolComparison, checksum string, rpt *report.ReportData, reporter Reporter) {
+	reporter.Broadcast(fmt.Sprintf("Starting protocol comparison: %s...", strings.Join(c.Versions, ", ")))
+	rpt.Protocols = &report.ProtocolResult{Files: c.Files, FileSize: int64(c.FileSize), Parallel: c.Parallel, LargeSize: int64(c.LargeSize)}
+	opts := benchmark.ProtocolOptions{
+		Files:     c.Files,
+		FileSize:  int64(c.FileSize),
+		Parallel:  c.Parallel,
+		LargeSize: int64(c.LargeSize),
+		Checksum:  checksum,
+	}
+	speed := func(res *benchmark.Result) *report.SpeedResult {
+		if res == nil {
+			return nil
+		}
+		return toSpeedResult(res, nil)
+	}
+
+	for _, version := range c.Versions {
+		if ctx.Err() != nil {
+			break
+		}
+		reporter.Broadcast(fmt.Sprintf("Protocol %s: transferring %d x %s and %s...", version, c.Files, c.FileSize, c.LargeSize))
+		run := benchmark.RunProtocol(ctx, client, testFolder, version, opts)
+		out := report.ProtocolRunResult{
+			Protocol:      version,
+			Negotiated:    run.Negotiated,
+			Upload:        speed(run.Upload),
+			Download:      speed(run.Download),
+			LargeUpload:   speed(run.LargeUpload),
+			LargeDownload: speed(run.LargeDownload),
+		}
+		for _, d := range []*report.SpeedResult{out.Download, out.LargeDownload} {
+			if d != nil {
+				d.Checksum = checksum
+			}
+		}
+		if run.Err != nil {
+			out.Error = run.Err.Error()
+			reporter.Broadcast(fmt.Sprintf("Protocol %s Warning: %v", version, run.Err))
+		} else {
+			reporter.Broadcast(fmt.Sprintf("Protocol %s (%s): small files %.2f MB/s up, %.2f MB/s down; large file %s up, %s down",
+				version, run.Negotiated, out.Upload.SpeedMBps, out.Download.SpeedMBps, formatSpeed(out.LargeUpload), formatSpeed(out.LargeDownload)))
+		}
+		rpt.Protocols.Runs = append(rpt.Protocols.Runs, out)
+		reporter.SendResult(*rpt)
+	}
+}
+
+// formatSpeed formats the speed of an optional result.
+func formatSpeed(s *report.SpeedResult) string {
+	if s == nil {
+		return "--"
+	}
+	return fmt.Sprintf("%.2f MB/s", s.SpeedMBps)
+}
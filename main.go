@@ -3,9 +3,11 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 	"os/exec"
 	"runtime"
 
+	"nextcloud-perf/internal/cli"
 	"nextcloud-perf/internal/ui"
 )
 
@@ -27,10 +29,18 @@ func openBrowser(url string) {
 }
 
 func main() {
+	// Headless mode: any known sub-command bypasses the web UI
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Main(os.Args[1:]))
+	}
+
 	fmt.Println("Starting Nextcloud Performance Tool...")
 
 	// Start UI Server
 	server := ui.NewServer(3000)
+	if host := os.Getenv("NEXTCLOUD_PERF_UI_HOST"); host != "" {
+		server.Host = host // e.g. 0.0.0.0 in a container; anyone who can reach it can start runs
+	}
 
 	// Open Browser in a goroutine (wait for server to be ready)
 	go func() {